@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type BackupSpec struct {
+	// Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	SourceSnapshot string `json:"sourceSnapshot"`
+
+	// Where to store the snapshot's data as a sparse qcow2 image. Should
+	// be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Target BackupTarget `json:"target"`
+}
+
+// +kubebuilder:validation:XValidation:rule="has(self.file) != has(self.s3)",message="exactly one of file and s3 must be set"
+type BackupTarget struct {
+	File *BackupTargetFile `json:"file,omitempty"`
+	S3   *BackupTargetS3   `json:"s3,omitempty"`
+}
+
+type BackupTargetFile struct {
+	// Absolute path of the image on the host of the node performing the
+	// backup. Must be on a file system that is mounted at the same path on
+	// all nodes, like NFS, so that the backup can be restored anywhere.
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+}
+
+type BackupTargetS3 struct {
+	// URL of the S3-compatible service, e.g. "https://s3.us-east-1.amazonaws.com".
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint"`
+
+	// Defaults to "us-east-1".
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	Bucket string `json:"bucket"`
+
+	// Object key of the image.
+	Key string `json:"key"`
+
+	// Name of a Secret in the KubeSAN namespace with "accessKeyId" and
+	// "secretAccessKey" keys.
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+const (
+	// Set while a node is copying the snapshot, with the percentage
+	// completed in the message.
+	BackupConditionProgressing = "Progressing"
+
+	// Set if copying the snapshot failed. The backup is retried.
+	BackupConditionFailed = "Failed"
+)
+
+type BackupStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Available: The backup is complete and can be restored.
+	// Progressing: A node is copying the snapshot to the target.
+	// Failed: The last attempt to copy the snapshot failed.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The node copying the snapshot, or "".
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// How much of the snapshot has been copied.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+
+	// The size of the snapshot, immutable once set.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	SizeBytes *int64 `json:"sizeBytes,omitempty"`
+
+	// The namespace of the PVC that the snapshot was taken of. Only
+	// PVCs in this namespace can be restored from the backup.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	SourceNamespace string `json:"sourceNamespace,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.sourceSnapshot`,description='Snapshot being backed up'
+// +kubebuilder:printcolumn:name="Progress",type=integer,JSONPath=`.status.progressPercent`,description='Percentage of the snapshot copied'
+// +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since backup was complete'
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`,description='Size of snapshot'
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.nodeName`,description='Node copying the snapshot',priority=1
+
+// Backup is a copy of a Snapshot outside of the shared VG.
+type Backup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupSpec   `json:"spec,omitempty"`
+	Status BackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BackupList contains a list of Backup
+type BackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Backup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Backup{}, &BackupList{})
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type GoldenImageSpec struct {
+	// Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	VgName string `json:"vgName"`
+
+	// Size of the read-only LV holding the image. Thin volumes using the
+	// golden image must be at least this large. Must be positive and a
+	// multiple of 512. Should be set from creation and never updated.
+	// +kubebuilder:validation:Minimum=512
+	// +kubebuilder:validation:MultipleOf=512
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	SizeBytes int64 `json:"sizeBytes"`
+
+	// The disk image to convert into the LV. Should be set from creation
+	// and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Source VolumeContentsImport `json:"source"`
+}
+
+const (
+	// Set while the image is being imported, with the percentage completed
+	// in the message.
+	GoldenImageConditionProgressing = "Progressing"
+
+	// Set if importing the image failed. The import is retried.
+	GoldenImageConditionFailed = "Failed"
+
+	GoldenImageConditionDeletionDeferred = "DeletionDeferred"
+)
+
+type GoldenImageStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Available: The image has been imported and its LV made read-only,
+	// so Thin volumes can use it.
+	// Progressing: The image is being imported.
+	// Failed: The last attempt to import the image failed.
+	// DeletionDeferred: The golden image is being deleted but Dependents
+	// still use it.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// How much of the image has been imported.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+
+	// The objects whose thin LVs use the golden image as their external
+	// origin, as "<kind>/<name>": volumes, and thin-pools that still hold
+	// snapshots of them. Deleting the golden image waits until this is
+	// empty.
+	// +optional
+	// +listType=set
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=gi;gis,categories=kubesan;lv
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="VG",type=string,JSONPath=`.spec.vgName`,description='VG owning the golden image'
+// +kubebuilder:printcolumn:name="Progress",type=integer,JSONPath=`.status.progressPercent`,description='Percentage of the image imported'
+// +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since golden image was available'
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.spec.sizeBytes`,description='Size of golden image'
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.source.url`,description='URL of the image',priority=1
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source.sourceVolume`,description='Volume holding the image',priority=1
+
+// GoldenImage is a disk image imported into a read-only LV, which Thin
+// volumes in the same VG use as the external origin of their thin LVs. Each
+// volume's thin pool then only stores what differs from the image.
+type GoldenImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GoldenImageSpec   `json:"spec,omitempty"`
+	Status GoldenImageStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GoldenImageList contains a list of GoldenImage
+type GoldenImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GoldenImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GoldenImage{}, &GoldenImageList{})
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type GroupSnapshotSpec struct {
+	// The Thin volumes to snapshot together. Should be set from creation
+	// and never updated.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +listType=set
+	SourceVolumes []string `json:"sourceVolumes"`
+}
+
+const (
+	GroupSnapshotConditionDeletionDeferred = "DeletionDeferred"
+)
+
+type GroupSnapshotStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Available: All member snapshots are available.
+	// DeletionDeferred: The group snapshot is being deleted but some of
+	// its member snapshots still have dependents.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The member snapshots, one per source volume and in the same order.
+	// +optional
+	Snapshots []string `json:"snapshots,omitempty"`
+
+	// While the member snapshots are being taken, the time by which nodes
+	// must resume I/O on all source volumes. Every member snapshot uses
+	// this deadline.
+	// +optional
+	FreezeDeadline *metav1.Time `json:"freezeDeadline,omitempty"`
+
+	// Set once every member snapshot has quiesced its source volume or
+	// given up on doing so, which lets the members cut their thin
+	// snapshots. The group is only Quiesced if all members are.
+	// +optional
+	Consistency SnapshotConsistency `json:"consistency,omitempty"`
+
+	// The time at which the last member snapshot was cut. Nodes resume I/O
+	// on the source volumes once this is set.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=gsnap;gsnaps,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Volumes",type=string,JSONPath=`.spec.sourceVolumes`,description='Volumes snapshotted together'
+// +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since group snapshot was available'
+// +kubebuilder:printcolumn:name="Consistency",type=string,JSONPath=`.status.consistency`,description='Crash or Quiesced',priority=1
+
+// GroupSnapshot takes a Snapshot of each of its source volumes at the same
+// point in time. I/O on all source volumes is suspended until every member
+// snapshot is cut, so the snapshots are consistent with each other.
+type GroupSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GroupSnapshotSpec   `json:"spec,omitempty"`
+	Status GroupSnapshotStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GroupSnapshotList contains a list of GroupSnapshot
+type GroupSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GroupSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GroupSnapshot{}, &GroupSnapshotList{})
+}
@@ -35,6 +35,11 @@ type NBDExportSpec struct {
 	// +optional
 	// +listType=set
 	Clients []string `json:"clients,omitempty"`
+
+	// I/O limits of the export, kept in sync with the exported Volume.
+	// May be updated at will.
+	// +optional
+	QoS *VolumeQoS `json:"qos,omitempty"`
 }
 
 type NBDExportStatus struct {
@@ -57,6 +62,10 @@ type NBDExportStatus struct {
 	// + TODO Add TLS support, which changes this to a nbds:// URI
 	// +kubebuilder:validation:Pattern="nbd://[0-9a-f:.]+/[-a-z0-9]+"
 	URI string `json:"uri,omitempty"`
+
+	// The I/O limits currently in effect for the export.
+	// +optional
+	QoS *VolumeQoS `json:"qos,omitempty"`
 }
 
 // +kubebuilder:object:root=true
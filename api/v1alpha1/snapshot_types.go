@@ -19,8 +19,62 @@ type SnapshotSpec struct {
 	// Should be set from creation and never updated.
 	// +kubebuilder:validation:XValidation:rule=oldSelf==self
 	SourceVolume string `json:"sourceVolume"`
+
+	// If set, the snapshot is copied in full into its own LV in another
+	// VG once it has been taken, so that it survives the loss of the
+	// source volume's storage. The snapshot only becomes available once
+	// the copy is complete.
+	//
+	// Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Copy *SnapshotCopy `json:"copy,omitempty"`
+
+	// The GroupSnapshot this snapshot is a member of, if any. Members are
+	// created by their group and cut together with the other members.
+	//
+	// Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	GroupSnapshot string `json:"groupSnapshot,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Linear;Thin
+type SnapshotCopyMode string
+
+const (
+	// The copy is a fully provisioned linear LV.
+	SnapshotCopyModeLinear SnapshotCopyMode = "Linear"
+
+	// The copy is a thin LV in a thin-pool of its own, which only takes
+	// up as much space as the data.
+	SnapshotCopyModeThin SnapshotCopyMode = "Thin"
+)
+
+type SnapshotCopy struct {
+	// The VG holding the copy. It should be on different storage than
+	// the source volume's VG.
+	// +kubebuilder:validation:MinLength=1
+	VgName string `json:"vgName"`
+
+	Mode SnapshotCopyMode `json:"mode"`
 }
 
+type SnapshotConsistency string
+
+const (
+	// The snapshot holds the contents of the source volume as if the
+	// nodes using it had crashed, e.g. a file system may need to replay
+	// its journal.
+	SnapshotConsistencyCrash SnapshotConsistency = "Crash"
+
+	// The source volume was not in use or its file system was frozen on
+	// all nodes where it was mounted while the snapshot was taken. For
+	// members of a GroupSnapshot, I/O on block volumes is suspended
+	// instead.
+	SnapshotConsistencyQuiesced SnapshotConsistency = "Quiesced"
+)
+
 type SnapshotStatus struct {
 	// The generation of the spec used to produce this status.  Useful
 	// as a witness when waiting for status to change.
@@ -28,6 +82,10 @@ type SnapshotStatus struct {
 
 	// Conditions
 	// Available: The snapshot can be sourced by volumes.
+	// Copied: The snapshot has been copied into its own LV, only for
+	// snapshots with Spec.Copy.
+	// DeletionDeferred: The snapshot is being deleted but Dependents
+	// still read from it.
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	// +optional
@@ -35,6 +93,12 @@ type SnapshotStatus struct {
 	// +listMapKey=type
 	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 
+	// The time at which the snapshot's point-in-time was cut. Set once its
+	// thin LV exists, possibly before the snapshot is available.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
 	// The size of the snapshot, immutable once set.
 	// +kubebuilder:validation:XValidation:rule=oldSelf==self
 	SizeBytes *int64 `json:"sizeBytes"`
@@ -42,8 +106,67 @@ type SnapshotStatus struct {
 	// The file system type of the snapshot. `nil` if a snapshot of a block volume.
 	// +kubebuilder:validation:XValidation:rule=oldSelf==self
 	FsType *string `json:"fsType,omitempty"`
+
+	// While the snapshot is being taken of a mounted file system, the time
+	// by which nodes must thaw it. Nodes never freeze the file system or
+	// keep it frozen past this time.
+	// Members of a GroupSnapshot use the group's deadline and keep it
+	// until the whole group has been cut.
+	// +optional
+	FreezeDeadline *metav1.Time `json:"freezeDeadline,omitempty"`
+
+	// The nodes that are freezing the source volume's file system, or
+	// suspending its I/O, for this snapshot. Nodes list themselves here
+	// before they start, so that they thaw it after a restart even if they
+	// never got to list themselves in FrozenNodes.
+	// +optional
+	// +listType=set
+	FreezingNodes []string `json:"freezingNodes,omitempty"`
+
+	// The nodes that currently have the source volume's file system frozen,
+	// or its I/O suspended, for this snapshot.
+	// +optional
+	// +listType=set
+	FrozenNodes []string `json:"frozenNodes,omitempty"`
+
+	// Whether writes to the source volume were quiesced when the snapshot
+	// was taken.
+	// +optional
+	Consistency SnapshotConsistency `json:"consistency,omitempty"`
+
+	// The bytes of thin pool data mapped by the snapshot, as last measured
+	// on the node where its thin pool was active.
+	// +optional
+	AllocatedBytes *int64 `json:"allocatedBytes,omitempty"`
+
+	// The bytes of thin pool data mapped only by the snapshot, which
+	// deleting it would free.
+	// +optional
+	ExclusiveBytes *int64 `json:"exclusiveBytes,omitempty"`
+
+	// The node that is copying the snapshot, if any.
+	// +optional
+	CopyNodeName string `json:"copyNodeName,omitempty"`
+
+	// How much of the snapshot has been copied.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	CopyProgressPercent int32 `json:"copyProgressPercent,omitempty"`
+
+	// The objects that still read from the snapshot, as "<kind>/<name>":
+	// volumes being cloned from it, and backups, reverts and replications
+	// using it. Deleting the snapshot waits until this is empty.
+	// +optional
+	// +listType=set
+	Dependents []string `json:"dependents,omitempty"`
 }
 
+const (
+	SnapshotConditionDeletionDeferred = "DeletionDeferred"
+	SnapshotConditionCopied           = "Copied"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Namespaced,shortName=snap;snaps,categories=kubesan;lv
 // +kubebuilder:subresource:status
@@ -51,7 +174,11 @@ type SnapshotStatus struct {
 // +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.sourceVolume`,description='Volume that this snapshot was created on'
 // +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since snapshot was available'
 // +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`,description='Size of snapshot'
+// +kubebuilder:printcolumn:name="Exclusive",type=integer,JSONPath=`.status.exclusiveBytes`,description='Thin pool data freed by deleting the snapshot',priority=1
 // +kubebuilder:printcolumn:name="FSType",type=string,JSONPath=`.status.fsType`,description='filesystem type (blank if block)',priority=1
+// +kubebuilder:printcolumn:name="Copy VG",type=string,JSONPath=`.spec.copy.vgName`,description='VG holding the full copy of the snapshot',priority=1
+// +kubebuilder:printcolumn:name="Consistency",type=string,JSONPath=`.status.consistency`,description='Crash or Quiesced',priority=1
+// +kubebuilder:printcolumn:name="Group",type=string,JSONPath=`.spec.groupSnapshot`,description='GroupSnapshot this snapshot is a member of',priority=1
 
 type Snapshot struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type SnapshotScheduleSpec struct {
+	// Selects the Thin Volumes to snapshot by their labels. An empty
+	// selector selects all Thin Volumes.
+	VolumeSelector metav1.LabelSelector `json:"volumeSelector"`
+
+	// When to snapshot the selected volumes, in cron(8) format
+	// ("minute hour day-of-month month day-of-week", e.g. "0 */6 * * *")
+	// or as one of @hourly, @daily, @weekly, @monthly and @yearly.
+	// Evaluated in UTC.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// +optional
+	Retention SnapshotScheduleRetention `json:"retention,omitempty"`
+
+	// Stop taking new snapshots. Retention still applies.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// Snapshots that exceed any of the limits are deleted. Without limits, all
+// snapshots are kept.
+type SnapshotScheduleRetention struct {
+	// How many of the most recent snapshots to keep per volume.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxCount *int32 `json:"maxCount,omitempty"`
+
+	// How long to keep snapshots, e.g. "168h".
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+const (
+	// Set if the schedule is invalid. No snapshots are taken until the
+	// spec is fixed.
+	SnapshotScheduleConditionFailed = "Failed"
+)
+
+type SnapshotScheduleStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Failed: The schedule is invalid.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The scheduled time of the last snapshots taken.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// The scheduled time of the next snapshots.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// The Snapshots taken by this schedule that have not been deleted,
+	// sorted by name.
+	// +optional
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`,description='When snapshots are taken'
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`,description='Whether new snapshots are taken'
+// +kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`,description='Time of the last snapshots'
+// +kubebuilder:printcolumn:name="Next Schedule",type=string,JSONPath=`.status.nextScheduleTime`,description='Time of the next snapshots',priority=1
+
+// SnapshotSchedule periodically snapshots the Thin Volumes matching a label
+// selector and deletes old snapshots.
+type SnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SnapshotScheduleSpec   `json:"spec,omitempty"`
+	Status SnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SnapshotScheduleList contains a list of SnapshotSchedule
+type SnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SnapshotSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SnapshotSchedule{}, &SnapshotScheduleList{})
+}
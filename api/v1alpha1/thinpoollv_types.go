@@ -14,8 +14,7 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 type ThinPoolLvSpec struct {
-	// Should be set from creation and never updated.
-	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// Should be set from creation and only updated by a VolumeMigration.
 	VgName string `json:"vgName"`
 
 	// Initial size of the thin pool.  Must be a multiple of 512.
@@ -36,8 +35,48 @@ type ThinPoolLvSpec struct {
 	// When changing, may only toggle between "" and non-empty.
 	// +kubebuilder:validation:XValidation:rule=(oldSelf==self)||((oldSelf=="")!=(self==""))
 	ActiveOnNode string `json:"activeOnNode,omitempty"`
+
+	// When to grow the LVM thin pool LV, or nil for the defaults of the
+	// KubeSAN LVM profile. May be updated at will.
+	// +optional
+	Autoextend *ThinPoolAutoextend `json:"autoextend,omitempty"`
+
+	// How discards are handled, or "" for Passdown. May be updated at
+	// will, but changes to or from Ignore only take effect the next time
+	// the LVM thin pool LV is activated.
+	// +kubebuilder:validation:Enum=Passdown;NoPassdown;Ignore
+	// +optional
+	Discards ThinPoolDiscards `json:"discards,omitempty"`
 }
 
+type ThinPoolAutoextend struct {
+	// How full the LVM thin pool LV must be before it is extended, in
+	// percent. 100 disables autoextension.
+	// +kubebuilder:validation:Minimum=50
+	// +kubebuilder:validation:Maximum=100
+	ThresholdPercent int32 `json:"thresholdPercent"`
+
+	// How much to extend the LVM thin pool LV by, in percent of its
+	// current size.
+	// +kubebuilder:validation:Minimum=0
+	Percent int32 `json:"percent"`
+}
+
+// See lvmthin(7) for details.
+type ThinPoolDiscards string
+
+const (
+	// Discards free blocks in the thin pool and are passed down to the
+	// underlying storage.
+	ThinPoolDiscardsPassdown ThinPoolDiscards = "Passdown"
+
+	// Discards free blocks in the thin pool but are not passed down.
+	ThinPoolDiscardsNoPassdown ThinPoolDiscards = "NoPassdown"
+
+	// Discards are ignored.
+	ThinPoolDiscardsIgnore ThinPoolDiscards = "Ignore"
+)
+
 func (s *ThinPoolLvSpec) FindThinLv(name string) *ThinLvSpec {
 	for i := range s.ThinLvs {
 		if s.ThinLvs[i].Name == name {
@@ -68,6 +107,22 @@ type ThinLvSpec struct {
 
 	// May be updated at will.
 	State ThinLvSpecState `json:"state"`
+
+	// Replaces the LVM thin LV's contents with those of another LVM thin
+	// LV in the same thin pool while it is inactive. May be updated at
+	// will.
+	// +optional
+	Revert *ThinLvRevert `json:"revert,omitempty"`
+}
+
+type ThinLvRevert struct {
+	// The LVM thin LV whose contents to revert to, usually a snapshot of
+	// this one.
+	SourceThinLvName string `json:"sourceThinLvName"`
+
+	// Identifies the request, so that reverting to the same source again
+	// can be requested by changing it.
+	Id string `json:"id"`
 }
 
 const (
@@ -95,22 +150,35 @@ const (
 
 	// The LVM thin LV should initially be a copy of another LVM thin LV.
 	ThinLvContentsTypeSnapshot = "Snapshot"
+
+	// The LVM thin LV should initially be a copy of a read-only LV outside
+	// the thin pool, which it uses as its external origin.
+	ThinLvContentsTypeExternalOrigin = "ExternalOrigin"
 )
 
 type ThinLvContents struct {
 	// +unionDiscriminator
-	// +kubebuilder:validation:Enum:="Empty";"Snapshot"
+	// +kubebuilder:validation:Enum:="Empty";"Snapshot";"ExternalOrigin"
 	// +kubebuilder:validation:Required
 	ContentsType string `json:"contentsType"`
 
 	// +optional
 	Snapshot *ThinLvContentsSnapshot `json:"snapshot,omitempty"`
+
+	// +optional
+	ExternalOrigin *ThinLvContentsExternalOrigin `json:"externalOrigin,omitempty"`
 }
 
 type ThinLvContentsSnapshot struct {
 	SourceThinLvName string `json:"sourceThinLvName"`
 }
 
+type ThinLvContentsExternalOrigin struct {
+	// The read-only LV in the same VG that unprovisioned blocks of the
+	// LVM thin LV are read from.
+	OriginLvName string `json:"originLvName"`
+}
+
 const (
 	ThinPoolLvConditionActive = "Active"
 )
@@ -123,6 +191,8 @@ type ThinPoolLvStatus struct {
 	// Conditions
 	// Available: The LVM volume has been created
 	// Active: The last time Status.ActiveOnNode changed
+	// Degraded: The LVM thin pool LV is unhealthy, e.g. out of data space
+	// or missing PVs
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	// +optional
@@ -141,6 +211,36 @@ type ThinPoolLvStatus struct {
 	// +listType=map
 	// +listMapKey=name
 	ThinLvs []ThinLvStatus `json:"thinLvs,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The autoextend policy last applied to the LVM thin pool LV.
+	// +optional
+	Autoextend *ThinPoolAutoextend `json:"autoextend,omitempty"`
+
+	// The discard behavior last applied to the LVM thin pool LV.
+	// +optional
+	Discards ThinPoolDiscards `json:"discards,omitempty"`
+
+	// Space usage of the LVM thin pool LV, as of the last time it was
+	// active.
+	// +optional
+	Usage *ThinPoolUsage `json:"usage,omitempty"`
+}
+
+type ThinPoolUsage struct {
+	// The size of the data LV of the LVM thin pool LV.
+	DataBytes int64 `json:"dataBytes"`
+
+	// The bytes of the data LV mapped by any thin LV.
+	DataUsedBytes int64 `json:"dataUsedBytes"`
+
+	// The size of the metadata LV of the LVM thin pool LV.
+	MetadataBytes int64 `json:"metadataBytes"`
+
+	// The bytes of the metadata LV in use.
+	MetadataUsedBytes int64 `json:"metadataUsedBytes"`
+
+	// When the usage of the thin pool and its thin LVs was last measured.
+	UpdateTime metav1.Time `json:"updateTime"`
 }
 
 func (s *ThinPoolLvStatus) FindThinLv(name string) *ThinLvStatus {
@@ -161,6 +261,21 @@ type ThinLvStatus struct {
 
 	// The current size of the LVM thin LV.
 	SizeBytes int64 `json:"sizeBytes"`
+
+	// The Id of the last completed revert, if any.
+	// +optional
+	RevertId string `json:"revertId,omitempty"`
+
+	// The bytes of thin pool data mapped by the LVM thin LV, as of
+	// Status.Usage.UpdateTime. Unset until first measured.
+	// +optional
+	AllocatedBytes *int64 `json:"allocatedBytes,omitempty"`
+
+	// The bytes of thin pool data mapped only by the LVM thin LV and not
+	// shared with snapshots or other thin LVs, which removing it would
+	// free. Unset until first measured.
+	// +optional
+	ExclusiveBytes *int64 `json:"exclusiveBytes,omitempty"`
 }
 
 const (
@@ -196,8 +311,10 @@ type ThinLvStatusStateActive struct {
 // +kubebuilder:printcolumn:name="VG",type=string,JSONPath=`.spec.vgName`,description=`VG owning the thin pool`
 // +kubebuilder:printcolumn:name="Activity",type=date,JSONPath=`.status.conditions[?(@.type=="Active")].lastTransitionTime`,description='Time since pool last changed activation status'
 // +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.activeOnNode`,description='Node where thin pool is currently active'
+// +kubebuilder:printcolumn:name="Data Size",type=integer,JSONPath=`.status.usage.dataBytes`,description='Size of thin pool data',priority=1
+// +kubebuilder:printcolumn:name="Data Used",type=integer,JSONPath=`.status.usage.dataUsedBytes`,description='Thin pool data in use',priority=1
+// +kubebuilder:printcolumn:name="Metadata Used",type=integer,JSONPath=`.status.usage.metadataUsedBytes`,description='Thin pool metadata in use',priority=1
 // + TODO determine if there is a way to print a column "LVs" that displays the number of items in the .status.thinLvs array
-// + TODO should we expose the thin pool size via Status?
 
 type ThinPoolLv struct {
 	metav1.TypeMeta   `json:",inline"`
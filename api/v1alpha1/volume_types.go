@@ -13,16 +13,30 @@ import (
 // Important: Run "make generate" to regenerate code after modifying this file
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// The rules on optional fields do not apply when the field is added or
+// removed, so that is rejected here.
+// +kubebuilder:validation:XValidation:rule="has(oldSelf.encryption) == has(self.encryption)",message="encryption is immutable"
 type VolumeSpec struct {
-	// Should be set from creation and never updated.
-	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// Should be set from creation and only updated by a VolumeMigration.
 	VgName string `json:"vgName"`
 
 	// Should be set from creation and never updated.
 	// +kubebuilder:validation:XValidation:rule=oldSelf==self
-	// +kubebuilder:validation:Enum=Thin;Linear
+	// +kubebuilder:validation:Enum=Thin;Linear;Raid1;Vdo
 	Mode VolumeMode `json:"mode"`
 
+	// Placement of the mirror legs of Raid1 volumes. Should be set from
+	// creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Raid *VolumeRaid `json:"raid,omitempty"`
+
+	// The VDO pool backing Vdo volumes. Should be set from creation and
+	// never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Vdo *VolumeVdo `json:"vdo,omitempty"`
+
 	// Should be set from creation and never updated.
 	// +kubebuilder:validation:XValidation:rule=oldSelf==self
 	Type VolumeType `json:"type"`
@@ -36,6 +50,26 @@ type VolumeSpec struct {
 	// +listType=set
 	AccessModes []VolumeAccessMode `json:"accessModes"`
 
+	// Encrypt the volume at rest with dm-crypt/LUKS. Should be set from
+	// creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Encryption *VolumeEncryption `json:"encryption,omitempty"`
+
+	// Checksum the volume's data so that corruption is detected on read.
+	// Only supported for Thin and Raid1 volumes. Should be set from
+	// creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Integrity *VolumeIntegrity `json:"integrity,omitempty"`
+
+	// Cache the volume on node-local storage while it is attached to a
+	// node. Only supported for Thin volumes. Should be set from creation
+	// and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	Cache *VolumeCache `json:"cache,omitempty"`
+
 	// Must be positive and a multiple of 512. May be updated at will, but the actual size will only ever increase.
 	// +kubebuilder:validation:Minimum=512
 	// +kubebuilder:validation:MultipleOf=512
@@ -44,6 +78,25 @@ type VolumeSpec struct {
 	// May be updated at will.
 	// +listType=set
 	AttachToNodes []string `json:"attachToNodes,omitempty"`
+
+	// Nodes that must stop writing to the volume. I/O to the volume fails
+	// on these nodes and they cannot attach it until they are removed
+	// from the list. Only for Thin volumes. May be updated at will.
+	// +listType=set
+	// +optional
+	FenceNodes []string `json:"fenceNodes,omitempty"`
+
+	// Tunables that can be changed after creation, e.g. through a
+	// VolumeAttributesClass. May be updated at will.
+	// +optional
+	MutableParameters VolumeMutableParameters `json:"mutableParameters,omitempty"`
+
+	// The StorageClass parameters that MutableParameters were derived
+	// from, so they can be derived again when the VolumeAttributesClass
+	// changes. Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	// +optional
+	StorageClassParameters map[string]string `json:"storageClassParameters,omitempty"`
 }
 
 func (v *VolumeSpec) ReadOnly() bool {
@@ -66,8 +119,31 @@ type VolumeMode string
 const (
 	VolumeModeThin   VolumeMode = "Thin"
 	VolumeModeLinear VolumeMode = "Linear"
+	VolumeModeRaid1  VolumeMode = "Raid1"
+	VolumeModeVdo    VolumeMode = "Vdo"
 )
 
+type VolumeRaid struct {
+	// LVM PV tags, one per mirror leg. Each leg is allocated from PVs with
+	// its tag, so the tags should group PVs by independent storage array.
+	// +kubebuilder:validation:MinItems=2
+	// +listType=atomic
+	PvTags []string `json:"pvTags"`
+}
+
+type VolumeVdo struct {
+	// Physical size of the VDO pool. Can be smaller than the size of the
+	// volume if its data is expected to compress or deduplicate well.
+	// Must be positive and a multiple of 512.
+	// +kubebuilder:validation:Minimum=512
+	// +kubebuilder:validation:MultipleOf=512
+	PoolSizeBytes int64 `json:"poolSizeBytes"`
+
+	Compression bool `json:"compression"`
+
+	Deduplication bool `json:"deduplication"`
+}
+
 type VolumeType struct {
 	Block      *VolumeTypeBlock      `json:"block,omitempty"`
 	Filesystem *VolumeTypeFilesystem `json:"filesystem,omitempty"`
@@ -87,6 +163,16 @@ type VolumeContents struct {
 	Empty         *VolumeContentsEmpty         `json:"empty,omitempty"`
 	CloneVolume   *VolumeContentsCloneVolume   `json:"cloneVolume,omitempty"`
 	CloneSnapshot *VolumeContentsCloneSnapshot `json:"cloneSnapshot,omitempty"`
+	Import        *VolumeContentsImport        `json:"import,omitempty"`
+	Restore       *VolumeContentsRestore       `json:"restore,omitempty"`
+	GoldenImage   *VolumeContentsGoldenImage   `json:"goldenImage,omitempty"`
+}
+
+// Returns true if the cluster controller copies data into the volume, which
+// must not be attached to nodes until the DataSourceCompleted condition is
+// set.
+func (c *VolumeContents) NeedsPopulating() bool {
+	return c.Import != nil || c.Restore != nil
 }
 
 type VolumeContentsEmpty struct {
@@ -100,6 +186,181 @@ type VolumeContentsCloneSnapshot struct {
 	SourceSnapshot string `json:"sourceSnapshot"`
 }
 
+// A disk image to convert into the volume, either downloaded from a URL or
+// read from a block Volume whose contents are the image.
+// +kubebuilder:validation:XValidation:rule="has(self.url) != has(self.sourceVolume)",message="exactly one of url and sourceVolume must be set"
+type VolumeContentsImport struct {
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// +optional
+	SourceVolume string `json:"sourceVolume,omitempty"`
+
+	// +kubebuilder:validation:Enum=Raw;Qcow2
+	Format ImageFormat `json:"format"`
+}
+
+type VolumeContentsRestore struct {
+	// Name of an available Backup.
+	Backup string `json:"backup"`
+}
+
+// A Thin volume whose thin LV uses the GoldenImage's LV as its external
+// origin, so that its thin pool only stores what is written to the volume.
+type VolumeContentsGoldenImage struct {
+	// Name of an available GoldenImage in the volume's VG.
+	GoldenImage string `json:"goldenImage"`
+}
+
+type VolumeEncryption struct {
+	// Where the LUKS passphrase comes from.
+	// +kubebuilder:validation:Enum=NodeStageSecret;KMS
+	KeySource VolumeEncryptionKeySource `json:"keySource"`
+}
+
+type VolumeEncryptionKeySource string
+
+const (
+	// The passphrase is taken from the CSI node-stage secret configured in
+	// the StorageClass.
+	VolumeEncryptionKeySourceNodeStageSecret VolumeEncryptionKeySource = "NodeStageSecret"
+
+	// The passphrase is generated when the volume is created and kept by
+	// KubeSAN. Until there is support for external key management
+	// services, it is stored in a Secret in KubeSAN's namespace.
+	VolumeEncryptionKeySourceKMS VolumeEncryptionKeySource = "KMS"
+)
+
+// Thin volumes use a standalone dm-integrity device on top of the LV. Raid1
+// volumes use LVM's RAID integrity, which also repairs a corrupted block from
+// the other leg.
+type VolumeIntegrity struct {
+}
+
+type VolumeCache struct {
+	// +kubebuilder:validation:Enum=Writethrough;Writeback
+	Mode VolumeCacheMode `json:"mode"`
+
+	// Name of a node-local (non-shared) LVM Volume Group from which the
+	// cache is allocated on each node the volume is attached to. Nodes
+	// without this VG access the volume uncached.
+	VgName string `json:"vgName"`
+
+	// Must be positive and a multiple of 512.
+	// +kubebuilder:validation:Minimum=512
+	// +kubebuilder:validation:MultipleOf=512
+	SizeBytes int64 `json:"sizeBytes"`
+}
+
+type VolumeCacheMode string
+
+const (
+	// Writes complete once they reach the volume, reads are served from
+	// the cache when possible. Implemented with dm-cache.
+	VolumeCacheModeWritethrough VolumeCacheMode = "Writethrough"
+
+	// Writes complete once they reach the cache and are written back to
+	// the volume later. Implemented with dm-writecache. Data not yet
+	// written back is lost if the node's cache device fails.
+	VolumeCacheModeWriteback VolumeCacheMode = "Writeback"
+)
+
+type VolumeMutableParameters struct {
+	// I/O limits.
+	// +optional
+	QoS *VolumeQoS `json:"qos,omitempty"`
+
+	// When to grow the thin-pool. Only for Thin volumes. Defaults to
+	// growing by 20% when 95% full.
+	// +optional
+	ThinPoolAutoextend *ThinPoolAutoextend `json:"thinPoolAutoextend,omitempty"`
+
+	// How discards are handled by the thin-pool. Only for Thin volumes.
+	// Defaults to Passdown.
+	// +kubebuilder:validation:Enum=Passdown;NoPassdown;Ignore
+	// +optional
+	Discards ThinPoolDiscards `json:"discards,omitempty"`
+
+	// How space freed by deleting files is given back to the thin pool or
+	// storage array. Only for Filesystem volumes. Defaults to never.
+	// +optional
+	Reclaim *VolumeReclaim `json:"reclaim,omitempty"`
+
+	// How long I/O is queued while a node has no path to the volume,
+	// e.g. because it was never resumed after a handoff, before it fails.
+	// Only for Thin volumes. Zero, the default, queues I/O forever.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NoPathTimeoutSeconds int64 `json:"noPathTimeoutSeconds,omitempty"`
+}
+
+type VolumeReclaim struct {
+	// +kubebuilder:validation:Enum=Discard;Fstrim
+	Policy VolumeReclaimPolicy `json:"policy"`
+
+	// When to run fstrim, in cron(8) format or as one of @hourly,
+	// @daily, @weekly, @monthly and @yearly, evaluated in UTC. Only for
+	// the Fstrim policy. Defaults to @weekly.
+	// +optional
+	FstrimSchedule string `json:"fstrimSchedule,omitempty"`
+}
+
+type VolumeReclaimPolicy string
+
+const (
+	// The file system is mounted with the "discard" option and discards
+	// blocks as soon as files are deleted.
+	VolumeReclaimPolicyDiscard VolumeReclaimPolicy = "Discard"
+
+	// fstrim runs on the schedule on every node where the file system is
+	// mounted, discarding all blocks that are free at the time.
+	VolumeReclaimPolicyFstrim VolumeReclaimPolicy = "Fstrim"
+)
+
+// The default VolumeReclaim.FstrimSchedule
+const DefaultFstrimSchedule = "@weekly"
+
+// Zero means unlimited. Burst limits allow short bursts above the base limit
+// and are only enforced for volumes accessed over NBD; each one requires the
+// corresponding base limit and must not be lower than it.
+// +kubebuilder:validation:XValidation:rule=`!has(self.readIopsBurst) || (has(self.readIops) && self.readIopsBurst >= self.readIops)`,message="readIopsBurst requires readIops and must not be lower"
+// +kubebuilder:validation:XValidation:rule=`!has(self.writeIopsBurst) || (has(self.writeIops) && self.writeIopsBurst >= self.writeIops)`,message="writeIopsBurst requires writeIops and must not be lower"
+// +kubebuilder:validation:XValidation:rule=`!has(self.readBytesPerSecondBurst) || (has(self.readBytesPerSecond) && self.readBytesPerSecondBurst >= self.readBytesPerSecond)`,message="readBytesPerSecondBurst requires readBytesPerSecond and must not be lower"
+// +kubebuilder:validation:XValidation:rule=`!has(self.writeBytesPerSecondBurst) || (has(self.writeBytesPerSecond) && self.writeBytesPerSecondBurst >= self.writeBytesPerSecond)`,message="writeBytesPerSecondBurst requires writeBytesPerSecond and must not be lower"
+type VolumeQoS struct {
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReadIops int64 `json:"readIops,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WriteIops int64 `json:"writeIops,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReadBytesPerSecond int64 `json:"readBytesPerSecond,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WriteBytesPerSecond int64 `json:"writeBytesPerSecond,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReadIopsBurst int64 `json:"readIopsBurst,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WriteIopsBurst int64 `json:"writeIopsBurst,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ReadBytesPerSecondBurst int64 `json:"readBytesPerSecondBurst,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	WriteBytesPerSecondBurst int64 `json:"writeBytesPerSecondBurst,omitempty"`
+}
+
 type VolumeAccessMode string
 
 const (
@@ -121,6 +382,15 @@ type VolumeStatus struct {
 	// Available: The LVM volume has been created
 	// DataSourceCompleted: Any data source has been copied into the LVM,
 	// so that it is now ready to be attached to nodes
+	// Degraded: The volume is in an abnormal state on a node where it is
+	// attached, e.g. missing PVs or a failed path
+	// Synced: All mirror legs of a Raid1 volume hold the same data
+	// IntegrityVerified: No checksum mismatches have been detected on the
+	// node where a volume with integrity checking is attached
+	// QoSEnforced: The I/O limits of a volume that has them are in
+	// effect on every node to which it is attached
+	// DeletionDeferred: The volume is being deleted but Dependents still
+	// read from it
 	// +patchMergeKey=type
 	// +patchStrategy=merge
 	// +optional
@@ -132,6 +402,12 @@ type VolumeStatus struct {
 	// +kubebuilder:validation:XValidation:rule=oldSelf<=self
 	SizeBytes int64 `json:"sizeBytes"`
 
+	// The bytes of thin pool data allocated to a Thin volume, including
+	// blocks shared with its snapshots, as last measured on the node where
+	// its thin pool was active.
+	// +optional
+	AllocatedBytes *int64 `json:"allocatedBytes,omitempty"`
+
 	// Reflects the nodes to which the volume is attached.
 	// +listType=set
 	AttachedToNodes []string `json:"attachedToNodes,omitempty"`
@@ -139,8 +415,170 @@ type VolumeStatus struct {
 	// The path at which the volume is available on nodes to which it is attached.
 	// + TODO does this have to be in Status, or can it be reliably generated/probed where needed?
 	Path string `json:"path,omitempty"`
+
+	// The I/O limits in effect on every node to which the volume is
+	// attached. Unset if there are none or if they differ between nodes,
+	// e.g. while new limits are being applied.
+	// +optional
+	QoS *VolumeQoS `json:"qos,omitempty"`
+
+	// The I/O limits in effect on each node to which the volume is
+	// attached, as reported by that node. The QoS field and the
+	// QoSEnforced condition summarize it.
+	// +listType=map
+	// +listMapKey=nodeName
+	// +optional
+	NodeQoS []VolumeNodeQoS `json:"nodeQoS,omitempty"`
+
+	// Space usage of the VDO pool of a Vdo volume, as last seen on the
+	// node where the volume was attached.
+	// +optional
+	Vdo *VolumeVdoStatus `json:"vdo,omitempty"`
+
+	// Space given back by discards from the file system of a volume with
+	// a reclaim policy, as last seen on the node where it is mounted.
+	// +optional
+	Reclaim *VolumeReclaimStatus `json:"reclaim,omitempty"`
+
+	// The state of the dm-multipath path to a Thin volume on each node
+	// to which it is attached.
+	// +listType=map
+	// +listMapKey=nodeName
+	// +optional
+	Paths []VolumePathStatus `json:"paths,omitempty"`
+
+	// The health of the volume on each node to which it is attached, as
+	// reported by that node. The Degraded condition summarizes it.
+	// +listType=map
+	// +listMapKey=nodeName
+	// +optional
+	NodeHealth []VolumeNodeHealth `json:"nodeHealth,omitempty"`
+
+	// The objects that still read from the volume, as "<kind>/<name>":
+	// volumes being cloned or imported from it and snapshots of it being
+	// taken. Deleting the volume waits until this is empty.
+	// +optional
+	// +listType=set
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+type VolumePathStatus struct {
+	NodeName string `json:"nodeName"`
+
+	// +kubebuilder:validation:Enum=Active;Queueing;Failing;Fenced
+	State VolumePathState `json:"state"`
+
+	// When the path entered its current state.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+}
+
+type VolumePathState string
+
+const (
+	// I/O reaches the volume.
+	VolumePathStateActive VolumePathState = "Active"
+
+	// The path has failed and I/O is queued until it is reinstated.
+	VolumePathStateQueueing VolumePathState = "Queueing"
+
+	// The path has failed for longer than the no-path timeout and I/O
+	// fails until it is reinstated.
+	VolumePathStateFailing VolumePathState = "Failing"
+
+	// The node has been fenced and I/O fails.
+	VolumePathStateFenced VolumePathState = "Fenced"
+)
+
+// Returns the path status of the given node, or nil if there is none
+func (v *VolumeStatus) FindPath(nodeName string) *VolumePathStatus {
+	for i := range v.Paths {
+		if v.Paths[i].NodeName == nodeName {
+			return &v.Paths[i]
+		}
+	}
+	return nil
+}
+
+type VolumeNodeHealth struct {
+	NodeName string `json:"nodeName"`
+
+	Degraded bool `json:"degraded"`
+
+	// A CamelCase reason like those of the Degraded condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// When Degraded last changed.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
 }
 
+func (v *VolumeStatus) FindNodeHealth(nodeName string) *VolumeNodeHealth {
+	for i := range v.NodeHealth {
+		if v.NodeHealth[i].NodeName == nodeName {
+			return &v.NodeHealth[i]
+		}
+	}
+	return nil
+}
+
+type VolumeNodeQoS struct {
+	NodeName string `json:"nodeName"`
+
+	// The limits in effect on the node. Unset if there are none.
+	// +optional
+	QoS *VolumeQoS `json:"qos,omitempty"`
+
+	// Why the volume's limits cannot be enforced on the node. Empty if
+	// they are.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+func (v *VolumeStatus) FindNodeQoS(nodeName string) *VolumeNodeQoS {
+	for i := range v.NodeQoS {
+		if v.NodeQoS[i].NodeName == nodeName {
+			return &v.NodeQoS[i]
+		}
+	}
+	return nil
+}
+
+type VolumeReclaimStatus struct {
+	// Bytes discarded on the node since the volume was attached there,
+	// whether by the "discard" mount option or by fstrim.
+	DiscardedBytes int64 `json:"discardedBytes"`
+
+	// When fstrim last ran. Only for the Fstrim policy.
+	// +optional
+	LastFstrimTime *metav1.Time `json:"lastFstrimTime,omitempty"`
+
+	// How many bytes fstrim discarded when it last ran.
+	// +optional
+	LastFstrimBytes int64 `json:"lastFstrimBytes,omitempty"`
+}
+
+type VolumeVdoStatus struct {
+	// Physical space used in the VDO pool.
+	UsedBytes int64 `json:"usedBytes"`
+
+	// Percentage of the data written to the volume that did not need
+	// physical space thanks to compression and deduplication.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SavingPercent int32 `json:"savingPercent"`
+}
+
+const (
+	VolumeConditionDataSourceCompleted = "DataSourceCompleted"
+	VolumeConditionSynced              = "Synced"
+	VolumeConditionIntegrityVerified   = "IntegrityVerified"
+	VolumeConditionQoSEnforced         = "QoSEnforced"
+	VolumeConditionDeletionDeferred    = "DeletionDeferred"
+)
+
 func (v *VolumeStatus) IsAttachedToNode(node string) bool {
 	return slices.Contains(v.AttachedToNodes, node)
 }
@@ -154,7 +592,8 @@ func (v *VolumeStatus) IsAttachedToNode(node string) bool {
 // +kubebuilder:printcolumn:name="Primary Node",type=string,JSONPath=`.status.attachedToNodes[0]`,description='Primary node where volume is currently active'
 // + TODO determine if there is a way to print a column "Active Nodes" that displays the number of items in the .status.attachedToNodes array
 // +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`,description='Size of volume'
-// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`,description='Mode of volume (thin or linear)',priority=2
+// +kubebuilder:printcolumn:name="Allocated",type=integer,JSONPath=`.status.allocatedBytes`,description='Thin pool data allocated to volume',priority=1
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`,description='Mode of volume (thin or linear or raid1 or vdo)',priority=2
 // +kubebuilder:printcolumn:name="FSType",type=string,JSONPath=`.spec.type.filesystem.fsType`,description='Filesystem type (blank if block)',priority=2
 
 // Volume is the Schema for the volumes API
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// +kubebuilder:validation:XValidation:rule="[has(self.url), has(self.persistentVolumeClaim), has(self.backup)].filter(x, x).size() == 1",message="exactly one of url, persistentVolumeClaim, and backup must be set"
+// +kubebuilder:validation:XValidation:rule="has(self.backup) != has(self.format)",message="format must be set unless restoring a backup"
+type VolumeImportSpec struct {
+	// HTTP or HTTPS URL of the image.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Name of a block PVC in the same namespace whose contents are the
+	// image. Must be backed by an unencrypted KubeSAN Linear volume.
+	// Image files in a PVC with a file system are not supported.
+	// +optional
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+
+	// Name of a Backup in the KubeSAN namespace to restore. Only backups
+	// of snapshots of PVCs in the same namespace can be restored.
+	// +optional
+	Backup string `json:"backup,omitempty"`
+
+	// The format of the image. It is never probed since an untrusted raw
+	// image could pass itself off as another format.
+	// +kubebuilder:validation:Enum=Raw;Qcow2
+	// +optional
+	Format ImageFormat `json:"format,omitempty"`
+}
+
+type ImageFormat string
+
+const (
+	ImageFormatRaw   ImageFormat = "Raw"
+	ImageFormatQcow2 ImageFormat = "Qcow2"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories=kubesan
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`,description='URL of the image'
+// +kubebuilder:printcolumn:name="PVC",type=string,JSONPath=`.spec.persistentVolumeClaim`,description='PVC holding the image'
+// +kubebuilder:printcolumn:name="Format",type=string,JSONPath=`.spec.format`,description='Format of the image'
+// +kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backup`,description='Backup to restore'
+
+// VolumeImport is a volume populator that fills new PVCs with a disk image or
+// a backup.
+// Reference it from a PVC's dataSourceRef.
+type VolumeImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VolumeImportSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeImportList contains a list of VolumeImport
+type VolumeImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeImport{}, &VolumeImportList{})
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type VolumeMigrationSpec struct {
+	// The Thin volume to migrate. Should be set from creation and never
+	// updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Volume string `json:"volume"`
+
+	// The shared LVM Volume Group to move the volume to. Should be set
+	// from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	VgName string `json:"vgName"`
+}
+
+const (
+	// Set once the volume's I/O goes to the copy in the target VG.
+	VolumeMigrationConditionSwitched = "Switched"
+
+	// Set once the volume's LVs in the source VG are gone.
+	VolumeMigrationConditionSourceRemoved = "SourceRemoved"
+
+	// Set if the migration failed before switching, in which case the
+	// volume stays in the source VG. Not retried.
+	VolumeMigrationConditionFailed = "Failed"
+)
+
+type VolumeMigrationStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Available: The volume has been moved to the target VG.
+	// Progressing: The volume is being mirrored to the target VG, with
+	// the percentage completed in the message.
+	// Switched: The volume's I/O goes to the target VG.
+	// SourceRemoved: The volume's LVs in the source VG are gone.
+	// Failed: The migration failed and the volume stays in the source VG.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The VG the volume was in when the migration started.
+	// +optional
+	SourceVgName string `json:"sourceVgName,omitempty"`
+
+	// The node the volume is attached to, which mirrors it.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// How much of the volume has been mirrored.
+	// +optional
+	ProgressPercent int32 `json:"progressPercent,omitempty"`
+}
+
+// Returns true if a node has taken on the migration and it has neither
+// completed nor failed
+func (s *VolumeMigrationStatus) IsInProgress() bool {
+	return s.NodeName != "" && !s.IsFinished()
+}
+
+// Returns true if the migration has either completed or failed
+func (s *VolumeMigrationStatus) IsFinished() bool {
+	return conditionsv1.IsStatusConditionTrue(s.Conditions, conditionsv1.ConditionAvailable) ||
+		conditionsv1.IsStatusConditionTrue(s.Conditions, VolumeMigrationConditionFailed)
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vmig;vmigs,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Volume",type=string,JSONPath=`.spec.volume`,description='Volume being migrated'
+// +kubebuilder:printcolumn:name="From",type=string,JSONPath=`.status.sourceVgName`,description='VG the volume is moved from'
+// +kubebuilder:printcolumn:name="To",type=string,JSONPath=`.spec.vgName`,description='VG the volume is moved to'
+// +kubebuilder:printcolumn:name="Progress",type=integer,JSONPath=`.status.progressPercent`,description='Percentage mirrored'
+// +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since the volume was moved'
+
+// VolumeMigration moves an attached Thin volume to another shared VG without
+// interrupting I/O. The node the volume is attached to mirrors it through
+// qemu-storage-daemon and then switches the volume over to the copy.
+type VolumeMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolumeMigrationSpec   `json:"spec,omitempty"`
+	Status VolumeMigrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeMigrationList contains a list of VolumeMigration
+type VolumeMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeMigration{}, &VolumeMigrationList{})
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type VolumeReplicationSpec struct {
+	// The Thin volume to replicate. Should be set from creation and never
+	// updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	SourceVolume string `json:"sourceVolume"`
+
+	// Should be set from creation and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Target VolumeReplicationTarget `json:"target"`
+
+	// How often to replicate the volume, e.g. "15m".
+	// +kubebuilder:default="5m"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+type VolumeReplicationTarget struct {
+	// URI of a writable NBD export of the target volume, e.g.
+	// "nbd://dr.example.com:10809/my-volume". The target must be at least
+	// as large as the source volume and must not be modified by anything
+	// else, since only changed blocks are sent after the first sync.
+	// +kubebuilder:validation:Pattern=`^nbd://`
+	URI string `json:"uri"`
+}
+
+const (
+	// Set while a node is sending changed blocks to the target, with
+	// the percentage completed in the message.
+	VolumeReplicationConditionProgressing = "Progressing"
+
+	// Set if the last sync failed. The sync is retried.
+	VolumeReplicationConditionFailed = "Failed"
+)
+
+type VolumeReplicationStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Progressing: A node is sending changed blocks to the target.
+	// Failed: The last sync failed.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+
+	// The Snapshot whose contents the target holds, which the next sync
+	// is computed against.
+	// +optional
+	LastSyncSnapshot string `json:"lastSyncSnapshot,omitempty"`
+
+	// When LastSyncSnapshot was taken. The target holds the source
+	// volume's contents as of this time.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// How many bytes the last sync sent to the target.
+	// +optional
+	LastSyncBytes int64 `json:"lastSyncBytes,omitempty"`
+
+	// How far the target is behind the source volume, as of the last
+	// status update.
+	// +optional
+	LagSeconds int64 `json:"lagSeconds,omitempty"`
+
+	// The Snapshot being sent to the target, or "".
+	// +optional
+	PendingSnapshot string `json:"pendingSnapshot,omitempty"`
+
+	// The node sending the pending snapshot, or "".
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.sourceVolume`,description='Volume being replicated'
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target.uri`,description='NBD export receiving the volume'
+// +kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`,description='Time of the data on the target'
+// +kubebuilder:printcolumn:name="Lag",type=integer,JSONPath=`.status.lagSeconds`,description='Seconds the target is behind the source'
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.status.nodeName`,description='Node sending changed blocks',priority=1
+
+// VolumeReplication periodically copies a Thin volume to an NBD export on
+// another cluster, sending only the blocks changed since the previous sync.
+type VolumeReplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolumeReplicationSpec   `json:"spec,omitempty"`
+	Status VolumeReplicationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeReplicationList contains a list of VolumeReplication
+type VolumeReplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeReplication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeReplication{}, &VolumeReplicationList{})
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+)
+
+// Important: Run "make generate" to regenerate code after modifying this file
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+type VolumeRevertSpec struct {
+	// The Thin volume to revert. Should be set from creation and never
+	// updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Volume string `json:"volume"`
+
+	// A snapshot of the volume to revert to. Should be set from creation
+	// and never updated.
+	// +kubebuilder:validation:XValidation:rule=oldSelf==self
+	Snapshot string `json:"snapshot"`
+}
+
+type VolumeRevertStatus struct {
+	// The generation of the spec used to produce this status.  Useful
+	// as a witness when waiting for status to change.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Conditions
+	// Available: The volume has been reverted to the snapshot.
+	// Progressing: The revert is waiting for the volume to be detached
+	// or is in progress.
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []conditionsv1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories=kubesan
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Volume",type=string,JSONPath=`.spec.volume`,description='Volume being reverted'
+// +kubebuilder:printcolumn:name="Snapshot",type=string,JSONPath=`.spec.snapshot`,description='Snapshot to revert to'
+// +kubebuilder:printcolumn:name="Available",type=date,JSONPath=`.status.conditions[?(@.type=="Available")].lastTransitionTime`,description='Time since the volume was reverted'
+
+// VolumeRevert rolls a detached Thin volume back to one of its snapshots in
+// place. Other snapshots of the volume are unaffected.
+type VolumeRevert struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VolumeRevertSpec   `json:"spec,omitempty"`
+	Status VolumeRevertStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VolumeRevertList contains a list of VolumeRevert
+type VolumeRevertList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VolumeRevert `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VolumeRevert{}, &VolumeRevertList{})
+}
@@ -8,11 +8,12 @@ package v1alpha1
 
 import (
 	"github.com/openshift/custom-resource-status/conditions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NBDExport) DeepCopyInto(out *NBDExport) {
+func (in *Backup) DeepCopyInto(out *Backup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -20,18 +21,18 @@ func (in *NBDExport) DeepCopyInto(out *NBDExport) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExport.
-func (in *NBDExport) DeepCopy() *NBDExport {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backup.
+func (in *Backup) DeepCopy() *Backup {
 	if in == nil {
 		return nil
 	}
-	out := new(NBDExport)
+	out := new(Backup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NBDExport) DeepCopyObject() runtime.Object {
+func (in *Backup) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -39,31 +40,31 @@ func (in *NBDExport) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NBDExportList) DeepCopyInto(out *NBDExportList) {
+func (in *BackupList) DeepCopyInto(out *BackupList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]NBDExport, len(*in))
+		*out = make([]Backup, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportList.
-func (in *NBDExportList) DeepCopy() *NBDExportList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupList.
+func (in *BackupList) DeepCopy() *BackupList {
 	if in == nil {
 		return nil
 	}
-	out := new(NBDExportList)
+	out := new(BackupList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NBDExportList) DeepCopyObject() runtime.Object {
+func (in *BackupList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -71,27 +72,23 @@ func (in *NBDExportList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NBDExportSpec) DeepCopyInto(out *NBDExportSpec) {
+func (in *BackupSpec) DeepCopyInto(out *BackupSpec) {
 	*out = *in
-	if in.Clients != nil {
-		in, out := &in.Clients, &out.Clients
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	in.Target.DeepCopyInto(&out.Target)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportSpec.
-func (in *NBDExportSpec) DeepCopy() *NBDExportSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSpec.
+func (in *BackupSpec) DeepCopy() *BackupSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NBDExportSpec)
+	out := new(BackupSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NBDExportStatus) DeepCopyInto(out *NBDExportStatus) {
+func (in *BackupStatus) DeepCopyInto(out *BackupStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -100,20 +97,80 @@ func (in *NBDExportStatus) DeepCopyInto(out *NBDExportStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SizeBytes != nil {
+		in, out := &in.SizeBytes, &out.SizeBytes
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportStatus.
-func (in *NBDExportStatus) DeepCopy() *NBDExportStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStatus.
+func (in *BackupStatus) DeepCopy() *BackupStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(NBDExportStatus)
+	out := new(BackupStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Snapshot) DeepCopyInto(out *Snapshot) {
+func (in *BackupTarget) DeepCopyInto(out *BackupTarget) {
+	*out = *in
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(BackupTargetFile)
+		**out = **in
+	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(BackupTargetS3)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTarget.
+func (in *BackupTarget) DeepCopy() *BackupTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTargetFile) DeepCopyInto(out *BackupTargetFile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTargetFile.
+func (in *BackupTargetFile) DeepCopy() *BackupTargetFile {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTargetFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupTargetS3) DeepCopyInto(out *BackupTargetS3) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupTargetS3.
+func (in *BackupTargetS3) DeepCopy() *BackupTargetS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupTargetS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GoldenImage) DeepCopyInto(out *GoldenImage) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -121,18 +178,18 @@ func (in *Snapshot) DeepCopyInto(out *Snapshot) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Snapshot.
-func (in *Snapshot) DeepCopy() *Snapshot {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoldenImage.
+func (in *GoldenImage) DeepCopy() *GoldenImage {
 	if in == nil {
 		return nil
 	}
-	out := new(Snapshot)
+	out := new(GoldenImage)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Snapshot) DeepCopyObject() runtime.Object {
+func (in *GoldenImage) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -140,31 +197,31 @@ func (in *Snapshot) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SnapshotList) DeepCopyInto(out *SnapshotList) {
+func (in *GoldenImageList) DeepCopyInto(out *GoldenImageList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Snapshot, len(*in))
+		*out = make([]GoldenImage, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotList.
-func (in *SnapshotList) DeepCopy() *SnapshotList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoldenImageList.
+func (in *GoldenImageList) DeepCopy() *GoldenImageList {
 	if in == nil {
 		return nil
 	}
-	out := new(SnapshotList)
+	out := new(GoldenImageList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *SnapshotList) DeepCopyObject() runtime.Object {
+func (in *GoldenImageList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -172,22 +229,23 @@ func (in *SnapshotList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SnapshotSpec) DeepCopyInto(out *SnapshotSpec) {
+func (in *GoldenImageSpec) DeepCopyInto(out *GoldenImageSpec) {
 	*out = *in
+	out.Source = in.Source
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotSpec.
-func (in *SnapshotSpec) DeepCopy() *SnapshotSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoldenImageSpec.
+func (in *GoldenImageSpec) DeepCopy() *GoldenImageSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(SnapshotSpec)
+	out := new(GoldenImageSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SnapshotStatus) DeepCopyInto(out *SnapshotStatus) {
+func (in *GoldenImageStatus) DeepCopyInto(out *GoldenImageStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -196,30 +254,25 @@ func (in *SnapshotStatus) DeepCopyInto(out *SnapshotStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.SizeBytes != nil {
-		in, out := &in.SizeBytes, &out.SizeBytes
-		*out = new(int64)
-		**out = **in
-	}
-	if in.FsType != nil {
-		in, out := &in.FsType, &out.FsType
-		*out = new(string)
-		**out = **in
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotStatus.
-func (in *SnapshotStatus) DeepCopy() *SnapshotStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoldenImageStatus.
+func (in *GoldenImageStatus) DeepCopy() *GoldenImageStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(SnapshotStatus)
+	out := new(GoldenImageStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlob) DeepCopyInto(out *ThinBlob) {
+func (in *GroupSnapshot) DeepCopyInto(out *GroupSnapshot) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -227,18 +280,18 @@ func (in *ThinBlob) DeepCopyInto(out *ThinBlob) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlob.
-func (in *ThinBlob) DeepCopy() *ThinBlob {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSnapshot.
+func (in *GroupSnapshot) DeepCopy() *GroupSnapshot {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlob)
+	out := new(GroupSnapshot)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ThinBlob) DeepCopyObject() runtime.Object {
+func (in *GroupSnapshot) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -246,277 +299,1545 @@ func (in *ThinBlob) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachment) DeepCopyInto(out *ThinBlobAttachment) {
+func (in *GroupSnapshotList) DeepCopyInto(out *GroupSnapshotList) {
 	*out = *in
-	in.State.DeepCopyInto(&out.State)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GroupSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachment.
-func (in *ThinBlobAttachment) DeepCopy() *ThinBlobAttachment {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSnapshotList.
+func (in *GroupSnapshotList) DeepCopy() *GroupSnapshotList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachment)
+	out := new(GroupSnapshotList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GroupSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentState) DeepCopyInto(out *ThinBlobAttachmentState) {
+func (in *GroupSnapshotSpec) DeepCopyInto(out *GroupSnapshotSpec) {
 	*out = *in
-	if in.Attaching != nil {
-		in, out := &in.Attaching, &out.Attaching
-		*out = new(ThinBlobAttachmentStateAttaching)
-		**out = **in
-	}
-	if in.AttachedLocal != nil {
-		in, out := &in.AttachedLocal, &out.AttachedLocal
-		*out = new(ThinBlobAttachmentStateAttachedLocal)
-		**out = **in
-	}
-	if in.AttachedRemote != nil {
-		in, out := &in.AttachedRemote, &out.AttachedRemote
-		*out = new(ThinBlobAttachmentStateAttachedRemote)
-		**out = **in
-	}
-	if in.Detaching != nil {
-		in, out := &in.Detaching, &out.Detaching
-		*out = new(ThinBlobAttachmentStateDetaching)
-		**out = **in
-	}
-	if in.Suspending != nil {
-		in, out := &in.Suspending, &out.Suspending
-		*out = new(ThinBlobAttachmentStateSuspending)
-		**out = **in
-	}
-	if in.Suspended != nil {
-		in, out := &in.Suspended, &out.Suspended
-		*out = new(ThinBlobAttachmentStateSuspended)
-		**out = **in
-	}
-	if in.Resuming != nil {
-		in, out := &in.Resuming, &out.Resuming
-		*out = new(ThinBlobAttachmentStateResuming)
-		**out = **in
+	if in.SourceVolumes != nil {
+		in, out := &in.SourceVolumes, &out.SourceVolumes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentState.
-func (in *ThinBlobAttachmentState) DeepCopy() *ThinBlobAttachmentState {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSnapshotSpec.
+func (in *GroupSnapshotSpec) DeepCopy() *GroupSnapshotSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentState)
+	out := new(GroupSnapshotSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateAttachedLocal) DeepCopyInto(out *ThinBlobAttachmentStateAttachedLocal) {
+func (in *GroupSnapshotStatus) DeepCopyInto(out *GroupSnapshotStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FreezeDeadline != nil {
+		in, out := &in.FreezeDeadline, &out.FreezeDeadline
+		*out = (*in).DeepCopy()
+	}
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttachedLocal.
-func (in *ThinBlobAttachmentStateAttachedLocal) DeepCopy() *ThinBlobAttachmentStateAttachedLocal {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GroupSnapshotStatus.
+func (in *GroupSnapshotStatus) DeepCopy() *GroupSnapshotStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateAttachedLocal)
+	out := new(GroupSnapshotStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateAttachedRemote) DeepCopyInto(out *ThinBlobAttachmentStateAttachedRemote) {
+func (in *NBDExport) DeepCopyInto(out *NBDExport) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttachedRemote.
-func (in *ThinBlobAttachmentStateAttachedRemote) DeepCopy() *ThinBlobAttachmentStateAttachedRemote {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExport.
+func (in *NBDExport) DeepCopy() *NBDExport {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateAttachedRemote)
+	out := new(NBDExport)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NBDExport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateAttaching) DeepCopyInto(out *ThinBlobAttachmentStateAttaching) {
+func (in *NBDExportList) DeepCopyInto(out *NBDExportList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NBDExport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttaching.
-func (in *ThinBlobAttachmentStateAttaching) DeepCopy() *ThinBlobAttachmentStateAttaching {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportList.
+func (in *NBDExportList) DeepCopy() *NBDExportList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateAttaching)
+	out := new(NBDExportList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NBDExportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateDetaching) DeepCopyInto(out *ThinBlobAttachmentStateDetaching) {
+func (in *NBDExportSpec) DeepCopyInto(out *NBDExportSpec) {
 	*out = *in
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(VolumeQoS)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateDetaching.
-func (in *ThinBlobAttachmentStateDetaching) DeepCopy() *ThinBlobAttachmentStateDetaching {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportSpec.
+func (in *NBDExportSpec) DeepCopy() *NBDExportSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateDetaching)
+	out := new(NBDExportSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateResuming) DeepCopyInto(out *ThinBlobAttachmentStateResuming) {
+func (in *NBDExportStatus) DeepCopyInto(out *NBDExportStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(VolumeQoS)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateResuming.
-func (in *ThinBlobAttachmentStateResuming) DeepCopy() *ThinBlobAttachmentStateResuming {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NBDExportStatus.
+func (in *NBDExportStatus) DeepCopy() *NBDExportStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateResuming)
+	out := new(NBDExportStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateSuspended) DeepCopyInto(out *ThinBlobAttachmentStateSuspended) {
+func (in *Snapshot) DeepCopyInto(out *Snapshot) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Snapshot.
+func (in *Snapshot) DeepCopy() *Snapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(Snapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Snapshot) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotCopy) DeepCopyInto(out *SnapshotCopy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotCopy.
+func (in *SnapshotCopy) DeepCopy() *SnapshotCopy {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotCopy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotList) DeepCopyInto(out *SnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Snapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotList.
+func (in *SnapshotList) DeepCopy() *SnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotSchedule) DeepCopyInto(out *SnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotSchedule.
+func (in *SnapshotSchedule) DeepCopy() *SnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotScheduleList) DeepCopyInto(out *SnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleList.
+func (in *SnapshotScheduleList) DeepCopy() *SnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotScheduleRetention) DeepCopyInto(out *SnapshotScheduleRetention) {
+	*out = *in
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleRetention.
+func (in *SnapshotScheduleRetention) DeepCopy() *SnapshotScheduleRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotScheduleRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotScheduleSpec) DeepCopyInto(out *SnapshotScheduleSpec) {
+	*out = *in
+	in.VolumeSelector.DeepCopyInto(&out.VolumeSelector)
+	in.Retention.DeepCopyInto(&out.Retention)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleSpec.
+func (in *SnapshotScheduleSpec) DeepCopy() *SnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotScheduleStatus) DeepCopyInto(out *SnapshotScheduleStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotScheduleStatus.
+func (in *SnapshotScheduleStatus) DeepCopy() *SnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotSpec) DeepCopyInto(out *SnapshotSpec) {
+	*out = *in
+	if in.Copy != nil {
+		in, out := &in.Copy, &out.Copy
+		*out = new(SnapshotCopy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotSpec.
+func (in *SnapshotSpec) DeepCopy() *SnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotStatus) DeepCopyInto(out *SnapshotStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CreationTime != nil {
+		in, out := &in.CreationTime, &out.CreationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SizeBytes != nil {
+		in, out := &in.SizeBytes, &out.SizeBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.FsType != nil {
+		in, out := &in.FsType, &out.FsType
+		*out = new(string)
+		**out = **in
+	}
+	if in.FreezeDeadline != nil {
+		in, out := &in.FreezeDeadline, &out.FreezeDeadline
+		*out = (*in).DeepCopy()
+	}
+	if in.FreezingNodes != nil {
+		in, out := &in.FreezingNodes, &out.FreezingNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FrozenNodes != nil {
+		in, out := &in.FrozenNodes, &out.FrozenNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllocatedBytes != nil {
+		in, out := &in.AllocatedBytes, &out.AllocatedBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ExclusiveBytes != nil {
+		in, out := &in.ExclusiveBytes, &out.ExclusiveBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotStatus.
+func (in *SnapshotStatus) DeepCopy() *SnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlob) DeepCopyInto(out *ThinBlob) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlob.
+func (in *ThinBlob) DeepCopy() *ThinBlob {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThinBlob) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachment) DeepCopyInto(out *ThinBlobAttachment) {
+	*out = *in
+	in.State.DeepCopyInto(&out.State)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachment.
+func (in *ThinBlobAttachment) DeepCopy() *ThinBlobAttachment {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentState) DeepCopyInto(out *ThinBlobAttachmentState) {
+	*out = *in
+	if in.Attaching != nil {
+		in, out := &in.Attaching, &out.Attaching
+		*out = new(ThinBlobAttachmentStateAttaching)
+		**out = **in
+	}
+	if in.AttachedLocal != nil {
+		in, out := &in.AttachedLocal, &out.AttachedLocal
+		*out = new(ThinBlobAttachmentStateAttachedLocal)
+		**out = **in
+	}
+	if in.AttachedRemote != nil {
+		in, out := &in.AttachedRemote, &out.AttachedRemote
+		*out = new(ThinBlobAttachmentStateAttachedRemote)
+		**out = **in
+	}
+	if in.Detaching != nil {
+		in, out := &in.Detaching, &out.Detaching
+		*out = new(ThinBlobAttachmentStateDetaching)
+		**out = **in
+	}
+	if in.Suspending != nil {
+		in, out := &in.Suspending, &out.Suspending
+		*out = new(ThinBlobAttachmentStateSuspending)
+		**out = **in
+	}
+	if in.Suspended != nil {
+		in, out := &in.Suspended, &out.Suspended
+		*out = new(ThinBlobAttachmentStateSuspended)
+		**out = **in
+	}
+	if in.Resuming != nil {
+		in, out := &in.Resuming, &out.Resuming
+		*out = new(ThinBlobAttachmentStateResuming)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentState.
+func (in *ThinBlobAttachmentState) DeepCopy() *ThinBlobAttachmentState {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateAttachedLocal) DeepCopyInto(out *ThinBlobAttachmentStateAttachedLocal) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttachedLocal.
+func (in *ThinBlobAttachmentStateAttachedLocal) DeepCopy() *ThinBlobAttachmentStateAttachedLocal {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateAttachedLocal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateAttachedRemote) DeepCopyInto(out *ThinBlobAttachmentStateAttachedRemote) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttachedRemote.
+func (in *ThinBlobAttachmentStateAttachedRemote) DeepCopy() *ThinBlobAttachmentStateAttachedRemote {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateAttachedRemote)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateAttaching) DeepCopyInto(out *ThinBlobAttachmentStateAttaching) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateAttaching.
+func (in *ThinBlobAttachmentStateAttaching) DeepCopy() *ThinBlobAttachmentStateAttaching {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateAttaching)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateDetaching) DeepCopyInto(out *ThinBlobAttachmentStateDetaching) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateDetaching.
+func (in *ThinBlobAttachmentStateDetaching) DeepCopy() *ThinBlobAttachmentStateDetaching {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateDetaching)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateResuming) DeepCopyInto(out *ThinBlobAttachmentStateResuming) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateResuming.
+func (in *ThinBlobAttachmentStateResuming) DeepCopy() *ThinBlobAttachmentStateResuming {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateResuming)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateSuspended) DeepCopyInto(out *ThinBlobAttachmentStateSuspended) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateSuspended.
+func (in *ThinBlobAttachmentStateSuspended) DeepCopy() *ThinBlobAttachmentStateSuspended {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateSuspended)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobAttachmentStateSuspending) DeepCopyInto(out *ThinBlobAttachmentStateSuspending) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateSuspending.
+func (in *ThinBlobAttachmentStateSuspending) DeepCopy() *ThinBlobAttachmentStateSuspending {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobAttachmentStateSuspending)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobContents) DeepCopyInto(out *ThinBlobContents) {
+	*out = *in
+	if in.Empty != nil {
+		in, out := &in.Empty, &out.Empty
+		*out = new(ThinBlobContentsEmpty)
+		**out = **in
+	}
+	if in.Filesystem != nil {
+		in, out := &in.Filesystem, &out.Filesystem
+		*out = new(ThinBlobContentsFilesystem)
+		**out = **in
+	}
+	if in.Copy != nil {
+		in, out := &in.Copy, &out.Copy
+		*out = new(ThinBlobContentsCopy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContents.
+func (in *ThinBlobContents) DeepCopy() *ThinBlobContents {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobContents)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobContentsCopy) DeepCopyInto(out *ThinBlobContentsCopy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsCopy.
+func (in *ThinBlobContentsCopy) DeepCopy() *ThinBlobContentsCopy {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobContentsCopy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobContentsEmpty) DeepCopyInto(out *ThinBlobContentsEmpty) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsEmpty.
+func (in *ThinBlobContentsEmpty) DeepCopy() *ThinBlobContentsEmpty {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobContentsEmpty)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobContentsFilesystem) DeepCopyInto(out *ThinBlobContentsFilesystem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsFilesystem.
+func (in *ThinBlobContentsFilesystem) DeepCopy() *ThinBlobContentsFilesystem {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobContentsFilesystem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobList) DeepCopyInto(out *ThinBlobList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ThinBlob, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobList.
+func (in *ThinBlobList) DeepCopy() *ThinBlobList {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThinBlobList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobSpec) DeepCopyInto(out *ThinBlobSpec) {
+	*out = *in
+	in.Contents.DeepCopyInto(&out.Contents)
+	if in.AttachToNodes != nil {
+		in, out := &in.AttachToNodes, &out.AttachToNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobSpec.
+func (in *ThinBlobSpec) DeepCopy() *ThinBlobSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobState) DeepCopyInto(out *ThinBlobState) {
+	*out = *in
+	if in.Creating != nil {
+		in, out := &in.Creating, &out.Creating
+		*out = new(ThinBlobStateCreating)
+		**out = **in
+	}
+	if in.Ready != nil {
+		in, out := &in.Ready, &out.Ready
+		*out = new(ThinBlobStateReady)
+		**out = **in
+	}
+	if in.Expanding != nil {
+		in, out := &in.Expanding, &out.Expanding
+		*out = new(ThinBlobStateExpanding)
+		**out = **in
+	}
+	if in.Migrating != nil {
+		in, out := &in.Migrating, &out.Migrating
+		*out = new(ThinBlobStateMigrating)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobState.
+func (in *ThinBlobState) DeepCopy() *ThinBlobState {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobStateCreating) DeepCopyInto(out *ThinBlobStateCreating) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateCreating.
+func (in *ThinBlobStateCreating) DeepCopy() *ThinBlobStateCreating {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobStateCreating)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobStateExpanding) DeepCopyInto(out *ThinBlobStateExpanding) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateExpanding.
+func (in *ThinBlobStateExpanding) DeepCopy() *ThinBlobStateExpanding {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobStateExpanding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobStateMigrating) DeepCopyInto(out *ThinBlobStateMigrating) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateMigrating.
+func (in *ThinBlobStateMigrating) DeepCopy() *ThinBlobStateMigrating {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobStateMigrating)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobStateReady) DeepCopyInto(out *ThinBlobStateReady) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateReady.
+func (in *ThinBlobStateReady) DeepCopy() *ThinBlobStateReady {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobStateReady)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinBlobStatus) DeepCopyInto(out *ThinBlobStatus) {
+	*out = *in
+	in.State.DeepCopyInto(&out.State)
+	if in.ThinPoolLvName != nil {
+		in, out := &in.ThinPoolLvName, &out.ThinPoolLvName
+		*out = new(string)
+		**out = **in
+	}
+	if in.FsType != nil {
+		in, out := &in.FsType, &out.FsType
+		*out = new(string)
+		**out = **in
+	}
+	if in.Attachments != nil {
+		in, out := &in.Attachments, &out.Attachments
+		*out = make([]ThinBlobAttachment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStatus.
+func (in *ThinBlobStatus) DeepCopy() *ThinBlobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinBlobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLv) DeepCopyInto(out *ThinLv) {
+	*out = *in
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(ThinLvSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(ThinLvStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLv.
+func (in *ThinLv) DeepCopy() *ThinLv {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLv)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvContents) DeepCopyInto(out *ThinLvContents) {
+	*out = *in
+	if in.Snapshot != nil {
+		in, out := &in.Snapshot, &out.Snapshot
+		*out = new(ThinLvContentsSnapshot)
+		**out = **in
+	}
+	if in.ExternalOrigin != nil {
+		in, out := &in.ExternalOrigin, &out.ExternalOrigin
+		*out = new(ThinLvContentsExternalOrigin)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvContents.
+func (in *ThinLvContents) DeepCopy() *ThinLvContents {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvContents)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvContentsExternalOrigin) DeepCopyInto(out *ThinLvContentsExternalOrigin) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvContentsExternalOrigin.
+func (in *ThinLvContentsExternalOrigin) DeepCopy() *ThinLvContentsExternalOrigin {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvContentsExternalOrigin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvContentsSnapshot) DeepCopyInto(out *ThinLvContentsSnapshot) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvContentsSnapshot.
+func (in *ThinLvContentsSnapshot) DeepCopy() *ThinLvContentsSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvContentsSnapshot)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateSuspended.
-func (in *ThinBlobAttachmentStateSuspended) DeepCopy() *ThinBlobAttachmentStateSuspended {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvRevert) DeepCopyInto(out *ThinLvRevert) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvRevert.
+func (in *ThinLvRevert) DeepCopy() *ThinLvRevert {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvRevert)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvSpec) DeepCopyInto(out *ThinLvSpec) {
+	*out = *in
+	in.Contents.DeepCopyInto(&out.Contents)
+	out.State = in.State
+	if in.Revert != nil {
+		in, out := &in.Revert, &out.Revert
+		*out = new(ThinLvRevert)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvSpec.
+func (in *ThinLvSpec) DeepCopy() *ThinLvSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvSpecState) DeepCopyInto(out *ThinLvSpecState) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvSpecState.
+func (in *ThinLvSpecState) DeepCopy() *ThinLvSpecState {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvSpecState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvStatus) DeepCopyInto(out *ThinLvStatus) {
+	*out = *in
+	in.State.DeepCopyInto(&out.State)
+	if in.AllocatedBytes != nil {
+		in, out := &in.AllocatedBytes, &out.AllocatedBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ExclusiveBytes != nil {
+		in, out := &in.ExclusiveBytes, &out.ExclusiveBytes
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatus.
+func (in *ThinLvStatus) DeepCopy() *ThinLvStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvStatusState) DeepCopyInto(out *ThinLvStatusState) {
+	*out = *in
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = new(ThinLvStatusStateActive)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatusState.
+func (in *ThinLvStatusState) DeepCopy() *ThinLvStatusState {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvStatusState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinLvStatusStateActive) DeepCopyInto(out *ThinLvStatusStateActive) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatusStateActive.
+func (in *ThinLvStatusStateActive) DeepCopy() *ThinLvStatusStateActive {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinLvStatusStateActive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolAutoextend) DeepCopyInto(out *ThinPoolAutoextend) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolAutoextend.
+func (in *ThinPoolAutoextend) DeepCopy() *ThinPoolAutoextend {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolAutoextend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolLv) DeepCopyInto(out *ThinPoolLv) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLv.
+func (in *ThinPoolLv) DeepCopy() *ThinPoolLv {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolLv)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThinPoolLv) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolLvList) DeepCopyInto(out *ThinPoolLvList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ThinPoolLv, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvList.
+func (in *ThinPoolLvList) DeepCopy() *ThinPoolLvList {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolLvList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ThinPoolLvList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolLvSpec) DeepCopyInto(out *ThinPoolLvSpec) {
+	*out = *in
+	if in.ThinLvs != nil {
+		in, out := &in.ThinLvs, &out.ThinLvs
+		*out = make([]ThinLvSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Autoextend != nil {
+		in, out := &in.Autoextend, &out.Autoextend
+		*out = new(ThinPoolAutoextend)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvSpec.
+func (in *ThinPoolLvSpec) DeepCopy() *ThinPoolLvSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolLvSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolLvStatus) DeepCopyInto(out *ThinPoolLvStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ThinLvs != nil {
+		in, out := &in.ThinLvs, &out.ThinLvs
+		*out = make([]ThinLvStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Autoextend != nil {
+		in, out := &in.Autoextend, &out.Autoextend
+		*out = new(ThinPoolAutoextend)
+		**out = **in
+	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(ThinPoolUsage)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvStatus.
+func (in *ThinPoolLvStatus) DeepCopy() *ThinPoolLvStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolLvStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinPoolUsage) DeepCopyInto(out *ThinPoolUsage) {
+	*out = *in
+	in.UpdateTime.DeepCopyInto(&out.UpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolUsage.
+func (in *ThinPoolUsage) DeepCopy() *ThinPoolUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinPoolUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Volume) DeepCopyInto(out *Volume) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Volume.
+func (in *Volume) DeepCopy() *Volume {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateSuspended)
+	out := new(Volume)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Volume) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobAttachmentStateSuspending) DeepCopyInto(out *ThinBlobAttachmentStateSuspending) {
+func (in *VolumeCache) DeepCopyInto(out *VolumeCache) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobAttachmentStateSuspending.
-func (in *ThinBlobAttachmentStateSuspending) DeepCopy() *ThinBlobAttachmentStateSuspending {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeCache.
+func (in *VolumeCache) DeepCopy() *VolumeCache {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobAttachmentStateSuspending)
+	out := new(VolumeCache)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobContents) DeepCopyInto(out *ThinBlobContents) {
+func (in *VolumeContents) DeepCopyInto(out *VolumeContents) {
 	*out = *in
 	if in.Empty != nil {
 		in, out := &in.Empty, &out.Empty
-		*out = new(ThinBlobContentsEmpty)
+		*out = new(VolumeContentsEmpty)
 		**out = **in
 	}
-	if in.Filesystem != nil {
-		in, out := &in.Filesystem, &out.Filesystem
-		*out = new(ThinBlobContentsFilesystem)
+	if in.CloneVolume != nil {
+		in, out := &in.CloneVolume, &out.CloneVolume
+		*out = new(VolumeContentsCloneVolume)
 		**out = **in
 	}
-	if in.Copy != nil {
-		in, out := &in.Copy, &out.Copy
-		*out = new(ThinBlobContentsCopy)
+	if in.CloneSnapshot != nil {
+		in, out := &in.CloneSnapshot, &out.CloneSnapshot
+		*out = new(VolumeContentsCloneSnapshot)
+		**out = **in
+	}
+	if in.Import != nil {
+		in, out := &in.Import, &out.Import
+		*out = new(VolumeContentsImport)
+		**out = **in
+	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(VolumeContentsRestore)
+		**out = **in
+	}
+	if in.GoldenImage != nil {
+		in, out := &in.GoldenImage, &out.GoldenImage
+		*out = new(VolumeContentsGoldenImage)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContents.
-func (in *ThinBlobContents) DeepCopy() *ThinBlobContents {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContents.
+func (in *VolumeContents) DeepCopy() *VolumeContents {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobContents)
+	out := new(VolumeContents)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobContentsCopy) DeepCopyInto(out *ThinBlobContentsCopy) {
+func (in *VolumeContentsCloneSnapshot) DeepCopyInto(out *VolumeContentsCloneSnapshot) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsCopy.
-func (in *ThinBlobContentsCopy) DeepCopy() *ThinBlobContentsCopy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsCloneSnapshot.
+func (in *VolumeContentsCloneSnapshot) DeepCopy() *VolumeContentsCloneSnapshot {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobContentsCopy)
+	out := new(VolumeContentsCloneSnapshot)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobContentsEmpty) DeepCopyInto(out *ThinBlobContentsEmpty) {
+func (in *VolumeContentsCloneVolume) DeepCopyInto(out *VolumeContentsCloneVolume) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsEmpty.
-func (in *ThinBlobContentsEmpty) DeepCopy() *ThinBlobContentsEmpty {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsCloneVolume.
+func (in *VolumeContentsCloneVolume) DeepCopy() *VolumeContentsCloneVolume {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobContentsEmpty)
+	out := new(VolumeContentsCloneVolume)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobContentsFilesystem) DeepCopyInto(out *ThinBlobContentsFilesystem) {
+func (in *VolumeContentsEmpty) DeepCopyInto(out *VolumeContentsEmpty) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobContentsFilesystem.
-func (in *ThinBlobContentsFilesystem) DeepCopy() *ThinBlobContentsFilesystem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsEmpty.
+func (in *VolumeContentsEmpty) DeepCopy() *VolumeContentsEmpty {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobContentsFilesystem)
+	out := new(VolumeContentsEmpty)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobList) DeepCopyInto(out *ThinBlobList) {
+func (in *VolumeContentsGoldenImage) DeepCopyInto(out *VolumeContentsGoldenImage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsGoldenImage.
+func (in *VolumeContentsGoldenImage) DeepCopy() *VolumeContentsGoldenImage {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeContentsGoldenImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeContentsImport) DeepCopyInto(out *VolumeContentsImport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsImport.
+func (in *VolumeContentsImport) DeepCopy() *VolumeContentsImport {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeContentsImport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeContentsRestore) DeepCopyInto(out *VolumeContentsRestore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsRestore.
+func (in *VolumeContentsRestore) DeepCopy() *VolumeContentsRestore {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeContentsRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeEncryption) DeepCopyInto(out *VolumeEncryption) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeEncryption.
+func (in *VolumeEncryption) DeepCopy() *VolumeEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeImport) DeepCopyInto(out *VolumeImport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeImport.
+func (in *VolumeImport) DeepCopy() *VolumeImport {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeImport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeImport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeImportList) DeepCopyInto(out *VolumeImportList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ThinBlob, len(*in))
+		*out = make([]VolumeImport, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobList.
-func (in *ThinBlobList) DeepCopy() *ThinBlobList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeImportList.
+func (in *VolumeImportList) DeepCopy() *VolumeImportList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobList)
+	out := new(VolumeImportList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ThinBlobList) DeepCopyObject() runtime.Object {
+func (in *VolumeImportList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -524,318 +1845,335 @@ func (in *ThinBlobList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobSpec) DeepCopyInto(out *ThinBlobSpec) {
+func (in *VolumeImportSpec) DeepCopyInto(out *VolumeImportSpec) {
 	*out = *in
-	in.Contents.DeepCopyInto(&out.Contents)
-	if in.AttachToNodes != nil {
-		in, out := &in.AttachToNodes, &out.AttachToNodes
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobSpec.
-func (in *ThinBlobSpec) DeepCopy() *ThinBlobSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeImportSpec.
+func (in *VolumeImportSpec) DeepCopy() *VolumeImportSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobSpec)
+	out := new(VolumeImportSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobState) DeepCopyInto(out *ThinBlobState) {
+func (in *VolumeIntegrity) DeepCopyInto(out *VolumeIntegrity) {
 	*out = *in
-	if in.Creating != nil {
-		in, out := &in.Creating, &out.Creating
-		*out = new(ThinBlobStateCreating)
-		**out = **in
-	}
-	if in.Ready != nil {
-		in, out := &in.Ready, &out.Ready
-		*out = new(ThinBlobStateReady)
-		**out = **in
-	}
-	if in.Expanding != nil {
-		in, out := &in.Expanding, &out.Expanding
-		*out = new(ThinBlobStateExpanding)
-		**out = **in
-	}
-	if in.Migrating != nil {
-		in, out := &in.Migrating, &out.Migrating
-		*out = new(ThinBlobStateMigrating)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobState.
-func (in *ThinBlobState) DeepCopy() *ThinBlobState {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeIntegrity.
+func (in *VolumeIntegrity) DeepCopy() *VolumeIntegrity {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobState)
+	out := new(VolumeIntegrity)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobStateCreating) DeepCopyInto(out *ThinBlobStateCreating) {
+func (in *VolumeList) DeepCopyInto(out *VolumeList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateCreating.
-func (in *ThinBlobStateCreating) DeepCopy() *ThinBlobStateCreating {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeList.
+func (in *VolumeList) DeepCopy() *VolumeList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobStateCreating)
+	out := new(VolumeList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobStateExpanding) DeepCopyInto(out *ThinBlobStateExpanding) {
+func (in *VolumeMigration) DeepCopyInto(out *VolumeMigration) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateExpanding.
-func (in *ThinBlobStateExpanding) DeepCopy() *ThinBlobStateExpanding {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMigration.
+func (in *VolumeMigration) DeepCopy() *VolumeMigration {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobStateExpanding)
+	out := new(VolumeMigration)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeMigration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobStateMigrating) DeepCopyInto(out *ThinBlobStateMigrating) {
+func (in *VolumeMigrationList) DeepCopyInto(out *VolumeMigrationList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VolumeMigration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateMigrating.
-func (in *ThinBlobStateMigrating) DeepCopy() *ThinBlobStateMigrating {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMigrationList.
+func (in *VolumeMigrationList) DeepCopy() *VolumeMigrationList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobStateMigrating)
+	out := new(VolumeMigrationList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeMigrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobStateReady) DeepCopyInto(out *ThinBlobStateReady) {
+func (in *VolumeMigrationSpec) DeepCopyInto(out *VolumeMigrationSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStateReady.
-func (in *ThinBlobStateReady) DeepCopy() *ThinBlobStateReady {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMigrationSpec.
+func (in *VolumeMigrationSpec) DeepCopy() *VolumeMigrationSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobStateReady)
+	out := new(VolumeMigrationSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinBlobStatus) DeepCopyInto(out *ThinBlobStatus) {
+func (in *VolumeMigrationStatus) DeepCopyInto(out *VolumeMigrationStatus) {
 	*out = *in
-	in.State.DeepCopyInto(&out.State)
-	if in.ThinPoolLvName != nil {
-		in, out := &in.ThinPoolLvName, &out.ThinPoolLvName
-		*out = new(string)
-		**out = **in
-	}
-	if in.FsType != nil {
-		in, out := &in.FsType, &out.FsType
-		*out = new(string)
-		**out = **in
-	}
-	if in.Attachments != nil {
-		in, out := &in.Attachments, &out.Attachments
-		*out = make([]ThinBlobAttachment, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinBlobStatus.
-func (in *ThinBlobStatus) DeepCopy() *ThinBlobStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMigrationStatus.
+func (in *VolumeMigrationStatus) DeepCopy() *VolumeMigrationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinBlobStatus)
+	out := new(VolumeMigrationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLv) DeepCopyInto(out *ThinLv) {
+func (in *VolumeMutableParameters) DeepCopyInto(out *VolumeMutableParameters) {
 	*out = *in
-	if in.Spec != nil {
-		in, out := &in.Spec, &out.Spec
-		*out = new(ThinLvSpec)
-		(*in).DeepCopyInto(*out)
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(VolumeQoS)
+		**out = **in
 	}
-	if in.Status != nil {
-		in, out := &in.Status, &out.Status
-		*out = new(ThinLvStatus)
-		(*in).DeepCopyInto(*out)
+	if in.ThinPoolAutoextend != nil {
+		in, out := &in.ThinPoolAutoextend, &out.ThinPoolAutoextend
+		*out = new(ThinPoolAutoextend)
+		**out = **in
+	}
+	if in.Reclaim != nil {
+		in, out := &in.Reclaim, &out.Reclaim
+		*out = new(VolumeReclaim)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLv.
-func (in *ThinLv) DeepCopy() *ThinLv {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeMutableParameters.
+func (in *VolumeMutableParameters) DeepCopy() *VolumeMutableParameters {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLv)
+	out := new(VolumeMutableParameters)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvContents) DeepCopyInto(out *ThinLvContents) {
+func (in *VolumeNodeHealth) DeepCopyInto(out *VolumeNodeHealth) {
 	*out = *in
-	if in.Snapshot != nil {
-		in, out := &in.Snapshot, &out.Snapshot
-		*out = new(ThinLvContentsSnapshot)
-		**out = **in
-	}
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvContents.
-func (in *ThinLvContents) DeepCopy() *ThinLvContents {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeNodeHealth.
+func (in *VolumeNodeHealth) DeepCopy() *VolumeNodeHealth {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvContents)
+	out := new(VolumeNodeHealth)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvContentsSnapshot) DeepCopyInto(out *ThinLvContentsSnapshot) {
+func (in *VolumeNodeQoS) DeepCopyInto(out *VolumeNodeQoS) {
 	*out = *in
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(VolumeQoS)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvContentsSnapshot.
-func (in *ThinLvContentsSnapshot) DeepCopy() *ThinLvContentsSnapshot {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeNodeQoS.
+func (in *VolumeNodeQoS) DeepCopy() *VolumeNodeQoS {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvContentsSnapshot)
+	out := new(VolumeNodeQoS)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvSpec) DeepCopyInto(out *ThinLvSpec) {
+func (in *VolumePathStatus) DeepCopyInto(out *VolumePathStatus) {
 	*out = *in
-	in.Contents.DeepCopyInto(&out.Contents)
-	out.State = in.State
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvSpec.
-func (in *ThinLvSpec) DeepCopy() *ThinLvSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumePathStatus.
+func (in *VolumePathStatus) DeepCopy() *VolumePathStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvSpec)
+	out := new(VolumePathStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvSpecState) DeepCopyInto(out *ThinLvSpecState) {
+func (in *VolumeQoS) DeepCopyInto(out *VolumeQoS) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvSpecState.
-func (in *ThinLvSpecState) DeepCopy() *ThinLvSpecState {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeQoS.
+func (in *VolumeQoS) DeepCopy() *VolumeQoS {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvSpecState)
+	out := new(VolumeQoS)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvStatus) DeepCopyInto(out *ThinLvStatus) {
+func (in *VolumeRaid) DeepCopyInto(out *VolumeRaid) {
 	*out = *in
-	in.State.DeepCopyInto(&out.State)
+	if in.PvTags != nil {
+		in, out := &in.PvTags, &out.PvTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatus.
-func (in *ThinLvStatus) DeepCopy() *ThinLvStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRaid.
+func (in *VolumeRaid) DeepCopy() *VolumeRaid {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvStatus)
+	out := new(VolumeRaid)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvStatusState) DeepCopyInto(out *ThinLvStatusState) {
+func (in *VolumeReclaim) DeepCopyInto(out *VolumeReclaim) {
 	*out = *in
-	if in.Active != nil {
-		in, out := &in.Active, &out.Active
-		*out = new(ThinLvStatusStateActive)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatusState.
-func (in *ThinLvStatusState) DeepCopy() *ThinLvStatusState {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReclaim.
+func (in *VolumeReclaim) DeepCopy() *VolumeReclaim {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvStatusState)
+	out := new(VolumeReclaim)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinLvStatusStateActive) DeepCopyInto(out *ThinLvStatusStateActive) {
+func (in *VolumeReclaimStatus) DeepCopyInto(out *VolumeReclaimStatus) {
 	*out = *in
+	if in.LastFstrimTime != nil {
+		in, out := &in.LastFstrimTime, &out.LastFstrimTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinLvStatusStateActive.
-func (in *ThinLvStatusStateActive) DeepCopy() *ThinLvStatusStateActive {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReclaimStatus.
+func (in *VolumeReclaimStatus) DeepCopy() *VolumeReclaimStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinLvStatusStateActive)
+	out := new(VolumeReclaimStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinPoolLv) DeepCopyInto(out *ThinPoolLv) {
+func (in *VolumeReplication) DeepCopyInto(out *VolumeReplication) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.Spec = in.Spec
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLv.
-func (in *ThinPoolLv) DeepCopy() *ThinPoolLv {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReplication.
+func (in *VolumeReplication) DeepCopy() *VolumeReplication {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinPoolLv)
+	out := new(VolumeReplication)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ThinPoolLv) DeepCopyObject() runtime.Object {
+func (in *VolumeReplication) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -843,31 +2181,31 @@ func (in *ThinPoolLv) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinPoolLvList) DeepCopyInto(out *ThinPoolLvList) {
+func (in *VolumeReplicationList) DeepCopyInto(out *VolumeReplicationList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ThinPoolLv, len(*in))
+		*out = make([]VolumeReplication, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvList.
-func (in *ThinPoolLvList) DeepCopy() *ThinPoolLvList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReplicationList.
+func (in *VolumeReplicationList) DeepCopy() *VolumeReplicationList {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinPoolLvList)
+	out := new(VolumeReplicationList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ThinPoolLvList) DeepCopyObject() runtime.Object {
+func (in *VolumeReplicationList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -875,29 +2213,24 @@ func (in *ThinPoolLvList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinPoolLvSpec) DeepCopyInto(out *ThinPoolLvSpec) {
+func (in *VolumeReplicationSpec) DeepCopyInto(out *VolumeReplicationSpec) {
 	*out = *in
-	if in.ThinLvs != nil {
-		in, out := &in.ThinLvs, &out.ThinLvs
-		*out = make([]ThinLvSpec, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	out.Target = in.Target
+	out.Interval = in.Interval
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvSpec.
-func (in *ThinPoolLvSpec) DeepCopy() *ThinPoolLvSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReplicationSpec.
+func (in *VolumeReplicationSpec) DeepCopy() *VolumeReplicationSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinPoolLvSpec)
+	out := new(VolumeReplicationSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ThinPoolLvStatus) DeepCopyInto(out *ThinPoolLvStatus) {
+func (in *VolumeReplicationStatus) DeepCopyInto(out *VolumeReplicationStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -906,162 +2239,146 @@ func (in *ThinPoolLvStatus) DeepCopyInto(out *ThinPoolLvStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.ThinLvs != nil {
-		in, out := &in.ThinLvs, &out.ThinLvs
-		*out = make([]ThinLvStatus, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.LastSyncTime != nil {
+		in, out := &in.LastSyncTime, &out.LastSyncTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinPoolLvStatus.
-func (in *ThinPoolLvStatus) DeepCopy() *ThinPoolLvStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReplicationStatus.
+func (in *VolumeReplicationStatus) DeepCopy() *VolumeReplicationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ThinPoolLvStatus)
+	out := new(VolumeReplicationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Volume) DeepCopyInto(out *Volume) {
+func (in *VolumeReplicationTarget) DeepCopyInto(out *VolumeReplicationTarget) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Volume.
-func (in *Volume) DeepCopy() *Volume {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeReplicationTarget.
+func (in *VolumeReplicationTarget) DeepCopy() *VolumeReplicationTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(Volume)
+	out := new(VolumeReplicationTarget)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Volume) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VolumeContents) DeepCopyInto(out *VolumeContents) {
+func (in *VolumeRevert) DeepCopyInto(out *VolumeRevert) {
 	*out = *in
-	if in.Empty != nil {
-		in, out := &in.Empty, &out.Empty
-		*out = new(VolumeContentsEmpty)
-		**out = **in
-	}
-	if in.CloneVolume != nil {
-		in, out := &in.CloneVolume, &out.CloneVolume
-		*out = new(VolumeContentsCloneVolume)
-		**out = **in
-	}
-	if in.CloneSnapshot != nil {
-		in, out := &in.CloneSnapshot, &out.CloneSnapshot
-		*out = new(VolumeContentsCloneSnapshot)
-		**out = **in
-	}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContents.
-func (in *VolumeContents) DeepCopy() *VolumeContents {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRevert.
+func (in *VolumeRevert) DeepCopy() *VolumeRevert {
 	if in == nil {
 		return nil
 	}
-	out := new(VolumeContents)
+	out := new(VolumeRevert)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VolumeContentsCloneSnapshot) DeepCopyInto(out *VolumeContentsCloneSnapshot) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsCloneSnapshot.
-func (in *VolumeContentsCloneSnapshot) DeepCopy() *VolumeContentsCloneSnapshot {
-	if in == nil {
-		return nil
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeRevert) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	out := new(VolumeContentsCloneSnapshot)
-	in.DeepCopyInto(out)
-	return out
+	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VolumeContentsCloneVolume) DeepCopyInto(out *VolumeContentsCloneVolume) {
+func (in *VolumeRevertList) DeepCopyInto(out *VolumeRevertList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VolumeRevert, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsCloneVolume.
-func (in *VolumeContentsCloneVolume) DeepCopy() *VolumeContentsCloneVolume {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRevertList.
+func (in *VolumeRevertList) DeepCopy() *VolumeRevertList {
 	if in == nil {
 		return nil
 	}
-	out := new(VolumeContentsCloneVolume)
+	out := new(VolumeRevertList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VolumeRevertList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VolumeContentsEmpty) DeepCopyInto(out *VolumeContentsEmpty) {
+func (in *VolumeRevertSpec) DeepCopyInto(out *VolumeRevertSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeContentsEmpty.
-func (in *VolumeContentsEmpty) DeepCopy() *VolumeContentsEmpty {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRevertSpec.
+func (in *VolumeRevertSpec) DeepCopy() *VolumeRevertSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VolumeContentsEmpty)
+	out := new(VolumeRevertSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VolumeList) DeepCopyInto(out *VolumeList) {
+func (in *VolumeRevertStatus) DeepCopyInto(out *VolumeRevertStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Volume, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeList.
-func (in *VolumeList) DeepCopy() *VolumeList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeRevertStatus.
+func (in *VolumeRevertStatus) DeepCopy() *VolumeRevertStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VolumeList)
+	out := new(VolumeRevertStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VolumeList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 	*out = *in
+	if in.Raid != nil {
+		in, out := &in.Raid, &out.Raid
+		*out = new(VolumeRaid)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Vdo != nil {
+		in, out := &in.Vdo, &out.Vdo
+		*out = new(VolumeVdo)
+		**out = **in
+	}
 	in.Type.DeepCopyInto(&out.Type)
 	in.Contents.DeepCopyInto(&out.Contents)
 	if in.AccessModes != nil {
@@ -1069,11 +2386,39 @@ func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 		*out = make([]VolumeAccessMode, len(*in))
 		copy(*out, *in)
 	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(VolumeEncryption)
+		**out = **in
+	}
+	if in.Integrity != nil {
+		in, out := &in.Integrity, &out.Integrity
+		*out = new(VolumeIntegrity)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(VolumeCache)
+		**out = **in
+	}
 	if in.AttachToNodes != nil {
 		in, out := &in.AttachToNodes, &out.AttachToNodes
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.FenceNodes != nil {
+		in, out := &in.FenceNodes, &out.FenceNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.MutableParameters.DeepCopyInto(&out.MutableParameters)
+	if in.StorageClassParameters != nil {
+		in, out := &in.StorageClassParameters, &out.StorageClassParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSpec.
@@ -1096,11 +2441,57 @@ func (in *VolumeStatus) DeepCopyInto(out *VolumeStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AllocatedBytes != nil {
+		in, out := &in.AllocatedBytes, &out.AllocatedBytes
+		*out = new(int64)
+		**out = **in
+	}
 	if in.AttachedToNodes != nil {
 		in, out := &in.AttachedToNodes, &out.AttachedToNodes
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.QoS != nil {
+		in, out := &in.QoS, &out.QoS
+		*out = new(VolumeQoS)
+		**out = **in
+	}
+	if in.NodeQoS != nil {
+		in, out := &in.NodeQoS, &out.NodeQoS
+		*out = make([]VolumeNodeQoS, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Vdo != nil {
+		in, out := &in.Vdo, &out.Vdo
+		*out = new(VolumeVdoStatus)
+		**out = **in
+	}
+	if in.Reclaim != nil {
+		in, out := &in.Reclaim, &out.Reclaim
+		*out = new(VolumeReclaimStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]VolumePathStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeHealth != nil {
+		in, out := &in.NodeHealth, &out.NodeHealth
+		*out = make([]VolumeNodeHealth, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Dependents != nil {
+		in, out := &in.Dependents, &out.Dependents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeStatus.
@@ -1172,3 +2563,33 @@ func (in *VolumeTypeFilesystem) DeepCopy() *VolumeTypeFilesystem {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeVdo) DeepCopyInto(out *VolumeVdo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeVdo.
+func (in *VolumeVdo) DeepCopy() *VolumeVdo {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeVdo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeVdoStatus) DeepCopyInto(out *VolumeVdoStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeVdoStatus.
+func (in *VolumeVdoStatus) DeepCopy() *VolumeVdoStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeVdoStatus)
+	in.DeepCopyInto(out)
+	return out
+}
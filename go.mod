@@ -8,6 +8,7 @@ require (
 	github.com/container-storage-interface/spec v1.9.0
 	github.com/digitalocean/go-qemu v0.0.0-20230711162256-2e3d0186973e
 	github.com/openshift/custom-resource-status v1.1.2
+	golang.org/x/sys v0.22.0
 	google.golang.org/grpc v1.62.0
 	google.golang.org/protobuf v1.34.2
 	k8s.io/api v0.30.3
@@ -57,7 +58,6 @@ require (
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
 	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/oauth2 v0.21.0 // indirect
-	golang.org/x/sys v0.22.0 // indirect
 	golang.org/x/term v0.22.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
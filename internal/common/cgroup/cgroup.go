@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+)
+
+// This package limits I/O of locally attached volumes with the cgroup v2 io
+// controller. Limits are set in io.max of the cgroup that contains all pods
+// on the node, so they apply to the combined I/O of every pod using the
+// volume. The io controller has no notion of bursts, so burst limits are not
+// enforced here.
+
+// The node has no cgroup v2 hierarchy with the io controller enabled for pods,
+// so I/O limits cannot be enforced on it.
+var ErrIoMaxUnavailable = errors.New("cgroup v2 io controller unavailable")
+
+// The host's cgroup v2 hierarchy, reachable because we run with hostPID: true
+const hostCgroupRoot = "/proc/1/root/sys/fs/cgroup"
+
+// Parent cgroups of all pods for the systemd and cgroupfs cgroup drivers
+var podsCgroupNames = []string{"kubepods.slice", "kubepods"}
+
+func podsCgroupPath() (string, error) {
+	for _, name := range podsCgroupNames {
+		p := path.Join(hostCgroupRoot, name)
+		if _, err := os.Stat(path.Join(p, "io.max")); err == nil {
+			return p, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: no pods cgroup with the io controller enabled under %s", ErrIoMaxUnavailable, hostCgroupRoot)
+}
+
+func formatLimit(limit int64) string {
+	if limit == 0 {
+		return "max"
+	}
+	return strconv.FormatInt(limit, 10)
+}
+
+// Sets the I/O limits of a block device, or removes them if qos is nil.
+// Returns an error matching ErrIoMaxUnavailable if the node cannot enforce
+// them.
+func SetIoMax(devicePathOnHost string, qos *v1alpha1.VolumeQoS) error {
+	if qos == nil {
+		qos = &v1alpha1.VolumeQoS{}
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Stat(path.Join("/proc/1/root", devicePathOnHost), &stat); err != nil {
+		return fmt.Errorf("failed to stat \"%s\": %w", devicePathOnHost, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return fmt.Errorf("\"%s\" is not a block device", devicePathOnHost)
+	}
+
+	cgroupPath, err := podsCgroupPath()
+	if err != nil {
+		return err
+	}
+
+	line := fmt.Sprintf("%d:%d rbps=%s wbps=%s riops=%s wiops=%s",
+		unix.Major(uint64(stat.Rdev)), unix.Minor(uint64(stat.Rdev)),
+		formatLimit(qos.ReadBytesPerSecond),
+		formatLimit(qos.WriteBytesPerSecond),
+		formatLimit(qos.ReadIops),
+		formatLimit(qos.WriteIops),
+	)
+
+	// io.max takes one line per write
+	err = os.WriteFile(path.Join(cgroupPath, "io.max"), []byte(line), 0)
+	if err != nil {
+		return fmt.Errorf("failed to set io.max \"%s\": %w", line, err)
+	}
+	return nil
+}
@@ -3,31 +3,130 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type Output struct {
 	ExitCode int
 	Combined []byte
+
+	// Only what the command wrote to stdout, for parsing machine-readable
+	// output that warnings on stderr must not be mixed into
+	Stdout []byte
 }
 
 // If the command exits with a non-zero status, an error is returned alongside the output.
 func RunInContainerContext(ctx context.Context, command ...string) (Output, error) {
+	return RunInContainerWithInputsContext(ctx, nil, command...)
+}
+
+// Like RunInContainerContext, but the command can read each of the inputs from
+// "/dev/fd/3", "/dev/fd/4", and so on. Useful for passing secrets such as
+// passphrases without placing them on the command line or in a file.
+func RunInContainerWithInputsContext(ctx context.Context, inputs [][]byte, command ...string) (Output, error) {
 	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
 	cmd.Env = append(cmd.Environ(), "LC_ALL=C")
 	cmd.Stdin = nil
 
-	combined, err := cmd.CombinedOutput()
+	for _, input := range inputs {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return Output{ExitCode: -1}, err
+		}
+		defer func() { _ = r.Close() }()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, r)
+
+		go func(input []byte) {
+			// errors show up as a short read in the command
+			_, _ = w.Write(input)
+			_ = w.Close()
+		}(input)
+	}
+
+	var stdout bytes.Buffer
+	combined := &lockedBuffer{}
+	cmd.Stdout = io.MultiWriter(&stdout, combined)
+	cmd.Stderr = combined
+
+	err := cmd.Run()
+	output, err := newOutput(command, combined.Bytes(), err)
+	output.Stdout = stdout.Bytes()
+	return output, err
+}
+
+// A bytes.Buffer that stdout and stderr can be written to concurrently
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// Like RunInContainerContext, but also calls onLine with each line of output
+// as it is produced. Lines may be terminated by '\r' as well as '\n', which
+// is how progress is reported by tools like qemu-img.
+func RunInContainerStreamingContext(ctx context.Context, onLine func(line string), command ...string) (Output, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(cmd.Environ(), "LC_ALL=C")
+	cmd.Stdin = nil
+
+	w := &lineWriter{onLine: onLine}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	err := cmd.Run()
+	return newOutput(command, w.combined.Bytes(), err)
+}
+
+// An io.Writer that accumulates output and splits it into lines
+type lineWriter struct {
+	mu       sync.Mutex
+	combined bytes.Buffer
+	partial  []byte
+	onLine   func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.combined.Write(p)
+
+	for _, b := range p {
+		if b == '\r' || b == '\n' {
+			if len(w.partial) > 0 {
+				w.onLine(string(w.partial))
+				w.partial = w.partial[:0]
+			}
+		} else {
+			w.partial = append(w.partial, b)
+		}
+	}
+	return len(p), nil
+}
 
+func newOutput(command []string, combined []byte, err error) (Output, error) {
 	output := Output{
 		Combined: combined,
 	}
@@ -75,165 +174,12 @@ func PathExistsOnHost(hostPath string) (bool, error) {
 	}
 }
 
-func Dmsetup(args ...string) (Output, error) {
-	log.Printf("dmsetup command: %v", args)
-	return RunOnHost(append([]string{"dmsetup"}, args...)...)
-}
-
-func DmsetupCreateIdempotent(args ...string) (Output, error) {
-	output, err := Dmsetup(append([]string{"create"}, args...)...)
-
-	if err != nil && strings.Contains(string(output.Combined), "resource busy") {
-		err = nil // suppress error for idempotency
-	}
-
-	return output, err
-}
-
-func DmsetupSuspendIdempotent(args ...string) (Output, error) {
-	output, err := Dmsetup(append([]string{"suspend"}, args...)...)
-
-	if err != nil && strings.Contains(string(output.Combined), "No such device or address") {
-		err = nil // suppress error for idempotency
-	}
-
-	return output, err
-}
-
-func DmsetupRemoveIdempotent(args ...string) (Output, error) {
-	output, err := Dmsetup(append([]string{"remove"}, args...)...)
-
-	if err != nil && strings.Contains(string(output.Combined), "No such device or address") {
-		err = nil // suppress error for idempotency
-	}
-
-	return output, err
-}
-
-// Atomic. Overwrites the profile if it already exists.
-func LvmCreateProfile(name string, contents string) error {
-	// This should never happen but be extra careful since the name is used to build a path outside the container's
-	// mount namespace and container escapes must be prevented.
-	if strings.ContainsAny(name, "/") {
-		return fmt.Errorf("lvm profile name \"%s\" must not contain a '/' character", name)
-	}
-	if name == ".." {
-		return fmt.Errorf("lvm profile name \"%s\" must not be \"..\"", name)
-	}
-
-	// This process runs in the host PID namespace, so the host's root dir is accessible through the init process.
-	profileDir := "/proc/1/root/etc/lvm/profile"
-	profilePath := path.Join(profileDir, name+".profile")
-
-	f, err := os.CreateTemp(profileDir, "kubesan-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file for lvm profile \"%s\": %s", name, err)
-	}
-	fClosed := false
-	fRenamed := false
-	defer func() {
-		if !fClosed {
-			if err := f.Close(); err != nil {
-				panic(fmt.Sprintf("failed to close lvm profile \"%s\": %s", name, err))
-			}
-		}
-		if !fRenamed {
-			if err := os.Remove(f.Name()); err != nil {
-				panic(fmt.Sprintf("failed to remove temporary file for lvm profile \"%s\": %s", name, err))
-			}
-		}
-	}()
-
-	if err := f.Chmod(0644); err != nil {
-		return fmt.Errorf("failed to chmod lvm profile \"%s\": %s", name, err)
-	}
-	if _, err := f.WriteString(contents); err != nil {
-		return fmt.Errorf("failed to write lvm profile \"%s\": %s", name, err)
-	}
-	if err := f.Close(); err != nil {
-		return fmt.Errorf("failed to close lvm profile \"%s\": %s", name, err)
-	}
-	fClosed = true
-	if err := os.Rename(f.Name(), profilePath); err != nil {
-		return fmt.Errorf("failed to rename lvm profile \"%s\": %s", name, err)
-	}
-	fRenamed = true
-	return nil
-}
-
-func Lvm(args ...string) (Output, error) {
-	log.Printf("LVM command: %v", args)
-	return RunOnHost(append([]string{"lvm"}, args...)...)
-}
-
-func LvmLvCreateIdempotent(args ...string) (Output, error) {
-	output, err := Lvm(append([]string{"lvcreate"}, args...)...)
-
-	if err != nil && strings.Contains(string(output.Combined), "already exists") {
-		err = nil // suppress error for idempotency
-	}
-
-	return output, err
-}
-
-func LvmLvRemoveIdempotent(args ...string) (Output, error) {
-	output, err := Lvm(append([]string{"lvremove"}, args...)...)
-
-	// ignore both "failed to find" and "Failed to find"
-	if err != nil && strings.Contains(string(output.Combined), "ailed to find") {
-		err = nil // suppress error for idempotency
-	}
-
-	return output, err
-}
-
-func LvmLvHasTag(vgName string, lvName string, tag string) (bool, error) {
-	output, err := Lvm(
-		"lvs",
-		"--devicesfile", vgName,
-		"--select", fmt.Sprintf("lv_tags = {\"%s\"}", tag),
-		fmt.Sprintf("%s/%s", vgName, lvName),
-	)
-	if err != nil {
-		return false, err
-	}
-
-	// there is output only if the tag is present
-	return string(output.Combined) != "", nil
-}
-
-func LvmLvAddTag(vgName string, lvName string, tag string) error {
-	// lvchange succeeds if the tag is already present
-	_, err := Lvm(
-		"lvchange",
-		"--devicesfile", vgName,
-		"--addtag", tag,
-		fmt.Sprintf("%s/%s", vgName, lvName),
-	)
-	return err
-}
-
-// Calls a function with an LV activated temporarily
-func WithLvmLvActivated(vgName string, lvName string, op func() error) (err error) {
-	vgLvName := fmt.Sprintf("%s/%s", vgName, lvName)
-
-	_, err = Lvm("lvchange", "--devicesfile", vgName, "--activate", "y", vgLvName)
-	if err != nil {
-		return err
-	}
-
-	defer func() {
-		_, err = Lvm("lvchange", "--devicesfile", vgName, "--activate", "n", vgLvName)
-	}()
-
-	return op()
-}
-
 var (
 	nbdClientConnectedPattern = regexp.MustCompile(`^Connected (/dev/\S*)`)
 )
 
-func NBDClientConnect(serverHostname string) (string, error) {
+// Connects a kernel NBD device to the export and returns its path
+func NBDClientConnect(serverHostname string, export string) (string, error) {
 	// we run nbd-client in the host net namespace, so we must resolve the server's hostname here
 
 	serverIps, err := net.LookupIP(serverHostname)
@@ -243,14 +189,14 @@ func NBDClientConnect(serverHostname string) (string, error) {
 		return "", fmt.Errorf("could not resolve hostname '%s'", serverHostname)
 	}
 
-	output, err := RunOnHost("nbd-client", serverIps[0].String(), "--persist", "--connections", "8")
+	output, err := RunOnHost("nbd-client", serverIps[0].String(), "--name", export, "--persist", "--connections", "8")
 	if err != nil {
 		return "", err
 	}
 
 	match := nbdClientConnectedPattern.FindSubmatch(output.Combined)
 	if match == nil {
-		return "", err
+		return "", fmt.Errorf("unexpected nbd-client output \"%s\"", string(output.Combined))
 	}
 
 	path := string(match[1])
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -23,8 +24,34 @@ const (
 
 	Finalizer = Domain + "/finalizer"
 
+	// Annotation on the prime PVCs created by the VolumeImport populator,
+	// holding the JSON VolumeContents to provision the volume with.
+	ImportAnnotation = Domain + "/import"
+
+	// Annotation on Volumes naming the node that is writing the volume's
+	// LUKS header, which nodes staging the volume take turns doing.
+	LuksHeaderLockAnnotation = Domain + "/luks-header-lock"
+
+	// Label on ThinPoolLvs holding thin LVs with an external origin, with
+	// the name of the origin LV. The origin must outlive the thin-pool.
+	ExternalOriginLabel = Domain + "/external-origin"
+
 	CsiSocketPath = "/run/csi/socket"
 
+	// How often node controllers refresh conditions derived from host
+	// state, like LV health, while a volume or thin-pool is in use.
+	HealthCheckInterval = 30 * time.Second
+
+	// How long a mounted file system may stay frozen while a snapshot of
+	// its volume is taken. Past this, the snapshot is taken anyway and is
+	// only crash-consistent.
+	SnapshotFreezeTimeout = 10 * time.Second
+
+	// Where the manager's scratch volume is mounted. S3 backups and
+	// restores stage their qcow2 images there, since qemu-img can neither
+	// write a qcow2 image to a pipe nor sign S3 requests.
+	ScratchDir = "/var/tmp/kubesan"
+
 	LvmProfileName = "kubesan"
 	LvmProfile     = "" +
 		"# This file is part of the KubeSAN CSI plugin and may be automatically\n" +
@@ -41,6 +68,10 @@ var (
 	PodName       = os.Getenv("POD_NAME")
 	PodIP         = os.Getenv("POD_IP")
 
+	// The size limit of the scratch volume, a quantity like "64Gi". Staged
+	// images are only limited by the free space in ScratchDir if unset.
+	ScratchSizeLimit = os.Getenv("SCRATCH_SIZE_LIMIT")
+
 	Namespace string
 
 	Scheme = runtime.NewScheme()
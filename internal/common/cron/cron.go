@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This package parses the standard 5-field cron(8) schedule format
+// ("minute hour day-of-month month day-of-week") with "*", ranges, steps and
+// lists, plus the @hourly, @daily, @weekly, @monthly and @yearly macros.
+// Month and day names are not supported. Schedules are evaluated in UTC.
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type Schedule struct {
+	minutes    uint64 // bit i set if minute i matches
+	hours      uint64
+	daysOfMon  uint64
+	months     uint64
+	daysOfWeek uint64
+
+	// like cron(8), if both day fields are restricted a day matches if
+	// either does
+	anyDayOfMon  bool
+	anyDayOfWeek bool
+}
+
+func Parse(spec string) (*Schedule, error) {
+	if expanded, ok := macros[strings.TrimSpace(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule \"%s\" must have 5 fields", spec)
+	}
+
+	s := &Schedule{
+		anyDayOfMon:  fields[2] == "*",
+		anyDayOfWeek: fields[4] == "*",
+	}
+
+	var err error
+	if s.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.daysOfMon, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.daysOfWeek, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+
+	// 7 is also Sunday
+	if s.daysOfWeek&(1<<7) != 0 {
+		s.daysOfWeek |= 1
+	}
+
+	return s, nil
+}
+
+// Parses a comma-separated list of "*", "N", "N-M", each optionally followed
+// by "/STEP"
+func parseField(field string, minValue int, maxValue int) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in \"%s\"", item)
+			}
+		}
+
+		var low, high int
+		if rangePart == "*" {
+			low, high = minValue, maxValue
+		} else {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+
+			var err error
+			low, err = strconv.Atoi(lowPart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in \"%s\"", item)
+			}
+
+			switch {
+			case isRange:
+				high, err = strconv.Atoi(highPart)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in \"%s\"", item)
+				}
+			case hasStep:
+				high = maxValue // "N/STEP" means "N-max/STEP"
+			default:
+				high = low
+			}
+		}
+
+		if low < minValue || high > maxValue || low > high {
+			return 0, fmt.Errorf("\"%s\" is out of range %d-%d", item, minValue, maxValue)
+		}
+
+		for i := low; i <= high; i += step {
+			bits |= 1 << i
+		}
+	}
+
+	return bits, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dayOfMon := s.daysOfMon&(1<<t.Day()) != 0
+	dayOfWeek := s.daysOfWeek&(1<<t.Weekday()) != 0
+
+	switch {
+	case s.anyDayOfMon && s.anyDayOfWeek:
+		return true
+	case s.anyDayOfMon:
+		return dayOfWeek
+	case s.anyDayOfWeek:
+		return dayOfMon
+	default:
+		return dayOfMon || dayOfWeek
+	}
+}
+
+// Returns the first time after t that matches the schedule, or the zero time
+// if there is none within the next 5 years (e.g. "0 0 30 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hours&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"-1 * * * *",
+		"1- * * * *",
+		"*/0 * * * *",
+		"*/-1 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"* * * JAN *",
+		"* * * * MON",
+	}
+	for _, spec := range tests {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(\"%s\") succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a Wednesday
+	from := time.Date(2025, time.January, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2025, time.January, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2025, time.January, 15, 10, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", from, time.Date(2025, time.January, 15, 10, 25, 0, 0, time.UTC)},
+		{"0,30 9-17 * * *", from, time.Date(2025, time.January, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", from, time.Date(2025, time.January, 15, 13, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2025, time.January, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2025, time.January, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", from, time.Date(2025, time.January, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", from, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)},
+
+		// 7 is also Sunday
+		{"0 0 * * 7", from, time.Date(2025, time.January, 19, 0, 0, 0, 0, time.UTC)},
+
+		// with both day fields restricted, either one matches
+		{"0 0 13 * 5", from, time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * 0", from, time.Date(2025, time.January, 16, 0, 0, 0, 0, time.UTC)},
+
+		// only restricted day fields restrict
+		{"0 0 31 * *", from, time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2-4 *", from, time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+
+		// a time that matches is skipped, the next one is returned
+		{"7 10 15 1 *", from, time.Date(2026, time.January, 15, 10, 7, 0, 0, time.UTC)},
+		{"8 10 15 1 *", time.Date(2025, time.January, 15, 10, 8, 0, 0, time.UTC), time.Date(2026, time.January, 15, 10, 8, 0, 0, time.UTC)},
+
+		// schedules are evaluated in UTC whatever the location of the time
+		{"0 12 * * *", from.In(time.FixedZone("UTC+13", 13*60*60)), time.Date(2025, time.January, 15, 12, 0, 0, 0, time.UTC)},
+
+		// never
+		{"0 0 30 2 *", from, time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(\"%s\") failed: %s", tt.spec, err)
+			continue
+		}
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("\"%s\" after %s is %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// This package manipulates device-mapper devices in the host through the
+// ioctls on /dev/mapper/control, the interface dmsetup(8) uses, without
+// forking a process per operation.  The host's /dev is reached through
+// /proc/1/root because we run with hostPID: true.  Device nodes in
+// /dev/mapper are created and removed here instead of waiting for udev,
+// which may not be running or may hang.
+
+var (
+	// The device does not exist.
+	ErrNotFound = errors.New("device-mapper device not found")
+
+	// A device with the name already exists.
+	ErrExists = errors.New("device-mapper device already exists")
+
+	// The device is in use, e.g. held open or referenced by another
+	// device.
+	ErrBusy = errors.New("device-mapper device is busy")
+
+	// An existing device's table differs from the requested one.
+	ErrTableMismatch = errors.New("device-mapper table does not match")
+)
+
+// A failed device-mapper operation.  Matches ErrNotFound, ErrExists and ErrBusy
+// with errors.Is(), depending on the errno returned by the kernel.
+type Error struct {
+	Op   string
+	Name string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("dm %s \"%s\": %v", e.Op, e.Name, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return errors.Is(e.Err, unix.ENXIO)
+	case ErrExists:
+		return e.Op == "create" && errors.Is(e.Err, unix.EBUSY)
+	case ErrBusy:
+		return e.Op != "create" && errors.Is(e.Err, unix.EBUSY)
+	}
+	return false
+}
+
+// One line of a device-mapper table, in 512-byte sectors.
+type Target struct {
+	Start  uint64
+	Length uint64
+	Type   string
+	Params string
+}
+
+// Formats the target like a line of "dmsetup table" or "dmsetup status".
+func (t Target) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%d %d %s %s", t.Start, t.Length, t.Type, t.Params))
+}
+
+// Parses a table in the format of dmsetup(8), one target per line.
+func ParseTable(table string) ([]Target, error) {
+	var targets []Target
+	for _, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid dm table line \"%s\"", line)
+		}
+
+		start, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dm table line \"%s\": %w", line, err)
+		}
+		length, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dm table line \"%s\": %w", line, err)
+		}
+
+		targets = append(targets, Target{
+			Start:  start,
+			Length: length,
+			Type:   fields[2],
+			Params: strings.Join(fields[3:], " "),
+		})
+	}
+	return targets, nil
+}
+
+// Returns true if the tables map the same sectors with the same target types.
+// Parameters are not compared since the kernel normalizes them, e.g. device
+// paths to major:minor numbers.
+func SameLayout(a []Target, b []Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Start != b[i].Start || a[i].Length != b[i].Length || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
+
+// The state of an existing device.
+type Info struct {
+	// The device number, for use with unix.Major() and unix.Minor().
+	Dev uint64
+
+	// How many times the device is held open.
+	OpenCount int32
+
+	Suspended bool
+}
+
+// Returns the path of the device's node in the host's /dev/mapper.
+func DevicePath(name string) string {
+	return "/dev/mapper/" + name
+}
+
+// Creates a device with the given table, activates it, and creates its node
+// in /dev/mapper.  Fails with ErrExists if a device with the name already
+// exists, in which case nothing is changed.
+func Create(name string, table []Target) error {
+	log.Printf("dm create: %s %v", name, table)
+
+	header, _, err := doIoctl("create", cmdDevCreate, name, 0, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	// the node may be needed before the table is loaded, e.g. for a
+	// multipath table referring to this device
+	if err := mknod(name, header.dev); err != nil {
+		_ = remove(name)
+		return err
+	}
+
+	if err := Load(name, table); err != nil {
+		_ = remove(name)
+		return err
+	}
+
+	if err := Resume(name); err != nil {
+		_ = remove(name)
+		return err
+	}
+
+	return nil
+}
+
+// Like Create, but an existing device is accepted if its table has the same
+// layout, see SameLayout().  Its node is created if missing.
+func CreateIdempotent(name string, table []Target) error {
+	err := Create(name, table)
+	if !errors.Is(err, ErrExists) {
+		return err
+	}
+
+	existing, err := Table(name)
+	if err != nil {
+		return err
+	}
+	if !SameLayout(existing, table) {
+		return &Error{Op: "create", Name: name, Err: ErrTableMismatch}
+	}
+
+	info, err := GetInfo(name)
+	if err != nil {
+		return err
+	}
+	return mknod(name, info.Dev)
+}
+
+// Loads a table into the device's inactive slot.  It takes effect with the
+// next Resume.
+func Load(name string, table []Target) error {
+	data, err := marshalTargets(table)
+	if err != nil {
+		return &Error{Op: "load", Name: name, Err: err}
+	}
+
+	_, _, err = doIoctl("load", cmdTableLoad, name, 0, uint32(len(table)), data)
+	return err
+}
+
+// Options for Suspend.
+type SuspendOptions struct {
+	// Do not flush the file system on the device, like dmsetup's
+	// --nolockfs.
+	SkipLockfs bool
+
+	// Push back I/O that has not completed instead of waiting for it,
+	// like dmsetup's --noflush.
+	NoFlush bool
+}
+
+// Suspends the device, after which I/O to it blocks until Resume.
+func Suspend(name string, options SuspendOptions) error {
+	log.Printf("dm suspend: %s %+v", name, options)
+
+	flags := uint32(flagSuspend)
+	if options.SkipLockfs {
+		flags |= flagSkipLockfs
+	}
+	if options.NoFlush {
+		flags |= flagNoFlush
+	}
+
+	_, _, err := doIoctl("suspend", cmdDevSuspend, name, flags, 0, nil)
+	return err
+}
+
+// Activates a table loaded with Load, if any, and resumes I/O to the device.
+func Resume(name string) error {
+	log.Printf("dm resume: %s", name)
+
+	_, _, err := doIoctl("resume", cmdDevSuspend, name, 0, 0, nil)
+	return err
+}
+
+// Removes the device and its node.  Fails with ErrBusy if the device is in
+// use.
+func Remove(name string) error {
+	log.Printf("dm remove: %s", name)
+	return remove(name)
+}
+
+// Like Remove, but if the device is held open its table is first replaced
+// with an error target, so that whoever holds it open sees I/O errors instead
+// of hanging, like dmsetup's --force.
+func ForceRemove(name string) error {
+	log.Printf("dm remove --force: %s", name)
+
+	err := remove(name)
+	if !errors.Is(err, ErrBusy) {
+		return err
+	}
+
+	table, err := Table(name)
+	if err != nil {
+		return err
+	}
+
+	var length uint64
+	for _, target := range table {
+		length = max(length, target.Start+target.Length)
+	}
+	if err := Load(name, []Target{{Start: 0, Length: length, Type: "error"}}); err != nil {
+		return err
+	}
+	if err := Resume(name); err != nil {
+		return err
+	}
+
+	return remove(name)
+}
+
+func remove(name string) error {
+	if _, _, err := doIoctl("remove", cmdDevRemove, name, 0, 0, nil); err != nil {
+		return err
+	}
+
+	err := os.Remove(path.Join("/proc/1/root", DevicePath(name)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return &Error{Op: "remove", Name: name, Err: err}
+	}
+	return nil
+}
+
+// Sends a message to the target at the given sector, like "dmsetup message".
+func Message(name string, sector uint64, message string) error {
+	log.Printf("dm message: %s %d %s", name, sector, message)
+
+	data := make([]byte, align8(targetMsgSize+len(message)+1))
+	binary.NativeEndian.PutUint64(data, sector)
+	copy(data[targetMsgSize:], message)
+
+	_, _, err := doIoctl("message", cmdTargetMsg, name, 0, 0, data)
+	return err
+}
+
+// Returns the device's active table.
+func Table(name string) ([]Target, error) {
+	return tableStatus("table", name, flagStatusTable)
+}
+
+// Returns the status of each target of the device's active table, with the
+// target-specific status in Params.
+func Status(name string) ([]Target, error) {
+	return tableStatus("status", name, 0)
+}
+
+func tableStatus(op string, name string, flags uint32) ([]Target, error) {
+	header, data, err := doIoctl(op, cmdTableStatus, name, flags, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := unmarshalTargets(data, header.targetCount)
+	if err != nil {
+		return nil, &Error{Op: op, Name: name, Err: err}
+	}
+	return targets, nil
+}
+
+// Returns the state of the device.
+func GetInfo(name string) (*Info, error) {
+	header, _, err := doIoctl("info", cmdDevStatus, name, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Info{
+		Dev:       header.dev,
+		OpenCount: header.openCount,
+		Suspended: header.flags&flagSuspend != 0,
+	}, nil
+}
+
+// Returns true if the device exists.
+func Exists(name string) (bool, error) {
+	_, err := GetInfo(name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Creates the device's node in /dev/mapper, replacing a node with the wrong
+// device number.  udev may also have created it, possibly as a symlink, which
+// is kept as long as it leads to the device.
+func mknod(name string, dev uint64) error {
+	nodePath := path.Join("/proc/1/root", DevicePath(name))
+
+	var stat unix.Stat_t
+	err := unix.Stat(nodePath, &stat)
+	if err == nil && stat.Mode&unix.S_IFMT == unix.S_IFBLK && uint64(stat.Rdev) == dev {
+		return nil
+	} else if err == nil {
+		if err := os.Remove(nodePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return &Error{Op: "mknod", Name: name, Err: err}
+		}
+	} else if !errors.Is(err, unix.ENOENT) {
+		return &Error{Op: "mknod", Name: name, Err: err}
+	}
+
+	err = unix.Mknod(nodePath, unix.S_IFBLK|0o600, int(dev))
+	if err != nil && !errors.Is(err, unix.EEXIST) {
+		return &Error{Op: "mknod", Name: name, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	// as printed by "dmsetup table" for a volume with a dm-cache layer
+	lines := []string{
+		"0 2097152 cache 253:3 253:2 253:4 128 1 writethrough smq 0",
+		"2097152 8 zero",
+	}
+
+	got, err := ParseTable(strings.Join(lines, "\n") + "\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Start: 0, Length: 2097152, Type: "cache", Params: "253:3 253:2 253:4 128 1 writethrough smq 0"},
+		{Start: 2097152, Length: 8, Type: "zero"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for i, target := range got {
+		if target.String() != lines[i] {
+			t.Errorf("target %d formats as \"%s\", want \"%s\"", i, target.String(), lines[i])
+		}
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+	}{
+		{"missing type", "0 2097152\n"},
+		{"negative start", "-1 2097152 linear 253:1 0\n"},
+		{"non-numeric length", "0 1G linear 253:1 0\n"},
+		{"overflowing length", "0 18446744073709551616 zero\n"},
+		{"bad second line", "0 8 zero\n8 8\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if targets, err := ParseTable(tt.table); err == nil {
+				t.Errorf("got %v, want an error", targets)
+			}
+		})
+	}
+}
+
+func TestParseTableEmpty(t *testing.T) {
+	targets, err := ParseTable("\n  \n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("got %v, want no targets", targets)
+	}
+}
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The device-mapper ioctl interface from <linux/dm-ioctl.h>.  Every ioctl
+// passes a buffer starting with struct dm_ioctl, followed by command-specific
+// data at dataStart.
+
+const (
+	controlPath = "/proc/1/root/dev/mapper/control"
+
+	// struct dm_ioctl is 312 bytes, the data that follows is 8-byte aligned
+	ioctlHeaderSize = 312
+	nameLen         = 128
+
+	// struct dm_target_spec is followed by its parameter string
+	targetSpecSize = 40
+	typeNameLen    = 16
+
+	// struct dm_target_msg is followed by the message
+	targetMsgSize = 8
+
+	initialBufferSize = 16 * 1024
+	maxBufferSize     = 16 * 1024 * 1024
+)
+
+const (
+	cmdDevCreate   = 3
+	cmdDevRemove   = 4
+	cmdDevSuspend  = 6
+	cmdDevStatus   = 7
+	cmdTableLoad   = 9
+	cmdTableStatus = 12
+	cmdTargetMsg   = 14
+)
+
+const (
+	flagSuspend     = 1 << 1
+	flagStatusTable = 1 << 4
+	flagBufferFull  = 1 << 8
+	flagSkipLockfs  = 1 << 10
+	flagNoFlush     = 1 << 11
+)
+
+// Version 4 of the interface, which every supported kernel implements
+var ioctlVersion = [3]uint32{4, 0, 0}
+
+// _IOWR(0xfd, cmd, struct dm_ioctl)
+func ioctlRequest(cmd uint) uint {
+	return 3<<30 | ioctlHeaderSize<<16 | 0xfd<<8 | cmd
+}
+
+// The fields of struct dm_ioctl that are used here
+type header struct {
+	dataSize    uint32
+	dataStart   uint32
+	targetCount uint32
+	openCount   int32
+	flags       uint32
+	eventNr     uint32
+	dev         uint64
+	name        string
+}
+
+func (h *header) marshal(buf []byte) {
+	ne := binary.NativeEndian
+	for i, v := range ioctlVersion {
+		ne.PutUint32(buf[4*i:], v)
+	}
+	ne.PutUint32(buf[12:], h.dataSize)
+	ne.PutUint32(buf[16:], h.dataStart)
+	ne.PutUint32(buf[20:], h.targetCount)
+	ne.PutUint32(buf[24:], uint32(h.openCount))
+	ne.PutUint32(buf[28:], h.flags)
+	ne.PutUint32(buf[32:], h.eventNr)
+	ne.PutUint64(buf[40:], h.dev)
+	copy(buf[48:48+nameLen-1], h.name)
+}
+
+func (h *header) unmarshal(buf []byte) {
+	ne := binary.NativeEndian
+	h.dataSize = ne.Uint32(buf[12:])
+	h.dataStart = ne.Uint32(buf[16:])
+	h.targetCount = ne.Uint32(buf[20:])
+	h.openCount = int32(ne.Uint32(buf[24:]))
+	h.flags = ne.Uint32(buf[28:])
+	h.eventNr = ne.Uint32(buf[32:])
+	h.dev = ne.Uint64(buf[40:])
+}
+
+// Issues a device-mapper ioctl on the named device with the given input data
+// and returns the header and output data.  The buffer is grown and the ioctl
+// repeated while the kernel reports that the output did not fit.
+func doIoctl(op string, cmd uint, name string, flags uint32, targetCount uint32, data []byte) (*header, []byte, error) {
+	if len(name) >= nameLen {
+		return nil, nil, &Error{Op: op, Name: name, Err: unix.ENAMETOOLONG}
+	}
+
+	control, err := os.OpenFile(controlPath, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, &Error{Op: op, Name: name, Err: err}
+	}
+	defer control.Close()
+
+	size := max(initialBufferSize, ioctlHeaderSize+align8(len(data)))
+	for {
+		buf := make([]byte, size)
+		in := header{
+			dataSize:    uint32(size),
+			dataStart:   ioctlHeaderSize,
+			targetCount: targetCount,
+			flags:       flags,
+			name:        name,
+		}
+		in.marshal(buf)
+		copy(buf[ioctlHeaderSize:], data)
+
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, control.Fd(), uintptr(ioctlRequest(cmd)), uintptr(unsafe.Pointer(&buf[0])))
+		if errno != 0 {
+			return nil, nil, &Error{Op: op, Name: name, Err: errno}
+		}
+
+		out := &header{name: name}
+		out.unmarshal(buf)
+
+		if out.flags&flagBufferFull != 0 {
+			if size >= maxBufferSize {
+				return nil, nil, &Error{Op: op, Name: name, Err: unix.ENOBUFS}
+			}
+			size *= 2
+			continue
+		}
+
+		if out.dataStart >= out.dataSize || int(out.dataSize) > len(buf) {
+			return out, nil, nil
+		}
+		return out, buf[out.dataStart:out.dataSize], nil
+	}
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+// Encodes targets as a sequence of struct dm_target_spec, where each "next"
+// is the offset of the following target from the current one
+func marshalTargets(targets []Target) ([]byte, error) {
+	var data []byte
+	for _, target := range targets {
+		if len(target.Type) >= typeNameLen {
+			return nil, fmt.Errorf("dm target type \"%s\" is too long", target.Type)
+		}
+
+		specLen := align8(targetSpecSize + len(target.Params) + 1)
+		spec := make([]byte, specLen)
+		binary.NativeEndian.PutUint64(spec[0:], target.Start)
+		binary.NativeEndian.PutUint64(spec[8:], target.Length)
+		binary.NativeEndian.PutUint32(spec[20:], uint32(specLen))
+		copy(spec[24:24+typeNameLen-1], target.Type)
+		copy(spec[targetSpecSize:], target.Params)
+
+		data = append(data, spec...)
+	}
+	return data, nil
+}
+
+// Decodes the output of a table status ioctl, where each "next" is the offset
+// of the following target from the start of the data
+func unmarshalTargets(data []byte, count uint32) ([]Target, error) {
+	targets := make([]Target, 0, count)
+	offset := 0
+	for i := uint32(0); i < count; i++ {
+		if offset+targetSpecSize > len(data) {
+			return nil, fmt.Errorf("truncated dm table")
+		}
+		spec := data[offset:]
+
+		target := Target{
+			Start:  binary.NativeEndian.Uint64(spec[0:]),
+			Length: binary.NativeEndian.Uint64(spec[8:]),
+			Type:   cString(spec[24 : 24+typeNameLen]),
+			Params: cString(spec[targetSpecSize:]),
+		}
+		targets = append(targets, target)
+
+		offset = int(binary.NativeEndian.Uint32(spec[20:]))
+	}
+	return targets, nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
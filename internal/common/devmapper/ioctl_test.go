@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The layout is checked against the kernel's structs as generated into
+// golang.org/x/sys/unix from <linux/dm-ioctl.h>
+
+func TestLayoutMatchesKernel(t *testing.T) {
+	if ioctlHeaderSize != unix.SizeofDmIoctl {
+		t.Errorf("ioctlHeaderSize is %d, struct dm_ioctl is %d bytes", ioctlHeaderSize, unix.SizeofDmIoctl)
+	}
+	if off := unsafe.Offsetof(unix.DmIoctl{}.Dev); off != 40 {
+		t.Errorf("dev is at offset %d, want 40", off)
+	}
+	if off := unsafe.Offsetof(unix.DmIoctl{}.Name); off != 48 {
+		t.Errorf("name is at offset %d, want 48", off)
+	}
+	if n := len(unix.DmIoctl{}.Name); n != nameLen {
+		t.Errorf("nameLen is %d, name is %d bytes", nameLen, n)
+	}
+	if targetSpecSize != unix.SizeofDmTargetSpec {
+		t.Errorf("targetSpecSize is %d, struct dm_target_spec is %d bytes", targetSpecSize, unix.SizeofDmTargetSpec)
+	}
+	if n := len(unix.DmTargetSpec{}.Target_type); n != typeNameLen {
+		t.Errorf("typeNameLen is %d, target_type is %d bytes", typeNameLen, n)
+	}
+}
+
+func TestIoctlRequest(t *testing.T) {
+	tests := []struct {
+		cmd  uint
+		want uint
+	}{
+		{cmdDevCreate, unix.DM_DEV_CREATE},
+		{cmdDevRemove, unix.DM_DEV_REMOVE},
+		{cmdDevSuspend, unix.DM_DEV_SUSPEND},
+		{cmdDevStatus, unix.DM_DEV_STATUS},
+		{cmdTableLoad, unix.DM_TABLE_LOAD},
+		{cmdTableStatus, unix.DM_TABLE_STATUS},
+		{cmdTargetMsg, unix.DM_TARGET_MSG},
+	}
+	for _, tt := range tests {
+		if got := ioctlRequest(tt.cmd); got != tt.want {
+			t.Errorf("ioctlRequest(%d) = %#x, want %#x", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderRoundTrip(t *testing.T) {
+	in := header{
+		dataSize:    16384,
+		dataStart:   ioctlHeaderSize,
+		targetCount: 2,
+		openCount:   -1,
+		flags:       flagSuspend | flagNoFlush,
+		eventNr:     7,
+		dev:         unix.Mkdev(253, 4),
+		name:        "kubesan-pvc-1",
+	}
+
+	buf := make([]byte, ioctlHeaderSize)
+	in.marshal(buf)
+
+	kernel := (*unix.DmIoctl)(unsafe.Pointer(&buf[0]))
+	if kernel.Version != ioctlVersion {
+		t.Errorf("version is %v, want %v", kernel.Version, ioctlVersion)
+	}
+	if kernel.Data_size != in.dataSize || kernel.Data_start != in.dataStart || kernel.Target_count != in.targetCount {
+		t.Errorf("sizes are %d/%d/%d, want %d/%d/%d", kernel.Data_size, kernel.Data_start, kernel.Target_count, in.dataSize, in.dataStart, in.targetCount)
+	}
+	if kernel.Open_count != in.openCount || kernel.Flags != in.flags || kernel.Event_nr != in.eventNr {
+		t.Errorf("open count, flags and event are %d/%#x/%d, want %d/%#x/%d", kernel.Open_count, kernel.Flags, kernel.Event_nr, in.openCount, in.flags, in.eventNr)
+	}
+	if kernel.Dev != in.dev {
+		t.Errorf("dev is %#x, want %#x", kernel.Dev, in.dev)
+	}
+	if name := cString(kernel.Name[:]); name != in.name {
+		t.Errorf("name is \"%s\", want \"%s\"", name, in.name)
+	}
+
+	var out header
+	out.unmarshal(buf)
+	out.name = in.name
+	if out != in {
+		t.Errorf("unmarshaled %+v, want %+v", out, in)
+	}
+}
+
+func TestHeaderNameIsNulTerminated(t *testing.T) {
+	buf := make([]byte, ioctlHeaderSize)
+	for i := range buf {
+		buf[i] = 0xff
+	}
+
+	long := make([]byte, nameLen+10)
+	for i := range long {
+		long[i] = 'a'
+	}
+	in := header{name: string(long)}
+	in.marshal(buf)
+
+	if buf[48+nameLen-1] != 0xff {
+		t.Errorf("name overwrote its terminating byte")
+	}
+}
+
+func TestTargetsRoundTrip(t *testing.T) {
+	targets := []Target{
+		{Start: 0, Length: 2048, Type: "linear", Params: "253:1 0"},
+		{Start: 2048, Length: 4096, Type: "thin", Params: "253:2 5"},
+		{Start: 6144, Length: 8, Type: "zero"},
+	}
+
+	data, err := marshalTargets(targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// table load: each "next" is relative to the current target
+	offset := 0
+	for i, target := range targets {
+		spec := (*unix.DmTargetSpec)(unsafe.Pointer(&data[offset]))
+		if spec.Sector_start != target.Start || spec.Length != target.Length {
+			t.Errorf("target %d maps %d+%d, want %d+%d", i, spec.Sector_start, spec.Length, target.Start, target.Length)
+		}
+		if typ := cString(spec.Target_type[:]); typ != target.Type {
+			t.Errorf("target %d has type \"%s\", want \"%s\"", i, typ, target.Type)
+		}
+		if params := cString(data[offset+targetSpecSize:]); params != target.Params {
+			t.Errorf("target %d has params \"%s\", want \"%s\"", i, params, target.Params)
+		}
+		if spec.Next%8 != 0 {
+			t.Errorf("target %d is followed at unaligned offset %d", i, spec.Next)
+		}
+		offset += int(spec.Next)
+	}
+	if offset != len(data) {
+		t.Errorf("targets take up %d bytes, data has %d", offset, len(data))
+	}
+
+	// table status: each "next" is relative to the start of the data
+	offset = 0
+	for range targets {
+		next := binary.NativeEndian.Uint32(data[offset+20:])
+		binary.NativeEndian.PutUint32(data[offset+20:], uint32(offset)+next)
+		offset += int(next)
+	}
+
+	got, err := unmarshalTargets(data, uint32(len(targets)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, targets) {
+		t.Errorf("unmarshaled %v, want %v", got, targets)
+	}
+}
+
+func TestMarshalTargetsRejectsLongType(t *testing.T) {
+	_, err := marshalTargets([]Target{{Length: 8, Type: "a-target-type-name"}})
+	if err == nil {
+		t.Error("type longer than the kernel's field was accepted")
+	}
+}
+
+func TestUnmarshalTargetsTruncated(t *testing.T) {
+	data, err := marshalTargets([]Target{{Length: 8, Type: "zero"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := unmarshalTargets(data[:targetSpecSize-1], 1); err == nil {
+		t.Error("truncated target spec was accepted")
+	}
+	if _, err := unmarshalTargets(data, 2); err == nil {
+		t.Error("missing target was accepted")
+	}
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Extracts the per-path state ("A" for active, "F" for failed) from the
+// output of "dmsetup status" on a multipath device, which looks like:
+//
+//	0 <size> multipath <#features> <features...> <#handler args> <handler args...>
+//	<#groups> <current group> { <group state> <#group args> <group args...>
+//	<#paths> <#selector args> { <device> <path state> <fail count> <selector args...> } }
+func ParseMultipathPathStates(status string) ([]string, error) {
+	fields := strings.Fields(status)
+	if len(fields) < 3 || fields[2] != "multipath" {
+		return nil, fmt.Errorf("unexpected dm status \"%s\"", status)
+	}
+	fields = fields[3:]
+
+	// consume a count followed by that many fields
+	next := func() (string, error) {
+		if len(fields) == 0 {
+			return "", fmt.Errorf("truncated dm status \"%s\"", status)
+		}
+		field := fields[0]
+		fields = fields[1:]
+		return field, nil
+	}
+	nextInt := func() (int, error) {
+		field, err := next()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(field)
+	}
+	skipCounted := func() error {
+		n, err := nextInt()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if _, err := next(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := skipCounted(); err != nil { // features
+		return nil, err
+	}
+	if err := skipCounted(); err != nil { // hardware handler
+		return nil, err
+	}
+	numGroups, err := nextInt()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := next(); err != nil { // current group
+		return nil, err
+	}
+
+	var states []string
+	for g := 0; g < numGroups; g++ {
+		if _, err := next(); err != nil { // group state
+			return nil, err
+		}
+		if err := skipCounted(); err != nil { // group args
+			return nil, err
+		}
+		numPaths, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		numSelectorArgs, err := nextInt()
+		if err != nil {
+			return nil, err
+		}
+		for p := 0; p < numPaths; p++ {
+			if _, err := next(); err != nil { // device
+				return nil, err
+			}
+			state, err := next()
+			if err != nil {
+				return nil, err
+			}
+			states = append(states, state)
+			if _, err := next(); err != nil { // fail count
+				return nil, err
+			}
+			for i := 0; i < numSelectorArgs; i++ {
+				if _, err := next(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return states, nil
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package devmapper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMultipathPathStates(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   []string
+	}{
+		{
+			// "dmsetup status" of a volume's upper device, whose single
+			// round-robin path is active
+			name:   "active",
+			status: "0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 253:5 A 0 ",
+			want:   []string{"A"},
+		},
+		{
+			// the same device after "fail_path" while queue_if_no_path
+			// is set, which queues I/O
+			name:   "failed and queueing",
+			status: "0 2097152 multipath 2 1 0 0 1 1 E 0 1 0 253:5 F 1 ",
+			want:   []string{"F"},
+		},
+		{
+			// multipathd's service-time selector reports two info args
+			// per path, in two priority groups
+			name:   "service-time groups",
+			status: "0 4194304 multipath 2 0 0 0 2 1 A 0 2 2 8:16 A 0 0 1 8:32 A 0 0 1 E 0 1 2 8:48 F 3 0 1 ",
+			want:   []string{"A", "A", "F"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMultipathPathStates(tt.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMultipathPathStatesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+	}{
+		{"not multipath", "0 2097152 linear 253:5 0"},
+		{"empty", ""},
+		{"truncated features", "0 2097152 multipath 2 0"},
+		{"truncated path", "0 2097152 multipath 2 0 0 0 1 1 A 0 1 0 253:5 A"},
+		{"missing group", "0 2097152 multipath 2 0 0 0 2 1 A 0 1 0 253:5 A 0"},
+		{"non-numeric path count", "0 2097152 multipath 2 0 0 0 1 1 A 0 x 0 253:5 A 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if states, err := ParseMultipathPathStates(tt.status); err == nil {
+				t.Errorf("got %v, want an error", states)
+			}
+		})
+	}
+}
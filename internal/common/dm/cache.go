@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/devmapper"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+)
+
+// Volumes can optionally be cached on node-local storage while attached to a
+// node.  The cache is a third device mapper object that sits between the
+// lower dm-linear layer and the LV:
+//
+//	upper (multipath) -> lower (linear) -> cache -> LV
+//
+// dm-writecache is used for writeback caching and dm-cache for writethrough
+// caching.  Its storage comes from LVs in a VG that is local to the node.
+// Since the cache holds the LV open and, in writeback mode, holds data that
+// has not reached the LV yet, it must be flushed and torn down before the LV
+// can be deactivated on this node and activated elsewhere.  Cached data is
+// never reused across attachments because the volume may have been written
+// by another node in the meantime.
+
+// Returns the path of the device to route I/O to for the given origin device,
+// which is the cache device wrapping origin if the cache can be set up on
+// this node, or origin itself if the node lacks the cache VG.
+func attachCache(ctx context.Context, name string, sizeBytes int64, origin string, cache *v1alpha1.VolumeCache) (string, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if exists, err := devmapper.Exists(cacheName(name)); err != nil {
+		return "", err
+	} else if exists {
+		return cacheDevicePath(name), nil
+	}
+
+	hasVg, err := lvm.VgExists(cache.VgName)
+	if err != nil {
+		return "", err
+	}
+	if !hasVg {
+		log.Info("Cache VG not found on this node, not caching volume", "volume", name, "cacheVgName", cache.VgName)
+		return origin, nil
+	}
+
+	// Any LVs left behind from an earlier attachment hold stale data.
+	if err := removeCacheLvs(cache.VgName, name); err != nil {
+		return "", err
+	}
+
+	var table string
+
+	switch cache.Mode {
+	case v1alpha1.VolumeCacheModeWriteback:
+		if err := createCacheLv(cache, cacheDataLvName(name), cache.SizeBytes); err != nil {
+			return "", err
+		}
+
+		// "s" selects SSD mode, as opposed to persistent memory
+		table = fmt.Sprintf("0 %d writecache s %s %s 4096 0",
+			sizeBytes/512, origin, cacheLvPath(cache, cacheDataLvName(name)))
+
+	case v1alpha1.VolumeCacheModeWritethrough:
+		if err := createCacheLv(cache, cacheMetadataLvName(name), cacheMetadataSizeBytes(cache.SizeBytes)); err != nil {
+			return "", err
+		}
+		if err := createCacheLv(cache, cacheDataLvName(name), cache.SizeBytes); err != nil {
+			return "", err
+		}
+
+		// 512 sector (256 KiB) cache blocks
+		table = fmt.Sprintf("0 %d cache %s %s %s 512 1 writethrough smq 0",
+			sizeBytes/512, cacheLvPath(cache, cacheMetadataLvName(name)),
+			cacheLvPath(cache, cacheDataLvName(name)), origin)
+
+	default:
+		return "", fmt.Errorf("invalid cache mode \"%s\"", cache.Mode)
+	}
+
+	err = createDevice(cacheName(name), table)
+	if errors.Is(err, devmapper.ErrExists) {
+		err = checkTable(cacheName(name), table)
+	}
+	if err != nil {
+		log.Error(err, "dm cache create failed")
+		_ = removeCacheLvs(cache.VgName, name)
+		return "", err
+	}
+
+	return cacheDevicePath(name), nil
+}
+
+// Write back any dirty data held by the cache to the LV.  Does nothing if the
+// volume is not cached on this node.
+func flushCache(ctx context.Context, name string) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	table, err := devmapper.Table(cacheName(name))
+	if errors.Is(err, devmapper.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		log.Error(err, "dm cache table failed")
+		return err
+	}
+
+	// dm-cache is only used in writethrough mode and never holds dirty data
+	if len(table) != 1 || table[0].Type != "writecache" {
+		return nil
+	}
+
+	// returns once all dirty data has been written back
+	err = devmapper.Message(cacheName(name), 0, "flush")
+	if err != nil {
+		log.Error(err, "dm cache flush failed")
+		return err
+	}
+
+	return nil
+}
+
+// Flush and tear down the cache.  Must only be called once the lower layer no
+// longer references the cache device.
+func detachCache(ctx context.Context, name string, cache *v1alpha1.VolumeCache) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if err := flushCache(ctx, name); err != nil {
+		return err
+	}
+
+	err := removeIdempotent(cacheName(name), false)
+	if err != nil {
+		log.Error(err, "dm cache remove failed")
+		return err
+	}
+
+	if cache == nil {
+		return nil
+	}
+
+	hasVg, err := lvm.VgExists(cache.VgName)
+	if err != nil || !hasVg {
+		return err
+	}
+
+	return removeCacheLvs(cache.VgName, name)
+}
+
+func createCacheLv(cache *v1alpha1.VolumeCache, lvName string, sizeBytes int64) error {
+	// zeroing the start of the LV makes the kernel format a fresh cache
+	return lvm.CreateLvIdempotent(cache.VgName, lvName, lvm.CreateLvOptions{
+		SizeBytes:  sizeBytes,
+		Activation: lvm.Activate,
+		Zero:       true,
+	})
+}
+
+func removeCacheLvs(vgName string, name string) error {
+	for _, lvName := range []string{cacheDataLvName(name), cacheMetadataLvName(name)} {
+		if err := lvm.RemoveLvIdempotent(vgName, lvName, lvm.RemoveLvOptions{Yes: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dm-cache metadata needs roughly 16 bytes per cache block plus a fixed
+// overhead, so 1/1000 of the cache with 256 KiB blocks is plenty.
+func cacheMetadataSizeBytes(cacheSizeBytes int64) int64 {
+	return max(8*1024*1024, cacheSizeBytes/1000)
+}
+
+func cacheName(name string) string {
+	return name + "-dm-cache"
+}
+
+func cacheDevicePath(name string) string {
+	return devmapper.DevicePath(cacheName(name))
+}
+
+func cacheDataLvName(name string) string {
+	return name + "-cache"
+}
+
+func cacheMetadataLvName(name string) string {
+	return name + "-cmeta"
+}
+
+func cacheLvPath(cache *v1alpha1.VolumeCache, lvName string) string {
+	return fmt.Sprintf("/dev/%s/%s", cache.VgName, lvName)
+}
@@ -4,151 +4,471 @@ package dm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
-	"k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
-	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/devmapper"
 )
 
 // This package provides the code for idempotent manipulation of
-// device mapper wrappers for thin volumes using dmsetup in the host
-// namespace.  Note that we need two devices per Volume.  This is
-// because "dmsetup suspend/dmsetup resume" is the only way to
+// device mapper wrappers for thin volumes in the host, using the
+// devmapper package.  Note that we need two devices per Volume.  This is
+// because suspending and resuming a device is the only way to
 // live-swap which underlying block device is dereferenced, however,
 // any userspace application doing IO to a dm device that is suspended
 // will block in D state until the resume.  Meanwhile, the only device
 // mapper object that can queue I/O without blocking the userspace
 // client is multipath (even if we are only using a single path),
-// using "dmsetup message" to fail or reinstate the underlying path as
+// using target messages to fail or reinstate the underlying path as
 // a faster way than waiting for the underlying storage to block.
 // Since we don't want userspace to block the upper layer has to be a
 // dm-multipath device that never suspends, but that means it can
 // never hot-swap the underlying device, so we also need a lower layer
 // dm-linear object that can hot-swap between direct LV access or
-// /dev/nbdX NBD client access.
+// /dev/nbdX NBD client access.  Optionally, a node-local cache and a
+// dm-integrity device can be inserted below the lower layer, see cache.go
+// and integrity.go.
 
 // Create the wrappers in the filesystem so that the device can be opened;
 // however, I/O to the device is not possible until Resume() is used.
 func Create(ctx context.Context, name string, sizeBytes int64) error {
 	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
 
-	// Use of --notable instead of zeroTable() is not portable to all
-	// versions of device-mapper.
-	_, err := commands.DmsetupCreateIdempotent(lowerName(name), "--table", zeroTable(sizeBytes), "--addnodeoncreate")
+	// The lower device may already exist and point at the LV or NBD, so
+	// only its size has to match.  A table is needed at all since a
+	// device without one cannot be referenced by the upper device.
+	err := createDevice(lowerName(name), zeroTable(sizeBytes))
+	if errors.Is(err, devmapper.ErrExists) {
+		err = checkSize(lowerName(name), sizeBytes)
+	}
 	if err != nil {
 		log.Error(err, "dm lower create failed")
 		return err
 	}
 
-	// At least with device-mapper 1.02.199, we have to avoid udev to
-	// not hang, at which point not even --addnodeoncreate will create
-	// a /dev/mapper entry without a separate mknodes call.  Thankfully,
-	// our use of the device does not depend on udev.
-	_, err = commands.DmsetupCreateIdempotent(upperName(name), "--table", upperTable(sizeBytes, name), "--noudevsync")
+	// The upper device never changes its table, so an existing one must
+	// match.  Its node is created here rather than by udev, which our use
+	// of the device does not depend on.
+	err = createDevice(upperName(name), upperTable(sizeBytes, name))
+	if errors.Is(err, devmapper.ErrExists) {
+		err = checkTable(upperName(name), upperTable(sizeBytes, name))
+	}
 	if err != nil {
 		log.Error(err, "dm upper create failed")
-		_, _ = commands.DmsetupRemoveIdempotent(lowerName(name))
+		_ = devmapper.Remove(lowerName(name))
 		return err
 	}
 
-	_, err = commands.Dmsetup("mknodes", upperName(name))
+	return nil
+}
+
+// Create a device from a table in the format of dmsetup(8).
+func createDevice(name string, table string) error {
+	targets, err := devmapper.ParseTable(table)
 	if err != nil {
-		log.Error(err, "dm upper mknodes failed")
-		_ = Remove(ctx, name)
 		return err
 	}
+	return devmapper.Create(name, targets)
+}
 
-	// Sanity check that the filesystem devices exist in /dev/mapper now,
-	// useful since dmsetup does not accept / in its device names.
-	exists, err := commands.PathExistsOnHost(GetDevicePath(name))
-	if err == nil && !exists {
-		err = errors.NewBadRequest("missing expected dm mapper path")
-	}
+// Check that an existing device has the same layout as the table, see
+// devmapper.SameLayout(), and that its node exists.
+func checkTable(name string, table string) error {
+	targets, err := devmapper.ParseTable(table)
 	if err != nil {
-		log.Error(err, "dm mknode failed")
-		_ = Remove(ctx, name)
 		return err
 	}
+	return devmapper.CreateIdempotent(name, targets)
+}
 
+// Check that an existing device has the given size, whatever it maps to.
+func checkSize(name string, sizeBytes int64) error {
+	targets, err := devmapper.Table(name)
+	if err != nil {
+		return err
+	}
+	if len(targets) != 1 || targets[0].Start != 0 || targets[0].Length != uint64(sizeBytes/512) {
+		return fmt.Errorf("dm device \"%s\" has table %v instead of %d sectors: %w", name, targets, sizeBytes/512, devmapper.ErrTableMismatch)
+	}
 	return nil
 }
 
+// Replace the table of a suspended device with a table in the format of
+// dmsetup(8) and resume it.
+func loadAndResume(name string, table string) error {
+	targets, err := devmapper.ParseTable(table)
+	if err != nil {
+		return err
+	}
+	if err := devmapper.Load(name, targets); err != nil {
+		return err
+	}
+	return devmapper.Resume(name)
+}
+
+// Suspend a device, doing nothing if it does not exist.
+func suspendIdempotent(name string, options devmapper.SuspendOptions) error {
+	err := devmapper.Suspend(name, options)
+	if errors.Is(err, devmapper.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Remove a device, doing nothing if it does not exist.
+func removeIdempotent(name string, force bool) error {
+	var err error
+	if force {
+		err = devmapper.ForceRemove(name)
+	} else {
+		err = devmapper.Remove(name)
+	}
+	if errors.Is(err, devmapper.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Optional per-node layers between the lower device and the LV.
+type Layers struct {
+	// Cache the volume on node-local storage, see cache.go.
+	Cache *v1alpha1.VolumeCache
+
+	// Verify checksums of the LV's data, see integrity.go.
+	Integrity bool
+}
+
 // Suspend the device by queuing I/O until the next Resume.  Do not try
-// to sync any filesystem if the device is block storage.
-func Suspend(ctx context.Context, name string, skipSync bool) error {
+// to sync any filesystem if the device is block storage.  Any layers below
+// the lower device are torn down, flushing the cache first, so that the LV
+// is no longer in use and can be activated elsewhere.
+func Suspend(ctx context.Context, name string, skipSync bool, layers Layers) error {
 	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
 
-	if exists, err := commands.PathExistsOnHost(GetDevicePath(name)); err == nil && exists {
-		_, err := commands.Dmsetup("message", upperName(name), "0", "fail_path /dev/mapper/"+lowerName(name))
+	if exists, err := devmapper.Exists(upperName(name)); err == nil && exists {
+		err := devmapper.Message(upperName(name), 0, "fail_path "+devmapper.DevicePath(lowerName(name)))
 		if err != nil {
 			log.Error(err, "dm upper suspend failed")
 			return err
 		}
 
-		args := []string{lowerName(name)}
-		if skipSync {
-			args = append(args, "--nolockfs")
-		}
-		_, err = commands.DmsetupSuspendIdempotent(args...)
+		err = suspendIdempotent(lowerName(name), devmapper.SuspendOptions{SkipLockfs: skipSync})
 		if err != nil {
 			log.Error(err, "dm lower suspend failed")
 			return err
 		}
+
+		if err := removeLayersFromLower(ctx, name, layers); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Resume I/O on the volume, as routed through devPath.
-func Resume(ctx context.Context, name string, sizeBytes int64, devPath string) error {
+// With the lower device suspended, flush the cache and point the lower
+// device at an error target instead, which is safe because the upper device
+// queues all I/O while its path is failed.  The layers below can then be torn
+// down.
+func removeLayersFromLower(ctx context.Context, name string, layers Layers) error {
 	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
 
-	_, err := commands.Dmsetup("load", lowerName(name), "--table", lowerTable(sizeBytes, devPath))
+	hasCache, err := devmapper.Exists(cacheName(name))
+	if err != nil {
+		return err
+	}
+	hasIntegrity, err := devmapper.Exists(integrityName(name))
 	if err != nil {
+		return err
+	}
+	if !hasCache && !hasIntegrity {
+		return nil
+	}
+
+	// flush while in-flight I/O has drained and before any handoff
+	if err := flushCache(ctx, name); err != nil {
+		return err
+	}
+
+	if err := loadErrorTable(lowerName(name)); err != nil {
 		log.Error(err, "dm lower load failed")
 		return err
 	}
 
-	_, err = commands.Dmsetup("resume", lowerName(name))
+	if err := detachLayers(ctx, name, layers); err != nil {
+		return err
+	}
+
+	err = suspendIdempotent(lowerName(name), devmapper.SuspendOptions{})
 	if err != nil {
-		log.Error(err, "dm lower resume failed")
+		log.Error(err, "dm lower suspend failed")
 		return err
 	}
 
-	_, err = commands.Dmsetup("message", upperName(name), "0", "reinstate_path /dev/mapper/"+lowerName(name))
+	return nil
+}
+
+// Resume I/O on the volume, as routed through devPath.  If layers are given
+// then devPath must be a local LV, and the layers are set up on top of it.
+func Resume(ctx context.Context, name string, sizeBytes int64, devPath string, layers Layers) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	var err error
+
+	if layers.Integrity {
+		devPath, err = attachIntegrity(ctx, name, sizeBytes, devPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if layers.Cache != nil {
+		devPath, err = attachCache(ctx, name, sizeBytes, devPath, layers.Cache)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = loadAndResume(lowerName(name), lowerTable(sizeBytes, devPath))
+	if err != nil {
+		log.Error(err, "dm lower load failed")
+		return err
+	}
+
+	err = devmapper.Message(upperName(name), 0, "reinstate_path "+devmapper.DevicePath(lowerName(name)))
 	if err != nil {
 		log.Error(err, "dm upper resume failed")
 		return err
 	}
 
+	// queue again in case FailQueuedIO() or Fence() stopped it
+	err = devmapper.Message(upperName(name), 0, "queue_if_no_path")
+	if err != nil {
+		log.Error(err, "dm upper queue_if_no_path failed")
+		return err
+	}
+
+	return nil
+}
+
+// Queue I/O on the volume until Unquiesce, without tearing down anything
+// below the lower device.  Writes that were already issued reach the LV
+// before this returns, so that a snapshot of the LV holds all of them.
+func Quiesce(ctx context.Context, name string) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if exists, err := devmapper.Exists(upperName(name)); err != nil || !exists {
+		return err
+	}
+
+	err := devmapper.Message(upperName(name), 0, "fail_path "+devmapper.DevicePath(lowerName(name)))
+	if err != nil {
+		log.Error(err, "dm upper quiesce failed")
+		return err
+	}
+
+	err = suspendIdempotent(lowerName(name), devmapper.SuspendOptions{})
+	if err != nil {
+		log.Error(err, "dm lower quiesce failed")
+		return err
+	}
+
 	return nil
 }
 
-// Tear down the wrappers.  Should only be called when the device is not in use.
-func Remove(ctx context.Context, name string) error {
+// Resume I/O on a volume quiesced with Quiesce.
+func Unquiesce(ctx context.Context, name string) error {
 	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
 
-	// --force is necessary to make udev see EIO instead of hanging
-	_, err := commands.DmsetupRemoveIdempotent("--force", upperName(name))
+	if exists, err := devmapper.Exists(upperName(name)); err != nil || !exists {
+		return err
+	}
+
+	err := devmapper.Resume(lowerName(name))
+	if err != nil {
+		log.Error(err, "dm lower unquiesce failed")
+		return err
+	}
+
+	err = devmapper.Message(upperName(name), 0, "reinstate_path "+devmapper.DevicePath(lowerName(name)))
+	if err != nil {
+		log.Error(err, "dm upper unquiesce failed")
+		return err
+	}
+
+	err = devmapper.Message(upperName(name), 0, "queue_if_no_path")
+	if err != nil {
+		log.Error(err, "dm upper queue_if_no_path failed")
+		return err
+	}
+
+	return nil
+}
+
+// Fail the I/O queued on the volume, and any further I/O, until the next
+// Resume or Unquiesce.  Used once the path has been failed for longer than
+// the volume's no-path timeout.
+func FailQueuedIO(ctx context.Context, name string) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if exists, err := devmapper.Exists(upperName(name)); err != nil || !exists {
+		return err
+	}
+
+	err := devmapper.Message(upperName(name), 0, "fail_if_no_path")
+	if err != nil {
+		log.Error(err, "dm upper fail_if_no_path failed")
+		return err
+	}
+
+	return nil
+}
+
+// Fence the volume on this node by pointing the lower device at an error
+// target and failing all I/O, including I/O that is queued or in flight, so
+// that nothing reaches the LV from this node any more.  The layers below the
+// lower device are left alone since they may not respond.  Resume undoes
+// this.
+func Fence(ctx context.Context, name string) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	state, err := GetPathState(ctx, name)
+	if err != nil || state == "" || state == v1alpha1.VolumePathStateFenced {
+		return err
+	}
+
+	err = devmapper.Message(upperName(name), 0, "fail_path "+devmapper.DevicePath(lowerName(name)))
+	if err != nil {
+		log.Error(err, "dm upper fence failed")
+		return err
+	}
+
+	// NoFlush pushes in-flight I/O back instead of waiting for storage
+	// that may never complete it, and it then fails against the error
+	// target
+	err = suspendIdempotent(lowerName(name), devmapper.SuspendOptions{SkipLockfs: true, NoFlush: true})
+	if err != nil {
+		log.Error(err, "dm lower suspend failed")
+		return err
+	}
+
+	if err := loadErrorTable(lowerName(name)); err != nil {
+		log.Error(err, "dm lower load failed")
+		return err
+	}
+
+	err = devmapper.Message(upperName(name), 0, "fail_if_no_path")
+	if err != nil {
+		log.Error(err, "dm upper fail_if_no_path failed")
+		return err
+	}
+
+	return nil
+}
+
+// Tear down the wrappers, including any layers below them.  Should only be
+// called when the device is not in use.
+func Remove(ctx context.Context, name string, layers Layers) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	// forcing is necessary to make udev see EIO instead of hanging
+	err := removeIdempotent(upperName(name), true)
 	if err != nil {
 		log.Error(err, "dm upper remove failed")
 		return err
 	}
 
-	_, err = commands.DmsetupRemoveIdempotent(lowerName(name))
+	err = removeIdempotent(lowerName(name), false)
 	if err != nil {
 		log.Error(err, "dm lower remove failed")
 		return err
 	}
 
-	return nil
+	return detachLayers(ctx, name, layers)
+}
+
+// Tear down the layers below the lower device, from the top down.
+func detachLayers(ctx context.Context, name string, layers Layers) error {
+	if err := detachCache(ctx, name, layers.Cache); err != nil {
+		return err
+	}
+
+	return detachIntegrity(ctx, name)
+}
+
+// Returns the state of the upper device's path to the lower device, or "" if
+// the upper device does not exist.  The path fails because of Suspend(),
+// Quiesce() or Fence(), or because the kernel saw an I/O error.
+func GetPathState(ctx context.Context, name string) (v1alpha1.VolumePathState, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if exists, err := devmapper.Exists(upperName(name)); err != nil || !exists {
+		return "", err
+	}
+
+	status, err := devmapper.Status(upperName(name))
+	if err != nil || len(status) != 1 {
+		log.Error(err, "dm upper status failed")
+		return "", fmt.Errorf("unexpected dm status %v: %w", status, err)
+	}
+
+	states, err := devmapper.ParseMultipathPathStates(status[0].String())
+	if err != nil {
+		log.Error(err, "dm upper status parse failed")
+		return "", err
+	}
+
+	if !slices.ContainsFunc(states, func(state string) bool { return state != "A" }) {
+		return v1alpha1.VolumePathStateActive, nil
+	}
+
+	// unlike the status, the table reflects queue_if_no_path and
+	// fail_if_no_path messages
+	upper, err := devmapper.Table(upperName(name))
+	if err != nil {
+		log.Error(err, "dm upper table failed")
+		return "", err
+	}
+	if len(upper) == 1 && slices.Contains(strings.Fields(upper[0].Params), "queue_if_no_path") {
+		return v1alpha1.VolumePathStateQueueing, nil
+	}
+
+	lower, err := devmapper.Table(lowerName(name))
+	if err != nil {
+		log.Error(err, "dm lower table failed")
+		return "", err
+	}
+	if len(lower) == 1 && lower[0].Type == "error" {
+		return v1alpha1.VolumePathStateFenced, nil
+	}
+	return v1alpha1.VolumePathStateFailing, nil
+}
+
+// Point a suspended device at an error target of the same size and resume it.
+func loadErrorTable(name string) error {
+	targets, err := devmapper.Table(name)
+	if err != nil {
+		return err
+	}
+	if len(targets) != 1 {
+		return fmt.Errorf("unexpected dm table %v", targets)
+	}
+
+	err = devmapper.Load(name, []devmapper.Target{{Start: 0, Length: targets[0].Length, Type: "error"}})
+	if err != nil {
+		return err
+	}
+	return devmapper.Resume(name)
 }
 
 func GetDevicePath(name string) string {
-	return "/dev/mapper/" + upperName(name)
+	return devmapper.DevicePath(upperName(name))
 }
 
 func lowerName(name string) string {
@@ -168,5 +488,5 @@ func lowerTable(sizeBytes int64, device string) string {
 }
 
 func upperTable(sizeBytes int64, name string) string {
-	return fmt.Sprintf("0 %d multipath 3 queue_if_no_path queue_mode bio 0 1 1 round-robin 0 1 0 /dev/mapper/%s", sizeBytes/512, lowerName(name))
+	return fmt.Sprintf("0 %d multipath 3 queue_if_no_path queue_mode bio 0 1 1 round-robin 0 1 0 %s", sizeBytes/512, devmapper.DevicePath(lowerName(name)))
 }
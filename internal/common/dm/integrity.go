@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/devmapper"
+	"gitlab.com/kubesan/kubesan/internal/common/integrity"
+)
+
+// Volumes can optionally be checksummed with dm-integrity.  The integrity
+// device sits directly on top of the LV so that everything between it and
+// the disk is covered:
+//
+//	upper (multipath) -> lower (linear) -> [cache ->] integrity -> LV
+//
+// The LV must already have been formatted with the integrity package, and
+// holds the checksums in addition to sizeBytes of data.  Like the cache, the
+// integrity device holds the LV open and must be torn down before the LV can
+// be activated elsewhere.
+
+// Returns the path of the integrity device wrapping origin, creating it if
+// necessary.
+func attachIntegrity(ctx context.Context, name string, sizeBytes int64, origin string) (string, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	// "B" selects bitmap mode, "recalculate" resumes computing the
+	// checksums of a freshly formatted LV, if not done yet, and
+	// "allow_discards" lets reclaimed space reach the thin pool
+	table := fmt.Sprintf("0 %d integrity %s 0 %d B 4 block_size:%d internal_hash:%s recalculate allow_discards",
+		sizeBytes/512, origin, integrity.TagSizeBytes, integrity.BlockSizeBytes, integrity.Algorithm)
+
+	err := createDevice(integrityName(name), table)
+	if errors.Is(err, devmapper.ErrExists) {
+		err = checkTable(integrityName(name), table)
+	}
+	if err != nil {
+		log.Error(err, "dm integrity create failed")
+		return "", err
+	}
+
+	return integrityDevicePath(name), nil
+}
+
+// Tear down the integrity device if it exists.  Must only be called once the
+// layers above no longer reference it.
+func detachIntegrity(ctx context.Context, name string) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	err := removeIdempotent(integrityName(name), false)
+	if err != nil {
+		log.Error(err, "dm integrity remove failed")
+		return err
+	}
+
+	return nil
+}
+
+// Returns the number of checksum mismatches seen since the integrity device
+// was created on this node, or -1 if the device does not exist.
+func IntegrityMismatches(ctx context.Context, name string) (int64, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	status, err := devmapper.Status(integrityName(name))
+	if errors.Is(err, devmapper.ErrNotFound) {
+		return -1, nil
+	} else if err != nil {
+		log.Error(err, "dm integrity status failed")
+		return -1, err
+	}
+
+	// <mismatches> <provided data sectors> <recalc sector>
+	if len(status) != 1 || status[0].Type != "integrity" {
+		return -1, fmt.Errorf("unexpected dm status %v", status)
+	}
+	fields := strings.Fields(status[0].Params)
+	if len(fields) < 1 {
+		return -1, fmt.Errorf("unexpected dm status %v", status)
+	}
+
+	return strconv.ParseInt(fields[0], 10, 64)
+}
+
+func integrityName(name string) string {
+	return name + "-dm-integrity"
+}
+
+func integrityDevicePath(name string) string {
+	return devmapper.DevicePath(integrityName(name))
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fsfreeze
+
+import (
+	"context"
+	"strings"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package freezes and thaws the file systems on a block device with
+// fsfreeze(8) in the host namespace. While a file system is frozen, writes to
+// it block and its on-disk state is consistent.
+
+// Returns the mount points of the file systems on the device or on devices
+// stacked on top of it, like a LUKS device. Each file system is only listed
+// once, even if it is mounted in several places.
+func MountPoints(ctx context.Context, devPath string) ([]string, error) {
+	output, err := commands.RunOnHostContext(ctx, "lsblk", "--noheadings", "--list", "--output", "MOUNTPOINT", devPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mountPoints []string
+	for _, line := range strings.Split(string(output.Combined), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "/") {
+			mountPoints = append(mountPoints, line)
+		}
+	}
+	return mountPoints, nil
+}
+
+// Freezes all given file systems. If one of them cannot be frozen, the ones
+// frozen so far are thawed again.
+func Freeze(ctx context.Context, mountPoints []string) error {
+	for i, mountPoint := range mountPoints {
+		if _, err := commands.RunOnHostContext(ctx, "fsfreeze", "--freeze", mountPoint); err != nil {
+			_ = Thaw(context.Background(), mountPoints[:i+1])
+			return err
+		}
+	}
+	return nil
+}
+
+// Thaws all given file systems. File systems that are not frozen are skipped.
+func Thaw(ctx context.Context, mountPoints []string) error {
+	var firstErr error
+	for _, mountPoint := range mountPoints {
+		_, err := commands.RunOnHostContext(ctx, "fsfreeze", "--unfreeze", mountPoint)
+		if err != nil && !strings.Contains(err.Error(), "Invalid argument") && firstErr == nil {
+			firstErr = err // not frozen yields EINVAL
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fstrim
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package returns space freed inside file systems to the storage below
+// them. fstrim(8) runs in the host namespace, where the file systems are
+// mounted, and the kernel's block device statistics tell how much has been
+// discarded in total, whether by fstrim or by the "discard" mount option.
+
+var trimmedRegexp = regexp.MustCompile(`\((\d+) bytes\) trimmed`)
+
+// Discards the unused blocks of all given file systems and returns the number
+// of bytes discarded.
+func Trim(ctx context.Context, mountPoints []string) (int64, error) {
+	var total int64
+	for _, mountPoint := range mountPoints {
+		output, err := commands.RunOnHostContext(ctx, "fstrim", "--verbose", mountPoint)
+		if err != nil {
+			return total, err
+		}
+
+		match := trimmedRegexp.FindSubmatch(output.Combined)
+		if match == nil {
+			return total, fmt.Errorf("unexpected fstrim output \"%s\"", strings.TrimSpace(string(output.Combined)))
+		}
+
+		bytes, err := strconv.ParseInt(string(match[1]), 10, 64)
+		if err != nil {
+			return total, err
+		}
+		total += bytes
+	}
+	return total, nil
+}
+
+// Returns the number of bytes discarded on a block device since it was
+// created.
+func DiscardedBytes(devicePathOnHost string) (int64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path.Join("/proc/1/root", devicePathOnHost), &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat \"%s\": %w", devicePathOnHost, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, fmt.Errorf("\"%s\" is not a block device", devicePathOnHost)
+	}
+
+	statPath := fmt.Sprintf("/proc/1/root/sys/dev/block/%d:%d/stat",
+		unix.Major(uint64(stat.Rdev)), unix.Minor(uint64(stat.Rdev)))
+	data, err := os.ReadFile(statPath)
+	if err != nil {
+		return 0, err
+	}
+
+	// see Documentation/block/stat.rst, field 14 counts discarded sectors
+	fields := strings.Fields(string(data))
+	if len(fields) < 14 {
+		return 0, fmt.Errorf("\"%s\" has no discard statistics", statPath)
+	}
+
+	sectors, err := strconv.ParseInt(fields[13], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse \"%s\": %w", statPath, err)
+	}
+	return sectors * 512, nil
+}
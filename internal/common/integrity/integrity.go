@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integrity
+
+import (
+	"context"
+	"log"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package manages the on-disk metadata of standalone dm-integrity
+// devices using integritysetup in the node manager's container. The device
+// mapper object itself is created alongside the other per-volume wrappers,
+// see the dm package.
+//
+// Every 4 KiB block gets a crc32c checksum that is verified on each read, so
+// corruption anywhere between the node and the disk becomes an I/O error
+// instead of bad data. Bitmap mode is used rather than a journal to avoid
+// writing all data twice; after a crash, the checksums of regions that were
+// being written are recalculated.
+
+const (
+	BlockSizeBytes = 4096
+	TagSizeBytes   = 4
+	Algorithm      = "crc32c"
+)
+
+// Returns the extra space a device needs for integrity metadata in order to
+// provide dataBytes of data. This is an overestimate: checksums take up
+// 4 bytes per 4 KiB block and the superblock and bitmap are small.
+func OverheadBytes(dataBytes int64) int64 {
+	overhead := dataBytes/512 + 64*1024*1024
+	return (overhead + BlockSizeBytes - 1) / BlockSizeBytes * BlockSizeBytes
+}
+
+func integritysetup(ctx context.Context, args ...string) (commands.Output, error) {
+	log.Printf("integritysetup command: %v", args)
+	return commands.RunInContainerContext(ctx, append([]string{"integritysetup"}, args...)...)
+}
+
+// Returns true if device has a dm-integrity superblock.
+func IsFormatted(ctx context.Context, device string) (bool, error) {
+	output, err := integritysetup(ctx, "dump", device)
+	if err != nil {
+		if output.ExitCode == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Write a new dm-integrity superblock to device, destroying any existing
+// integrity metadata. The data area is not wiped, so the checksums must be
+// recalculated when the device is first activated.
+func Format(ctx context.Context, device string) error {
+	_, err := integritysetup(ctx,
+		"format",
+		"--batch-mode",
+		"--no-wipe",
+		"--integrity", Algorithm,
+		"--tag-size", "4",
+		"--sector-size", "4096",
+		device,
+	)
+	return err
+}
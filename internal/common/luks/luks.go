@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package luks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package provides idempotent manipulation of dm-crypt/LUKS mappings
+// layered on top of a volume's device using cryptsetup in the CSI node
+// plugin's container. Passphrases are handed to cryptsetup through pipes so
+// they never appear on a command line or in a file.
+//
+// Changing the passphrase only rewrites a LUKS key slot; the volume key that
+// the data is encrypted with stays the same. Thin snapshots and clones carry
+// a copy of the LUKS header and are therefore unlocked by the passphrase that
+// was current when they were taken.
+
+const (
+	// Space reserved for the LUKS2 header at the start of the volume.
+	HeaderSizeBytes = 16 * 1024 * 1024
+
+	// Secret keys under which passphrases are stored. During key rotation
+	// the previous passphrase is used to unlock the volume and is then
+	// replaced by the new one.
+	PassphraseKey         = "passphrase"
+	PreviousPassphraseKey = "previousPassphrase"
+
+	// cryptsetup exit code for a passphrase that does not unlock any key slot
+	exitCodeNoPermission = 2
+)
+
+var (
+	ErrWrongPassphrase = errors.New("passphrase does not match any LUKS key slot")
+)
+
+// Returns the name of the Secret holding a KMS-managed passphrase for the
+// given Volume or Snapshot.
+func KeySecretName(name string) string {
+	return name + "-luks"
+}
+
+func GetDevicePath(name string) string {
+	return "/dev/mapper/" + mappingName(name)
+}
+
+func mappingName(name string) string {
+	return name + "-luks"
+}
+
+func cryptsetup(ctx context.Context, inputs [][]byte, args ...string) (commands.Output, error) {
+	log.Printf("cryptsetup command: %v", args)
+	return commands.RunInContainerWithInputsContext(ctx, inputs, append([]string{"cryptsetup"}, args...)...)
+}
+
+// Returns true if device has a LUKS header.
+func IsLuks(ctx context.Context, device string) (bool, error) {
+	output, err := cryptsetup(ctx, nil, "isLuks", device)
+	if err != nil {
+		if output.ExitCode == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Write a new LUKS header to device, destroying any existing header.
+func Format(ctx context.Context, device string, passphrase []byte) error {
+	_, err := cryptsetup(ctx, [][]byte{passphrase},
+		"luksFormat",
+		"--batch-mode",
+		"--type", "luks2",
+		"--key-file", "/dev/fd/3",
+		device,
+	)
+	return err
+}
+
+// Create the decrypted mapping for device unless it already exists. Returns
+// ErrWrongPassphrase if the passphrase does not unlock the device.
+func Open(ctx context.Context, device string, name string, passphrase []byte, allowDiscards bool) error {
+	if _, err := os.Stat(GetDevicePath(name)); err == nil {
+		return nil
+	}
+
+	args := []string{
+		"open",
+		"--type", "luks",
+		"--key-file", "/dev/fd/3",
+	}
+	if allowDiscards {
+		// reveals which blocks are unused, so only when reclaiming space
+		args = append(args, "--allow-discards")
+	}
+	args = append(args, device, mappingName(name))
+
+	output, err := cryptsetup(ctx, [][]byte{passphrase}, args...)
+	if err != nil && output.ExitCode == exitCodeNoPermission {
+		return ErrWrongPassphrase
+	}
+	return err
+}
+
+// Replace the key slot unlocked by oldPassphrase with one for newPassphrase.
+// The data does not need to be re-encrypted.
+func ChangePassphrase(ctx context.Context, device string, oldPassphrase []byte, newPassphrase []byte) error {
+	output, err := cryptsetup(ctx, [][]byte{oldPassphrase, newPassphrase},
+		"luksChangeKey",
+		"--batch-mode",
+		"--key-file", "/dev/fd/3",
+		device,
+		"/dev/fd/4",
+	)
+	if err != nil && output.ExitCode == exitCodeNoPermission {
+		return ErrWrongPassphrase
+	}
+	return err
+}
+
+// Remove the decrypted mapping if it exists. Should only be called when the
+// mapping is not in use.
+func Close(ctx context.Context, name string) error {
+	if _, err := os.Stat(GetDevicePath(name)); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	_, err := cryptsetup(ctx, nil, "close", mappingName(name))
+	return err
+}
@@ -0,0 +1,343 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type LvType string
+
+const (
+	LvTypeLinear   LvType = "linear"
+	LvTypeRaid1    LvType = "raid1"
+	LvTypeThinPool LvType = "thin-pool"
+	LvTypeThin     LvType = "thin"
+	LvTypeVdo      LvType = "vdo"
+)
+
+// The argument of "--activate". In a shared VG, "y" is the same as "ey".
+type ActivationMode string
+
+const (
+	Activate          ActivationMode = "y"
+	ActivateShared    ActivationMode = "sy"
+	ActivateExclusive ActivationMode = "ey"
+	Deactivate        ActivationMode = "n"
+)
+
+// Options for CreateLv. Sizes are rounded up to whole extents by LVM.
+type CreateLvOptions struct {
+	// Empty to let LVM choose, e.g. for thin snapshots
+	Type LvType
+
+	SizeBytes int64
+
+	// The size of a thin or VDO LV, which may exceed its pool's size
+	VirtualSizeBytes int64
+
+	// The thin pool of a thin LV or thin snapshot
+	ThinPool string
+
+	// Creates a thin snapshot of this LV, which is an external origin if
+	// ThinPool is set
+	SnapshotOrigin string
+
+	// The name of the VDO pool LV created along with a VDO LV
+	VdoPool string
+
+	// Only for LvTypeVdo
+	Compression   bool
+	Deduplication bool
+
+	// Restricts allocation to the PVs with these tags
+	PvTags []string
+
+	// Empty for LVM's default, which activates most LV types
+	Activation ActivationMode
+
+	MetadataProfile string
+
+	// Zeroes the start of the LV without wiping signatures, which LVM only
+	// does for active LVs
+	Zero bool
+
+	// Clears the activation skip flag that LVM sets on thin snapshots
+	NoActivationSkip bool
+
+	// Makes a thin snapshot of a read-only LV writable
+	ReadWrite bool
+}
+
+// Creates an LV. Fails with ErrAlreadyExists if an LV with the name exists,
+// or ErrInsufficientSpace if the VG is too full.
+func CreateLv(vgName string, lvName string, options CreateLvOptions) error {
+	args := []string{
+		"lvcreate",
+		"--devicesfile", vgName,
+		"--name", lvName,
+	}
+
+	if options.Type != "" {
+		args = append(args, "--type", string(options.Type))
+	}
+	if options.SizeBytes != 0 {
+		args = append(args, "--size", fmt.Sprintf("%db", options.SizeBytes))
+	}
+	if options.VirtualSizeBytes != 0 {
+		args = append(args, "--virtualsize", fmt.Sprintf("%db", options.VirtualSizeBytes))
+	}
+	if options.ThinPool != "" {
+		args = append(args, "--thinpool", options.ThinPool)
+	}
+	if options.SnapshotOrigin != "" {
+		args = append(args, "--snapshot")
+	}
+	if options.Type == LvTypeVdo {
+		args = append(args,
+			"--compression", yesNo(options.Compression),
+			"--deduplication", yesNo(options.Deduplication),
+		)
+	}
+	if options.Activation != "" {
+		args = append(args, "--activate", string(options.Activation))
+	}
+	if options.MetadataProfile != "" {
+		args = append(args, "--metadataprofile", options.MetadataProfile)
+	}
+	if options.Zero {
+		args = append(args, "--zero", "y", "--wipesignatures", "n")
+	}
+	if options.NoActivationSkip {
+		args = append(args, "--setactivationskip", "n")
+	}
+	if options.ReadWrite {
+		args = append(args, "--permission", "rw")
+	}
+
+	switch {
+	case options.SnapshotOrigin != "":
+		args = append(args, vgLvName(vgName, options.SnapshotOrigin))
+	case options.VdoPool != "":
+		args = append(args, vgLvName(vgName, options.VdoPool))
+	default:
+		args = append(args, vgName)
+	}
+	for _, tag := range options.PvTags {
+		args = append(args, "@"+tag)
+	}
+
+	_, err := run(args...)
+	return err
+}
+
+// Like CreateLv, but succeeds if an LV with the name already exists. Its
+// type and size are not checked.
+func CreateLvIdempotent(vgName string, lvName string, options CreateLvOptions) error {
+	err := CreateLv(vgName, lvName, options)
+	if errors.Is(err, ErrAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// Options for ConvertLv. Only one conversion can be requested at a time.
+type ConvertLvOptions struct {
+	// Converts the LV to a RAID1 LV with this many additional legs, e.g.
+	// a linear LV when adding the first one
+	Mirrors int
+
+	// Adds dm-integrity checksums to each leg of a RAID LV
+	RaidIntegrity bool
+
+	// Restricts allocation of new legs to the PVs with these tags
+	PvTags []string
+}
+
+// Converts an LV. New RAID legs are synchronized from the existing ones when
+// the LV is next activated.
+func ConvertLv(vgName string, lvName string, options ConvertLvOptions) error {
+	args := []string{"lvconvert", "--devicesfile", vgName, "--yes"}
+
+	if options.Mirrors != 0 {
+		args = append(args, "--type", string(LvTypeRaid1), "--mirrors", fmt.Sprint(options.Mirrors))
+	}
+	if options.RaidIntegrity {
+		args = append(args, "--raidintegrity", "y")
+	}
+
+	args = append(args, vgLvName(vgName, lvName))
+	for _, tag := range options.PvTags {
+		args = append(args, "@"+tag)
+	}
+
+	_, err := run(args...)
+	return err
+}
+
+// Options for RemoveLv
+type RemoveLvOptions struct {
+	// Removes the LV even if it is active, instead of failing because
+	// there is no one to confirm
+	Yes bool
+}
+
+// Removes an LV. Fails with ErrNotFound if it does not exist, or ErrInUse if it
+// is open.
+func RemoveLv(vgName string, lvName string, options RemoveLvOptions) error {
+	args := []string{"lvremove", "--devicesfile", vgName}
+	if options.Yes {
+		args = append(args, "--yes")
+	}
+	args = append(args, vgLvName(vgName, lvName))
+
+	_, err := run(args...)
+	return err
+}
+
+// Like RemoveLv, but succeeds if the LV does not exist.
+func RemoveLvIdempotent(vgName string, lvName string, options RemoveLvOptions) error {
+	err := RemoveLv(vgName, lvName, options)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Activates an LV on this node, or deactivates it with Deactivate. Activation
+// fails with ErrLockConflict if the mode is incompatible with the LV's
+// activation on other nodes, and deactivation fails with ErrInUse if the LV is
+// open. Both succeed if the LV already is in the requested state.
+func ActivateLv(vgName string, lvName string, mode ActivationMode) error {
+	_, err := run("lvchange", "--devicesfile", vgName, "--activate", string(mode), vgLvName(vgName, lvName))
+	return err
+}
+
+// Same as ActivateLv(vgName, lvName, Deactivate).
+func DeactivateLv(vgName string, lvName string) error {
+	return ActivateLv(vgName, lvName, Deactivate)
+}
+
+// Reloads an active LV's device-mapper tables from the metadata, e.g. after
+// another node changed the LV.
+func RefreshLv(vgName string, lvName string) error {
+	_, err := run("lvchange", "--devicesfile", vgName, "--refresh", vgLvName(vgName, lvName))
+	return err
+}
+
+// Options for ChangeLv. Empty fields are left unchanged.
+type ChangeLvOptions struct {
+	MetadataProfile string
+
+	// How a thin pool handles discards: "ignore", "nopassdown" or
+	// "passdown"
+	Discards string
+}
+
+func ChangeLv(vgName string, lvName string, options ChangeLvOptions) error {
+	args := []string{"lvchange", "--devicesfile", vgName}
+	if options.MetadataProfile != "" {
+		args = append(args, "--metadataprofile", options.MetadataProfile)
+	}
+	if options.Discards != "" {
+		args = append(args, "--discards", options.Discards)
+	}
+	args = append(args, vgLvName(vgName, lvName))
+
+	_, err := run(args...)
+	return err
+}
+
+// Makes an LV read-only. Succeeds if it already is.
+func SetLvReadOnly(vgName string, lvName string) error {
+	_, err := run("lvchange", "--devicesfile", vgName, "--permission", "r", vgLvName(vgName, lvName))
+
+	var lvmErr *Error
+	if errors.As(err, &lvmErr) && strings.Contains(lvmErr.Output, "already read only") {
+		return nil
+	}
+	return err
+}
+
+// Adds a tag to an LV. Succeeds if the tag is already present.
+func AddLvTag(vgName string, lvName string, tag string) error {
+	_, err := run("lvchange", "--devicesfile", vgName, "--addtag", tag, vgLvName(vgName, lvName))
+	return err
+}
+
+func LvHasTag(vgName string, lvName string, tag string) (bool, error) {
+	lv, err := GetLv(vgName, lvName, LvFieldTags)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range lv.Tags {
+		if t == tag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Grows an LV to sizeBytes. Fails with ErrInsufficientSpace if the VG is too
+// full.
+func ExtendLv(vgName string, lvName string, sizeBytes int64) error {
+	_, err := run("lvextend", "--devicesfile", vgName, "--size", fmt.Sprintf("%db", sizeBytes), vgLvName(vgName, lvName))
+	return err
+}
+
+// Fails with ErrAlreadyExists if an LV named newLvName exists.
+func RenameLv(vgName string, lvName string, newLvName string) error {
+	_, err := run("lvrename", "--devicesfile", vgName, vgLvName(vgName, lvName), newLvName)
+	return err
+}
+
+// Calls a function with an LV activated temporarily. Returns the function's
+// error if it fails, otherwise any error deactivating the LV.
+func WithLvActivated(vgName string, lvName string, op func() error) (err error) {
+	if err := ActivateLv(vgName, lvName, Activate); err != nil {
+		return err
+	}
+
+	defer func() {
+		if deactivateErr := DeactivateLv(vgName, lvName); err == nil {
+			err = deactivateErr
+		}
+	}()
+
+	return op()
+}
+
+// Like WithLvActivated, but activates the LV in shared mode so that it can be
+// read while active on other nodes, and leaves it active if it already was on
+// this node.
+func WithLvActivatedShared(vgName string, lvName string, op func() error) (err error) {
+	lv, err := GetLv(vgName, lvName, LvFieldActiveLocally)
+	if err != nil {
+		return err
+	}
+	if lv.ActiveLocally {
+		return op()
+	}
+
+	if err := ActivateLv(vgName, lvName, ActivateShared); err != nil {
+		return err
+	}
+
+	defer func() {
+		if deactivateErr := DeactivateLv(vgName, lvName); err == nil {
+			err = deactivateErr
+		}
+	}()
+
+	return op()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "y"
+	}
+	return "n"
+}
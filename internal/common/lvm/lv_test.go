@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Replaces nsenter, through which LVM commands run on the host, with a script
+// that logs its arguments and then runs script. Returns the path of the log.
+func fakeHostCommands(t *testing.T, script string) string {
+	dir := t.TempDir()
+	logPath := path.Join(dir, "nsenter.log")
+
+	script = "#!/bin/sh\necho \"$@\" >> " + logPath + "\n" + script
+	if err := os.WriteFile(path.Join(dir, "nsenter"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir)
+	return logPath
+}
+
+func readLog(t *testing.T, logPath string) string {
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(log)
+}
+
+func TestWithLvActivatedKeepsOpError(t *testing.T) {
+	logPath := fakeHostCommands(t, "")
+	opErr := errors.New("convert failed")
+
+	err := WithLvActivated("vg", "lv", func() error { return opErr })
+	if !errors.Is(err, opErr) {
+		t.Errorf("got error %v, want %v", err, opErr)
+	}
+
+	if log := readLog(t, logPath); !strings.Contains(log, "--activate n vg/lv") {
+		t.Errorf("LV was not deactivated, commands run:\n%s", log)
+	}
+}
+
+func TestWithLvActivatedReturnsDeactivateError(t *testing.T) {
+	fakeHostCommands(t, "case \"$*\" in *'--activate n'*) exit 5 ;; esac\n")
+
+	err := WithLvActivated("vg", "lv", func() error { return nil })
+	if err == nil {
+		t.Error("deactivation failure was not reported")
+	}
+}
+
+// "lvs --reportformat json" output for an LV that is not active on this node
+const inactiveLvReport = `{"report": [{"lv": [{"lv_name":"lv", "vg_name":"vg", "lv_active_locally":""}]}]}`
+
+func TestWithLvActivatedSharedKeepsOpError(t *testing.T) {
+	logPath := fakeHostCommands(t, "case \"$*\" in *lvs*) printf '%s' "+shellQuote(inactiveLvReport)+" ;; esac\n")
+	opErr := errors.New("convert failed")
+
+	err := WithLvActivatedShared("vg", "lv", func() error { return opErr })
+	if !errors.Is(err, opErr) {
+		t.Errorf("got error %v, want %v", err, opErr)
+	}
+
+	log := readLog(t, logPath)
+	if !strings.Contains(log, "--activate sy vg/lv") {
+		t.Errorf("LV was not activated shared, commands run:\n%s", log)
+	}
+	if !strings.Contains(log, "--activate n vg/lv") {
+		t.Errorf("LV was not deactivated, commands run:\n%s", log)
+	}
+}
+
+func TestWithLvActivatedSharedReturnsDeactivateError(t *testing.T) {
+	fakeHostCommands(t, "case \"$*\" in\n"+
+		"*lvs*) printf '%s' "+shellQuote(inactiveLvReport)+" ;;\n"+
+		"*'--activate n'*) exit 5 ;;\n"+
+		"esac\n")
+
+	err := WithLvActivatedShared("vg", "lv", func() error { return nil })
+	if err == nil {
+		t.Error("deactivation failure was not reported")
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package runs LVM commands in the host. Reports from lvs(8), vgs(8) and
+// pvs(8) are requested in JSON and parsed into Go structs, see report.go, and
+// changes are made through functions that take typed options, see lv.go.
+//
+// LVM reports most failures with exit code 5 and a message, so failed commands
+// return an *Error that is classified by its output. Callers use errors.Is()
+// with the errors below instead of looking at the output themselves.
+
+var (
+	// The VG or LV does not exist.
+	ErrNotFound = errors.New("LVM object not found")
+
+	// An LV with the name already exists.
+	ErrAlreadyExists = errors.New("LVM object already exists")
+
+	// The VG does not have enough free extents for the allocation.
+	ErrInsufficientSpace = errors.New("insufficient free space in LVM volume group")
+
+	// lvmlockd could not acquire a lock because another host holds it,
+	// e.g. when activating an LV exclusively that is active elsewhere.
+	ErrLockConflict = errors.New("LVM lock held by another host")
+
+	// The LV is open and cannot be deactivated or removed.
+	ErrInUse = errors.New("LVM logical volume in use")
+)
+
+// LVM's messages for each error. Warnings such as "Device for PV ... not
+// found" are printed alongside the output of commands that may still succeed,
+// so the patterns must not match them.
+var errorPatterns = map[error]*regexp.Regexp{
+	ErrNotFound:          regexp.MustCompile(`(?i)failed to find|volume group "[^"]*" not found`),
+	ErrAlreadyExists:     regexp.MustCompile(`(?i)already exists`),
+	ErrInsufficientSpace: regexp.MustCompile(`(?i)insufficient (free space|suitable allocatable extents)`),
+	ErrLockConflict:      regexp.MustCompile(`(?i)(locked|held) by other host|lock failed`),
+	ErrInUse:             regexp.MustCompile(`(?i)logical volume \S+ (contains a filesystem )?in use|in use by|is used by another device`),
+}
+
+// A failed LVM command. Matches ErrNotFound, ErrAlreadyExists,
+// ErrInsufficientSpace, ErrLockConflict and ErrInUse with errors.Is(),
+// depending on the messages in its output.
+type Error struct {
+	Args   []string
+	Output string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	pattern, ok := errorPatterns[target]
+	return ok && pattern.MatchString(e.Output)
+}
+
+func run(args ...string) (commands.Output, error) {
+	log.Printf("LVM command: %v", args)
+
+	output, err := commands.RunOnHost(append([]string{"lvm"}, args...)...)
+	if err != nil {
+		return output, &Error{Args: args, Output: string(output.Combined), Err: err}
+	}
+	return output, nil
+}
+
+func vgLvName(vgName string, lvName string) string {
+	return fmt.Sprintf("%s/%s", vgName, lvName)
+}
+
+// Atomic. Overwrites the profile if it already exists.
+func CreateProfile(name string, contents string) error {
+	// This should never happen but be extra careful since the name is used to build a path outside the container's
+	// mount namespace and container escapes must be prevented.
+	if strings.ContainsAny(name, "/") {
+		return fmt.Errorf("lvm profile name \"%s\" must not contain a '/' character", name)
+	}
+	if name == ".." {
+		return fmt.Errorf("lvm profile name \"%s\" must not be \"..\"", name)
+	}
+
+	// This process runs in the host PID namespace, so the host's root dir is accessible through the init process.
+	profileDir := "/proc/1/root/etc/lvm/profile"
+	profilePath := path.Join(profileDir, name+".profile")
+
+	f, err := os.CreateTemp(profileDir, "kubesan-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for lvm profile \"%s\": %s", name, err)
+	}
+	fClosed := false
+	fRenamed := false
+	defer func() {
+		if !fClosed {
+			if err := f.Close(); err != nil {
+				panic(fmt.Sprintf("failed to close lvm profile \"%s\": %s", name, err))
+			}
+		}
+		if !fRenamed {
+			if err := os.Remove(f.Name()); err != nil {
+				panic(fmt.Sprintf("failed to remove temporary file for lvm profile \"%s\": %s", name, err))
+			}
+		}
+	}()
+
+	if err := f.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to chmod lvm profile \"%s\": %s", name, err)
+	}
+	if _, err := f.WriteString(contents); err != nil {
+		return fmt.Errorf("failed to write lvm profile \"%s\": %s", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close lvm profile \"%s\": %s", name, err)
+	}
+	fClosed = true
+	if err := os.Rename(f.Name(), profilePath); err != nil {
+		return fmt.Errorf("failed to rename lvm profile \"%s\": %s", name, err)
+	}
+	fRenamed = true
+	return nil
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"errors"
+	"testing"
+)
+
+var typedErrors = []error{ErrNotFound, ErrAlreadyExists, ErrInsufficientSpace, ErrLockConflict, ErrInUse}
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   error // nil if no typed error must match
+	}{
+		{
+			name:   "lv not found",
+			output: "  Failed to find logical volume \"kubesan-vg/pvc-1\"\n",
+			want:   ErrNotFound,
+		},
+		{
+			name:   "vg not found",
+			output: "  Volume group \"kubesan-vg\" not found\n  Cannot process volume group kubesan-vg\n",
+			want:   ErrNotFound,
+		},
+		{
+			name:   "lv already exists",
+			output: "  Logical Volume \"pvc-1\" already exists in volume group \"kubesan-vg\"\n",
+			want:   ErrAlreadyExists,
+		},
+		{
+			name:   "vg has insufficient free space",
+			output: "  Volume group \"kubesan-vg\" has insufficient free space (255 extents): 512 required.\n",
+			want:   ErrInsufficientSpace,
+		},
+		{
+			name:   "insufficient allocatable extents",
+			output: "  Insufficient suitable allocatable extents for logical volume pvc-1: 1024 more required\n",
+			want:   ErrInsufficientSpace,
+		},
+		{
+			name:   "lv locked by other host",
+			output: "  LV locked by other host: kubesan-vg/pvc-1\n  Failed to lock logical volume kubesan-vg/pvc-1.\n",
+			want:   ErrLockConflict,
+		},
+		{
+			name:   "lvmlockd lock held by other host",
+			output: "  LV kubesan-vg/pvc-1 lock failed: held by other host.\n",
+			want:   ErrLockConflict,
+		},
+		{
+			name:   "deactivating open lv",
+			output: "  Logical volume kubesan-vg/pvc-1 in use.\n",
+			want:   ErrInUse,
+		},
+		{
+			name:   "removing lv with mounted file system",
+			output: "  Logical volume kubesan-vg/pvc-1 contains a filesystem in use.\n",
+			want:   ErrInUse,
+		},
+		{
+			name:   "removing lv with holders",
+			output: "  Logical volume kubesan-vg/pvc-1 is used by another device.\n",
+			want:   ErrInUse,
+		},
+		{
+			name:   "missing pv warning",
+			output: "  WARNING: Couldn't find device with uuid 3UWEfG-kd2D-xLd9-3fAk-QyVx-Y3Ds-O6ONkN.\n  WARNING: VG kubesan-vg is missing PV 3UWEfG-kd2D-xLd9-3fAk-QyVx-Y3Ds-O6ONkN (last written to /dev/sdb).\n  Device for PV 3UWEfG-kd2D-xLd9-3fAk-QyVx-Y3Ds-O6ONkN not found or rejected by a filter.\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &Error{Output: tt.output, Err: errors.New("command failed")}
+			for _, typed := range typedErrors {
+				if got := errors.Is(err, typed); got != (typed == tt.want) {
+					t.Errorf("errors.Is(err, %q) = %v", typed, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRunReturnsTypedError(t *testing.T) {
+	fakeHostCommands(t, "echo '  Failed to find logical volume \"vg/lv\"' >&2\nexit 5\n")
+
+	_, err := GetLv("vg", "lv")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+
+	exists, err := LvExists("vg", "lv")
+	if exists || err != nil {
+		t.Errorf("LvExists() = %v, %v, want false, nil", exists, err)
+	}
+}
@@ -0,0 +1,335 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Reports are requested with "--reportformat json --units b --nosuffix", where
+// every field is a string, sizes are in bytes, and fields that do not apply to
+// an object, such as data_percent of an inactive LV, are empty:
+//
+//	{
+//	    "report": [
+//	        {
+//	            "lv": [
+//	                {"lv_name":"pool", "vg_name":"vg", "lv_size":"1073741824", "data_percent":""}
+//	            ]
+//	        }
+//	    ]
+//	}
+
+// An LV report field. Only the requested fields are filled in an Lv.
+type LvField string
+
+const (
+	LvFieldSize                LvField = "lv_size"
+	LvFieldTags                LvField = "lv_tags"
+	LvFieldHealthStatus        LvField = "lv_health_status"
+	LvFieldActiveLocally       LvField = "lv_active_locally"
+	LvFieldPoolLv              LvField = "pool_lv"
+	LvFieldThinId              LvField = "thin_id"
+	LvFieldChunkSize           LvField = "chunk_size"
+	LvFieldDataPercent         LvField = "data_percent"
+	LvFieldMetadataSize        LvField = "lv_metadata_size"
+	LvFieldMetadataPercent     LvField = "metadata_percent"
+	LvFieldSyncPercent         LvField = "sync_percent"
+	LvFieldDataCopies          LvField = "data_copies"
+	LvFieldRaidIntegrityMode   LvField = "raidintegritymode"
+	LvFieldIntegrityMismatches LvField = "integritymismatches"
+	LvFieldVdoUsedSize         LvField = "vdo_used_size"
+	LvFieldVdoSavingPercent    LvField = "vdo_saving_percent"
+)
+
+type Lv struct {
+	Name   string
+	VgName string
+
+	SizeBytes int64
+	Tags      []string
+
+	// Empty if the LV is healthy. Otherwise a short description such as
+	// "partial" (missing PVs), "out_of_data" or "metadata_read_only" (full
+	// thin pool), "refresh needed" (a RAID leg's PV is back), or "failed".
+	HealthStatus string
+
+	ActiveLocally bool
+
+	// The thin pool of a thin LV and the LV's ID within it
+	PoolLv string
+	ThinId int64
+
+	// The allocation unit of a thin pool
+	ChunkSizeBytes int64
+
+	// How full the data and metadata of an active thin pool are, or how
+	// much of an active thin LV is mapped
+	DataPercent       float64
+	MetadataSizeBytes int64
+	MetadataPercent   float64
+
+	// The percentage of a RAID LV's legs that are in sync
+	SyncPercent float64
+
+	// The number of legs of a RAID LV, or 1 for a linear LV
+	DataCopies int64
+
+	// "journal" or "bitmap" if the legs of a RAID LV have integrity,
+	// otherwise empty
+	RaidIntegrityMode string
+
+	// The number of checksum mismatches detected in a RAID LV with
+	// integrity since it was activated, summed over its legs
+	IntegrityMismatches int64
+
+	// The physical space used by an active VDO pool LV and the percentage
+	// of space saved by compression and deduplication
+	VdoUsedSizeBytes int64
+	VdoSavingPercent float64
+}
+
+type Vg struct {
+	Name            string
+	SizeBytes       int64
+	FreeBytes       int64
+	ExtentSizeBytes int64
+}
+
+type Pv struct {
+	Name      string
+	VgName    string
+	SizeBytes int64
+	FreeBytes int64
+	Tags      []string
+}
+
+// Returns the LV with the requested fields filled in, or ErrNotFound.
+func GetLv(vgName string, lvName string, fields ...LvField) (*Lv, error) {
+	lvs, err := lvsReport(vgName, vgLvName(vgName, lvName), "", fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(lvs) != 1 {
+		return nil, fmt.Errorf("expected one LV in lvs report for \"%s\", got %d", vgLvName(vgName, lvName), len(lvs))
+	}
+	return &lvs[0], nil
+}
+
+// Returns the LVs of the VG that match the selection criteria, see
+// lvmreport(7), with the requested fields filled in. An empty selection
+// matches all LVs.
+func ListLvs(vgName string, selection string, fields ...LvField) ([]Lv, error) {
+	return lvsReport(vgName, vgName, selection, fields)
+}
+
+func LvExists(vgName string, lvName string) (bool, error) {
+	_, err := GetLv(vgName, lvName)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func lvsReport(vgName string, target string, selection string, fields []LvField) ([]Lv, error) {
+	options := []string{"lv_name", "vg_name"}
+	for _, field := range fields {
+		options = append(options, string(field))
+	}
+
+	rows, err := report("lvs", "lv", vgName, target, selection, options)
+	if err != nil {
+		return nil, err
+	}
+
+	lvs := make([]Lv, 0, len(rows))
+	for _, row := range rows {
+		lv, err := parseLv(row)
+		if err != nil {
+			return nil, err
+		}
+		lvs = append(lvs, lv)
+	}
+	return lvs, nil
+}
+
+func parseLv(row map[string]string) (Lv, error) {
+	lv := Lv{
+		Name:   row["lv_name"],
+		VgName: row["vg_name"],
+	}
+
+	var err error
+	for field, value := range row {
+		switch LvField(field) {
+		case LvFieldSize:
+			lv.SizeBytes, err = parseInt(value)
+		case LvFieldTags:
+			lv.Tags = parseList(value)
+		case LvFieldHealthStatus:
+			lv.HealthStatus = value
+		case LvFieldActiveLocally:
+			lv.ActiveLocally = value != ""
+		case LvFieldPoolLv:
+			lv.PoolLv = value
+		case LvFieldThinId:
+			lv.ThinId, err = parseInt(value)
+		case LvFieldChunkSize:
+			lv.ChunkSizeBytes, err = parseInt(value)
+		case LvFieldDataPercent:
+			lv.DataPercent, err = parseFloat(value)
+		case LvFieldMetadataSize:
+			lv.MetadataSizeBytes, err = parseInt(value)
+		case LvFieldMetadataPercent:
+			lv.MetadataPercent, err = parseFloat(value)
+		case LvFieldSyncPercent:
+			lv.SyncPercent, err = parseFloat(value)
+		case LvFieldDataCopies:
+			lv.DataCopies, err = parseInt(value)
+		case LvFieldRaidIntegrityMode:
+			lv.RaidIntegrityMode = value
+		case LvFieldIntegrityMismatches:
+			lv.IntegrityMismatches, err = parseInt(value)
+		case LvFieldVdoUsedSize:
+			lv.VdoUsedSizeBytes, err = parseInt(value)
+		case LvFieldVdoSavingPercent:
+			lv.VdoSavingPercent, err = parseFloat(value)
+		}
+		if err != nil {
+			return Lv{}, fmt.Errorf("invalid lvs field %s=\"%s\" for LV \"%s\": %w", field, value, lv.Name, err)
+		}
+	}
+	return lv, nil
+}
+
+// Returns the VG, or ErrNotFound.
+func GetVg(vgName string) (*Vg, error) {
+	rows, err := report("vgs", "vg", vgName, vgName, "", []string{"vg_name", "vg_size", "vg_free", "vg_extent_size"})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) != 1 {
+		return nil, fmt.Errorf("expected one VG in vgs report for \"%s\", got %d", vgName, len(rows))
+	}
+
+	row := rows[0]
+	vg := &Vg{Name: row["vg_name"]}
+	if vg.SizeBytes, err = parseInt(row["vg_size"]); err != nil {
+		return nil, fmt.Errorf("invalid vgs field vg_size=\"%s\": %w", row["vg_size"], err)
+	}
+	if vg.FreeBytes, err = parseInt(row["vg_free"]); err != nil {
+		return nil, fmt.Errorf("invalid vgs field vg_free=\"%s\": %w", row["vg_free"], err)
+	}
+	if vg.ExtentSizeBytes, err = parseInt(row["vg_extent_size"]); err != nil {
+		return nil, fmt.Errorf("invalid vgs field vg_extent_size=\"%s\": %w", row["vg_extent_size"], err)
+	}
+	return vg, nil
+}
+
+func VgExists(vgName string) (bool, error) {
+	_, err := GetVg(vgName)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Returns the PVs of the VG.
+func ListPvs(vgName string) ([]Pv, error) {
+	rows, err := report("pvs", "pv", vgName, "", fmt.Sprintf("vg_name = \"%s\"", vgName),
+		[]string{"pv_name", "vg_name", "pv_size", "pv_free", "pv_tags"})
+	if err != nil {
+		return nil, err
+	}
+
+	pvs := make([]Pv, 0, len(rows))
+	for _, row := range rows {
+		pv := Pv{
+			Name:   row["pv_name"],
+			VgName: row["vg_name"],
+			Tags:   parseList(row["pv_tags"]),
+		}
+		if pv.SizeBytes, err = parseInt(row["pv_size"]); err != nil {
+			return nil, fmt.Errorf("invalid pvs field pv_size=\"%s\" for PV \"%s\": %w", row["pv_size"], pv.Name, err)
+		}
+		if pv.FreeBytes, err = parseInt(row["pv_free"]); err != nil {
+			return nil, fmt.Errorf("invalid pvs field pv_free=\"%s\" for PV \"%s\": %w", row["pv_free"], pv.Name, err)
+		}
+		pvs = append(pvs, pv)
+	}
+	return pvs, nil
+}
+
+// Runs a report command and returns the rows of its report of the given type
+// ("lv", "vg" or "pv"), each mapping field names to values. The report is
+// limited to the devices of vgName and, if not empty, to target and the
+// selection criteria.
+func report(command string, reportType string, vgName string, target string, selection string, options []string) ([]map[string]string, error) {
+	args := []string{
+		command,
+		"--devicesfile", vgName,
+		"--reportformat", "json",
+		"--units", "b",
+		"--nosuffix",
+		"--options", strings.Join(options, ","),
+	}
+	if selection != "" {
+		args = append(args, "--select", selection)
+	}
+	if target != "" {
+		args = append(args, target)
+	}
+
+	output, err := run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Warnings go to stderr and may be printed while the report is being
+	// written, so only stdout is parsed
+	return parseReport(command, reportType, output.Stdout)
+}
+
+// Returns the rows of the given type from a JSON report
+func parseReport(command string, reportType string, stdout []byte) ([]map[string]string, error) {
+	var document struct {
+		Report []map[string][]map[string]string `json:"report"`
+	}
+	if err := json.Unmarshal(stdout, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s output \"%s\": %w", command, string(stdout), err)
+	}
+
+	var rows []map[string]string
+	for _, r := range document.Report {
+		rows = append(rows, r[reportType]...)
+	}
+	return rows, nil
+}
+
+// Parses a size or count, which is empty if the field does not apply
+func parseInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Parses a percentage, which is empty if the field does not apply
+func parseFloat(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// Parses a comma-separated list such as lv_tags
+func parseList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Output of "lvs --reportformat json --units b --nosuffix --options
+// lv_name,vg_name,lv_size,lv_tags,pool_lv,thin_id,data_percent,lv_active_locally"
+const lvsJson = `  {
+      "report": [
+          {
+              "lv": [
+                  {"lv_name":"kubesan-thin-pool-pvc-1", "vg_name":"kubesan-vg", "lv_size":"1077936128", "lv_tags":"kubesan-vg,kubesan-managed", "pool_lv":"", "thin_id":"", "data_percent":"12.50", "lv_active_locally":"active locally"},
+                  {"lv_name":"pvc-1", "vg_name":"kubesan-vg", "lv_size":"1073741824", "lv_tags":"", "pool_lv":"kubesan-thin-pool-pvc-1", "thin_id":"1", "data_percent":"", "lv_active_locally":""}
+              ]
+          }
+      ]
+  }
+`
+
+// Output of "vgs --reportformat json --units b --nosuffix --options
+// vg_name,vg_size,vg_free,vg_extent_size"
+const vgsJson = `  {
+      "report": [
+          {
+              "vg": [
+                  {"vg_name":"kubesan-vg", "vg_size":"21470642176", "vg_free":"20392706048", "vg_extent_size":"4194304"}
+              ]
+          }
+      ]
+  }
+`
+
+// Output of "pvs --reportformat json --units b --nosuffix --options
+// pv_name,vg_name,pv_size,pv_free,pv_tags --select 'vg_name = "kubesan-vg"'"
+const pvsJson = `  {
+      "report": [
+          {
+              "pv": [
+                  {"pv_name":"/dev/sdb", "vg_name":"kubesan-vg", "pv_size":"10733223936", "pv_free":"9655287808", "pv_tags":"tier-fast"},
+                  {"pv_name":"/dev/sdc", "vg_name":"kubesan-vg", "pv_size":"10737418240", "pv_free":"10737418240", "pv_tags":""}
+              ]
+          }
+      ]
+  }
+`
+
+// Makes the LVM command print stdout on stdout and warning on stderr, with
+// the warning written while stdout is half written
+func fakeReport(t *testing.T, stdout string, warning string) {
+	half := len(stdout) / 2
+	fakeHostCommands(t, "printf '%s' "+shellQuote(stdout[:half])+"\n"+
+		"echo "+shellQuote(warning)+" >&2\n"+
+		"printf '%s' "+shellQuote(stdout[half:])+"\n")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
+
+func TestListLvs(t *testing.T) {
+	fakeReport(t, lvsJson, "  WARNING: Couldn't find device with uuid 3UWEfG-kd2D-xLd9-3fAk-QyVx-Y3Ds-O6ONkN.")
+
+	lvs, err := ListLvs("kubesan-vg", "", LvFieldSize, LvFieldTags, LvFieldPoolLv, LvFieldThinId, LvFieldDataPercent, LvFieldActiveLocally)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Lv{
+		{
+			Name:          "kubesan-thin-pool-pvc-1",
+			VgName:        "kubesan-vg",
+			SizeBytes:     1077936128,
+			Tags:          []string{"kubesan-vg", "kubesan-managed"},
+			DataPercent:   12.5,
+			ActiveLocally: true,
+		},
+		{
+			Name:      "pvc-1",
+			VgName:    "kubesan-vg",
+			SizeBytes: 1073741824,
+			PoolLv:    "kubesan-thin-pool-pvc-1",
+			ThinId:    1,
+		},
+	}
+	if !reflect.DeepEqual(lvs, want) {
+		t.Errorf("got %+v, want %+v", lvs, want)
+	}
+}
+
+func TestGetVg(t *testing.T) {
+	fakeReport(t, vgsJson, "  WARNING: lvmlockd process is not running.")
+
+	vg, err := GetVg("kubesan-vg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Vg{Name: "kubesan-vg", SizeBytes: 21470642176, FreeBytes: 20392706048, ExtentSizeBytes: 4194304}
+	if !reflect.DeepEqual(vg, want) {
+		t.Errorf("got %+v, want %+v", vg, want)
+	}
+}
+
+func TestListPvs(t *testing.T) {
+	fakeReport(t, pvsJson, "  WARNING: PV /dev/sdd in VG other-vg is using an old PV header, modify the VG to update.")
+
+	pvs, err := ListPvs("kubesan-vg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Pv{
+		{Name: "/dev/sdb", VgName: "kubesan-vg", SizeBytes: 10733223936, FreeBytes: 9655287808, Tags: []string{"tier-fast"}},
+		{Name: "/dev/sdc", VgName: "kubesan-vg", SizeBytes: 10737418240, FreeBytes: 10737418240},
+	}
+	if !reflect.DeepEqual(pvs, want) {
+		t.Errorf("got %+v, want %+v", pvs, want)
+	}
+}
+
+func TestParseReportErrors(t *testing.T) {
+	if _, err := parseReport("lvs", "lv", []byte("")); err == nil {
+		t.Error("empty output was accepted")
+	}
+	if _, err := parseReport("lvs", "lv", []byte(lvsJson[:len(lvsJson)/2])); err == nil {
+		t.Error("truncated output was accepted")
+	}
+
+	rows, err := parseReport("lvs", "lv", []byte(`{"report": [{"lv": [{"lv_name":"pvc-1", "lv_size":"1k"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseLv(rows[0]); err == nil {
+		t.Error("invalid lv_size was accepted")
+	}
+}
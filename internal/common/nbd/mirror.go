@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package nbd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// A running server started with StartServer() can mirror the device it
+// exports to another device while clients keep writing to the export. In
+// write-blocking mode every write completes on both devices, so once the
+// mirror is ready the target stays identical to the source.
+
+func (q *qemuStorageDaemonMonitor) BlockdevMirror(ctx context.Context, jobId string, device string, target string) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "blockdev-mirror",
+    "arguments": {
+        "job-id": %s,
+        "device": %s,
+        "target": %s,
+        "sync": "full",
+        "copy-mode": "write-blocking"
+    }
+}
+`, jsonify(jobId), jsonify(device), jsonify(target))
+
+	return q.run(ctx, cmd, " already in use")
+}
+
+func (q *qemuStorageDaemonMonitor) BlockJobCancel(ctx context.Context, jobId string) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "block-job-cancel",
+    "arguments": { "device": %s }
+}`, jsonify(jobId))
+
+	return q.run(ctx, cmd, "No active block job")
+}
+
+// The response to the query-block-jobs QMP command
+type blockJobInfo struct {
+	Device string `json:"device"`
+	Type   string `json:"type"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Ready  bool   `json:"ready"`
+	Status string `json:"status"`
+}
+
+func (q *qemuStorageDaemonMonitor) QueryBlockJobs(ctx context.Context) ([]blockJobInfo, error) {
+	cmd := `{"execute": "query-block-jobs"}`
+	raw, err := q.monitor.Run([]byte(cmd))
+	if err != nil {
+		return nil, err
+	}
+
+	response := struct {
+		Return []blockJobInfo `json:"return"`
+	}{}
+	err = json.Unmarshal(raw, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Return, nil
+}
+
+// Returns the mirror job, or nil if there is none
+func (q *qemuStorageDaemonMonitor) findMirrorJob(ctx context.Context, id *ServerId) (*blockJobInfo, error) {
+	jobs, err := q.QueryBlockJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jobId := mirrorJobId(id.Export)
+	for i := range jobs {
+		if jobs[i].Device == jobId {
+			return &jobs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns the QMP node name of the mirror target given an NBD export name.
+func mirrorTargetNodeName(export string) string {
+	return nodeName(export) + "-target"
+}
+
+// Returns the QMP block job id of the mirror given an NBD export name.
+func mirrorJobId(export string) string {
+	return nodeName(export) + "-mirror"
+}
+
+// Starts mirroring the device exported by the server to devicePathOnHost,
+// which must be at least as large.
+func StartMirror(ctx context.Context, id *ServerId, devicePathOnHost string) error {
+	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
+	if err != nil {
+		return err
+	}
+	defer qsd.Close()
+
+	targetNodeName := mirrorTargetNodeName(id.Export)
+	err = qsd.BlockdevAdd(ctx, targetNodeName, devicePathOnHost)
+	if err != nil {
+		return err
+	}
+
+	return qsd.BlockdevMirror(ctx, mirrorJobId(id.Export), nodeName(id.Export), targetNodeName)
+}
+
+// Returns how much of the device has been mirrored and whether the target
+// has caught up with the source.
+func QueryMirror(ctx context.Context, id *ServerId) (float64, bool, error) {
+	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
+	if err != nil {
+		return 0, false, err
+	}
+	defer qsd.Close()
+
+	job, err := qsd.findMirrorJob(ctx, id)
+	if err != nil {
+		return 0, false, err
+	}
+	if job == nil {
+		return 0, false, k8serrors.NewServiceUnavailable("mirror job unexpectedly gone")
+	}
+
+	percent := 0.0
+	if job.Len > 0 {
+		percent = 100 * float64(job.Offset) / float64(job.Len)
+	}
+	return percent, job.Ready, nil
+}
+
+// Stops the mirror and waits until it is gone. Cancelling a ready mirror while
+// no writes are in flight leaves the target identical to the source.
+func StopMirror(ctx context.Context, id *ServerId) error {
+	log := log.FromContext(ctx)
+
+	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
+	if err != nil {
+		return err
+	}
+	defer qsd.Close()
+
+	err = qsd.BlockJobCancel(ctx, mirrorJobId(id.Export))
+	if err != nil {
+		return err
+	}
+
+	for {
+		job, err := qsd.findMirrorJob(ctx, id)
+		if err != nil {
+			return err
+		}
+		if job == nil {
+			break
+		}
+
+		log.Info("waiting for mirror job to stop", "status", job.Status)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return qsd.BlockdevDel(ctx, mirrorTargetNodeName(id.Export))
+}
@@ -70,14 +70,15 @@ func jsonify(v any) string {
 	return string(raw)
 }
 
-// Run a QMP command ignoring error strings containing idempotencyGuard.
+// Run a QMP command ignoring error strings containing idempotencyGuard, unless
+// it is empty.
 func (q *qemuStorageDaemonMonitor) run(ctx context.Context, cmd string, idempotencyGuard string) error {
 	log := log.FromContext(ctx)
 
 	log.Info("sending QMP to q-s-d", "command", cmd)
 	_, err := q.monitor.Run([]byte(cmd))
 	if err != nil {
-		if !strings.Contains(err.Error(), idempotencyGuard) {
+		if idempotencyGuard == "" || !strings.Contains(err.Error(), idempotencyGuard) {
 			log.Info("q-s-d returned failure", "error", err.Error())
 			return err
 		}
@@ -96,7 +97,8 @@ func (q *qemuStorageDaemonMonitor) BlockdevAdd(ctx context.Context, nodeName str
             "direct": true
         },
         "filename": %s,
-        "aio": "native"
+        "aio": "native",
+        "discard": "unmap"
     }
 }
 `, jsonify(nodeName), jsonify(devicePathOnHost))
@@ -114,6 +116,85 @@ func (q *qemuStorageDaemonMonitor) BlockdevDel(ctx context.Context, nodeName str
 	return q.run(ctx, cmd, "Failed to find node with node-name")
 }
 
+// QMP ThrottleLimits for the given QoS, with zero meaning unlimited. All
+// limits are always given so that qom-set replaces any previous ones.
+func throttleLimits(qos *v1alpha1.VolumeQoS) string {
+	if qos == nil {
+		qos = &v1alpha1.VolumeQoS{}
+	}
+
+	limits := map[string]int64{
+		"iops-read":      qos.ReadIops,
+		"iops-write":     qos.WriteIops,
+		"bps-read":       qos.ReadBytesPerSecond,
+		"bps-write":      qos.WriteBytesPerSecond,
+		"iops-read-max":  qos.ReadIopsBurst,
+		"iops-write-max": qos.WriteIopsBurst,
+		"bps-read-max":   qos.ReadBytesPerSecondBurst,
+		"bps-write-max":  qos.WriteBytesPerSecondBurst,
+	}
+	return jsonify(limits)
+}
+
+func (q *qemuStorageDaemonMonitor) ThrottleGroupAdd(ctx context.Context, id string, qos *v1alpha1.VolumeQoS) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "object-add",
+    "arguments": {
+        "qom-type": "throttle-group",
+        "id": %s,
+        "limits": %s
+    }
+}
+`, jsonify(id), throttleLimits(qos))
+
+	return q.run(ctx, cmd, "duplicate property")
+}
+
+func (q *qemuStorageDaemonMonitor) ThrottleGroupSet(ctx context.Context, id string, qos *v1alpha1.VolumeQoS) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "qom-set",
+    "arguments": {
+        "path": %s,
+        "property": "limits",
+        "value": %s
+    }
+}
+`, jsonify("/objects/"+id), throttleLimits(qos))
+
+	// setting the same limits twice is harmless
+	return q.run(ctx, cmd, "")
+}
+
+func (q *qemuStorageDaemonMonitor) ThrottleGroupDel(ctx context.Context, id string) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "object-del",
+    "arguments": { "id": %s }
+}`, jsonify(id))
+
+	return q.run(ctx, cmd, " not found")
+}
+
+// Add a throttle filter node on top of child that is limited by the given
+// throttle group.
+func (q *qemuStorageDaemonMonitor) BlockdevAddThrottle(ctx context.Context, nodeName string, child string, group string) error {
+	cmd := fmt.Sprintf(`
+{
+    "execute": "blockdev-add",
+    "arguments": {
+        "driver": "throttle",
+        "node-name": %s,
+        "throttle-group": %s,
+        "file": %s
+    }
+}
+`, jsonify(nodeName), jsonify(group), jsonify(child))
+
+	return q.run(ctx, cmd, "Duplicate nodes with node-name")
+}
+
 func (q *qemuStorageDaemonMonitor) BlockExportAdd(ctx context.Context, id string, nodeName string, export string) error {
 	cmd := fmt.Sprintf(`
 {
@@ -203,13 +284,25 @@ func nodeName(export string) string {
 	return blockdev
 }
 
+// Returns the QMP node name of the throttle filter given an NBD export name.
+func throttleNodeName(export string) string {
+	return nodeName(export) + "-throttle"
+}
+
+// Returns the QMP throttle group object id given an NBD export name.
+func throttleGroupId(export string) string {
+	return nodeName(export) + "-group"
+}
+
 // Returns the QMP block export id given an NBD export name.
 func blockExportId(export string) string {
 	return fmt.Sprintf("export-%s", export)
 }
 
 // Returns success only once the server is running and has the TCP port open.
-func StartServer(ctx context.Context, id *ServerId, devicePathOnHost string) (string, error) {
+// I/O goes through a throttle filter so that the limits in qos can be changed
+// later with SetServerQoS().
+func StartServer(ctx context.Context, id *ServerId, devicePathOnHost string, qos *v1alpha1.VolumeQoS) (string, error) {
 	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
 	if err != nil {
 		return "", err
@@ -222,8 +315,20 @@ func StartServer(ctx context.Context, id *ServerId, devicePathOnHost string) (st
 		return "", err
 	}
 
+	throttleGroupId := throttleGroupId(id.Export)
+	err = qsd.ThrottleGroupAdd(ctx, throttleGroupId, qos)
+	if err != nil {
+		return "", err
+	}
+
+	throttleNodeName := throttleNodeName(id.Export)
+	err = qsd.BlockdevAddThrottle(ctx, throttleNodeName, nodeName, throttleGroupId)
+	if err != nil {
+		return "", err
+	}
+
 	blockExportId := blockExportId(id.Export)
-	err = qsd.BlockExportAdd(ctx, blockExportId, nodeName, id.Export)
+	err = qsd.BlockExportAdd(ctx, blockExportId, throttleNodeName, id.Export)
 	if err != nil {
 		return "", err
 	}
@@ -237,6 +342,17 @@ func StartServer(ctx context.Context, id *ServerId, devicePathOnHost string) (st
 	return url.String(), nil
 }
 
+// Changes the I/O limits of a running server.
+func SetServerQoS(ctx context.Context, id *ServerId, qos *v1alpha1.VolumeQoS) error {
+	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
+	if err != nil {
+		return err
+	}
+	defer qsd.Close()
+
+	return qsd.ThrottleGroupSet(ctx, throttleGroupId(id.Export), qos)
+}
+
 func CheckServerHealth(ctx context.Context, id *ServerId) error {
 	qsd, err := newQemuStorageDaemonMonitor(QmpSockPath)
 	if err != nil {
@@ -271,6 +387,16 @@ func StopServer(ctx context.Context, id *ServerId) error {
 		return err
 	}
 
+	err = qsd.BlockdevDel(ctx, throttleNodeName(id.Export))
+	if err != nil {
+		return err
+	}
+
+	err = qsd.ThrottleGroupDel(ctx, throttleGroupId(id.Export))
+	if err != nil {
+		return err
+	}
+
 	err = qsd.BlockdevDel(ctx, nodeName(id.Export))
 	if err != nil {
 		return err
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package nbdclient
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// A minimal client for writing to remote NBD exports, such as those of
+// qemu-storage-daemon or qemu-nbd. It speaks the fixed newstyle handshake
+// and simple replies, sending one request at a time. TLS is not supported.
+
+const (
+	DefaultPort = "10809"
+
+	// Servers may reject larger requests
+	MaxWriteBytes = 32 * 1024 * 1024
+
+	nbdMagic            = 0x4e42444d41474943 // "NBDMAGIC"
+	nbdOptMagic         = 0x49484156454f5054 // "IHAVEOPT"
+	nbdOptReplyMagic    = 0x3e889045565a9
+	nbdRequestMagic     = 0x25609513
+	nbdSimpleReplyMagic = 0x67446698
+
+	nbdFlagFixedNewstyle = 1 << 0
+	nbdFlagNoZeroes      = 1 << 1
+
+	nbdOptGo = 7
+
+	nbdRepAck    = 1
+	nbdRepInfo   = 3
+	nbdRepErrBit = 1 << 31
+
+	nbdInfoExport = 0
+
+	nbdFlagReadOnly        = 1 << 1
+	nbdFlagSendFlush       = 1 << 2
+	nbdFlagSendWriteZeroes = 1 << 6
+
+	nbdCmdWrite       = 1
+	nbdCmdDisc        = 2
+	nbdCmdFlush       = 3
+	nbdCmdWriteZeroes = 6
+)
+
+type Client struct {
+	conn   net.Conn
+	size   int64
+	flags  uint16
+	handle uint64
+}
+
+// Connects to an export given by a URI like "nbd://host:port/export"
+func Dial(ctx context.Context, uri string) (*Client, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nbd" {
+		return nil, fmt.Errorf("unsupported NBD URI \"%s\"", uri)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), DefaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	// unblock the handshake when ctx is canceled
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	c := &Client{conn: conn}
+	if err := c.handshake(strings.TrimPrefix(u.Path, "/")); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) handshake(export string) error {
+	var hello struct {
+		Magic    uint64
+		OptMagic uint64
+		Flags    uint16
+	}
+	if err := binary.Read(c.conn, binary.BigEndian, &hello); err != nil {
+		return err
+	}
+	if hello.Magic != nbdMagic || hello.OptMagic != nbdOptMagic || hello.Flags&nbdFlagFixedNewstyle == 0 {
+		return fmt.Errorf("NBD server does not support the fixed newstyle handshake")
+	}
+
+	clientFlags := uint32(nbdFlagFixedNewstyle | hello.Flags&nbdFlagNoZeroes)
+	if err := binary.Write(c.conn, binary.BigEndian, clientFlags); err != nil {
+		return err
+	}
+
+	// NBD_OPT_GO with the export name and no information requests
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(export)))
+	data = append(data, export...)
+	data = binary.BigEndian.AppendUint16(data, 0)
+
+	opt := binary.BigEndian.AppendUint64(nil, nbdOptMagic)
+	opt = binary.BigEndian.AppendUint32(opt, nbdOptGo)
+	opt = binary.BigEndian.AppendUint32(opt, uint32(len(data)))
+	if _, err := c.conn.Write(append(opt, data...)); err != nil {
+		return err
+	}
+
+	for {
+		var reply struct {
+			Magic  uint64
+			Option uint32
+			Type   uint32
+			Length uint32
+		}
+		if err := binary.Read(c.conn, binary.BigEndian, &reply); err != nil {
+			return err
+		}
+		if reply.Magic != nbdOptReplyMagic {
+			return fmt.Errorf("invalid NBD option reply")
+		}
+
+		payload := make([]byte, reply.Length)
+		if _, err := io.ReadFull(c.conn, payload); err != nil {
+			return err
+		}
+
+		switch {
+		case reply.Type&nbdRepErrBit != 0:
+			return fmt.Errorf("NBD server rejected export \"%s\": %s", export, payload)
+
+		case reply.Type == nbdRepInfo && len(payload) >= 12 && binary.BigEndian.Uint16(payload) == nbdInfoExport:
+			c.size = int64(binary.BigEndian.Uint64(payload[2:]))
+			c.flags = binary.BigEndian.Uint16(payload[10:])
+
+		case reply.Type == nbdRepAck:
+			if c.flags&nbdFlagReadOnly != 0 {
+				return fmt.Errorf("NBD export \"%s\" is read-only", export)
+			}
+			return nil
+		}
+	}
+}
+
+// Returns the size of the export
+func (c *Client) Size() int64 {
+	return c.size
+}
+
+// Sends a request and waits for its reply. Canceling ctx closes the
+// connection, which fails the request and leaves the client unusable.
+func (c *Client) request(ctx context.Context, cmd uint16, offset int64, length uint32, data []byte) error {
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.Close() })
+	defer stop()
+
+	c.handle++
+
+	req := binary.BigEndian.AppendUint32(nil, nbdRequestMagic)
+	req = binary.BigEndian.AppendUint16(req, 0)
+	req = binary.BigEndian.AppendUint16(req, cmd)
+	req = binary.BigEndian.AppendUint64(req, c.handle)
+	req = binary.BigEndian.AppendUint64(req, uint64(offset))
+	req = binary.BigEndian.AppendUint32(req, length)
+	if _, err := c.conn.Write(append(req, data...)); err != nil {
+		return err
+	}
+
+	if cmd == nbdCmdDisc {
+		return nil // there is no reply
+	}
+
+	var reply struct {
+		Magic  uint32
+		Error  uint32
+		Handle uint64
+	}
+	if err := binary.Read(c.conn, binary.BigEndian, &reply); err != nil {
+		return err
+	}
+	if reply.Magic != nbdSimpleReplyMagic || reply.Handle != c.handle {
+		return fmt.Errorf("invalid NBD reply")
+	}
+	if reply.Error != 0 {
+		return fmt.Errorf("NBD request failed with error %d", reply.Error)
+	}
+	return nil
+}
+
+// Writes data at offset. len(data) must not exceed MaxWriteBytes.
+func (c *Client) WriteAt(ctx context.Context, data []byte, offset int64) error {
+	return c.request(ctx, nbdCmdWrite, offset, uint32(len(data)), data)
+}
+
+// Writes length zero bytes at offset, without sending them if the server
+// supports it
+func (c *Client) WriteZeroesAt(ctx context.Context, offset int64, length int64) error {
+	for length > 0 {
+		n := min(length, MaxWriteBytes)
+
+		var err error
+		if c.flags&nbdFlagSendWriteZeroes != 0 {
+			err = c.request(ctx, nbdCmdWriteZeroes, offset, uint32(n), nil)
+		} else {
+			err = c.WriteAt(ctx, make([]byte, n), offset)
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += n
+		length -= n
+	}
+	return nil
+}
+
+// Makes completed writes persistent. Servers that do not advertise flush
+// support have no volatile cache and may reject the command.
+func (c *Client) Flush(ctx context.Context) error {
+	if c.flags&nbdFlagSendFlush == 0 {
+		return nil
+	}
+	return c.request(ctx, nbdCmdFlush, 0, 0, nil)
+}
+
+// Disconnects from the server
+func (c *Client) Close() error {
+	_ = c.request(context.Background(), nbdCmdDisc, 0, 0, nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package nbdclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// Serves one connection to an in-memory export, recording the commands it
+// receives
+type fakeServer struct {
+	export string
+	flags  uint16
+
+	// Rejects NBD_OPT_GO with this message if not empty
+	reject string
+
+	// Fails requests for this command with EIO
+	failCmd uint16
+
+	data []byte
+	cmds []uint16
+	err  error
+}
+
+func startFakeServer(t *testing.T, s *fakeServer) (uri string, wait func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := listener.Accept()
+		_ = listener.Close()
+		if err != nil {
+			s.err = err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		s.err = s.serve(conn)
+	}()
+
+	return fmt.Sprintf("nbd://%s/%s", listener.Addr(), s.export), func() {
+		<-done
+		if s.err != nil {
+			t.Errorf("fake server: %s", s.err)
+		}
+	}
+}
+
+func (s *fakeServer) serve(conn net.Conn) error {
+	hello := binary.BigEndian.AppendUint64(nil, nbdMagic)
+	hello = binary.BigEndian.AppendUint64(hello, nbdOptMagic)
+	hello = binary.BigEndian.AppendUint16(hello, nbdFlagFixedNewstyle|nbdFlagNoZeroes)
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	var clientFlags uint32
+	if err := binary.Read(conn, binary.BigEndian, &clientFlags); err != nil {
+		return err
+	}
+	if clientFlags != nbdFlagFixedNewstyle|nbdFlagNoZeroes {
+		return fmt.Errorf("client flags are %#x", clientFlags)
+	}
+
+	var opt struct {
+		Magic  uint64
+		Option uint32
+		Length uint32
+	}
+	if err := binary.Read(conn, binary.BigEndian, &opt); err != nil {
+		return err
+	}
+	optData := make([]byte, opt.Length)
+	if _, err := io.ReadFull(conn, optData); err != nil {
+		return err
+	}
+	if opt.Magic != nbdOptMagic || opt.Option != nbdOptGo {
+		return fmt.Errorf("unexpected option %d", opt.Option)
+	}
+	nameLen := binary.BigEndian.Uint32(optData)
+	if name := string(optData[4 : 4+nameLen]); name != s.export {
+		return fmt.Errorf("client asked for export \"%s\"", name)
+	}
+
+	optReply := func(typ uint32, payload []byte) error {
+		reply := binary.BigEndian.AppendUint64(nil, nbdOptReplyMagic)
+		reply = binary.BigEndian.AppendUint32(reply, nbdOptGo)
+		reply = binary.BigEndian.AppendUint32(reply, typ)
+		reply = binary.BigEndian.AppendUint32(reply, uint32(len(payload)))
+		_, err := conn.Write(append(reply, payload...))
+		return err
+	}
+
+	if s.reject != "" {
+		return optReply(nbdRepErrBit|1, []byte(s.reject))
+	}
+
+	info := binary.BigEndian.AppendUint16(nil, nbdInfoExport)
+	info = binary.BigEndian.AppendUint64(info, uint64(len(s.data)))
+	info = binary.BigEndian.AppendUint16(info, s.flags)
+	if err := optReply(nbdRepInfo, info); err != nil {
+		return err
+	}
+	if err := optReply(nbdRepAck, nil); err != nil {
+		return err
+	}
+
+	for {
+		var req struct {
+			Magic  uint32
+			Flags  uint16
+			Cmd    uint16
+			Handle uint64
+			Offset uint64
+			Length uint32
+		}
+		if err := binary.Read(conn, binary.BigEndian, &req); err != nil {
+			if err == io.EOF && s.flags&nbdFlagReadOnly != 0 {
+				return nil // the client hung up after the handshake
+			}
+			return err
+		}
+		if req.Magic != nbdRequestMagic {
+			return fmt.Errorf("invalid request magic %#x", req.Magic)
+		}
+		s.cmds = append(s.cmds, req.Cmd)
+
+		var errno uint32
+		switch req.Cmd {
+		case nbdCmdDisc:
+			return nil
+		case nbdCmdWrite:
+			if _, err := io.ReadFull(conn, s.data[req.Offset:req.Offset+uint64(req.Length)]); err != nil {
+				return err
+			}
+		case nbdCmdWriteZeroes:
+			clear(s.data[req.Offset : req.Offset+uint64(req.Length)])
+		case nbdCmdFlush:
+		default:
+			errno = 22 // EINVAL
+		}
+		if req.Cmd == s.failCmd {
+			errno = 5 // EIO
+		}
+
+		reply := binary.BigEndian.AppendUint32(nil, nbdSimpleReplyMagic)
+		reply = binary.BigEndian.AppendUint32(reply, errno)
+		reply = binary.BigEndian.AppendUint64(reply, req.Handle)
+		if _, err := conn.Write(reply); err != nil {
+			return err
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s := &fakeServer{
+		export: "pvc-1",
+		flags:  nbdFlagSendFlush | nbdFlagSendWriteZeroes,
+		data:   bytes.Repeat([]byte{0xff}, 4096),
+	}
+	uri, wait := startFakeServer(t, s)
+
+	ctx := context.Background()
+	c, err := Dial(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != 4096 {
+		t.Errorf("size is %d, want 4096", c.Size())
+	}
+
+	if err := c.WriteAt(ctx, []byte("hello"), 512); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteZeroesAt(ctx, 1024, 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+
+	want := []uint16{nbdCmdWrite, nbdCmdWriteZeroes, nbdCmdFlush, nbdCmdDisc}
+	if !reflect.DeepEqual(s.cmds, want) {
+		t.Errorf("server received commands %v, want %v", s.cmds, want)
+	}
+	if got := string(s.data[512:517]); got != "hello" {
+		t.Errorf("export holds \"%s\" at 512, want \"hello\"", got)
+	}
+	if !bytes.Equal(s.data[1024:2048], make([]byte, 1024)) {
+		t.Error("export was not zeroed at 1024")
+	}
+	if s.data[2048] != 0xff {
+		t.Error("export was zeroed past the requested range")
+	}
+}
+
+func TestWriteWithoutOptionalCommands(t *testing.T) {
+	s := &fakeServer{
+		export: "pvc-1",
+		data:   bytes.Repeat([]byte{0xff}, 4096),
+	}
+	uri, wait := startFakeServer(t, s)
+
+	ctx := context.Background()
+	c, err := Dial(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// zeroes are written as data and flush is skipped
+	if err := c.WriteZeroesAt(ctx, 0, 4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+
+	want := []uint16{nbdCmdWrite, nbdCmdDisc}
+	if !reflect.DeepEqual(s.cmds, want) {
+		t.Errorf("server received commands %v, want %v", s.cmds, want)
+	}
+	if !bytes.Equal(s.data, make([]byte, 4096)) {
+		t.Error("export was not zeroed")
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	s := &fakeServer{
+		export:  "pvc-1",
+		failCmd: nbdCmdWrite,
+		data:    make([]byte, 4096),
+	}
+	uri, wait := startFakeServer(t, s)
+
+	ctx := context.Background()
+	c, err := Dial(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteAt(ctx, []byte("hello"), 0); err == nil {
+		t.Error("failed write was not reported")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wait()
+}
+
+func TestDialErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		s    *fakeServer
+	}{
+		{"rejected export", &fakeServer{export: "pvc-1", reject: "no such export"}},
+		{"read-only export", &fakeServer{export: "pvc-1", flags: nbdFlagReadOnly, data: make([]byte, 4096)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, wait := startFakeServer(t, tt.s)
+			if c, err := Dial(context.Background(), uri); err == nil {
+				_ = c.Close()
+				t.Error("Dial succeeded, want an error")
+			}
+			wait()
+		})
+	}
+}
+
+func TestDialInvalidURI(t *testing.T) {
+	for _, uri := range []string{"http://localhost/pvc-1", "nbd://[::1/pvc-1"} {
+		if _, err := Dial(context.Background(), uri); err == nil {
+			t.Errorf("Dial(\"%s\") succeeded, want an error", uri)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package qemuimg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+)
+
+// This package imports disk images into block devices and exports block
+// devices as qcow2 images using qemu-img in the manager's container. The
+// source of an import can be a local path or an HTTP(S) URL, which qemu-img
+// reads with ranged requests so that only the allocated parts of a qcow2
+// image are downloaded.
+//
+// Images may come from untrusted URLs or S3 objects, and a qcow2 image can
+// name a backing file or external data file, such as another tenant's LV or a
+// host file, that qemu-img would read into the volume. Images are therefore
+// opened with --image-opts, which gives the format and protocol drivers
+// explicitly instead of probing them and opens qcow2 images with backing=null
+// so that no backing chain is followed, and images that reference other files
+// at all are rejected before they are read.
+
+var (
+	progressPattern = regexp.MustCompile(`^\s*\((\d+(?:\.\d+)?)/100%\)`)
+)
+
+func qemuImgFormat(format v1alpha1.ImageFormat) (string, error) {
+	switch format {
+	case v1alpha1.ImageFormatRaw:
+		return "raw", nil
+	case v1alpha1.ImageFormatQcow2:
+		return "qcow2", nil
+	default:
+		return "", fmt.Errorf("invalid image format \"%s\"", format)
+	}
+}
+
+// Returns the --image-opts string that opens source, a URL or a path, in
+// format without following any references to other files
+func imageOpts(source string, format v1alpha1.ImageFormat) (string, error) {
+	f, err := qemuImgFormat(format)
+	if err != nil {
+		return "", err
+	}
+
+	opts := []string{"driver=" + f}
+
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		opts = append(opts, "file.driver="+u.Scheme, "file.url="+escapeOpt(source))
+	} else if strings.HasPrefix(source, "/dev/") {
+		opts = append(opts, "file.driver=host_device", "file.filename="+escapeOpt(source))
+	} else if path.IsAbs(source) {
+		opts = append(opts, "file.driver=file", "file.filename="+escapeOpt(source))
+	} else {
+		return "", fmt.Errorf("image source \"%s\" is neither an HTTP(S) URL nor an absolute path", source)
+	}
+
+	if f == "qcow2" {
+		opts = append(opts, "backing=null")
+	}
+	return strings.Join(opts, ","), nil
+}
+
+// Commas in option values are escaped by doubling them
+func escapeOpt(value string) string {
+	return strings.ReplaceAll(value, ",", ",,")
+}
+
+type imageInfo struct {
+	VirtualSize         int64  `json:"virtual-size"`
+	BackingFilename     string `json:"backing-filename"`
+	FullBackingFilename string `json:"full-backing-filename"`
+	FormatSpecific      struct {
+		Data struct {
+			DataFile string `json:"data-file"`
+		} `json:"data"`
+	} `json:"format-specific"`
+}
+
+// Returns information about the image, or an error if it references a backing
+// file or an external data file
+func inspect(ctx context.Context, source string, format v1alpha1.ImageFormat) (*imageInfo, error) {
+	opts, err := imageOpts(source, format)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("qemu-img info %s", source)
+	output, err := commands.RunInContainerContext(ctx, "qemu-img", "info", "--output=json", "--image-opts", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var info imageInfo
+	if err := json.Unmarshal(output.Stdout, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse qemu-img info output: %s", err)
+	}
+
+	if info.BackingFilename != "" || info.FullBackingFilename != "" {
+		return nil, fmt.Errorf("image \"%s\" has a backing file, which is not supported", source)
+	}
+	if info.FormatSpecific.Data.DataFile != "" {
+		return nil, fmt.Errorf("image \"%s\" has an external data file, which is not supported", source)
+	}
+	return &info, nil
+}
+
+// Returns the virtual size of the image, which is the size of the disk it
+// holds rather than the size of the image file. Fails if the image references
+// other files.
+func VirtualSize(ctx context.Context, source string, format v1alpha1.ImageFormat) (int64, error) {
+	info, err := inspect(ctx, source, format)
+	if err != nil {
+		return 0, err
+	}
+	return info.VirtualSize, nil
+}
+
+// Writes the disk held by the image to target, which must already read as
+// zeroes. Zero regions of the image are skipped so the target stays sparse
+// where the storage supports it. progress is called with the percentage
+// completed as the conversion proceeds. Fails if the image references other
+// files.
+func Convert(ctx context.Context, source string, format v1alpha1.ImageFormat, target string, progress func(percent float64)) error {
+	// checked again here, right before the conversion, since an image
+	// served over HTTP may have changed since VirtualSize() looked at it
+	if _, err := inspect(ctx, source, format); err != nil {
+		return err
+	}
+
+	opts, err := imageOpts(source, format)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("qemu-img convert %s to %s", source, target)
+	_, err = commands.RunInContainerStreamingContext(ctx,
+		progressParser(progress),
+		"qemu-img", "convert",
+		"-p",
+		"-n",
+		"--target-is-zero",
+		"-t", "none",
+		"--image-opts",
+		"-O", "raw",
+		opts,
+		target,
+	)
+	return err
+}
+
+// Writes the source block device to a new qcow2 image file at target,
+// replacing any existing file. Zero regions are left unallocated so the image
+// only takes up as much space as the data.
+func ConvertToQcow2(ctx context.Context, source string, target string, progress func(percent float64)) error {
+	log.Printf("qemu-img convert %s to qcow2 %s", source, target)
+	_, err := commands.RunInContainerStreamingContext(ctx,
+		progressParser(progress),
+		"qemu-img", "convert",
+		"-p",
+		"-T", "none",
+		"-f", "raw",
+		"-O", "qcow2",
+		source,
+		target,
+	)
+	return err
+}
+
+// Returns the largest size that ConvertToQcow2() could make the image of the
+// source block device, including qcow2 metadata
+func MeasureQcow2(ctx context.Context, source string) (int64, error) {
+	output, err := commands.RunInContainerContext(ctx, "qemu-img", "measure", "--output=json", "-f", "raw", "-O", "qcow2", source)
+	if err != nil {
+		return 0, err
+	}
+
+	var measure struct {
+		Required       int64 `json:"required"`
+		FullyAllocated int64 `json:"fully-allocated"`
+	}
+	if err := json.Unmarshal(output.Stdout, &measure); err != nil {
+		return 0, fmt.Errorf("failed to parse qemu-img measure output: %s", err)
+	}
+	return max(measure.Required, measure.FullyAllocated), nil
+}
+
+func progressParser(progress func(percent float64)) func(line string) {
+	return func(line string) {
+		if m := progressPattern.FindStringSubmatch(line); m != nil {
+			if percent, err := strconv.ParseFloat(m[1], 64); err == nil {
+				progress(percent)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package qemuimg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+)
+
+// Output of "qemu-img info --output=json" for a qcow2 image created with
+// "qemu-img create -f qcow2 -b /dev/kubesan-vg/pvc-2 -F raw"
+const backingFileInfo = `{
+    "backing-filename-format": "raw",
+    "virtual-size": 1073741824,
+    "filename": "/tmp/image.qcow2",
+    "cluster-size": 65536,
+    "format": "qcow2",
+    "actual-size": 200704,
+    "format-specific": {
+        "type": "qcow2",
+        "data": {
+            "compat": "1.1",
+            "compression-type": "zlib",
+            "lazy-refcounts": false,
+            "refcount-bits": 16,
+            "corrupt": false,
+            "extended-l2": false
+        }
+    },
+    "full-backing-filename": "/dev/kubesan-vg/pvc-2",
+    "backing-filename": "/dev/kubesan-vg/pvc-2",
+    "dirty-flag": false
+}`
+
+// Output of "qemu-img info --output=json" for a qcow2 image created with
+// "qemu-img create -f qcow2 -o data_file=/dev/kubesan-vg/pvc-2"
+const dataFileInfo = `{
+    "virtual-size": 1073741824,
+    "filename": "/tmp/image.qcow2",
+    "cluster-size": 65536,
+    "format": "qcow2",
+    "actual-size": 200704,
+    "format-specific": {
+        "type": "qcow2",
+        "data": {
+            "compat": "1.1",
+            "compression-type": "zlib",
+            "lazy-refcounts": false,
+            "refcount-bits": 16,
+            "corrupt": false,
+            "data-file": "/dev/kubesan-vg/pvc-2",
+            "data-file-raw": false,
+            "extended-l2": false
+        }
+    },
+    "dirty-flag": false
+}`
+
+// Output of "qemu-img info --output=json" for a standalone qcow2 image
+const plainInfo = `{
+    "virtual-size": 1073741824,
+    "filename": "/tmp/image.qcow2",
+    "cluster-size": 65536,
+    "format": "qcow2",
+    "actual-size": 200704,
+    "format-specific": {
+        "type": "qcow2",
+        "data": {
+            "compat": "1.1",
+            "compression-type": "zlib",
+            "lazy-refcounts": false,
+            "refcount-bits": 16,
+            "corrupt": false,
+            "extended-l2": false
+        }
+    },
+    "dirty-flag": false
+}`
+
+// Replaces qemu-img with a script that logs its arguments, prints info for
+// "qemu-img info", and succeeds. Returns the path of the log.
+func fakeQemuImg(t *testing.T, info string) string {
+	dir := t.TempDir()
+	logPath := path.Join(dir, "qemu-img.log")
+
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\n" +
+		"if [ \"$1\" = info ]; then printf '%s' '" + info + "'; fi\n"
+	if err := os.WriteFile(path.Join(dir, "qemu-img"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir)
+	return logPath
+}
+
+func readLog(t *testing.T, logPath string) string {
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(log)
+}
+
+func TestRejectsReferencesToOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		info string
+	}{
+		{name: "backing file", info: backingFileInfo},
+		{name: "data file", info: dataFileInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := fakeQemuImg(t, tt.info)
+
+			if _, err := VirtualSize(context.Background(), "https://example.com/image.qcow2", v1alpha1.ImageFormatQcow2); err == nil {
+				t.Error("VirtualSize() accepted the image")
+			}
+
+			err := Convert(context.Background(), "https://example.com/image.qcow2", v1alpha1.ImageFormatQcow2, "/dev/vg/lv", func(float64) {})
+			if err == nil {
+				t.Error("Convert() accepted the image")
+			}
+			if log := readLog(t, logPath); strings.Contains(log, "convert") {
+				t.Errorf("image was converted, commands run:\n%s", log)
+			}
+		})
+	}
+}
+
+func TestOpensImageWithoutBackingChain(t *testing.T) {
+	logPath := fakeQemuImg(t, plainInfo)
+
+	size, err := VirtualSize(context.Background(), "https://example.com/a,b.qcow2", v1alpha1.ImageFormatQcow2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 1073741824 {
+		t.Errorf("got virtual size %d, want 1073741824", size)
+	}
+
+	err = Convert(context.Background(), "https://example.com/a,b.qcow2", v1alpha1.ImageFormatQcow2, "/dev/vg/lv", func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := "driver=qcow2,file.driver=https,file.url=https://example.com/a,,b.qcow2,backing=null"
+	for _, line := range strings.Split(strings.TrimSpace(readLog(t, logPath)), "\n") {
+		if !strings.Contains(line, "--image-opts") || !strings.Contains(line, " "+opts+" ") && !strings.HasSuffix(line, " "+opts) {
+			t.Errorf("qemu-img run without \"%s\": %s", opts, line)
+		}
+	}
+}
+
+func TestImageOpts(t *testing.T) {
+	tests := []struct {
+		source string
+		format v1alpha1.ImageFormat
+		want   string
+	}{
+		{"http://example.com/image.raw", v1alpha1.ImageFormatRaw, "driver=raw,file.driver=http,file.url=http://example.com/image.raw"},
+		{"/dev/vg/lv", v1alpha1.ImageFormatRaw, "driver=raw,file.driver=host_device,file.filename=/dev/vg/lv"},
+		{"/proc/1/root/backups/image.qcow2", v1alpha1.ImageFormatQcow2, "driver=qcow2,file.driver=file,file.filename=/proc/1/root/backups/image.qcow2,backing=null"},
+	}
+
+	for _, tt := range tests {
+		got, err := imageOpts(tt.source, tt.format)
+		if err != nil {
+			t.Errorf("imageOpts(%q, %q) failed: %s", tt.source, tt.format, err)
+		} else if got != tt.want {
+			t.Errorf("imageOpts(%q, %q) = %q, want %q", tt.source, tt.format, got, tt.want)
+		}
+	}
+
+	for _, source := range []string{"image.qcow2", "file:///etc/shadow", "json:{\"driver\":\"qcow2\"}"} {
+		if _, err := imageOpts(source, v1alpha1.ImageFormatQcow2); err == nil {
+			t.Errorf("imageOpts(%q) was accepted", source)
+		}
+	}
+}
+
+// Uses the real qemu-img, if installed, to create a qcow2 image whose backing
+// file is another image
+func TestRejectsRealBackingFile(t *testing.T) {
+	if _, err := exec.LookPath("qemu-img"); err != nil {
+		t.Skip("qemu-img is not installed")
+	}
+
+	dir := t.TempDir()
+	backing := path.Join(dir, "secret.raw")
+	image := path.Join(dir, "image.qcow2")
+	if err := os.WriteFile(backing, make([]byte, 1024*1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("qemu-img", "create", "-f", "qcow2", "-b", backing, "-F", "raw", image).CombinedOutput(); err != nil {
+		t.Fatalf("qemu-img create failed: %s: %s", err, out)
+	}
+
+	if _, err := VirtualSize(context.Background(), image, v1alpha1.ImageFormatQcow2); err == nil {
+		t.Error("VirtualSize() accepted the image")
+	}
+
+	target := path.Join(dir, "target.raw")
+	if err := os.WriteFile(target, make([]byte, 1024*1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Convert(context.Background(), image, v1alpha1.ImageFormatQcow2, target, func(float64) {}); err == nil {
+		t.Error("Convert() accepted the image")
+	}
+}
+
+func TestConvertReportsFailure(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = info ]; then printf '%s' '" + plainInfo + "'; exit 0; fi\n" +
+		"echo 'qemu-img: Could not open image' >&2\nexit 1\n"
+	if err := os.WriteFile(path.Join(dir, "qemu-img"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	err := Convert(context.Background(), "https://example.com/image.qcow2", v1alpha1.ImageFormatQcow2, "/dev/vg/lv", func(float64) {})
+	if err == nil || !strings.Contains(err.Error(), "Could not open image") {
+		t.Errorf("got error %v, want qemu-img's error", err)
+	}
+}
@@ -0,0 +1,309 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This package is a minimal client for S3-compatible object storage, such as
+// AWS S3 or MinIO, covering what backups need: multipart uploads of large
+// files, downloads, and deletion. Requests are signed with AWS Signature
+// Version 4 and use path-style URLs, which all S3-compatible services
+// support.
+
+const (
+	DefaultRegion = "us-east-1"
+
+	// S3 allows at most 10000 parts per upload, each at least 5 MiB
+	minPartSizeBytes = 64 * 1024 * 1024
+	maxParts         = 10000
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+type Client struct {
+	endpoint        *url.URL
+	region          string
+	accessKeyId     string
+	secretAccessKey string
+	httpClient      *http.Client
+}
+
+func NewClient(endpoint string, region string, accessKeyId string, secretAccessKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint \"%s\"", endpoint)
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+
+	return &Client{
+		endpoint:        u,
+		region:          region,
+		accessKeyId:     accessKeyId,
+		secretAccessKey: secretAccessKey,
+		httpClient:      http.DefaultClient,
+	}, nil
+}
+
+// Uploads the file to bucket/key, calling progress with the number of bytes
+// uploaded so far after each part. An incomplete upload is aborted on error.
+func (c *Client) Upload(ctx context.Context, bucket string, key string, file *os.File, progress func(doneBytes int64)) (err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	partSize := max(minPartSizeBytes, (size+maxParts-1)/maxParts)
+
+	var initResult struct {
+		UploadId string `xml:"UploadId"`
+	}
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	if err := decodeXml(resp, &initResult); err != nil {
+		return err
+	}
+	uploadId := initResult.UploadId
+
+	defer func() {
+		if err != nil {
+			// use a fresh context in case ctx was canceled
+			resp, abortErr := c.do(context.Background(), http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadId}}, nil, 0)
+			if abortErr == nil {
+				_ = resp.Body.Close()
+			}
+		}
+	}()
+
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []part
+
+	for offset, number := int64(0), 1; offset < size || number == 1; offset, number = offset+partSize, number+1 {
+		length := min(partSize, size-offset)
+		query := url.Values{
+			"partNumber": {strconv.Itoa(number)},
+			"uploadId":   {uploadId},
+		}
+
+		resp, err := c.do(ctx, http.MethodPut, bucket, key, query, io.NewSectionReader(file, offset, length), length)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+
+		parts = append(parts, part{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		progress(offset + length)
+	}
+
+	complete := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts}
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return err
+	}
+
+	resp, err = c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadId}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return err
+	}
+
+	// errors can also be reported in the body of a 200 response
+	var completeResult struct {
+		XMLName xml.Name
+		Message string `xml:"Message"`
+	}
+	if err := decodeXml(resp, &completeResult); err != nil {
+		return err
+	}
+	if completeResult.XMLName.Local == "Error" {
+		return fmt.Errorf("S3 upload of \"%s/%s\" failed: %s", bucket, key, completeResult.Message)
+	}
+	return nil
+}
+
+// Downloads bucket/key into the file, calling progress with the number of
+// bytes downloaded so far and the total size.
+func (c *Client) Download(ctx context.Context, bucket string, key string, file *os.File, progress func(doneBytes int64, sizeBytes int64)) error {
+	resp, err := c.do(ctx, http.MethodGet, bucket, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	w := &progressWriter{w: file, progress: func(done int64) { progress(done, resp.ContentLength) }}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Returns the size of bucket/key in bytes
+func (c *Client) Size(ctx context.Context, bucket string, key string) (int64, error) {
+	resp, err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("S3 HEAD of \"%s/%s\" returned no Content-Length", bucket, key)
+	}
+	return resp.ContentLength, nil
+}
+
+// Deletes bucket/key. Succeeds if it does not exist.
+func (c *Client) Delete(ctx context.Context, bucket string, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, bucket, key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	progress func(done int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.progress(p.done)
+	return n, err
+}
+
+// Sends a signed request and returns the response if it was successful
+func (c *Client) do(ctx context.Context, method string, bucket string, key string, query url.Values, body io.Reader, contentLength int64) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = contentLength
+	}
+	c.sign(req, time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer func() { _ = resp.Body.Close() }()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("S3 %s of \"%s/%s\" failed with status %d: %s", method, bucket, key, resp.StatusCode, msg)
+	}
+	return resp, nil
+}
+
+func decodeXml(resp *http.Response, v any) error {
+	defer func() { _ = resp.Body.Close() }()
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// Adds AWS Signature Version 4 headers to the request
+func (c *Client) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(req.Header.Get(h)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		unsignedPayload,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSha256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+c.secretAccessKey), date)
+	key = hmacSha256(key, c.region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKeyId, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// Returns the query string with sorted keys and RFC 3986 escaping, as needed
+// for signing
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hexSha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package thindelta
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/internal/common/devmapper"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+)
+
+// This package finds the blocks that differ between two thin LVs of the same
+// thin-pool by comparing their mappings in the pool's metadata with
+// thin_delta(8), and how many blocks thin LVs map with thin_ls(8), see
+// usage.go. The pool must be active on this node. Since the pool is in use,
+// the tools read a metadata snapshot that is reserved for the duration of the
+// command.
+
+const sectorBytes = 512
+
+var metadataSnapMutex sync.Mutex
+
+// A byte range that differs between the two thin LVs
+type Range struct {
+	OffsetBytes int64
+	LengthBytes int64
+
+	// The range is unmapped in the newer thin LV and reads as zeroes
+	Discarded bool
+}
+
+// Returns the ranges of newLvName that differ from oldLvName
+func Changes(ctx context.Context, vgName string, poolLvName string, oldLvName string, newLvName string) ([]Range, error) {
+	oldId, err := thinId(vgName, oldLvName)
+	if err != nil {
+		return nil, err
+	}
+	newId, err := thinId(vgName, newLvName)
+	if err != nil {
+		return nil, err
+	}
+
+	var output commands.Output
+	err = withMetadataSnap(vgName, poolLvName, func(tmeta string) error {
+		output, err = commands.RunOnHostContext(ctx,
+			"thin_delta",
+			"--metadata-snap",
+			"--snap1", strconv.FormatUint(oldId, 10),
+			"--snap2", strconv.FormatUint(newId, 10),
+			tmeta,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(output.Stdout)
+}
+
+// Parses thin_delta output like:
+//
+//	<superblock uuid="" time="1" transaction="2" data_block_size="128" nr_data_blocks="0">
+//	  <diff left="1" right="2">
+//	    <different begin="10" length="5"/>
+//	    <right_only begin="20" length="1"/>
+//	    <left_only begin="30" length="2"/>
+//	  </diff>
+//	</superblock>
+//
+// where begin and length count blocks of data_block_size sectors.
+func parse(output []byte) ([]Range, error) {
+	var superblock struct {
+		DataBlockSize int64 `xml:"data_block_size,attr"`
+		Diff          struct {
+			Entries []struct {
+				XMLName xml.Name
+				Begin   int64 `xml:"begin,attr"`
+				Length  int64 `xml:"length,attr"`
+			} `xml:",any"`
+		} `xml:"diff"`
+	}
+	if err := xml.Unmarshal(output, &superblock); err != nil {
+		return nil, fmt.Errorf("failed to parse thin_delta output: %s", err)
+	}
+	if superblock.DataBlockSize <= 0 {
+		return nil, fmt.Errorf("thin_delta output has no data block size")
+	}
+	blockBytes := superblock.DataBlockSize * sectorBytes
+
+	var ranges []Range
+	for _, e := range superblock.Diff.Entries {
+		r := Range{
+			OffsetBytes: e.Begin * blockBytes,
+			LengthBytes: e.Length * blockBytes,
+		}
+
+		switch e.XMLName.Local {
+		case "same":
+			continue
+		case "different", "right_only":
+		case "left_only":
+			r.Discarded = true
+		default:
+			return nil, fmt.Errorf("unexpected thin_delta entry \"%s\"", e.XMLName.Local)
+		}
+
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func thinId(vgName string, lvName string) (uint64, error) {
+	lv, err := lvm.GetLv(vgName, lvName, lvm.LvFieldThinId)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(lv.ThinId), nil
+}
+
+// Reserves a metadata snapshot of the active thin-pool for the duration of f,
+// which is passed the path of the pool's metadata device
+func withMetadataSnap(vgName string, poolLvName string, f func(tmeta string) error) error {
+	// a pool has at most one metadata snapshot, so users must take turns
+	metadataSnapMutex.Lock()
+	defer metadataSnapMutex.Unlock()
+
+	tpool := dmName(vgName, poolLvName) + "-tpool"
+	tmeta := devmapper.DevicePath(dmName(vgName, poolLvName+"_tmeta"))
+
+	// a previous user may have been interrupted
+	_ = devmapper.Message(tpool, 0, "release_metadata_snap")
+
+	if err := devmapper.Message(tpool, 0, "reserve_metadata_snap"); err != nil {
+		return err
+	}
+	defer func() {
+		_ = devmapper.Message(tpool, 0, "release_metadata_snap")
+	}()
+
+	return f(tmeta)
+}
+
+// Returns the device-mapper name of an LV, which escapes dashes in the VG and
+// LV names by doubling them
+func dmName(vgName string, lvName string) string {
+	return strings.ReplaceAll(vgName, "-", "--") + "-" + strings.ReplaceAll(lvName, "-", "--")
+}
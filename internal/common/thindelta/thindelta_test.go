@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package thindelta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Range
+	}{
+		{
+			name: "all entry types",
+			output: `<superblock uuid="" time="1" transaction="2" data_block_size="128" nr_data_blocks="0">
+  <diff left="1" right="2">
+    <same begin="0" length="10"/>
+    <different begin="10" length="5"/>
+    <right_only begin="20" length="1"/>
+    <left_only begin="30" length="2"/>
+  </diff>
+</superblock>
+`,
+			want: []Range{
+				{OffsetBytes: 10 * 65536, LengthBytes: 5 * 65536},
+				{OffsetBytes: 20 * 65536, LengthBytes: 65536},
+				{OffsetBytes: 30 * 65536, LengthBytes: 2 * 65536, Discarded: true},
+			},
+		},
+		{
+			name: "no changes",
+			output: `<superblock uuid="" time="1" transaction="2" data_block_size="1024" nr_data_blocks="0">
+  <diff left="1" right="2">
+  </diff>
+</superblock>
+`,
+			want: nil,
+		},
+		{
+			name: "only same",
+			output: `<superblock uuid="" time="1" transaction="2" data_block_size="128" nr_data_blocks="0">
+  <diff left="1" right="2">
+    <same begin="0" length="1000"/>
+  </diff>
+</superblock>
+`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse([]byte(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{"empty", ""},
+		{"not XML", "thin_delta: metadata snapshot not found\n"},
+		{"truncated", `<superblock data_block_size="128"><diff left="1" right="2"><different begin="10"`},
+		{"no data block size", `<superblock><diff left="1" right="2"/></superblock>`},
+		{"zero data block size", `<superblock data_block_size="0"><diff left="1" right="2"/></superblock>`},
+		{"unknown entry", `<superblock data_block_size="128"><diff left="1" right="2"><moved begin="1" length="1"/></diff></superblock>`},
+		{"non-numeric begin", `<superblock data_block_size="128"><diff left="1" right="2"><different begin="x" length="1"/></diff></superblock>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ranges, err := parse([]byte(tt.output)); err == nil {
+				t.Errorf("got %v, want an error", ranges)
+			}
+		})
+	}
+}
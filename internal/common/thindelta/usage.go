@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package thindelta
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+)
+
+// Space usage of a thin LV in its thin-pool
+type LvUsage struct {
+	// Bytes of pool data mapped by the thin LV
+	AllocatedBytes int64
+
+	// Bytes of pool data mapped only by the thin LV, which deleting it
+	// would free
+	ExclusiveBytes int64
+}
+
+// Space usage of a thin-pool
+type PoolUsage struct {
+	DataBytes         int64
+	DataUsedBytes     int64
+	MetadataBytes     int64
+	MetadataUsedBytes int64
+}
+
+// Returns the usage of each thin LV in the pool, by LV name
+func LvUsages(ctx context.Context, vgName string, poolLvName string) (map[string]LvUsage, error) {
+	names, err := thinLvNamesById(vgName, poolLvName)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := lvm.GetLv(vgName, poolLvName, lvm.LvFieldChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	chunkBytes := pool.ChunkSizeBytes
+
+	var output commands.Output
+	err = withMetadataSnap(vgName, poolLvName, func(tmeta string) error {
+		output, err = commands.RunOnHostContext(ctx,
+			"thin_ls",
+			"--metadata-snap",
+			"--no-headers",
+			"--format", "DEV,MAPPED_BLOCKS,EXCLUSIVE_BLOCKS",
+			tmeta,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]LvUsage)
+	for _, line := range strings.Split(strings.TrimSpace(string(output.Stdout)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected thin_ls output \"%s\"", line)
+		}
+
+		values := make([]int64, len(fields))
+		for i, field := range fields {
+			if values[i], err = strconv.ParseInt(field, 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected thin_ls output \"%s\"", line)
+			}
+		}
+
+		// thin devices without an LV are leftovers of interrupted
+		// LVM commands
+		if name, ok := names[values[0]]; ok {
+			usages[name] = LvUsage{
+				AllocatedBytes: values[1] * chunkBytes,
+				ExclusiveBytes: values[2] * chunkBytes,
+			}
+		}
+	}
+	return usages, nil
+}
+
+// Returns the data and metadata usage of the pool
+func GetPoolUsage(vgName string, poolLvName string) (PoolUsage, error) {
+	lv, err := lvm.GetLv(vgName, poolLvName,
+		lvm.LvFieldSize,
+		lvm.LvFieldDataPercent,
+		lvm.LvFieldMetadataSize,
+		lvm.LvFieldMetadataPercent,
+	)
+	if err != nil {
+		return PoolUsage{}, err
+	}
+
+	return PoolUsage{
+		DataBytes:         lv.SizeBytes,
+		DataUsedBytes:     int64(float64(lv.SizeBytes) * lv.DataPercent / 100),
+		MetadataBytes:     lv.MetadataSizeBytes,
+		MetadataUsedBytes: int64(float64(lv.MetadataSizeBytes) * lv.MetadataPercent / 100),
+	}, nil
+}
+
+func thinLvNamesById(vgName string, poolLvName string) (map[int64]string, error) {
+	lvs, err := lvm.ListLvs(vgName, "pool_lv="+poolLvName, lvm.LvFieldThinId)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int64]string)
+	for _, lv := range lvs {
+		names[lv.ThinId] = lv.Name
+	}
+	return names, nil
+}
@@ -25,8 +25,9 @@ import (
 type CsiK8sClient struct {
 	client.Client
 
-	volumeRestClient   rest.Interface
-	snapshotRestClient rest.Interface
+	volumeRestClient        rest.Interface
+	snapshotRestClient      rest.Interface
+	groupSnapshotRestClient rest.Interface
 }
 
 func NewCsiK8sClient() (*CsiK8sClient, error) {
@@ -52,10 +53,16 @@ func NewCsiK8sClient() (*CsiK8sClient, error) {
 		return nil, err
 	}
 
+	groupSnapshotRestClient, err := createRestClient("GroupSnapshot", cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &CsiK8sClient{
-		Client:             k8sClient,
-		volumeRestClient:   volumeRestClient,
-		snapshotRestClient: snapshotRestClient,
+		Client:                  k8sClient,
+		volumeRestClient:        volumeRestClient,
+		snapshotRestClient:      snapshotRestClient,
+		groupSnapshotRestClient: groupSnapshotRestClient,
 	}
 
 	return client, nil
@@ -128,3 +135,25 @@ func (c *CsiK8sClient) TryWatchSnapshotUntil(ctx context.Context, snapshot *v1al
 	_, err := watch.UntilWithSync(ctx, lw, &v1alpha1.Snapshot{}, nil, cond)
 	return err
 }
+
+// Updates `group` with its last seen state in the cluster. Tries condition once before starting to watch.
+func (c *CsiK8sClient) WatchGroupSnapshotUntil(ctx context.Context, group *v1alpha1.GroupSnapshot, condition func() bool) error {
+	if condition() {
+		return nil
+	}
+
+	lw := cache.NewListWatchFromClient(
+		c.groupSnapshotRestClient,
+		"groupsnapshots",
+		config.Namespace,
+		fields.OneTermEqualSelector("metadata.name", group.Name),
+	)
+
+	cond := func(event apimachinerywatch.Event) (bool, error) {
+		event.Object.(*v1alpha1.GroupSnapshot).DeepCopyInto(group)
+		return condition(), nil
+	}
+
+	_, err := watch.UntilWithSync(ctx, lw, &v1alpha1.GroupSnapshot{}, nil, cond)
+	return err
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sanitize
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// This package makes CSI requests and responses safe to log. Fields that the
+// CSI spec marks with the csi_secret option, like the secrets passed to
+// NodeStageVolume, can hold LUKS passphrases and storage credentials.
+
+const stripped = "***stripped***"
+
+// Returns a copy of msg in which the values of all secret fields are replaced,
+// or msg itself if it is not a protobuf message or holds no secrets. The keys
+// of secret maps are kept since they help debugging.
+func StripSecrets(msg interface{}) interface{} {
+	v1, ok := msg.(protoadapt.MessageV1)
+	if !ok {
+		return msg
+	}
+
+	v2 := protoadapt.MessageV2Of(v1)
+	if !hasSecrets(v2.ProtoReflect()) {
+		return msg
+	}
+
+	clone := proto.Clone(v2)
+	stripSecrets(clone.ProtoReflect())
+	return protoadapt.MessageV1Of(clone)
+}
+
+func isSecret(fd protoreflect.FieldDescriptor) bool {
+	secret, _ := proto.GetExtension(fd.Options(), csi.E_CsiSecret).(bool)
+	return secret
+}
+
+func hasSecrets(m protoreflect.Message) bool {
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		found = isSecret(fd) || anyMessage(fd, v, hasSecrets)
+		return !found
+	})
+	return found
+}
+
+func stripSecrets(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if isSecret(fd) {
+			stripValue(m, fd, v)
+		} else {
+			anyMessage(fd, v, func(child protoreflect.Message) bool {
+				stripSecrets(child)
+				return false
+			})
+		}
+		return true
+	})
+}
+
+func stripValue(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case fd.IsMap() && fd.MapValue().Kind() == protoreflect.StringKind:
+		v.Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			v.Map().Set(k, protoreflect.ValueOfString(stripped))
+			return true
+		})
+	case !fd.IsList() && !fd.IsMap() && fd.Kind() == protoreflect.StringKind:
+		m.Set(fd, protoreflect.ValueOfString(stripped))
+	default:
+		m.Clear(fd)
+	}
+}
+
+// Calls f on each message held by the field until it returns true, and
+// returns whether it did
+func anyMessage(fd protoreflect.FieldDescriptor, v protoreflect.Value, f func(protoreflect.Message) bool) bool {
+	switch {
+	case fd.IsMap():
+		if fd.MapValue().Message() == nil {
+			return false
+		}
+		found := false
+		v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+			found = f(mv.Message())
+			return !found
+		})
+		return found
+	case fd.IsList():
+		if fd.Message() == nil {
+			return false
+		}
+		for i := 0; i < v.List().Len(); i++ {
+			if f(v.List().Get(i).Message()) {
+				return true
+			}
+		}
+		return false
+	case fd.Message() != nil:
+		return f(v.Message())
+	default:
+		return false
+	}
+}
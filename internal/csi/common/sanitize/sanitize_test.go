@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sanitize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+func TestStripSecrets(t *testing.T) {
+	req := &csi.NodeStageVolumeRequest{
+		VolumeId:          "pvc-1",
+		StagingTargetPath: "/var/lib/kubelet/staging",
+		Secrets:           map[string]string{"passphrase": "hunter2"},
+	}
+
+	logged := fmt.Sprintf("%+v", StripSecrets(req))
+	if strings.Contains(logged, "hunter2") {
+		t.Errorf("secret was logged: %s", logged)
+	}
+	for _, want := range []string{"pvc-1", "passphrase", stripped} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("\"%s\" is missing from %s", want, logged)
+		}
+	}
+
+	if req.Secrets["passphrase"] != "hunter2" {
+		t.Error("the request itself was modified")
+	}
+}
+
+func TestStripSecretsWithoutSecrets(t *testing.T) {
+	req := &csi.NodeUnstageVolumeRequest{VolumeId: "pvc-1"}
+	if got := StripSecrets(req); got != req {
+		t.Errorf("got %v, want the request itself", got)
+	}
+
+	if got := StripSecrets("not a message"); got != "not a message" {
+		t.Errorf("got %v, want the value itself", got)
+	}
+}
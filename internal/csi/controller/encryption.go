@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/luks"
+)
+
+func getVolumeEncryption(req *csi.CreateVolumeRequest) (*v1alpha1.VolumeEncryption, error) {
+	switch req.Parameters["encryption"] {
+	case "":
+		return nil, nil
+	case "luks":
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid encryption, must be \"luks\"")
+	}
+
+	keySource := req.Parameters["encryptionKeySource"]
+	switch keySource {
+	case "":
+		keySource = string(v1alpha1.VolumeEncryptionKeySourceKMS)
+	case string(v1alpha1.VolumeEncryptionKeySourceKMS), string(v1alpha1.VolumeEncryptionKeySourceNodeStageSecret):
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid encryption key source")
+	}
+
+	encryption := &v1alpha1.VolumeEncryption{
+		KeySource: v1alpha1.VolumeEncryptionKeySource(keySource),
+	}
+	return encryption, nil
+}
+
+// Clones are byte-for-byte copies that include the source's LUKS header and
+// integrity metadata, so they can only be created with the same encryption
+// and integrity settings as the source.
+func (s *ControllerServer) validateCloneSettings(ctx context.Context, contents *v1alpha1.VolumeContents, encryption *v1alpha1.VolumeEncryption, integrity *v1alpha1.VolumeIntegrity) error {
+	var sourceName string
+	switch {
+	case contents.CloneVolume != nil:
+		sourceName = contents.CloneVolume.SourceVolume
+	case contents.CloneSnapshot != nil:
+		snapshot := &v1alpha1.Snapshot{}
+		err := s.client.Get(ctx, types.NamespacedName{Name: contents.CloneSnapshot.SourceSnapshot, Namespace: config.Namespace}, snapshot)
+		if errors.IsNotFound(err) {
+			return status.Errorf(codes.NotFound, "snapshot \"%s\" does not exist", contents.CloneSnapshot.SourceSnapshot)
+		} else if err != nil {
+			return err
+		}
+		sourceName = snapshot.Spec.SourceVolume
+	default:
+		return nil
+	}
+
+	source := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: sourceName, Namespace: config.Namespace}, source)
+	if errors.IsNotFound(err) && contents.CloneSnapshot != nil {
+		// the snapshot outlived its source volume, rely on its key Secret below
+		return nil
+	} else if errors.IsNotFound(err) {
+		return status.Errorf(codes.NotFound, "volume \"%s\" does not exist", sourceName)
+	} else if err != nil {
+		return err
+	}
+
+	if (encryption == nil) != (source.Spec.Encryption == nil) ||
+		(encryption != nil && *encryption != *source.Spec.Encryption) {
+		return status.Errorf(codes.InvalidArgument, "encryption settings must match those of source volume \"%s\"", sourceName)
+	}
+
+	if (integrity == nil) != (source.Spec.Integrity == nil) {
+		return status.Errorf(codes.InvalidArgument, "integrity settings must match those of source volume \"%s\"", sourceName)
+	}
+
+	return nil
+}
+
+// Creates the Secret holding the passphrase of a KMS-encrypted Volume. New
+// volumes get a random passphrase and clones get a copy of their source's
+// passphrase. The Secret is owned by the Volume and is garbage collected with
+// it.
+func (s *ControllerServer) createVolumeKey(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Spec.Encryption == nil || volume.Spec.Encryption.KeySource != v1alpha1.VolumeEncryptionKeySourceKMS {
+		return nil
+	}
+
+	switch {
+	case volume.Spec.Contents.CloneVolume != nil:
+		return s.copyKey(ctx, volume.Spec.Contents.CloneVolume.SourceVolume, volume)
+
+	case volume.Spec.Contents.CloneSnapshot != nil:
+		return s.copyKey(ctx, volume.Spec.Contents.CloneSnapshot.SourceSnapshot, volume)
+
+	default:
+		passphrase := make([]byte, 32)
+		if _, err := rand.Read(passphrase); err != nil {
+			return err
+		}
+
+		data := map[string][]byte{
+			luks.PassphraseKey: []byte(hex.EncodeToString(passphrase)),
+		}
+		return s.createKeySecret(ctx, volume, data)
+	}
+}
+
+// Creates the Secret holding the passphrase of a Snapshot's copy of a
+// KMS-encrypted Volume. Later key rotation on the Volume does not affect the
+// Snapshot's LUKS header, so the Snapshot keeps its own copy of the
+// passphrase.
+func (s *ControllerServer) createSnapshotKey(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	volume := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, volume)
+	if err != nil {
+		return err
+	}
+
+	if volume.Spec.Encryption == nil || volume.Spec.Encryption.KeySource != v1alpha1.VolumeEncryptionKeySourceKMS {
+		return nil
+	}
+
+	return s.copyKey(ctx, volume.Name, snapshot)
+}
+
+func (s *ControllerServer) copyKey(ctx context.Context, sourceName string, owner client.Object) error {
+	source := &corev1.Secret{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: luks.KeySecretName(sourceName), Namespace: config.Namespace}, source)
+	if errors.IsNotFound(err) {
+		return status.Errorf(codes.InvalidArgument, "\"%s\" has no encryption key", sourceName)
+	} else if err != nil {
+		return err
+	}
+
+	return s.createKeySecret(ctx, owner, source.Data)
+}
+
+func (s *ControllerServer) createKeySecret(ctx context.Context, owner client.Object, data map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      luks.KeySecretName(owner.GetName()),
+			Namespace: config.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+
+	if err := controllerutil.SetControllerReference(owner, secret, s.client.Scheme()); err != nil {
+		return err
+	}
+
+	if err := s.client.Create(ctx, secret); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+)
+
+// Volume group snapshots are GroupSnapshot objects, whose member Snapshots
+// are the snapshots of the group.
+
+type GroupControllerServer struct {
+	csi.UnimplementedGroupControllerServer
+
+	controller *ControllerServer
+}
+
+func NewGroupControllerServer(controller *ControllerServer) *GroupControllerServer {
+	return &GroupControllerServer{
+		controller: controller,
+	}
+}
+
+func (s *GroupControllerServer) GroupControllerGetCapabilities(ctx context.Context, req *csi.GroupControllerGetCapabilitiesRequest) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+	resp := &csi.GroupControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.GroupControllerServiceCapability{
+			{
+				Type: &csi.GroupControllerServiceCapability_Rpc{
+					Rpc: &csi.GroupControllerServiceCapability_RPC{
+						Type: csi.GroupControllerServiceCapability_RPC_CREATE_DELETE_GET_VOLUME_GROUP_SNAPSHOT,
+					},
+				},
+			},
+		},
+	}
+
+	return resp, nil
+}
+
+func (s *GroupControllerServer) CreateVolumeGroupSnapshot(ctx context.Context, req *csi.CreateVolumeGroupSnapshotRequest) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	// validate request
+
+	if req.Name == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify group snapshot name")
+	}
+
+	if len(req.SourceVolumeIds) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify source volume ids")
+	}
+
+	if _, ok := req.Parameters["copyVolumeGroup"]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "full copies are not supported for group snapshots")
+	}
+
+	for i, id := range req.SourceVolumeIds {
+		if slices.Contains(req.SourceVolumeIds[:i], id) {
+			return nil, status.Errorf(codes.InvalidArgument, "volume \"%s\" is listed more than once", id)
+		}
+
+		source := &v1alpha1.Volume{}
+		err := s.controller.client.Get(ctx, types.NamespacedName{Name: id, Namespace: config.Namespace}, source)
+		if errors.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "volume \"%s\" does not exist", id)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if source.Spec.Mode != v1alpha1.VolumeModeThin {
+			return nil, status.Errorf(codes.InvalidArgument, "snapshots are only supported for Thin volumes")
+		}
+	}
+
+	// create group snapshot
+
+	group := &v1alpha1.GroupSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      req.Name,
+			Namespace: config.Namespace,
+		},
+		Spec: v1alpha1.GroupSnapshotSpec{
+			SourceVolumes: req.SourceVolumeIds,
+		},
+	}
+
+	err := s.controller.client.Create(ctx, group)
+	if errors.IsAlreadyExists(err) {
+		if err := s.controller.client.Get(ctx, client.ObjectKeyFromObject(group), group); err != nil {
+			return nil, err
+		}
+		if !slices.Equal(group.Spec.SourceVolumes, req.SourceVolumeIds) {
+			return nil, status.Errorf(codes.AlreadyExists, "group snapshot \"%s\" exists with different source volumes", req.Name)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Return once the point-in-time has been cut, which is when the
+	// source volumes are thawed. The members may not be ready to use yet.
+
+	err = s.controller.client.WatchGroupSnapshotUntil(ctx, group, func() bool {
+		return group.Status.CreationTime != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groupSnapshot, err := s.volumeGroupSnapshot(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &csi.CreateVolumeGroupSnapshotResponse{
+		GroupSnapshot: groupSnapshot,
+	}
+
+	return resp, nil
+}
+
+// Describes a group snapshot that has been cut, copying the source volumes'
+// encryption keys to the member snapshots like CreateSnapshot() does
+func (s *GroupControllerServer) volumeGroupSnapshot(ctx context.Context, group *v1alpha1.GroupSnapshot) (*csi.VolumeGroupSnapshot, error) {
+	groupSnapshot := &csi.VolumeGroupSnapshot{
+		GroupSnapshotId: group.Name,
+		CreationTime:    timestamppb.New(group.Status.CreationTime.Time),
+		ReadyToUse:      conditionsv1.IsStatusConditionTrue(group.Status.Conditions, conditionsv1.ConditionAvailable),
+	}
+
+	for _, name := range group.Status.Snapshots {
+		snapshot := &v1alpha1.Snapshot{}
+		err := s.controller.client.Get(ctx, types.NamespacedName{Name: name, Namespace: config.Namespace}, snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.controller.createSnapshotKey(ctx, snapshot); err != nil {
+			return nil, err
+		}
+
+		groupSnapshot.Snapshots = append(groupSnapshot.Snapshots, &csi.Snapshot{
+			SizeBytes:       *snapshot.Status.SizeBytes,
+			SnapshotId:      snapshot.Name,
+			SourceVolumeId:  snapshot.Spec.SourceVolume,
+			CreationTime:    timestamppb.New(snapshotCreationTime(snapshot)),
+			ReadyToUse:      conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable),
+			GroupSnapshotId: group.Name,
+		})
+	}
+
+	return groupSnapshot, nil
+}
+
+// Checks that the snapshot ids passed along with a group snapshot id are all
+// members of the group
+func checkGroupSnapshotIds(group *v1alpha1.GroupSnapshot, snapshotIds []string) error {
+	for _, id := range snapshotIds {
+		if !slices.Contains(group.Status.Snapshots, id) {
+			return status.Errorf(codes.InvalidArgument, "snapshot \"%s\" is not a member of group snapshot \"%s\"", id, group.Name)
+		}
+	}
+	return nil
+}
+
+func (s *GroupControllerServer) DeleteVolumeGroupSnapshot(ctx context.Context, req *csi.DeleteVolumeGroupSnapshotRequest) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+	// validate request
+
+	if req.GroupSnapshotId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify group snapshot id")
+	}
+
+	group := &v1alpha1.GroupSnapshot{}
+	err := s.controller.client.Get(ctx, types.NamespacedName{Name: req.GroupSnapshotId, Namespace: config.Namespace}, group)
+	if errors.IsNotFound(err) {
+		return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := checkGroupSnapshotIds(group, req.SnapshotIds); err != nil {
+		return nil, err
+	}
+
+	// refuse to delete group snapshots whose members are still in use
+
+	for _, name := range group.Status.Snapshots {
+		snapshot := &v1alpha1.Snapshot{}
+		err := s.controller.client.Get(ctx, types.NamespacedName{Name: name, Namespace: config.Namespace}, snapshot)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if len(snapshot.Status.Dependents) > 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "snapshot \"%s\" is in use by %s", name, strings.Join(snapshot.Status.Dependents, ", "))
+		}
+	}
+
+	// delete group snapshot, which deletes its members
+
+	propagation := client.PropagationPolicy(metav1.DeletePropagationForeground)
+
+	if err := s.controller.client.Delete(ctx, group, propagation); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// success
+
+	resp := &csi.DeleteVolumeGroupSnapshotResponse{}
+
+	return resp, nil
+}
+
+func (s *GroupControllerServer) GetVolumeGroupSnapshot(ctx context.Context, req *csi.GetVolumeGroupSnapshotRequest) (*csi.GetVolumeGroupSnapshotResponse, error) {
+	// validate request
+
+	if req.GroupSnapshotId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify group snapshot id")
+	}
+
+	group := &v1alpha1.GroupSnapshot{}
+	err := s.controller.client.Get(ctx, types.NamespacedName{Name: req.GroupSnapshotId, Namespace: config.Namespace}, group)
+	if errors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "group snapshot \"%s\" does not exist", req.GroupSnapshotId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := checkGroupSnapshotIds(group, req.SnapshotIds); err != nil {
+		return nil, err
+	}
+
+	if group.Status.CreationTime == nil {
+		return nil, status.Errorf(codes.Unavailable, "group snapshot \"%s\" is still being taken", req.GroupSnapshotId)
+	}
+
+	groupSnapshot, err := s.volumeGroupSnapshot(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &csi.GetVolumeGroupSnapshotResponse{
+		GroupSnapshot: groupSnapshot,
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/cron"
+)
+
+// The StorageClass or VolumeAttributesClass parameters holding I/O limits
+var qosParameters = map[string]func(qos *v1alpha1.VolumeQoS) *int64{
+	"readIopsLimit":                 func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.ReadIops },
+	"writeIopsLimit":                func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.WriteIops },
+	"readBytesPerSecondLimit":       func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.ReadBytesPerSecond },
+	"writeBytesPerSecondLimit":      func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.WriteBytesPerSecond },
+	"readIopsBurstLimit":            func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.ReadIopsBurst },
+	"writeIopsBurstLimit":           func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.WriteIopsBurst },
+	"readBytesPerSecondBurstLimit":  func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.ReadBytesPerSecondBurst },
+	"writeBytesPerSecondBurstLimit": func(qos *v1alpha1.VolumeQoS) *int64 { return &qos.WriteBytesPerSecondBurst },
+}
+
+const (
+	thinPoolAutoextendThresholdParameter = "thinPoolAutoextendThreshold"
+	thinPoolAutoextendPercentParameter   = "thinPoolAutoextendPercent"
+	discardsParameter                    = "discards"
+	spaceReclaimParameter                = "spaceReclaim"
+	fstrimScheduleParameter              = "fstrimSchedule"
+	noPathTimeoutSecondsParameter        = "noPathTimeoutSeconds"
+)
+
+func isMutableParameter(name string) bool {
+	if _, ok := qosParameters[name]; ok {
+		return true
+	}
+	switch name {
+	case thinPoolAutoextendThresholdParameter, thinPoolAutoextendPercentParameter, discardsParameter,
+		spaceReclaimParameter, fstrimScheduleParameter, noPathTimeoutSecondsParameter:
+		return true
+	}
+	return false
+}
+
+// Fails with InvalidArgument if a VolumeAttributesClass parameter map has
+// parameters that are unknown or that cannot be changed after creation.
+func validateMutableParameterNames(parameters map[string]string) error {
+	for name := range parameters {
+		if !isMutableParameter(name) {
+			return status.Errorf(codes.InvalidArgument, "unknown or immutable parameter \"%s\"", name)
+		}
+	}
+	return nil
+}
+
+// Returns the StorageClass parameters that set mutable parameters, which are
+// recorded in the Volume so that they can be applied again under a different
+// VolumeAttributesClass.
+func storageClassMutableParameters(parameters map[string]string) map[string]string {
+	var result map[string]string
+	for name, value := range parameters {
+		if isMutableParameter(name) {
+			if result == nil {
+				result = map[string]string{}
+			}
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Derives the mutable parameters from the StorageClass parameters and the
+// VolumeAttributesClass parameters, which take precedence. Parameters given
+// by neither get their default value, so the result never depends on a
+// VolumeAttributesClass the volume used before.
+func getMutableParameters(mode v1alpha1.VolumeMode, volumeType *v1alpha1.VolumeType, storageClassParameters map[string]string, mutableParameters map[string]string) (v1alpha1.VolumeMutableParameters, error) {
+	params := v1alpha1.VolumeMutableParameters{}
+	err := applyMutableParameters(&params, mode, volumeType, storageClassParameters, mutableParameters)
+	return params, err
+}
+
+// Updates the mutable parameters from StorageClass or VolumeAttributesClass
+// parameters, with later parameter maps taking precedence. Parameters that are
+// not given keep their current value.
+func applyMutableParameters(params *v1alpha1.VolumeMutableParameters, mode v1alpha1.VolumeMode, volumeType *v1alpha1.VolumeType, parameterMaps ...map[string]string) error {
+	for _, parameters := range parameterMaps {
+		if err := applyQoSParameters(params, parameters); err != nil {
+			return err
+		}
+
+		if err := applyThinPoolParameters(params, mode, parameters); err != nil {
+			return err
+		}
+
+		if err := applyReclaimParameters(params, volumeType, parameters); err != nil {
+			return err
+		}
+
+		if err := applyNoPathParameters(params, mode, parameters); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyQoSParameters(params *v1alpha1.VolumeMutableParameters, parameters map[string]string) error {
+	qos := &v1alpha1.VolumeQoS{}
+	if params.QoS != nil {
+		*qos = *params.QoS
+	}
+
+	for name, field := range qosParameters {
+		value, ok := parameters[name]
+		if !ok {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil || quantity.Sign() < 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be a non-negative quantity", name)
+		}
+
+		*field(qos) = quantity.Value()
+	}
+
+	for _, pair := range [][2]int64{
+		{qos.ReadIops, qos.ReadIopsBurst},
+		{qos.WriteIops, qos.WriteIopsBurst},
+		{qos.ReadBytesPerSecond, qos.ReadBytesPerSecondBurst},
+		{qos.WriteBytesPerSecond, qos.WriteBytesPerSecondBurst},
+	} {
+		if pair[1] != 0 && (pair[0] == 0 || pair[1] < pair[0]) {
+			return status.Error(codes.InvalidArgument, "burst limits require the corresponding limit and must not be lower than it")
+		}
+	}
+
+	if *qos == (v1alpha1.VolumeQoS{}) {
+		params.QoS = nil // unlimited
+	} else {
+		params.QoS = qos
+	}
+	return nil
+}
+
+func applyThinPoolParameters(params *v1alpha1.VolumeMutableParameters, mode v1alpha1.VolumeMode, parameters map[string]string) error {
+	threshold, hasThreshold := parameters[thinPoolAutoextendThresholdParameter]
+	percent, hasPercent := parameters[thinPoolAutoextendPercentParameter]
+	discards, hasDiscards := parameters[discardsParameter]
+
+	if !hasThreshold && !hasPercent && !hasDiscards {
+		return nil
+	}
+
+	if mode != v1alpha1.VolumeModeThin {
+		return status.Errorf(codes.InvalidArgument, "parameters \"%s\", \"%s\", and \"%s\" require mode \"Thin\"",
+			thinPoolAutoextendThresholdParameter, thinPoolAutoextendPercentParameter, discardsParameter)
+	}
+
+	if hasThreshold || hasPercent {
+		if !hasThreshold || !hasPercent {
+			return status.Errorf(codes.InvalidArgument, "parameters \"%s\" and \"%s\" must be given together",
+				thinPoolAutoextendThresholdParameter, thinPoolAutoextendPercentParameter)
+		}
+
+		thresholdValue, err := strconv.ParseInt(threshold, 10, 32)
+		if err != nil || thresholdValue < 50 || thresholdValue > 100 {
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be between 50 and 100", thinPoolAutoextendThresholdParameter)
+		}
+
+		percentValue, err := strconv.ParseInt(percent, 10, 32)
+		if err != nil || percentValue < 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be a non-negative integer", thinPoolAutoextendPercentParameter)
+		}
+
+		params.ThinPoolAutoextend = &v1alpha1.ThinPoolAutoextend{
+			ThresholdPercent: int32(thresholdValue),
+			Percent:          int32(percentValue),
+		}
+	}
+
+	if hasDiscards {
+		switch v1alpha1.ThinPoolDiscards(discards) {
+		case v1alpha1.ThinPoolDiscardsPassdown, v1alpha1.ThinPoolDiscardsNoPassdown, v1alpha1.ThinPoolDiscardsIgnore:
+			params.Discards = v1alpha1.ThinPoolDiscards(discards)
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be \"Passdown\", \"NoPassdown\", or \"Ignore\"", discardsParameter)
+		}
+	}
+
+	return nil
+}
+
+func applyReclaimParameters(params *v1alpha1.VolumeMutableParameters, volumeType *v1alpha1.VolumeType, parameters map[string]string) error {
+	policy, hasPolicy := parameters[spaceReclaimParameter]
+	schedule, hasSchedule := parameters[fstrimScheduleParameter]
+
+	if !hasPolicy && !hasSchedule {
+		return nil
+	}
+
+	reclaim := &v1alpha1.VolumeReclaim{}
+	if params.Reclaim != nil {
+		*reclaim = *params.Reclaim
+	}
+
+	if hasPolicy {
+		switch policy {
+		case "None":
+			reclaim.Policy = ""
+		case string(v1alpha1.VolumeReclaimPolicyDiscard), string(v1alpha1.VolumeReclaimPolicyFstrim):
+			reclaim.Policy = v1alpha1.VolumeReclaimPolicy(policy)
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be \"None\", \"Discard\", or \"Fstrim\"", spaceReclaimParameter)
+		}
+	}
+
+	if hasSchedule {
+		if _, err := cron.Parse(schedule); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\": %v", fstrimScheduleParameter, err)
+		}
+		reclaim.FstrimSchedule = schedule
+	}
+
+	if reclaim.Policy == "" {
+		params.Reclaim = nil // never
+		return nil
+	}
+
+	if volumeType.Filesystem == nil {
+		return status.Errorf(codes.InvalidArgument, "parameter \"%s\" requires a Filesystem volume", spaceReclaimParameter)
+	}
+	if reclaim.FstrimSchedule != "" && reclaim.Policy != v1alpha1.VolumeReclaimPolicyFstrim {
+		return status.Errorf(codes.InvalidArgument, "parameter \"%s\" requires \"%s\" to be \"Fstrim\"", fstrimScheduleParameter, spaceReclaimParameter)
+	}
+
+	params.Reclaim = reclaim
+	return nil
+}
+
+func applyNoPathParameters(params *v1alpha1.VolumeMutableParameters, mode v1alpha1.VolumeMode, parameters map[string]string) error {
+	timeout, ok := parameters[noPathTimeoutSecondsParameter]
+	if !ok {
+		return nil
+	}
+
+	// only Thin volumes have the dm-multipath wrapper that queues I/O
+	if mode != v1alpha1.VolumeModeThin {
+		return status.Errorf(codes.InvalidArgument, "parameter \"%s\" requires mode \"Thin\"", noPathTimeoutSecondsParameter)
+	}
+
+	value, err := strconv.ParseInt(timeout, 10, 64)
+	if err != nil || value < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be a non-negative integer", noPathTimeoutSecondsParameter)
+	}
+
+	params.NoPathTimeoutSeconds = value
+	return nil
+}
@@ -4,6 +4,8 @@ package controller
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
@@ -12,6 +14,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
@@ -29,6 +32,23 @@ func (s *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSn
 		return nil, status.Errorf(codes.InvalidArgument, "must specify snapshot name")
 	}
 
+	source := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: req.SourceVolumeId, Namespace: config.Namespace}, source)
+	if errors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "volume \"%s\" does not exist", req.SourceVolumeId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if source.Spec.Mode != v1alpha1.VolumeModeThin {
+		return nil, status.Errorf(codes.InvalidArgument, "snapshots are only supported for Thin volumes")
+	}
+
+	snapshotCopy, err := getSnapshotCopy(req, source)
+	if err != nil {
+		return nil, err
+	}
+
 	// create snapshot
 
 	snapshot := &v1alpha1.Snapshot{
@@ -37,7 +57,9 @@ func (s *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSn
 			Namespace: config.Namespace,
 		},
 		Spec: v1alpha1.SnapshotSpec{
+			VgName:       source.Spec.VgName,
 			SourceVolume: req.SourceVolumeId,
+			Copy:         snapshotCopy,
 		},
 	}
 
@@ -45,28 +67,77 @@ func (s *ControllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSn
 		return nil, err
 	}
 
-	err := s.client.WatchSnapshotUntil(ctx, snapshot, func() bool {
-		return conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable)
+	// Return once the point-in-time has been cut. The snapshot may not be
+	// ready to use yet, e.g. while it is being copied, in which case the
+	// sidecar calls again until it is.
+
+	err = s.client.WatchSnapshotUntil(ctx, snapshot, func() bool {
+		return snapshot.Status.CreationTime != nil || conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable)
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	condition := conditionsv1.FindStatusCondition(snapshot.Status.Conditions, conditionsv1.ConditionAvailable)
+	if err := s.createSnapshotKey(ctx, snapshot); err != nil {
+		return nil, err
+	}
 
 	resp := &csi.CreateSnapshotResponse{
 		Snapshot: &csi.Snapshot{
 			SizeBytes:      *snapshot.Status.SizeBytes,
 			SnapshotId:     snapshot.Name,
 			SourceVolumeId: snapshot.Spec.SourceVolume,
-			CreationTime:   timestamppb.New(condition.LastTransitionTime.Time),
-			ReadyToUse:     true,
+			CreationTime:   timestamppb.New(snapshotCreationTime(snapshot)),
+			ReadyToUse:     conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable),
 		},
 	}
 
 	return resp, nil
 }
 
+// Snapshots taken before CreationTime was recorded were cut when they became
+// available
+func snapshotCreationTime(snapshot *v1alpha1.Snapshot) time.Time {
+	if snapshot.Status.CreationTime != nil {
+		return snapshot.Status.CreationTime.Time
+	}
+	return conditionsv1.FindStatusCondition(snapshot.Status.Conditions, conditionsv1.ConditionAvailable).LastTransitionTime.Time
+}
+
+// Full copies are requested through the VolumeSnapshotClass parameters
+func getSnapshotCopy(req *csi.CreateSnapshotRequest, source *v1alpha1.Volume) (*v1alpha1.SnapshotCopy, error) {
+	vgName, hasVgName := req.Parameters["copyVolumeGroup"]
+	mode, hasMode := req.Parameters["copyMode"]
+
+	if !hasVgName {
+		if hasMode {
+			return nil, status.Error(codes.InvalidArgument, "parameter \"copyMode\" requires \"copyVolumeGroup\"")
+		}
+		return nil, nil
+	}
+
+	if vgName == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty parameter \"copyVolumeGroup\"")
+	}
+	if vgName == source.Spec.VgName {
+		return nil, status.Error(codes.InvalidArgument, "parameter \"copyVolumeGroup\" must differ from the source volume's volume group")
+	}
+
+	if mode == "" {
+		mode = string(v1alpha1.SnapshotCopyModeLinear)
+	}
+	switch v1alpha1.SnapshotCopyMode(mode) {
+	case v1alpha1.SnapshotCopyModeLinear, v1alpha1.SnapshotCopyModeThin:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid parameter \"copyMode\", must be \"Linear\" or \"Thin\"")
+	}
+
+	return &v1alpha1.SnapshotCopy{
+		VgName: vgName,
+		Mode:   v1alpha1.SnapshotCopyMode(mode),
+	}, nil
+}
+
 func (s *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
 	// validate request
 
@@ -74,15 +145,21 @@ func (s *ControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSn
 		return nil, status.Errorf(codes.InvalidArgument, "must specify snapshot id")
 	}
 
-	// delete snapshot
+	// refuse to delete snapshots that are still in use
 
-	snapshot := &v1alpha1.Snapshot{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.SnapshotId,
-			Namespace: config.Namespace,
-		},
+	snapshot := &v1alpha1.Snapshot{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: req.SnapshotId, Namespace: config.Namespace}, snapshot)
+	if errors.IsNotFound(err) {
+		return &csi.DeleteSnapshotResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(snapshot.Status.Dependents) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "snapshot \"%s\" is in use by %s", req.SnapshotId, strings.Join(snapshot.Status.Dependents, ", "))
 	}
 
+	// delete snapshot
+
 	propagation := client.PropagationPolicy(metav1.DeletePropagationForeground)
 
 	if err := s.client.Delete(ctx, snapshot, propagation); err != nil && !errors.IsNotFound(err) {
@@ -4,8 +4,13 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"math"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,14 +18,19 @@ import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/luks"
+	"gitlab.com/kubesan/kubesan/internal/common/nbd"
 	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
 )
 
@@ -45,21 +55,78 @@ func (s *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return nil, err
 	}
 
-	volumeContents, err := getVolumeContents(req)
+	volumeContents, err := s.getVolumeContents(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
+	encryption, err := getVolumeEncryption(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateMutableParameterNames(req.MutableParameters); err != nil {
+		return nil, err
+	}
+
+	storageClassParameters := storageClassMutableParameters(req.Parameters)
+	mutableParameters, err := getMutableParameters(volumeMode, volumeType, storageClassParameters, req.MutableParameters)
+	if err != nil {
+		return nil, err
+	}
+
+	integrity, err := getVolumeIntegrity(req, volumeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateVolumeImport(volumeContents, volumeMode, encryption, integrity); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateCloneSettings(ctx, volumeContents, encryption, integrity); err != nil {
+		return nil, err
+	}
+
 	accessModes, err := getVolumeAccessModes(req)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := validateVolumeAccessModes(volumeMode, accessModes); err != nil {
+		return nil, err
+	}
+
+	raid, err := getVolumeRaid(req, volumeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := getVolumeCache(req, volumeMode)
+	if err != nil {
+		return nil, err
+	}
+
 	capacity, _, _, err := validateCapacity(req.CapacityRange)
 	if err != nil {
 		return nil, err
 	}
 
+	// the LUKS header takes up space in front of the data
+	sizeBytes := capacity
+	if encryption != nil {
+		sizeBytes += luks.HeaderSizeBytes
+	}
+
+	if err := s.applyGoldenImage(ctx, req, volumeContents, lvmVolumeGroup, volumeMode, encryption, integrity, sizeBytes); err != nil {
+		return nil, err
+	}
+
+	vdo, err := getVolumeVdo(req, volumeMode, sizeBytes)
+	if err != nil {
+		return nil, err
+	}
+
 	// Kubernetes object names are typically DNS Subdomain Names (RFC
 	// 1123). Only lowercase characters are allowed.
 	//
@@ -79,10 +146,18 @@ func (s *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		Spec: v1alpha1.VolumeSpec{
 			VgName:      lvmVolumeGroup,
 			Mode:        volumeMode,
+			Raid:        raid,
+			Vdo:         vdo,
 			Type:        *volumeType,
 			Contents:    *volumeContents,
 			AccessModes: accessModes,
-			SizeBytes:   capacity,
+			SizeBytes:   sizeBytes,
+			Encryption:  encryption,
+			Integrity:   integrity,
+			Cache:       cache,
+
+			MutableParameters:      mutableParameters,
+			StorageClassParameters: storageClassParameters,
 		},
 	}
 
@@ -97,6 +172,10 @@ func (s *ControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolu
 		return nil, err
 	}
 
+	if err := s.createVolumeKey(ctx, volume); err != nil {
+		return nil, err
+	}
+
 	resp := &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			CapacityBytes: capacity,
@@ -114,11 +193,168 @@ func getVolumeMode(req *csi.CreateVolumeRequest) (v1alpha1.VolumeMode, error) {
 		return v1alpha1.VolumeModeThin, nil
 	}
 
-	if mode != string(v1alpha1.VolumeModeThin) && mode != string(v1alpha1.VolumeModeLinear) {
+	switch v1alpha1.VolumeMode(mode) {
+	case v1alpha1.VolumeModeThin, v1alpha1.VolumeModeLinear, v1alpha1.VolumeModeRaid1, v1alpha1.VolumeModeVdo:
+		return v1alpha1.VolumeMode(mode), nil
+	default:
 		return "", status.Error(codes.InvalidArgument, "invalid volume mode")
 	}
+}
+
+func getVolumeRaid(req *csi.CreateVolumeRequest, mode v1alpha1.VolumeMode) (*v1alpha1.VolumeRaid, error) {
+	pvTags := req.Parameters["raidPvTags"]
+	if pvTags == "" {
+		return nil, nil
+	}
+
+	if mode != v1alpha1.VolumeModeRaid1 {
+		return nil, status.Error(codes.InvalidArgument, "parameter \"raidPvTags\" requires mode \"Raid1\"")
+	}
+
+	raid := &v1alpha1.VolumeRaid{
+		PvTags: strings.Split(pvTags, ","),
+	}
+	if len(raid.PvTags) < 2 || slices.Contains(raid.PvTags, "") {
+		return nil, status.Error(codes.InvalidArgument, "parameter \"raidPvTags\" must be a comma-separated list of at least two PV tags")
+	}
+
+	return raid, nil
+}
+
+// Returns the integrity settings requested by the "integrity" parameter, or
+// nil if it is unset or false.
+func getVolumeIntegrity(req *csi.CreateVolumeRequest, mode v1alpha1.VolumeMode) (*v1alpha1.VolumeIntegrity, error) {
+	value, ok := req.Parameters["integrity"]
+	if !ok {
+		return nil, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid parameter \"integrity\", must be \"true\" or \"false\"")
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	if mode != v1alpha1.VolumeModeThin && mode != v1alpha1.VolumeModeRaid1 {
+		return nil, status.Error(codes.InvalidArgument, "parameter \"integrity\" requires mode \"Thin\" or \"Raid1\"")
+	}
+
+	return &v1alpha1.VolumeIntegrity{}, nil
+}
+
+func getVolumeVdo(req *csi.CreateVolumeRequest, mode v1alpha1.VolumeMode, sizeBytes int64) (*v1alpha1.VolumeVdo, error) {
+	poolSize, hasPoolSize := req.Parameters["vdoPoolSize"]
+	compression, hasCompression := req.Parameters["vdoCompression"]
+	deduplication, hasDeduplication := req.Parameters["vdoDeduplication"]
+
+	if mode != v1alpha1.VolumeModeVdo {
+		if hasPoolSize || hasCompression || hasDeduplication {
+			return nil, status.Error(codes.InvalidArgument, "parameters \"vdoPoolSize\", \"vdoCompression\", and \"vdoDeduplication\" require mode \"Vdo\"")
+		}
+		return nil, nil
+	}
+
+	vdo := &v1alpha1.VolumeVdo{
+		PoolSizeBytes: sizeBytes,
+		Compression:   true,
+		Deduplication: true,
+	}
+
+	if hasPoolSize {
+		quantity, err := resource.ParseQuantity(poolSize)
+		if err != nil || quantity.Sign() <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid parameter \"vdoPoolSize\", must be a positive quantity")
+		}
+
+		// round up to a multiple of 512
+		vdo.PoolSizeBytes = (quantity.Value() + 511) / 512 * 512
+	}
 
-	return v1alpha1.VolumeMode(mode), nil
+	for _, p := range []struct {
+		name    string
+		value   string
+		present bool
+		field   *bool
+	}{
+		{"vdoCompression", compression, hasCompression, &vdo.Compression},
+		{"vdoDeduplication", deduplication, hasDeduplication, &vdo.Deduplication},
+	} {
+		if !p.present {
+			continue
+		}
+
+		value, err := strconv.ParseBool(p.value)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid parameter \"%s\", must be \"true\" or \"false\"", p.name)
+		}
+		*p.field = value
+	}
+
+	return vdo, nil
+}
+
+func getVolumeCache(req *csi.CreateVolumeRequest, mode v1alpha1.VolumeMode) (*v1alpha1.VolumeCache, error) {
+	cacheMode, hasMode := req.Parameters["cacheMode"]
+	vgName, hasVgName := req.Parameters["cacheVolumeGroup"]
+	size, hasSize := req.Parameters["cacheSize"]
+
+	if !hasMode && !hasVgName && !hasSize {
+		return nil, nil
+	}
+
+	if !hasMode || !hasVgName || !hasSize {
+		return nil, status.Error(codes.InvalidArgument, "parameters \"cacheMode\", \"cacheVolumeGroup\", and \"cacheSize\" must be given together")
+	}
+
+	// only thin volumes go through the dm wrappers that the cache is
+	// inserted into
+	if mode != v1alpha1.VolumeModeThin {
+		return nil, status.Error(codes.InvalidArgument, "caching requires mode \"Thin\"")
+	}
+
+	switch v1alpha1.VolumeCacheMode(cacheMode) {
+	case v1alpha1.VolumeCacheModeWritethrough, v1alpha1.VolumeCacheModeWriteback:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid parameter \"cacheMode\", must be \"Writethrough\" or \"Writeback\"")
+	}
+
+	if vgName == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty parameter \"cacheVolumeGroup\"")
+	}
+
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil || quantity.Sign() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid parameter \"cacheSize\", must be a positive quantity")
+	}
+
+	// round up to a multiple of 512
+	sizeBytes := (quantity.Value() + 511) / 512 * 512
+
+	return &v1alpha1.VolumeCache{
+		Mode:      v1alpha1.VolumeCacheMode(cacheMode),
+		VgName:    vgName,
+		SizeBytes: sizeBytes,
+	}, nil
+}
+
+// Raid1 and Vdo volumes can only be activated on one node at a time
+func validateVolumeAccessModes(mode v1alpha1.VolumeMode, accessModes []v1alpha1.VolumeAccessMode) error {
+	if mode != v1alpha1.VolumeModeRaid1 && mode != v1alpha1.VolumeModeVdo {
+		return nil
+	}
+
+	for _, accessMode := range accessModes {
+		switch accessMode {
+		case v1alpha1.VolumeAccessModeMultiNodeReaderOnly,
+			v1alpha1.VolumeAccessModeMultiNodeSingleWriter,
+			v1alpha1.VolumeAccessModeMultiNodeMultiWriter:
+			return status.Errorf(codes.InvalidArgument, "mode \"%s\" does not support multi-node access", mode)
+		}
+	}
+
+	return nil
 }
 
 func getVolumeType(req *csi.CreateVolumeRequest) (*v1alpha1.VolumeType, error) {
@@ -161,11 +397,19 @@ func getVolumeType(req *csi.CreateVolumeRequest) (*v1alpha1.VolumeType, error) {
 	return volumeType, nil
 }
 
-func getVolumeContents(req *csi.CreateVolumeRequest) (*v1alpha1.VolumeContents, error) {
+func (s *ControllerServer) getVolumeContents(ctx context.Context, req *csi.CreateVolumeRequest) (*v1alpha1.VolumeContents, error) {
 	volumeContents := &v1alpha1.VolumeContents{}
 
 	if req.VolumeContentSource == nil {
-		volumeContents.Empty = &v1alpha1.VolumeContentsEmpty{}
+		populated, err := s.getPopulatedContents(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if populated != nil {
+			volumeContents = populated
+		} else {
+			volumeContents.Empty = &v1alpha1.VolumeContentsEmpty{}
+		}
 	} else if source := req.VolumeContentSource.GetVolume(); source != nil {
 		volumeContents.CloneVolume = &v1alpha1.VolumeContentsCloneVolume{
 			SourceVolume: source.VolumeId,
@@ -181,6 +425,92 @@ func getVolumeContents(req *csi.CreateVolumeRequest) (*v1alpha1.VolumeContents,
 	return volumeContents, nil
 }
 
+// Returns the image or backup to populate the volume with if it is being
+// provisioned for a prime PVC of the VolumeImport populator, or nil otherwise.
+// Only PVCs in the KubeSAN namespace are trusted to carry import annotations.
+func (s *ControllerServer) getPopulatedContents(ctx context.Context, req *csi.CreateVolumeRequest) (*v1alpha1.VolumeContents, error) {
+	pvcName := req.Parameters["csi.storage.k8s.io/pvc/name"]
+	pvcNamespace := req.Parameters["csi.storage.k8s.io/pvc/namespace"]
+	if pvcName == "" || pvcNamespace != config.Namespace {
+		return nil, nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: pvcName, Namespace: pvcNamespace}, pvc); err != nil {
+		return nil, err
+	}
+
+	annotation, ok := pvc.Annotations[config.ImportAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	contents := &v1alpha1.VolumeContents{}
+	if err := json.Unmarshal([]byte(annotation), contents); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid import annotation: %s", err)
+	}
+	if kubesanslices.CountNonNil(contents.Import, contents.Restore) != 1 || kubesanslices.CountNonNil(contents.Empty, contents.CloneVolume, contents.CloneSnapshot) != 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid import annotation: must hold import or restore contents")
+	}
+	return contents, nil
+}
+
+// Empty Thin volumes of a StorageClass with the "goldenImage" parameter start
+// out with the contents of that GoldenImage instead, which their thin LVs use
+// as their external origin.
+func (s *ControllerServer) applyGoldenImage(ctx context.Context, req *csi.CreateVolumeRequest, contents *v1alpha1.VolumeContents, vgName string, mode v1alpha1.VolumeMode, encryption *v1alpha1.VolumeEncryption, integrity *v1alpha1.VolumeIntegrity, sizeBytes int64) error {
+	name, ok := req.Parameters["goldenImage"]
+	if !ok || contents.Empty == nil {
+		return nil
+	}
+
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "empty parameter \"goldenImage\"")
+	}
+	if mode != v1alpha1.VolumeModeThin {
+		return status.Error(codes.InvalidArgument, "parameter \"goldenImage\" requires mode \"Thin\"")
+	}
+	if encryption != nil || integrity != nil {
+		return status.Error(codes.InvalidArgument, "parameter \"goldenImage\" cannot be combined with encryption or integrity")
+	}
+
+	goldenImage := &v1alpha1.GoldenImage{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: config.Namespace}, goldenImage)
+	if errors.IsNotFound(err) {
+		return status.Errorf(codes.InvalidArgument, "golden image \"%s\" does not exist", name)
+	} else if err != nil {
+		return err
+	}
+
+	if goldenImage.Spec.VgName != vgName {
+		return status.Errorf(codes.InvalidArgument, "golden image \"%s\" is not in volume group \"%s\"", name, vgName)
+	}
+	if goldenImage.Spec.SizeBytes > sizeBytes {
+		return status.Errorf(codes.OutOfRange, "capacity must be at least the golden image size of %d bytes", goldenImage.Spec.SizeBytes)
+	}
+
+	contents.Empty = nil
+	contents.GoldenImage = &v1alpha1.VolumeContentsGoldenImage{
+		GoldenImage: name,
+	}
+	return nil
+}
+
+// Images and backups are converted into the LV by the cluster controller,
+// which does not set up the LUKS and dm-integrity layers of Thin volumes.
+func validateVolumeImport(contents *v1alpha1.VolumeContents, mode v1alpha1.VolumeMode, encryption *v1alpha1.VolumeEncryption, integrity *v1alpha1.VolumeIntegrity) error {
+	if !contents.NeedsPopulating() {
+		return nil
+	}
+	if mode == v1alpha1.VolumeModeThin && integrity != nil {
+		return status.Error(codes.InvalidArgument, "importing images and backups into Thin volumes with integrity checking is not yet supported")
+	}
+	if encryption != nil {
+		return status.Error(codes.InvalidArgument, "importing images and backups into encrypted volumes is not yet supported")
+	}
+	return nil
+}
+
 func getVolumeAccessModes(req *csi.CreateVolumeRequest) ([]v1alpha1.VolumeAccessMode, error) {
 	modes, err := kubesanslices.TryMap(req.VolumeCapabilities, getVolumeAccessMode)
 	if err != nil {
@@ -257,15 +587,21 @@ func (s *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolu
 		return nil, status.Errorf(codes.InvalidArgument, "must specify volume id")
 	}
 
-	// delete volume
+	// refuse to delete volumes that are still in use
 
-	volume := &v1alpha1.Volume{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.VolumeId,
-			Namespace: config.Namespace,
-		},
+	volume := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: req.VolumeId, Namespace: config.Namespace}, volume)
+	if errors.IsNotFound(err) {
+		return &csi.DeleteVolumeResponse{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if volume.DeletionTimestamp == nil && len(volume.Status.Dependents) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume \"%s\" is in use by %s", req.VolumeId, strings.Join(volume.Status.Dependents, ", "))
 	}
 
+	// delete volume
+
 	propagation := client.PropagationPolicy(metav1.DeletePropagationForeground)
 
 	if err := s.client.Delete(ctx, volume, propagation); err != nil && !errors.IsNotFound(err) {
@@ -274,7 +610,7 @@ func (s *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolu
 
 	// Delete() returns immediately so wait for the resource to go away
 
-	err := wait.Backoff{
+	err = wait.Backoff{
 		Duration: 500 * time.Millisecond,
 		Factor:   2, // exponential backoff
 		Jitter:   0.1,
@@ -304,6 +640,136 @@ func (s *ControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolu
 	return resp, nil
 }
 
+func (s *ControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	// validate request
+
+	if req.VolumeId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify volume id")
+	}
+
+	// get volume
+
+	volume := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: req.VolumeId, Namespace: config.Namespace}, volume)
+	if errors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "volume \"%s\" does not exist", req.VolumeId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	condition, err := s.getVolumeCondition(ctx, volume)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := volume.Status.SizeBytes
+	if volume.Spec.Encryption != nil && capacity >= luks.HeaderSizeBytes {
+		capacity -= luks.HeaderSizeBytes
+	}
+
+	// success
+
+	resp := &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			CapacityBytes: capacity,
+			VolumeId:      volume.Name,
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			VolumeCondition: condition,
+		},
+	}
+
+	return resp, nil
+}
+
+func (s *ControllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	// validate request
+
+	if req.VolumeId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify volume id")
+	}
+
+	if err := validateMutableParameterNames(req.MutableParameters); err != nil {
+		return nil, err
+	}
+
+	// update mutable parameters, the reconcilers apply them online
+
+	volume := &v1alpha1.Volume{}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := s.client.Get(ctx, types.NamespacedName{Name: req.VolumeId, Namespace: config.Namespace}, volume)
+		if errors.IsNotFound(err) {
+			return status.Errorf(codes.NotFound, "volume \"%s\" does not exist", req.VolumeId)
+		} else if err != nil {
+			return err
+		}
+
+		mutableParameters, err := getMutableParameters(volume.Spec.Mode, &volume.Spec.Type, volume.Spec.StorageClassParameters, req.MutableParameters)
+		if err != nil {
+			return err
+		}
+
+		if reflect.DeepEqual(mutableParameters, volume.Spec.MutableParameters) {
+			return nil
+		}
+
+		volume.Spec.MutableParameters = mutableParameters
+		return s.client.Update(ctx, volume)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// success
+
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
+
+// Derives the CSI volume condition from the conditions of the Volume and the
+// resources backing it. The node controllers keep those conditions up to date
+// with host state such as LVM health and dm-multipath path state.
+func (s *ControllerServer) getVolumeCondition(ctx context.Context, volume *v1alpha1.Volume) (*csi.VolumeCondition, error) {
+	var problems []string
+
+	if !conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, conditionsv1.ConditionAvailable) {
+		problems = append(problems, "volume is not available")
+	}
+
+	if condition := conditionsv1.FindStatusCondition(volume.Status.Conditions, conditionsv1.ConditionDegraded); condition != nil && condition.Status == corev1.ConditionTrue {
+		problems = append(problems, fmt.Sprintf("volume is degraded: %s", condition.Message))
+	}
+
+	if volume.Spec.Mode == v1alpha1.VolumeModeThin {
+		thinPoolLv := &v1alpha1.ThinPoolLv{}
+		err := s.client.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		if err == nil {
+			if condition := conditionsv1.FindStatusCondition(thinPoolLv.Status.Conditions, conditionsv1.ConditionDegraded); condition != nil && condition.Status == corev1.ConditionTrue {
+				problems = append(problems, fmt.Sprintf("thin pool is degraded: %s", condition.Message))
+			}
+		}
+	}
+
+	exports := &v1alpha1.NBDExportList{}
+	if err := s.client.List(ctx, exports, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range exports.Items {
+		export := &exports.Items[i]
+		if export.Spec.Export == volume.Name && nbd.ExportDegraded(export) {
+			problems = append(problems, fmt.Sprintf("NBD export on node \"%s\" is degraded", export.Spec.Host))
+		}
+	}
+
+	if len(problems) == 0 {
+		return &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"}, nil
+	}
+	return &csi.VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}, nil
+}
+
 // func (s *ControllerServer) createVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
 // 	// TODO: Reject unknown parameters in req.Parameters that *don't* start with `csi.storage.k8s.io/`.
 
@@ -14,6 +14,7 @@ import (
 
 	"gitlab.com/kubesan/kubesan/internal/common/config"
 	csiclient "gitlab.com/kubesan/kubesan/internal/csi/common/client"
+	"gitlab.com/kubesan/kubesan/internal/csi/common/sanitize"
 	"gitlab.com/kubesan/kubesan/internal/csi/controller"
 	"gitlab.com/kubesan/kubesan/internal/csi/identity"
 	"gitlab.com/kubesan/kubesan/internal/csi/node"
@@ -22,7 +23,9 @@ import (
 func RunControllerPlugin() error {
 	return serve(func(server *grpc.Server, client *csiclient.CsiK8sClient) {
 		csi.RegisterIdentityServer(server, &identity.IdentityServer{})
-		csi.RegisterControllerServer(server, controller.NewControllerServer(client))
+		controllerServer := controller.NewControllerServer(client)
+		csi.RegisterControllerServer(server, controllerServer)
+		csi.RegisterGroupControllerServer(server, controller.NewGroupControllerServer(controllerServer))
 	})
 }
 
@@ -61,10 +64,10 @@ func serve(register func(*grpc.Server, *csiclient.CsiK8sClient)) error {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		log.Printf("%s({ %+v})", info.FullMethod, req)
+		log.Printf("%s({ %+v})", info.FullMethod, sanitize.StripSecrets(req))
 		resp, err := handler(ctx, req)
 		if err == nil {
-			log.Printf("%s(...) --> { %+v}", info.FullMethod, resp)
+			log.Printf("%s(...) --> { %+v}", info.FullMethod, sanitize.StripSecrets(resp))
 		} else {
 			log.Printf("%s(...) --> %+v", info.FullMethod, err)
 		}
@@ -32,6 +32,13 @@ func (s *IdentityServer) GetPluginCapabilities(ctx context.Context, req *csi.Get
 				},
 			},
 		},
+		{
+			Type: &csi.PluginCapability_Service_{
+				Service: &csi.PluginCapability_Service{
+					Type: csi.PluginCapability_Service_GROUP_CONTROLLER_SERVICE,
+				},
+			},
+		},
 	}
 
 	resp := &csi.GetPluginCapabilitiesResponse{
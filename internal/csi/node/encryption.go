@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/luks"
+)
+
+// Returns the current passphrase and, during key rotation, the previous
+// passphrase of an encrypted volume.
+func (s *NodeServer) getPassphrases(ctx context.Context, volume *v1alpha1.Volume, stageSecrets map[string]string) ([]byte, []byte, error) {
+	var data map[string][]byte
+
+	switch volume.Spec.Encryption.KeySource {
+	case v1alpha1.VolumeEncryptionKeySourceNodeStageSecret:
+		data = make(map[string][]byte, len(stageSecrets))
+		for k, v := range stageSecrets {
+			data[k] = []byte(v)
+		}
+
+	case v1alpha1.VolumeEncryptionKeySourceKMS:
+		secret := &corev1.Secret{}
+		err := s.client.Get(ctx, types.NamespacedName{Name: luks.KeySecretName(volume.Name), Namespace: config.Namespace}, secret)
+		if err != nil {
+			return nil, nil, status.Errorf(codes.Internal, "failed to get encryption key for volume \"%s\": %v", volume.Name, err)
+		}
+		data = secret.Data
+
+	default:
+		return nil, nil, status.Errorf(codes.Internal, "unknown encryption key source \"%s\"", volume.Spec.Encryption.KeySource)
+	}
+
+	passphrase := data[luks.PassphraseKey]
+	if len(passphrase) == 0 {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "encryption key for volume \"%s\" is missing \"%s\"", volume.Name, luks.PassphraseKey)
+	}
+
+	return passphrase, data[luks.PreviousPassphraseKey], nil
+}
+
+// Opens the LUKS mapping on top of an attached volume, formatting it first if
+// the volume was created empty. If the volume is still locked with the
+// previous passphrase, its key slot is switched over to the current one.
+// Returns the path of the decrypted device.
+func (s *NodeServer) openEncryptedVolume(ctx context.Context, volume *v1alpha1.Volume, stageSecrets map[string]string) (string, error) {
+	passphrase, previousPassphrase, err := s.getPassphrases(ctx, volume, stageSecrets)
+	if err != nil {
+		return "", err
+	}
+
+	device := volume.Status.Path
+
+	isLuks, err := luks.IsLuks(ctx, device)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to check for LUKS header on volume \"%s\": %v", volume.Name, err)
+	}
+
+	if !isLuks {
+		// never format over existing data
+		if volume.Spec.Contents.Empty == nil {
+			return "", status.Errorf(codes.FailedPrecondition, "volume \"%s\" has no LUKS header", volume.Name)
+		}
+
+		err = s.withLuksHeaderLock(ctx, volume, func() error {
+			// another node may have formatted it in the meantime
+			isLuks, err := luks.IsLuks(ctx, device)
+			if err != nil {
+				return status.Errorf(codes.Internal, "failed to check for LUKS header on volume \"%s\": %v", volume.Name, err)
+			}
+			if isLuks {
+				return nil
+			}
+
+			if err := luks.Format(ctx, device, passphrase); err != nil {
+				return status.Errorf(codes.Internal, "failed to format LUKS on volume \"%s\": %v", volume.Name, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = luks.Open(ctx, device, volume.Name, passphrase, volume.Spec.MutableParameters.Reclaim != nil)
+	if errors.Is(err, luks.ErrWrongPassphrase) && len(previousPassphrase) > 0 {
+		err = s.withLuksHeaderLock(ctx, volume, func() error {
+			log.Printf("Rotating encryption key of volume \"%s\"", volume.Name)
+
+			// another node may have rotated it in the meantime
+			err := luks.ChangePassphrase(ctx, device, previousPassphrase, passphrase)
+			if err != nil && !errors.Is(err, luks.ErrWrongPassphrase) {
+				return status.Errorf(codes.Internal, "failed to rotate encryption key of volume \"%s\": %v", volume.Name, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = luks.Open(ctx, device, volume.Name, passphrase, volume.Spec.MutableParameters.Reclaim != nil)
+	}
+	if errors.Is(err, luks.ErrWrongPassphrase) {
+		return "", status.Errorf(codes.PermissionDenied, "wrong passphrase for volume \"%s\"", volume.Name)
+	} else if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to open LUKS on volume \"%s\": %v", volume.Name, err)
+	}
+
+	return luks.GetDevicePath(volume.Name), nil
+}
+
+// Runs op, which writes the LUKS header of the volume, while holding a lock
+// recorded in an annotation on the Volume. A volume attached to several nodes
+// is staged on all of them at once, and two nodes formatting it concurrently
+// would each end up with a different volume key. While another node holds the
+// lock, staging fails with codes.Unavailable so that it is retried. A lock
+// left behind by a node that has since detached the volume is taken over.
+func (s *NodeServer) withLuksHeaderLock(ctx context.Context, volume *v1alpha1.Volume, op func() error) error {
+	key := types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}
+	locked := &v1alpha1.Volume{}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.client.Get(ctx, key, locked); err != nil {
+			return err
+		}
+
+		holder := locked.Annotations[config.LuksHeaderLockAnnotation]
+		if holder == config.LocalNodeName {
+			return nil
+		}
+		if holder != "" && slices.Contains(locked.Status.AttachedToNodes, holder) {
+			return status.Errorf(codes.Unavailable, "LUKS header of volume \"%s\" is being written on node \"%s\"", volume.Name, holder)
+		}
+
+		if locked.Annotations == nil {
+			locked.Annotations = map[string]string{}
+		}
+		locked.Annotations[config.LuksHeaderLockAnnotation] = config.LocalNodeName
+		return s.client.Update(ctx, locked)
+	})
+	if err != nil {
+		return err
+	}
+
+	opErr := op()
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.client.Get(ctx, key, locked); err != nil {
+			return err
+		}
+		if locked.Annotations[config.LuksHeaderLockAnnotation] != config.LocalNodeName {
+			return nil
+		}
+
+		delete(locked.Annotations, config.LuksHeaderLockAnnotation)
+		return s.client.Update(ctx, locked)
+	})
+	if err != nil {
+		if opErr != nil {
+			log.Printf("Failed to release LUKS header lock of volume \"%s\": %v", volume.Name, err)
+			return opErr
+		}
+		return err
+	}
+	return opErr
+}
@@ -16,6 +16,7 @@ import (
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/luks"
 	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
 	"gitlab.com/kubesan/kubesan/internal/csi/common/validate"
 )
@@ -66,12 +67,24 @@ func (s *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolu
 		return nil, err
 	}
 
+	// unlock encrypted volumes
+
+	path := volume.Status.Path
+	if volume.Spec.Encryption != nil {
+		path, err = s.openEncryptedVolume(ctx, volume, req.Secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// mount filesystem
 	if mount := req.VolumeCapability.GetMount(); mount != nil {
-		path := volume.Status.Path
-
 		// format and mount (Filesystem volumes only)
-		if err := s.formatAndMount(path, req.StagingTargetPath, mount.FsType, mount.MountFlags); err != nil {
+		mountFlags := mount.MountFlags
+		if volume.Spec.MutableParameters.Reclaim != nil && volume.Spec.MutableParameters.Reclaim.Policy == v1alpha1.VolumeReclaimPolicyDiscard {
+			mountFlags = append(slices.Clone(mountFlags), "discard")
+		}
+		if err := s.formatAndMount(path, req.StagingTargetPath, mount.FsType, mountFlags); err != nil {
 			return nil, err
 		}
 	} else {
@@ -82,7 +95,7 @@ func (s *NodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolu
 			return nil, err
 		}
 
-		err = os.Symlink(volume.Status.Path, req.StagingTargetPath)
+		err = os.Symlink(path, req.StagingTargetPath)
 		if err != nil {
 			return nil, err
 		}
@@ -131,6 +144,12 @@ func (s *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstage
 		}
 	}
 
+	// close LUKS mapping, if any
+
+	if err := luks.Close(ctx, req.VolumeId); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to close LUKS on volume %s: %v", req.VolumeId, err)
+	}
+
 	// detach volume from local node
 
 	volume := &v1alpha1.Volume{}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+)
+
+func (s *NodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	// validate request
+
+	if req.VolumeId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify volume id")
+	}
+
+	if req.VolumePath == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "must specify volume path")
+	}
+
+	volume := &v1alpha1.Volume{}
+	err := s.client.Get(ctx, types.NamespacedName{Name: req.VolumeId, Namespace: config.Namespace}, volume)
+	if errors.IsNotFound(err) {
+		return nil, status.Errorf(codes.NotFound, "volume \"%s\" does not exist", req.VolumeId)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The published path is a mount point for Filesystem volumes and a
+	// symlink to the device for Block volumes.
+
+	fileInfo, err := os.Stat(req.VolumePath)
+	if os.IsNotExist(err) {
+		return nil, status.Errorf(codes.NotFound, "volume path \"%s\" does not exist", req.VolumePath)
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to stat volume path \"%s\": %v", req.VolumePath, err)
+	}
+
+	var usage []*csi.VolumeUsage
+	if fileInfo.Mode()&os.ModeDevice != 0 {
+		usage, err = getBlockUsage(req.VolumePath)
+	} else {
+		usage, err = getFilesystemUsage(req.VolumePath)
+	}
+
+	// success, I/O errors are reported as an abnormal volume condition
+
+	resp := &csi.NodeGetVolumeStatsResponse{
+		Usage:           usage,
+		VolumeCondition: getVolumeCondition(volume, err),
+	}
+
+	return resp, nil
+}
+
+func getBlockUsage(path string) ([]*csi.VolumeUsage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	sizeBytes, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := []*csi.VolumeUsage{
+		{
+			Unit:  csi.VolumeUsage_BYTES,
+			Total: sizeBytes,
+		},
+	}
+	return usage, nil
+}
+
+func getFilesystemUsage(path string) ([]*csi.VolumeUsage, error) {
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(path, &statfs); err != nil {
+		return nil, err
+	}
+
+	blockSize := int64(statfs.Bsize)
+
+	usage := []*csi.VolumeUsage{
+		{
+			Unit:      csi.VolumeUsage_BYTES,
+			Total:     int64(statfs.Blocks) * blockSize,
+			Available: int64(statfs.Bavail) * blockSize,
+			Used:      int64(statfs.Blocks-statfs.Bfree) * blockSize,
+		},
+		{
+			Unit:      csi.VolumeUsage_INODES,
+			Total:     int64(statfs.Files),
+			Available: int64(statfs.Ffree),
+			Used:      int64(statfs.Files - statfs.Ffree),
+		},
+	}
+	return usage, nil
+}
+
+// Derives the CSI volume condition on this node from the Volume's
+// Status.NodeHealth entry for this node, which the node controller keeps up to
+// date with host state, and from any error encountered while accessing the
+// volume.
+func getVolumeCondition(volume *v1alpha1.Volume, accessErr error) *csi.VolumeCondition {
+	if accessErr != nil {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("failed to access volume: %v", accessErr),
+		}
+	}
+
+	if health := volume.Status.FindNodeHealth(config.LocalNodeName); health != nil && health.Degraded {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("volume is degraded: %s", health.Message),
+		}
+	}
+
+	return &csi.VolumeCondition{
+		Abnormal: false,
+		Message:  "volume is healthy",
+	}
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/backup"
+)
+
+// The cluster controller activates the snapshot's thin LV while a backup of it
+// is in progress, which also activates its thin-pool on some node. The node
+// controller on that node then copies the snapshot to the target.
+
+type BackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpBackupReconciler(mgr ctrl.Manager) error {
+	r := &BackupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Backup{}).
+		Watches(&v1alpha1.Snapshot{}, handler.EnqueueRequestsFromMapFunc(r.mapSnapshotToBackups)).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch,namespace=kubesan-system
+
+func (r *BackupReconciler) mapSnapshotToBackups(ctx context.Context, snapshot client.Object) []reconcile.Request {
+	backups := &v1alpha1.BackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range backups.Items {
+		if backups.Items[i].Spec.SourceSnapshot == snapshot.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&backups.Items[i])})
+		}
+	}
+	return requests
+}
+
+// Returns true if the backup still needs its snapshot's thin LV
+func backupInProgress(b *v1alpha1.Backup) bool {
+	return b.DeletionTimestamp == nil && !conditionsv1.IsStatusConditionTrue(b.Status.Conditions, conditionsv1.ConditionAvailable)
+}
+
+// Activates the snapshot's thin LV while any backup of it is in progress and
+// deactivates it afterwards
+func (r *BackupReconciler) updateSnapshotThinLv(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	backups := &v1alpha1.BackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(config.Namespace)); err != nil {
+		return err
+	}
+
+	needed := kubesanslices.Any(backups.Items, func(b v1alpha1.Backup) bool {
+		return b.Spec.SourceSnapshot == snapshot.Name && backupInProgress(&b)
+	})
+	return setSnapshotThinLvActive(ctx, r.Client, snapshot, needed)
+}
+
+func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("BackupReconciler entered")
+	defer log.Info("BackupReconciler exited")
+
+	b := &v1alpha1.Backup{}
+	if err := r.Get(ctx, req.NamespacedName, b); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	snapshot := &v1alpha1.Snapshot{}
+	err := r.Get(ctx, types.NamespacedName{Name: b.Spec.SourceSnapshot, Namespace: config.Namespace}, snapshot)
+	if errors.IsNotFound(err) {
+		snapshot = nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if b.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, b, snapshot)
+	}
+
+	return ctrl.Result{}, r.reconcileNotDeleting(ctx, b, snapshot)
+}
+
+func (r *BackupReconciler) reconcileNotDeleting(ctx context.Context, b *v1alpha1.Backup, snapshot *v1alpha1.Snapshot) error {
+	// add finalizer
+
+	if !controllerutil.ContainsFinalizer(b, config.Finalizer) {
+		controllerutil.AddFinalizer(b, config.Finalizer)
+
+		if err := r.Update(ctx, b); err != nil {
+			return err
+		}
+	}
+
+	if snapshot == nil {
+		if backupInProgress(b) {
+			return errors.NewBadRequest("source snapshot does not exist")
+		}
+		return nil
+	}
+
+	if snapshot.Spec.Copy != nil {
+		return errors.NewBadRequest("backing up full-copy snapshots is not supported")
+	}
+
+	if !conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return nil // wait until the snapshot has been created
+	}
+
+	if b.Status.SizeBytes == nil {
+		b.Status.SizeBytes = snapshot.Status.SizeBytes
+
+		// Restoring is limited to the namespace of the snapshotted PVC
+		// because the backup holds its data. The PV is named after the
+		// Volume.
+		pv := &corev1.PersistentVolume{}
+		err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume}, pv)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && pv.Spec.ClaimRef != nil {
+			b.Status.SourceNamespace = pv.Spec.ClaimRef.Namespace
+		}
+
+		if err := r.statusUpdate(ctx, b); err != nil {
+			return err
+		}
+	}
+
+	return r.updateSnapshotThinLv(ctx, snapshot)
+}
+
+func (r *BackupReconciler) reconcileDeleting(ctx context.Context, b *v1alpha1.Backup, snapshot *v1alpha1.Snapshot) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if b.Status.NodeName != "" {
+		log.Info("reconcileDeleting waiting for node to stop copying", "node", b.Status.NodeName)
+		return nil // wait until the node controller cancels the copy
+	}
+
+	if snapshot != nil {
+		if err := r.updateSnapshotThinLv(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+
+	if err := backup.RemoveImage(ctx, r.Client, &b.Spec.Target); err != nil {
+		return err
+	}
+
+	if controllerutil.RemoveFinalizer(b, config.Finalizer) {
+		if err := r.Update(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *BackupReconciler) statusUpdate(ctx context.Context, b *v1alpha1.Backup) error {
+	b.Status.ObservedGeneration = b.Generation
+	return r.Status().Update(ctx, b)
+}
@@ -2,7 +2,11 @@
 
 package cluster
 
-import "context"
+import (
+	"context"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+)
 
 // BlobManager abstracts operations that depend on the volume mode (linear or
 // thin).
@@ -18,6 +22,11 @@ type BlobManager interface {
 	// blob does not exist.
 	RemoveBlob(ctx context.Context, name string) error
 
+	// UpdateBlobParameters applies the tunables in params that depend on
+	// the volume mode to an existing blob. Tunables that are enforced on
+	// nodes, like I/O limits, are not handled here.
+	UpdateBlobParameters(ctx context.Context, name string, params *v1alpha1.VolumeMutableParameters) error
+
 	// GetPath returns the matching device name that should exist on
 	// any node where the blob is staged.
 	GetPath(name string) string
@@ -9,7 +9,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
 	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
 )
 
@@ -40,7 +42,7 @@ type blkdiscardWork struct {
 
 func (w *blkdiscardWork) Run(ctx context.Context) error {
 	log := log.FromContext(ctx)
-	return commands.WithLvmLvActivated(w.vgName, w.lvName, func() error {
+	return lvm.WithLvActivated(w.vgName, w.lvName, func() error {
 		path := fmt.Sprintf("/dev/%s/%s", w.vgName, w.lvName)
 		log.Info("blkdiscard worker zeroing LV", "path", path)
 		_, err := commands.RunOnHostContext(ctx, "blkdiscard", "--zeroout", path)
@@ -56,15 +58,12 @@ func (m *LinearBlobManager) blkdiscardWorkName(name string) string {
 }
 
 func (m *LinearBlobManager) CreateBlob(ctx context.Context, name string, sizeBytes int64) error {
-	_, err := commands.LvmLvCreateIdempotent(
-		"--devicesfile", m.vgName,
-		"--activate", "n",
-		"--type", "linear",
-		"--metadataprofile", "kubesan",
-		"--name", name,
-		"--size", fmt.Sprintf("%db", sizeBytes),
-		m.vgName,
-	)
+	err := lvm.CreateLvIdempotent(m.vgName, name, lvm.CreateLvOptions{
+		Type:            lvm.LvTypeLinear,
+		SizeBytes:       sizeBytes,
+		Activation:      lvm.Deactivate,
+		MetadataProfile: "kubesan",
+	})
 	if err != nil {
 		return err
 	}
@@ -74,11 +73,15 @@ func (m *LinearBlobManager) CreateBlob(ctx context.Context, name string, sizeByt
 	// different size. A blob must never be recreated after volume creation
 	// has completed since that could lose data!
 
-	// Linear volumes contain the previous contents of the disk, which can
-	// be an information leak if multiple users have access to the same
-	// Volume Group. Zero the LV to avoid security issues.
+	return m.zeroBlob(name)
+}
+
+// Linear volumes contain the previous contents of the disk, which can be an
+// information leak if multiple users have access to the same Volume Group.
+// Zero the LV to avoid security issues.
+func (m *LinearBlobManager) zeroBlob(name string) error {
 	LvmLvTagZeroed := "kubesan.gitlab.io/zeroed=true"
-	hasTag, err := commands.LvmLvHasTag(m.vgName, name, LvmLvTagZeroed)
+	hasTag, err := lvm.LvHasTag(m.vgName, name, LvmLvTagZeroed)
 	if err != nil {
 		return err
 	}
@@ -92,7 +95,7 @@ func (m *LinearBlobManager) CreateBlob(ctx context.Context, name string, sizeByt
 			return err
 		}
 
-		err = commands.LvmLvAddTag(m.vgName, name, LvmLvTagZeroed)
+		err = lvm.AddLvTag(m.vgName, name, LvmLvTagZeroed)
 		if err != nil {
 			return err
 		}
@@ -107,11 +110,11 @@ func (m *LinearBlobManager) RemoveBlob(ctx context.Context, name string) error {
 		return err
 	}
 
-	_, err := commands.LvmLvRemoveIdempotent(
-		"--devicesfile", m.vgName,
-		fmt.Sprintf("%s/%s", m.vgName, name),
-	)
-	return err
+	return lvm.RemoveLvIdempotent(m.vgName, name, lvm.RemoveLvOptions{})
+}
+
+func (m *LinearBlobManager) UpdateBlobParameters(ctx context.Context, name string, params *v1alpha1.VolumeMutableParameters) error {
+	return nil // no linear-specific tunables
 }
 
 func (m *LinearBlobManager) GetPath(name string) string {
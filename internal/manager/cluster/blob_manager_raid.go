@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// Per-reconcile invocation state
+type RaidBlobManager struct {
+	*LinearBlobManager
+	pvTags    []string
+	integrity bool
+}
+
+// NewRaidBlobManager returns a BlobManager implemented using LVM's raid1
+// logical volumes. They are fully provisioned like linear LVs but keep a
+// mirror leg on the PVs of each of pvTags, so that a volume survives the loss
+// of a storage array. RAID LVs can only be activated exclusively and are
+// therefore limited to one node at a time.
+//
+// If pvTags is empty then two legs are allocated anywhere in the VG, on
+// different PVs. If integrity is true then each leg is checksummed with
+// dm-integrity, and a corrupted block is repaired from the other leg when it
+// is read.
+func NewRaidBlobManager(workers *workers.Workers, owner client.Object, vgName string, pvTags []string, integrity bool) BlobManager {
+	return &RaidBlobManager{
+		LinearBlobManager: &LinearBlobManager{
+			workers: workers,
+			owner:   owner,
+			vgName:  vgName,
+		},
+		pvTags:    pvTags,
+		integrity: integrity,
+	}
+}
+
+// Allocating all legs with one lvcreate and a list of PV tags does not
+// guarantee that each leg lands on a different tag, so the LV is created
+// linear on the first tag's PVs and grown one leg at a time, restricting each
+// new leg to its own tag. Only the first leg is zeroed, the others are
+// synchronized from it when the LV is first activated.
+func (m *RaidBlobManager) CreateBlob(ctx context.Context, name string, sizeBytes int64) error {
+	legTags := [][]string{nil, nil} // two legs anywhere
+	if len(m.pvTags) > 0 {
+		legTags = make([][]string, len(m.pvTags))
+		for i, tag := range m.pvTags {
+			legTags[i] = []string{tag}
+		}
+	}
+
+	err := lvm.CreateLvIdempotent(m.vgName, name, lvm.CreateLvOptions{
+		Type:            lvm.LvTypeLinear,
+		SizeBytes:       sizeBytes,
+		PvTags:          legTags[0],
+		Activation:      lvm.Deactivate,
+		MetadataProfile: "kubesan",
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.zeroBlob(name); err != nil {
+		return err
+	}
+
+	lv, err := lvm.GetLv(m.vgName, name, lvm.LvFieldDataCopies, lvm.LvFieldRaidIntegrityMode)
+	if err != nil {
+		return err
+	}
+
+	for legs := int(lv.DataCopies); legs < len(legTags); legs++ {
+		err := lvm.ConvertLv(m.vgName, name, lvm.ConvertLvOptions{
+			Mirrors: legs,
+			PvTags:  legTags[legs],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.integrity && lv.RaidIntegrityMode == "" {
+		// LVM initializes the checksums of all legs in the background
+		if err := lvm.ConvertLv(m.vgName, name, lvm.ConvertLvOptions{RaidIntegrity: true}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ package cluster
 
 import (
 	"context"
+	"reflect"
 	"slices"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
@@ -27,6 +28,9 @@ type ThinBlobManager struct {
 	scheme *runtime.Scheme
 	owner  metav1.Object
 	vgName string
+
+	// The initial contents of the thin LVs
+	contents v1alpha1.ThinLvContents
 }
 
 // NewThinBlobManager returns a BlobManager implemented using LVM's thin
@@ -43,6 +47,27 @@ func NewThinBlobManager(client client.Client, scheme *runtime.Scheme, owner meta
 		scheme: scheme,
 		owner:  owner,
 		vgName: vgName,
+		contents: v1alpha1.ThinLvContents{
+			ContentsType: v1alpha1.ThinLvContentsTypeEmpty,
+		},
+	}
+}
+
+// NewThinBlobManagerWithExternalOrigin returns a ThinBlobManager whose thin
+// LVs use the read-only LV originLvName in the same VG as their external
+// origin, so that their thin pools only store what is written to them.
+func NewThinBlobManagerWithExternalOrigin(client client.Client, scheme *runtime.Scheme, owner metav1.Object, vgName string, originLvName string) BlobManager {
+	return &ThinBlobManager{
+		client: client,
+		scheme: scheme,
+		owner:  owner,
+		vgName: vgName,
+		contents: v1alpha1.ThinLvContents{
+			ContentsType: v1alpha1.ThinLvContentsTypeExternalOrigin,
+			ExternalOrigin: &v1alpha1.ThinLvContentsExternalOrigin{
+				OriginLvName: originLvName,
+			},
+		},
 	}
 }
 
@@ -71,6 +96,12 @@ func (m *ThinBlobManager) createThinPoolLv(ctx context.Context, name string, siz
 		},
 	}
 
+	if m.contents.ExternalOrigin != nil {
+		thinPoolLv.Labels = map[string]string{
+			config.ExternalOriginLabel: m.contents.ExternalOrigin.OriginLvName,
+		}
+	}
+
 	if err := controllerutil.SetControllerReference(m.owner, thinPoolLv, m.scheme); err != nil {
 		return nil, err
 	}
@@ -87,10 +118,8 @@ func (m *ThinBlobManager) createThinPoolLv(ctx context.Context, name string, siz
 // Add or update ThinLvSpec in ThinPoolLv.Spec.ThinLvs[]
 func (m *ThinBlobManager) createThinLv(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv, name string, sizeBytes int64) error {
 	thinlv := &v1alpha1.ThinLvSpec{
-		Name: name,
-		Contents: v1alpha1.ThinLvContents{
-			ContentsType: v1alpha1.ThinLvContentsTypeEmpty,
-		},
+		Name:      name,
+		Contents:  m.contents,
 		ReadOnly:  false, // TODO fill in?
 		SizeBytes: sizeBytes,
 		State: v1alpha1.ThinLvSpecState{
@@ -103,7 +132,7 @@ func (m *ThinBlobManager) createThinLv(ctx context.Context, thinPoolLv *v1alpha1
 	old := thinPoolLv.Spec.FindThinLv(name)
 	if old == nil {
 		thinPoolLv.Spec.ThinLvs = append(thinPoolLv.Spec.ThinLvs, *thinlv)
-	} else if *old == *thinlv {
+	} else if reflect.DeepEqual(old, thinlv) {
 		return nil // no change
 	} else {
 		*old = *thinlv
@@ -220,37 +249,30 @@ func (m *ThinBlobManager) RemoveBlob(ctx context.Context, name string) error {
 		return &util.WatchPending{}
 	}
 
-	// orphan thinPoolLv since we don't need it anymore but snapshots may still need it
-	// TODO can this introduce leaks?
-
-	needUpdate := false
-	if controllerutil.HasControllerReference(thinPoolLv) {
-		err = controllerutil.RemoveControllerReference(m.owner, thinPoolLv, m.scheme)
-		if err != nil {
-			log.Error(err, "RemoveControllerReference failed")
-			return err
-		}
+	// snapshots in the thin-pool hold their own owner references and
+	// delete it once the last one is gone
 
-		needUpdate = true
+	if err := thinpoollv.ReleaseThinPoolLv(ctx, m.client, m.scheme, thinPoolLv, m.owner); err != nil {
+		log.Error(err, "RemoveBlob ReleaseThinPoolLv failed")
+		return err
 	}
 
-	// update thinPoolLv to remove controller reference or clear Spec.ActiveOnNode, if necessary
+	return nil
+}
 
-	err = thinpoollv.UpdateThinPoolLv(ctx, m.client, thinPoolLv, needUpdate)
+// The node controller applies the thin-pool tunables on the node where the
+// thin-pool is active.
+func (m *ThinBlobManager) UpdateBlobParameters(ctx context.Context, name string, params *v1alpha1.VolumeMutableParameters) error {
+	thinPoolLv, err := m.getThinPoolLv(ctx, name)
 	if err != nil {
-		log.Error(err, "RemoveBlob UpdateThinPoolLv failed")
 		return err
 	}
 
-	// delete thinPoolLv without waiting, snapshots may still need it
-
-	propagation := client.PropagationPolicy(metav1.DeletePropagationForeground)
+	needUpdate := !reflect.DeepEqual(thinPoolLv.Spec.Autoextend, params.ThinPoolAutoextend) || thinPoolLv.Spec.Discards != params.Discards
 
-	if err := m.client.Delete(ctx, thinPoolLv, propagation); err != nil && !errors.IsNotFound(err) {
-		return err
-	}
-
-	return nil
+	thinPoolLv.Spec.Autoextend = params.ThinPoolAutoextend.DeepCopy()
+	thinPoolLv.Spec.Discards = params.Discards
+	return thinpoollv.UpdateThinPoolLv(ctx, m.client, thinPoolLv, needUpdate)
 }
 
 func (m *ThinBlobManager) GetPath(name string) string {
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// Per-reconcile invocation state
+type VdoBlobManager struct {
+	*LinearBlobManager
+	vdo *v1alpha1.VolumeVdo
+}
+
+// NewVdoBlobManager returns a BlobManager implemented using LVM's VDO logical
+// volumes. Each blob gets its own VDO pool that compresses and deduplicates
+// the data written to it, so the pool can be smaller than the blob. Like thin
+// pools, VDO pools can only be activated exclusively and are therefore
+// limited to one node at a time.
+func NewVdoBlobManager(workers *workers.Workers, owner client.Object, vgName string, vdo *v1alpha1.VolumeVdo) BlobManager {
+	return &VdoBlobManager{
+		LinearBlobManager: &LinearBlobManager{
+			workers: workers,
+			owner:   owner,
+			vgName:  vgName,
+		},
+		vdo: vdo,
+	}
+}
+
+func (m *VdoBlobManager) CreateBlob(ctx context.Context, name string, sizeBytes int64) error {
+	poolSizeBytes := sizeBytes
+	compression := true
+	deduplication := true
+	if m.vdo != nil {
+		poolSizeBytes = m.vdo.PoolSizeBytes
+		compression = m.vdo.Compression
+		deduplication = m.vdo.Deduplication
+	}
+
+	// VDO volumes read as zeroes until written, so there is no need to
+	// zero them like linear LVs.
+	return lvm.CreateLvIdempotent(m.vgName, name, lvm.CreateLvOptions{
+		Type:             lvm.LvTypeVdo,
+		SizeBytes:        poolSizeBytes,
+		VirtualSizeBytes: sizeBytes,
+		VdoPool:          util.VdoPoolLvName(name),
+		Compression:      compression,
+		Deduplication:    deduplication,
+		Activation:       lvm.Deactivate,
+		MetadataProfile:  "kubesan",
+	})
+}
+
+func (m *VdoBlobManager) RemoveBlob(ctx context.Context, name string) error {
+	err := lvm.RemoveLvIdempotent(m.vgName, name, lvm.RemoveLvOptions{})
+	if err != nil {
+		return err
+	}
+
+	return lvm.RemoveLvIdempotent(m.vgName, util.VdoPoolLvName(name), lvm.RemoveLvOptions{})
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// Volumes, Snapshots and GoldenImages record the objects that still read from
+// them in Status.Dependents, as "<kind>/<name>". Deleting any of them is
+// deferred with a DeletionDeferred condition until it has no dependents.
+// Thin-pools are tracked separately through owner references, see
+// thinpoollv.ReleaseThinPoolLv().
+
+// Is the volume still being populated from its source? Volumes that the
+// controller refused never get the finalizer and do not count.
+func volumeIsPopulating(volume *v1alpha1.Volume) bool {
+	return volume.DeletionTimestamp == nil && controllerutil.ContainsFinalizer(volume, config.Finalizer) &&
+		!conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, v1alpha1.VolumeConditionDataSourceCompleted)
+}
+
+// Returns the volumes and golden images whose contents are cloned or imported
+// from the volume and the snapshots of it that are still being taken
+func listVolumeDependents(ctx context.Context, c client.Client, volume *v1alpha1.Volume) ([]string, error) {
+	var dependents []string
+
+	volumes := &v1alpha1.VolumeList{}
+	if err := c.List(ctx, volumes, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range volumes.Items {
+		v := &volumes.Items[i]
+		if slices.Contains(volumeSources(v), volume.Name) && volumeIsPopulating(v) {
+			dependents = append(dependents, "Volume/"+v.Name)
+		}
+	}
+
+	snapshots := &v1alpha1.SnapshotList{}
+	if err := c.List(ctx, snapshots, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range snapshots.Items {
+		s := &snapshots.Items[i]
+		if s.Spec.SourceVolume == volume.Name && s.DeletionTimestamp == nil && !snapshotCreatedAfterDeletion(s, volume) &&
+			!conditionsv1.IsStatusConditionTrue(s.Status.Conditions, conditionsv1.ConditionAvailable) {
+			dependents = append(dependents, "Snapshot/"+s.Name)
+		}
+	}
+
+	goldenImages := &v1alpha1.GoldenImageList{}
+	if err := c.List(ctx, goldenImages, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range goldenImages.Items {
+		g := &goldenImages.Items[i]
+		if g.Spec.Source.SourceVolume == volume.Name && g.DeletionTimestamp == nil && controllerutil.ContainsFinalizer(g, config.Finalizer) &&
+			!conditionsv1.IsStatusConditionTrue(g.Status.Conditions, conditionsv1.ConditionAvailable) {
+			dependents = append(dependents, "GoldenImage/"+g.Name)
+		}
+	}
+
+	slices.Sort(dependents)
+	return dependents, nil
+}
+
+// Snapshots requested after their source volume started being deleted are
+// refused, so the volume does not wait for them
+func snapshotCreatedAfterDeletion(snapshot *v1alpha1.Snapshot, volume *v1alpha1.Volume) bool {
+	return volume.DeletionTimestamp != nil && !snapshot.CreationTimestamp.Before(volume.DeletionTimestamp)
+}
+
+// Returns the names of the volumes that the volume's contents come from
+func volumeSources(volume *v1alpha1.Volume) []string {
+	var sources []string
+	if volume.Spec.Contents.CloneVolume != nil {
+		sources = append(sources, volume.Spec.Contents.CloneVolume.SourceVolume)
+	}
+	if volume.Spec.Contents.Import != nil && volume.Spec.Contents.Import.SourceVolume != "" {
+		sources = append(sources, volume.Spec.Contents.Import.SourceVolume)
+	}
+	return sources
+}
+
+// Returns the clones being populated from the snapshot and the backups,
+// reverts and replications still using it
+func listSnapshotDependents(ctx context.Context, c client.Client, snapshot *v1alpha1.Snapshot) ([]string, error) {
+	var dependents []string
+
+	volumes := &v1alpha1.VolumeList{}
+	if err := c.List(ctx, volumes, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range volumes.Items {
+		v := &volumes.Items[i]
+		if v.Spec.Contents.CloneSnapshot != nil && v.Spec.Contents.CloneSnapshot.SourceSnapshot == snapshot.Name && volumeIsPopulating(v) {
+			dependents = append(dependents, "Volume/"+v.Name)
+		}
+	}
+
+	backups := &v1alpha1.BackupList{}
+	if err := c.List(ctx, backups, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range backups.Items {
+		b := &backups.Items[i]
+		if b.Spec.SourceSnapshot == snapshot.Name && backupInProgress(b) {
+			dependents = append(dependents, "Backup/"+b.Name)
+		}
+	}
+
+	reverts := &v1alpha1.VolumeRevertList{}
+	if err := c.List(ctx, reverts, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range reverts.Items {
+		revert := &reverts.Items[i]
+		if revert.Spec.Snapshot == snapshot.Name && revert.DeletionTimestamp == nil &&
+			!conditionsv1.IsStatusConditionTrue(revert.Status.Conditions, conditionsv1.ConditionAvailable) {
+			dependents = append(dependents, "VolumeRevert/"+revert.Name)
+		}
+	}
+
+	// the replication deletes its own snapshots once it is done with them
+
+	replications := &v1alpha1.VolumeReplicationList{}
+	if err := c.List(ctx, replications, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range replications.Items {
+		replication := &replications.Items[i]
+		if replication.DeletionTimestamp == nil &&
+			(replication.Status.LastSyncSnapshot == snapshot.Name || replication.Status.PendingSnapshot == snapshot.Name) {
+			dependents = append(dependents, "VolumeReplication/"+replication.Name)
+		}
+	}
+
+	slices.Sort(dependents)
+	return dependents, nil
+}
+
+// Returns the volumes whose thin LVs use the golden image as their external
+// origin and the thin-pools holding those thin LVs and their snapshots. They
+// depend on it for as long as the thin LVs exist.
+func listGoldenImageDependents(ctx context.Context, c client.Client, goldenImage *v1alpha1.GoldenImage) ([]string, error) {
+	var dependents []string
+
+	volumes := &v1alpha1.VolumeList{}
+	if err := c.List(ctx, volumes, client.InNamespace(config.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range volumes.Items {
+		v := &volumes.Items[i]
+		if v.Spec.Contents.GoldenImage != nil && v.Spec.Contents.GoldenImage.GoldenImage == goldenImage.Name &&
+			controllerutil.ContainsFinalizer(v, config.Finalizer) {
+			dependents = append(dependents, "Volume/"+v.Name)
+		}
+	}
+
+	// snapshots of those volumes keep their thin-pools, and so the
+	// external origin, around after the volumes are gone
+
+	thinPoolLvs := &v1alpha1.ThinPoolLvList{}
+	err := c.List(ctx, thinPoolLvs, client.InNamespace(config.Namespace),
+		client.MatchingLabels{config.ExternalOriginLabel: util.GoldenImageLvName(goldenImage.Name)})
+	if err != nil {
+		return nil, err
+	}
+	for i := range thinPoolLvs.Items {
+		tp := &thinPoolLvs.Items[i]
+		if tp.Spec.VgName == goldenImage.Spec.VgName {
+			dependents = append(dependents, "ThinPoolLv/"+tp.Name)
+		}
+	}
+
+	slices.Sort(dependents)
+	return dependents, nil
+}
+
+// Stores the current dependents and, if the object is being deleted, whether
+// its deletion must wait for them. Returns true if the status changed.
+func setDependents(statusDependents *[]string, conditions *[]conditionsv1.Condition, conditionType conditionsv1.ConditionType, dependents []string, deleting bool) bool {
+	changed := !slices.Equal(*statusDependents, dependents)
+	*statusDependents = dependents
+
+	if !deleting {
+		return changed
+	}
+
+	condition := conditionsv1.Condition{
+		Type:   conditionType,
+		Status: corev1.ConditionFalse,
+	}
+	if len(dependents) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "InUse"
+		condition.Message = "waiting for " + strings.Join(dependents, ", ")
+	}
+	if util.SetStatusConditionIfChanged(conditions, condition) {
+		changed = true
+	}
+	return changed
+}
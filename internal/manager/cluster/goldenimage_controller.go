@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// The cluster controller imports the image into a Linear LV and then makes it
+// read-only. Thin volumes using the golden image create their thin LVs with
+// it as the external origin, see ThinBlobManager, and the node controllers
+// activate it in shared mode wherever those thin LVs are active.
+
+type GoldenImageReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	workers *workers.Workers
+
+	// Import work in progress, for reporting progress
+	imports map[string]*importWork
+}
+
+func SetUpGoldenImageReconciler(mgr ctrl.Manager) error {
+	r := &GoldenImageReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		workers: workers.NewWorkers(),
+		imports: make(map[string]*importWork),
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.GoldenImage{}).
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(mapVolumeToGoldenImage)).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapThinPoolLvToGoldenImages))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
+}
+
+// Volumes using the golden image are its dependents
+func mapVolumeToGoldenImage(ctx context.Context, obj client.Object) []reconcile.Request {
+	volume := obj.(*v1alpha1.Volume)
+	if volume.Spec.Contents.GoldenImage == nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: volume.Spec.Contents.GoldenImage.GoldenImage, Namespace: config.Namespace}}}
+}
+
+// Thin-pools holding thin LVs that use the golden image are its dependents
+func (r *GoldenImageReconciler) mapThinPoolLvToGoldenImages(ctx context.Context, obj client.Object) []reconcile.Request {
+	originLvName, ok := obj.GetLabels()[config.ExternalOriginLabel]
+	if !ok {
+		return nil
+	}
+
+	goldenImages := &v1alpha1.GoldenImageList{}
+	if err := r.List(ctx, goldenImages, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range goldenImages.Items {
+		if util.GoldenImageLvName(goldenImages.Items[i].Name) == originLvName {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&goldenImages.Items[i])})
+		}
+	}
+	return requests
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=goldenimages,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=goldenimages/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=goldenimages/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=thinpoollvs,verbs=get;list;watch,namespace=kubesan-system
+
+func (r *GoldenImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("GoldenImageReconciler entered")
+	defer log.Info("GoldenImageReconciler exited")
+
+	goldenImage := &v1alpha1.GoldenImage{}
+	if err := r.Get(ctx, req.NamespacedName, goldenImage); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	blobMgr := NewLinearBlobManager(r.workers, goldenImage, goldenImage.Spec.VgName)
+
+	if err := r.reconcileDependents(ctx, goldenImage); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if goldenImage.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, blobMgr, goldenImage)
+	}
+
+	return r.reconcileNotDeleting(ctx, blobMgr, goldenImage)
+}
+
+// Records the volumes and thin-pools whose thin LVs use the golden image
+func (r *GoldenImageReconciler) reconcileDependents(ctx context.Context, goldenImage *v1alpha1.GoldenImage) error {
+	dependents, err := listGoldenImageDependents(ctx, r.Client, goldenImage)
+	if err != nil {
+		return err
+	}
+
+	if setDependents(&goldenImage.Status.Dependents, &goldenImage.Status.Conditions, v1alpha1.GoldenImageConditionDeletionDeferred, dependents, goldenImage.DeletionTimestamp != nil) {
+		return r.statusUpdate(ctx, goldenImage)
+	}
+	return nil
+}
+
+func (r *GoldenImageReconciler) reconcileNotDeleting(ctx context.Context, blobMgr BlobManager, goldenImage *v1alpha1.GoldenImage) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	// add finalizer
+
+	if !controllerutil.ContainsFinalizer(goldenImage, config.Finalizer) {
+		controllerutil.AddFinalizer(goldenImage, config.Finalizer)
+
+		if err := r.Update(ctx, goldenImage); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if conditionsv1.IsStatusConditionTrue(goldenImage.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, nil
+	}
+
+	// create the zeroed LVM LV that the image is converted into
+
+	lvName := util.GoldenImageLvName(goldenImage.Name)
+
+	err := blobMgr.CreateBlob(ctx, lvName, goldenImage.Spec.SizeBytes)
+	if err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			log.Info("CreateBlob waiting for Watch")
+			return ctrl.Result{}, nil // wait until Watch triggers
+		}
+		return ctrl.Result{}, err
+	}
+
+	result, err := r.reconcileImport(ctx, goldenImage)
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	// thin LVs can only use read-only LVs as their external origin
+
+	if err := lvm.SetLvReadOnly(goldenImage.Spec.VgName, lvName); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("golden image available")
+
+	condition := conditionsv1.Condition{
+		Type:   conditionsv1.ConditionAvailable,
+		Status: corev1.ConditionTrue,
+	}
+	conditionsv1.SetStatusCondition(&goldenImage.Status.Conditions, condition)
+
+	return ctrl.Result{}, r.statusUpdate(ctx, goldenImage)
+}
+
+// Returns a unique name for a golden image import work item
+func goldenImageImportWorkName(goldenImage *v1alpha1.GoldenImage) string {
+	return fmt.Sprintf("goldenimage/%s/%s", goldenImage.Spec.VgName, goldenImage.Name)
+}
+
+// Converts the image into the golden image's LV in the background, reflecting
+// progress in the Progressing condition. Returns a zero result once the image
+// has been imported.
+func (r *GoldenImageReconciler) reconcileImport(ctx context.Context, goldenImage *v1alpha1.GoldenImage) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	// the LV may already have been made read-only
+
+	progressing := conditionsv1.FindStatusCondition(goldenImage.Status.Conditions, v1alpha1.GoldenImageConditionProgressing)
+	if progressing != nil && progressing.Reason == "Imported" {
+		return ctrl.Result{}, nil
+	}
+
+	name := goldenImageImportWorkName(goldenImage)
+	work, ok := r.imports[name]
+	if !ok {
+		var err error
+		work, err = newImageImportWork(ctx, r.Client, &goldenImage.Spec.Source, goldenImage.Spec.VgName, util.GoldenImageLvName(goldenImage.Name), goldenImage.Spec.SizeBytes)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.imports[name] = work
+	}
+
+	err := r.workers.Run(name, goldenImage, work)
+	if _, ok := err.(*util.WatchPending); ok {
+		percent := work.progress()
+		changed := util.SetStatusConditionIfChanged(&goldenImage.Status.Conditions, conditionsv1.Condition{
+			Type:    v1alpha1.GoldenImageConditionProgressing,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Importing",
+			Message: fmt.Sprintf("%.0f%% imported", percent),
+		})
+		if goldenImage.Status.ProgressPercent != int32(percent) {
+			goldenImage.Status.ProgressPercent = int32(percent)
+			changed = true
+		}
+		if changed {
+			if err := r.statusUpdate(ctx, goldenImage); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: importProgressInterval}, nil
+	}
+	delete(r.imports, name)
+
+	if err != nil {
+		log.Error(err, "golden image import failed")
+		conditionsv1.SetStatusCondition(&goldenImage.Status.Conditions, conditionsv1.Condition{
+			Type:   v1alpha1.GoldenImageConditionProgressing,
+			Status: corev1.ConditionFalse,
+			Reason: "ImportFailed",
+		})
+		conditionsv1.SetStatusCondition(&goldenImage.Status.Conditions, conditionsv1.Condition{
+			Type:    v1alpha1.GoldenImageConditionFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  "ImportFailed",
+			Message: err.Error(),
+		})
+		if err := r.statusUpdate(ctx, goldenImage); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// returning the error retries the import with backoff
+		return ctrl.Result{}, err
+	}
+
+	log.Info("golden image import succeeded")
+	conditionsv1.SetStatusCondition(&goldenImage.Status.Conditions, conditionsv1.Condition{
+		Type:   v1alpha1.GoldenImageConditionProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "Imported",
+	})
+	conditionsv1.RemoveStatusCondition(&goldenImage.Status.Conditions, v1alpha1.GoldenImageConditionFailed)
+	goldenImage.Status.ProgressPercent = 100
+	return ctrl.Result{}, r.statusUpdate(ctx, goldenImage)
+}
+
+func (r *GoldenImageReconciler) reconcileDeleting(ctx context.Context, blobMgr BlobManager, goldenImage *v1alpha1.GoldenImage) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if len(goldenImage.Status.Dependents) > 0 {
+		log.Info("reconcileDeleting waiting for Dependents[] to become empty", "dependents", goldenImage.Status.Dependents)
+		return nil // the watches trigger once the volumes and thin-pools are gone
+	}
+
+	name := goldenImageImportWorkName(goldenImage)
+	if err := r.workers.Cancel(name); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			log.Info("cancel import waiting for Watch")
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+	delete(r.imports, name)
+
+	if err := blobMgr.RemoveBlob(ctx, util.GoldenImageLvName(goldenImage.Name)); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			log.Info("RemoveBlob waiting for Watch")
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+
+	if controllerutil.RemoveFinalizer(goldenImage, config.Finalizer) {
+		if err := r.Update(ctx, goldenImage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *GoldenImageReconciler) statusUpdate(ctx context.Context, goldenImage *v1alpha1.GoldenImage) error {
+	goldenImage.Status.ObservedGeneration = goldenImage.Generation
+	return r.Status().Update(ctx, goldenImage)
+}
@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// A group snapshot creates one member Snapshot per source volume and steps
+// them through taking the snapshot together. All members freeze their source
+// volume by the group's deadline. Once every member is frozen, or has given
+// up, the group sets its Consistency and the members cut their thin
+// snapshots. Once every member is cut, the group sets its CreationTime and the
+// members become available, which has the nodes thaw all source volumes.
+
+type GroupSnapshotReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpGroupSnapshotReconciler(mgr ctrl.Manager) error {
+	r := &GroupSnapshotReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.GroupSnapshot{}).
+		Owns(&v1alpha1.Snapshot{}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=groupsnapshots,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=groupsnapshots/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=groupsnapshots/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch,namespace=kubesan-system
+
+func (r *GroupSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("GroupSnapshotReconciler entered")
+	defer log.Info("GroupSnapshotReconciler exited")
+
+	group := &v1alpha1.GroupSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, group); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if group.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, group)
+	}
+
+	return ctrl.Result{}, r.reconcileNotDeleting(ctx, group)
+}
+
+func (r *GroupSnapshotReconciler) reconcileNotDeleting(ctx context.Context, group *v1alpha1.GroupSnapshot) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if conditionsv1.IsStatusConditionTrue(group.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return nil
+	}
+
+	// add finalizer
+
+	if !controllerutil.ContainsFinalizer(group, config.Finalizer) {
+		controllerutil.AddFinalizer(group, config.Finalizer)
+
+		if err := r.Update(ctx, group); err != nil {
+			return err
+		}
+	}
+
+	// the freeze deadline starts before the members exist so that they
+	// all share it
+
+	if len(group.Status.Snapshots) == 0 {
+		var names []string
+		for i := range group.Spec.SourceVolumes {
+			names = append(names, util.GroupSnapshotMemberName(group.Name, i))
+		}
+		deadline := metav1.NewTime(time.Now().Add(config.SnapshotFreezeTimeout))
+
+		group.Status.Snapshots = names
+		group.Status.FreezeDeadline = &deadline
+		if err := r.statusUpdate(ctx, group); err != nil {
+			return err
+		}
+	}
+
+	members, err := r.getMembers(ctx, group)
+	if err != nil {
+		return err
+	}
+
+	// Members are only created before they are cut, so that one that
+	// went missing later is not silently taken at a different time
+
+	if group.Status.CreationTime == nil {
+		for i := range members {
+			if members[i] != nil {
+				continue
+			}
+			members[i], err = r.createMember(ctx, group, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, member := range members {
+		if member == nil || member.DeletionTimestamp != nil {
+			return errors.NewBadRequest(fmt.Sprintf("member snapshot \"%s\" is gone", group.Status.Snapshots[i]))
+		}
+	}
+
+	if group.Status.Consistency == "" {
+		for _, member := range members {
+			if member.Status.Consistency == "" {
+				return nil // wait until all members are frozen or have given up
+			}
+		}
+
+		log.Info("All members frozen, cutting snapshots")
+
+		group.Status.Consistency = membersConsistency(members)
+		return r.statusUpdate(ctx, group)
+	}
+
+	if group.Status.CreationTime == nil {
+		for _, member := range members {
+			if member.Status.CreationTime == nil {
+				return nil // wait until all members are cut
+			}
+		}
+
+		log.Info("All members cut, thawing source volumes")
+
+		// members cut after the deadline have turned Crash
+		now := metav1.Now()
+		group.Status.CreationTime = &now
+		group.Status.FreezeDeadline = nil
+		group.Status.Consistency = membersConsistency(members)
+		return r.statusUpdate(ctx, group)
+	}
+
+	for _, member := range members {
+		if !conditionsv1.IsStatusConditionTrue(member.Status.Conditions, conditionsv1.ConditionAvailable) {
+			return nil // wait until all members are available
+		}
+	}
+
+	log.Info("Group snapshot created", "snapshots", group.Status.Snapshots)
+
+	conditionsv1.SetStatusCondition(&group.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionAvailable,
+		Status: corev1.ConditionTrue,
+	})
+	return r.statusUpdate(ctx, group)
+}
+
+// Returns the member snapshots in the order of Status.Snapshots, with nil for
+// those that do not exist
+func (r *GroupSnapshotReconciler) getMembers(ctx context.Context, group *v1alpha1.GroupSnapshot) ([]*v1alpha1.Snapshot, error) {
+	members := make([]*v1alpha1.Snapshot, len(group.Status.Snapshots))
+
+	for i, name := range group.Status.Snapshots {
+		member := &v1alpha1.Snapshot{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: config.Namespace}, member)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if member.Spec.GroupSnapshot != group.Name {
+			return nil, errors.NewBadRequest(fmt.Sprintf("snapshot \"%s\" already exists outside of the group", name))
+		}
+		members[i] = member
+	}
+
+	return members, nil
+}
+
+// Creates the member snapshot of the index-th source volume
+func (r *GroupSnapshotReconciler) createMember(ctx context.Context, group *v1alpha1.GroupSnapshot, index int) (*v1alpha1.Snapshot, error) {
+	source := &v1alpha1.Volume{}
+	err := r.Get(ctx, types.NamespacedName{Name: group.Spec.SourceVolumes[index], Namespace: config.Namespace}, source)
+	if err != nil {
+		return nil, err
+	}
+	if source.Spec.Mode != v1alpha1.VolumeModeThin {
+		return nil, errors.NewBadRequest("group snapshots are only supported for Thin volumes")
+	}
+	if source.DeletionTimestamp != nil {
+		return nil, errors.NewBadRequest(fmt.Sprintf("source volume \"%s\" is being deleted", source.Name))
+	}
+
+	member := &v1alpha1.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      group.Status.Snapshots[index],
+			Namespace: config.Namespace,
+		},
+		Spec: v1alpha1.SnapshotSpec{
+			VgName:        source.Spec.VgName,
+			SourceVolume:  source.Name,
+			GroupSnapshot: group.Name,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(group, member, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if err := r.Create(ctx, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// The group is only Quiesced if all of its members are
+func membersConsistency(members []*v1alpha1.Snapshot) v1alpha1.SnapshotConsistency {
+	for _, member := range members {
+		if member.Status.Consistency != v1alpha1.SnapshotConsistencyQuiesced {
+			return v1alpha1.SnapshotConsistencyCrash
+		}
+	}
+	return v1alpha1.SnapshotConsistencyQuiesced
+}
+
+// Deletes the member snapshots and waits until they are gone, which may be
+// deferred while they have dependents
+func (r *GroupSnapshotReconciler) reconcileDeleting(ctx context.Context, group *v1alpha1.GroupSnapshot) error {
+	members, err := r.getMembers(ctx, group)
+	if err != nil {
+		return err
+	}
+
+	var remaining, deferred []string
+	for _, member := range members {
+		if member == nil {
+			continue
+		}
+		remaining = append(remaining, member.Name)
+
+		if member.DeletionTimestamp == nil {
+			if err := r.Delete(ctx, member); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+		if len(member.Status.Dependents) > 0 {
+			deferred = append(deferred, "Snapshot/"+member.Name)
+		}
+	}
+
+	condition := conditionsv1.Condition{
+		Type:   v1alpha1.GroupSnapshotConditionDeletionDeferred,
+		Status: corev1.ConditionFalse,
+	}
+	if len(deferred) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "InUse"
+		condition.Message = "waiting for " + strings.Join(deferred, ", ")
+	}
+	if util.SetStatusConditionIfChanged(&group.Status.Conditions, condition) {
+		if err := r.statusUpdate(ctx, group); err != nil {
+			return err
+		}
+	}
+
+	if len(remaining) > 0 {
+		log.FromContext(ctx).Info("Group snapshot deletion waiting for members", "snapshots", remaining)
+		return nil // the Owns watch triggers once the members are gone
+	}
+
+	if controllerutil.RemoveFinalizer(group, config.Finalizer) {
+		if err := r.Update(ctx, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *GroupSnapshotReconciler) statusUpdate(ctx context.Context, group *v1alpha1.GroupSnapshot) error {
+	group.Status.ObservedGeneration = group.Generation
+	return r.Status().Update(ctx, group)
+}
@@ -4,34 +4,673 @@ package cluster
 
 import (
 	"context"
+	"reflect"
+	"slices"
+	"time"
 
-	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
 )
 
+// Snapshots with Spec.Copy are first taken as thin LVs in the thin-pool of
+// their source volume like any other snapshot. The cluster controller then
+// creates the LV holding the copy and activates both, the node controller on
+// the node where they are active copies the data, and finally the thin LV is
+// removed again.
+
 type SnapshotReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme  *runtime.Scheme
+	workers *workers.Workers // for LinearBlobManager
 }
 
 func SetUpSnapshotReconciler(mgr ctrl.Manager) error {
 	r := &SnapshotReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		workers: workers.NewWorkers(),
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Snapshot{}).
-		Complete(r)
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapThinPoolLvToSnapshots)).
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(r.mapVolumeToSnapshots)).
+		Watches(&v1alpha1.Backup{}, handler.EnqueueRequestsFromMapFunc(r.mapBackupToSnapshots)).
+		Watches(&v1alpha1.VolumeRevert{}, handler.EnqueueRequestsFromMapFunc(r.mapVolumeRevertToSnapshots)).
+		Watches(&v1alpha1.VolumeReplication{}, handler.EnqueueRequestsFromMapFunc(r.mapVolumeReplicationToSnapshots)).
+		Watches(&v1alpha1.GroupSnapshot{}, handler.EnqueueRequestsFromMapFunc(r.mapGroupSnapshotToSnapshots)).
+		Watches(&v1alpha1.VolumeMigration{}, handler.EnqueueRequestsFromMapFunc(r.mapVolumeMigrationToSnapshots))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
 }
 
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots/status,verbs=get;update;patch,namespace=kubesan-system
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereverts,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=groupsnapshots,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations,verbs=get;list;watch,namespace=kubesan-system
+
+// Snapshots live in the thin-pool of their source volume, which is named after
+// the volume. Thin copies live in a thin-pool named after the snapshot.
+func (r *SnapshotReconciler) mapThinPoolLvToSnapshots(ctx context.Context, thinPoolLv client.Object) []reconcile.Request {
+	snapshots := &v1alpha1.SnapshotList{}
+	if err := r.List(ctx, snapshots, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range snapshots.Items {
+		if snapshots.Items[i].Spec.SourceVolume == thinPoolLv.GetName() || snapshots.Items[i].Name == thinPoolLv.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&snapshots.Items[i])})
+		}
+	}
+	return requests
+}
+
+// The following map the objects that may depend on a snapshot to it
+
+func snapshotRequests(names ...string) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range names {
+		if name != "" {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: config.Namespace}})
+		}
+	}
+	return requests
+}
+
+func (r *SnapshotReconciler) mapVolumeToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	volume := obj.(*v1alpha1.Volume)
+	if volume.Spec.Contents.CloneSnapshot == nil {
+		return nil
+	}
+	return snapshotRequests(volume.Spec.Contents.CloneSnapshot.SourceSnapshot)
+}
+
+func (r *SnapshotReconciler) mapBackupToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	return snapshotRequests(obj.(*v1alpha1.Backup).Spec.SourceSnapshot)
+}
+
+func (r *SnapshotReconciler) mapVolumeRevertToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	return snapshotRequests(obj.(*v1alpha1.VolumeRevert).Spec.Snapshot)
+}
+
+func (r *SnapshotReconciler) mapVolumeReplicationToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	replication := obj.(*v1alpha1.VolumeReplication)
+	return snapshotRequests(replication.Status.LastSyncSnapshot, replication.Status.PendingSnapshot)
+}
+
+// Members of a group snapshot wait for it between the steps of taking them
+func (r *SnapshotReconciler) mapGroupSnapshotToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	return snapshotRequests(obj.(*v1alpha1.GroupSnapshot).Status.Snapshots...)
+}
+
+// Snapshots of a volume wait for its migrations to finish
+func (r *SnapshotReconciler) mapVolumeMigrationToSnapshots(ctx context.Context, obj client.Object) []reconcile.Request {
+	snapshots := &v1alpha1.SnapshotList{}
+	if err := r.List(ctx, snapshots, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range snapshots.Items {
+		if snapshots.Items[i].Spec.SourceVolume == obj.(*v1alpha1.VolumeMigration).Spec.Volume {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&snapshots.Items[i])})
+		}
+	}
+	return requests
+}
 
 func (r *SnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	return ctrl.Result{}, errors.NewBadRequest("not implemented") // TODO
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("SnapshotReconciler entered")
+	defer log.Info("SnapshotReconciler exited")
+
+	snapshot := &v1alpha1.Snapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, thinPoolLv)
+	if errors.IsNotFound(err) {
+		thinPoolLv = nil
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileDependents(ctx, snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if snapshot.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, snapshot, thinPoolLv)
+	}
+
+	return r.reconcileNotDeleting(ctx, snapshot, thinPoolLv)
+}
+
+func (r *SnapshotReconciler) reconcileNotDeleting(ctx context.Context, snapshot *v1alpha1.Snapshot, thinPoolLv *v1alpha1.ThinPoolLv) (ctrl.Result, error) {
+	if conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, r.reconcileUsage(ctx, snapshot, thinPoolLv)
+	}
+
+	// add finalizer
+
+	if !controllerutil.ContainsFinalizer(snapshot, config.Finalizer) {
+		controllerutil.AddFinalizer(snapshot, config.Finalizer)
+
+		if err := r.Update(ctx, snapshot); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	source := &v1alpha1.Volume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, source); err != nil {
+		return ctrl.Result{}, err
+	}
+	if source.Spec.Mode != v1alpha1.VolumeModeThin {
+		return ctrl.Result{}, errors.NewBadRequest("snapshots are only supported for Thin volumes")
+	}
+	if thinPoolLv == nil || thinPoolLv.DeletionTimestamp != nil || snapshotCreatedAfterDeletion(snapshot, source) {
+		return ctrl.Result{}, errors.NewBadRequest("source volume is being deleted")
+	}
+
+	group, err := r.getGroupSnapshot(ctx, snapshot)
+	if err != nil || (group == nil && snapshot.Spec.GroupSnapshot != "") {
+		return ctrl.Result{}, err // the member is deleted along with its group
+	}
+
+	sourceThinLvName := thinpoollv.VolumeToThinLvName(source.Name)
+	sourceThinLvStatus := thinPoolLv.Status.FindThinLv(sourceThinLvName)
+	if sourceThinLvStatus == nil {
+		return ctrl.Result{}, nil // wait until the source thin LV exists
+	}
+
+	// add the snapshot thin LV to the source's thin-pool
+
+	thinLvName := thinpoollv.SnapshotToThinLvName(snapshot.Name)
+	copied := conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, v1alpha1.SnapshotConditionCopied)
+	if thinPoolLv.Spec.FindThinLv(thinLvName) == nil && !copied {
+		if migration, err := unfinishedMigration(ctx, r.Client, source.Name); err != nil || migration != "" {
+			if migration != "" {
+				log.FromContext(ctx).Info("Waiting for volume migration to finish", "migration", migration)
+			}
+			return ctrl.Result{}, err
+		}
+
+		if requeueAfter, err := r.reconcileFreeze(ctx, snapshot, source, group); err != nil || requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, err
+		}
+
+		if group != nil && group.Status.Consistency == "" {
+			return ctrl.Result{}, nil // wait until all members of the group are frozen
+		}
+
+		thinPoolLv.Spec.ThinLvs = append(thinPoolLv.Spec.ThinLvs, v1alpha1.ThinLvSpec{
+			Name: thinLvName,
+			Contents: v1alpha1.ThinLvContents{
+				ContentsType: v1alpha1.ThinLvContentsTypeSnapshot,
+				Snapshot: &v1alpha1.ThinLvContentsSnapshot{
+					SourceThinLvName: sourceThinLvName,
+				},
+			},
+			ReadOnly:  true,
+			SizeBytes: sourceThinLvStatus.SizeBytes,
+			State: v1alpha1.ThinLvSpecState{
+				Name: v1alpha1.ThinLvSpecStateNameInactive,
+			},
+		})
+
+		// the thin-pool outlives the source volume until the
+		// snapshot releases it
+
+		if err := controllerutil.SetOwnerReference(snapshot, thinPoolLv, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, true)
+	}
+
+	if thinPoolLv.Status.FindThinLv(thinLvName) == nil && !copied {
+		return ctrl.Result{}, nil // wait until the node controller creates the thin LV
+	}
+
+	// The snapshot's point-in-time is cut once its thin LV exists, even if
+	// more work remains before it is available
+
+	if snapshot.Status.CreationTime == nil {
+		// The thin LV may have been created after the nodes thawed the
+		// file system. Nodes thaw by the deadline, so seeing the thin
+		// LV before it means it was created while frozen. Members of a
+		// group stay frozen until the whole group has been cut.
+
+		if snapshot.Status.FreezeDeadline != nil {
+			if !time.Now().Before(snapshot.Status.FreezeDeadline.Time) {
+				snapshot.Status.Consistency = v1alpha1.SnapshotConsistencyCrash
+			}
+			if group == nil {
+				snapshot.Status.FreezeDeadline = nil
+			}
+		}
+
+		now := metav1.Now()
+		snapshot.Status.CreationTime = &now
+
+		sizeBytes := source.Spec.SizeBytes
+		snapshot.Status.SizeBytes = &sizeBytes
+
+		if source.Spec.Type.Filesystem != nil {
+			fsType := source.Spec.Type.Filesystem.FsType
+			snapshot.Status.FsType = &fsType
+		}
+
+		if err := r.statusUpdate(ctx, snapshot); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if snapshot.Spec.Copy != nil {
+		if done, err := r.reconcileCopy(ctx, snapshot, thinPoolLv); err != nil || !done {
+			return ctrl.Result{}, err
+		}
+	} else {
+		// clear thinPoolLv.Spec.ActiveOnNode, if necessary
+
+		if err := thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, false); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if group != nil {
+		if group.Status.CreationTime == nil {
+			return ctrl.Result{}, nil // wait until all members of the group are cut
+		}
+		snapshot.Status.FreezeDeadline = nil
+	}
+
+	log.FromContext(ctx).Info("Snapshot created", "thin LV", thinLvName)
+
+	condition := conditionsv1.Condition{
+		Type:   conditionsv1.ConditionAvailable,
+		Status: corev1.ConditionTrue,
+	}
+	conditionsv1.SetStatusCondition(&snapshot.Status.Conditions, condition)
+
+	return ctrl.Result{}, r.statusUpdate(ctx, snapshot)
+}
+
+// Copy the space used by the snapshot from its ThinPoolLv, where the node
+// controller measures it
+func (r *SnapshotReconciler) reconcileUsage(ctx context.Context, snapshot *v1alpha1.Snapshot, thinPoolLv *v1alpha1.ThinPoolLv) error {
+	if thinPoolLv == nil {
+		return nil
+	}
+
+	thinLvStatus := thinPoolLv.Status.FindThinLv(thinpoollv.SnapshotToThinLvName(snapshot.Name))
+	if thinLvStatus == nil || thinLvStatus.AllocatedBytes == nil || thinLvStatus.ExclusiveBytes == nil {
+		return nil
+	}
+
+	if reflect.DeepEqual(snapshot.Status.AllocatedBytes, thinLvStatus.AllocatedBytes) &&
+		reflect.DeepEqual(snapshot.Status.ExclusiveBytes, thinLvStatus.ExclusiveBytes) {
+		return nil
+	}
+
+	allocatedBytes := *thinLvStatus.AllocatedBytes
+	exclusiveBytes := *thinLvStatus.ExclusiveBytes
+	snapshot.Status.AllocatedBytes = &allocatedBytes
+	snapshot.Status.ExclusiveBytes = &exclusiveBytes
+	return r.statusUpdate(ctx, snapshot)
+}
+
+// A migration only moves the volume's thin LV and removes its source
+// thin-pool, so a snapshot taken before it finishes would be lost. Returns the
+// name of a migration of the volume that has not finished, or "" if there is
+// none. Migrations that have not been taken on by a node yet count too, since
+// the check for snapshots at their start may already have passed.
+func unfinishedMigration(ctx context.Context, c client.Client, volumeName string) (string, error) {
+	migrations := &v1alpha1.VolumeMigrationList{}
+	if err := c.List(ctx, migrations, client.InNamespace(config.Namespace)); err != nil {
+		return "", err
+	}
+	for i := range migrations.Items {
+		if migrations.Items[i].Spec.Volume == volumeName && !migrations.Items[i].Status.IsFinished() {
+			return migrations.Items[i].Name, nil
+		}
+	}
+	return "", nil
+}
+
+// Returns the group snapshot the snapshot is a member of, or nil if it is not a
+// member or the group is gone
+func (r *SnapshotReconciler) getGroupSnapshot(ctx context.Context, snapshot *v1alpha1.Snapshot) (*v1alpha1.GroupSnapshot, error) {
+	if snapshot.Spec.GroupSnapshot == "" {
+		return nil, nil
+	}
+
+	group := &v1alpha1.GroupSnapshot{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.GroupSnapshot, Namespace: config.Namespace}, group)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Asks the nodes that have the source volume attached to freeze its file
+// system and waits until they have, or until the deadline passes. Records the
+// resulting consistency. Returns how long to wait, or 0 if the snapshot can
+// be taken now. Members of a group also freeze block volumes, by the group's
+// deadline.
+func (r *SnapshotReconciler) reconcileFreeze(ctx context.Context, snapshot *v1alpha1.Snapshot, source *v1alpha1.Volume, group *v1alpha1.GroupSnapshot) (time.Duration, error) {
+	if snapshot.Status.Consistency != "" {
+		return 0, nil // already decided
+	}
+
+	if len(source.Status.AttachedToNodes) == 0 {
+		snapshot.Status.Consistency = v1alpha1.SnapshotConsistencyQuiesced
+		return 0, r.statusUpdate(ctx, snapshot)
+	}
+
+	// a thin snapshot of a single block volume is crash consistent anyway
+
+	if source.Spec.Type.Filesystem == nil && group == nil {
+		snapshot.Status.Consistency = v1alpha1.SnapshotConsistencyCrash
+		return 0, r.statusUpdate(ctx, snapshot)
+	}
+
+	if snapshot.Status.FreezeDeadline == nil {
+		log.FromContext(ctx).Info("Requesting file system freeze", "nodes", source.Status.AttachedToNodes)
+
+		deadline := metav1.NewTime(time.Now().Add(config.SnapshotFreezeTimeout))
+		if group != nil && group.Status.FreezeDeadline != nil {
+			deadline = *group.Status.FreezeDeadline
+		}
+		snapshot.Status.FreezeDeadline = &deadline
+		if err := r.statusUpdate(ctx, snapshot); err != nil {
+			return 0, err
+		}
+	}
+
+	allFrozen := !kubesanslices.Any(source.Status.AttachedToNodes, func(node string) bool {
+		return !slices.Contains(snapshot.Status.FrozenNodes, node)
+	})
+	remaining := time.Until(snapshot.Status.FreezeDeadline.Time)
+
+	switch {
+	case allFrozen && remaining > 0:
+		snapshot.Status.Consistency = v1alpha1.SnapshotConsistencyQuiesced
+	case remaining > 0:
+		return remaining, nil // wait until the nodes have frozen the file system
+	default:
+		log.FromContext(ctx).Info("Timed out waiting for file system freeze", "frozen nodes", snapshot.Status.FrozenNodes)
+		snapshot.Status.Consistency = v1alpha1.SnapshotConsistencyCrash
+	}
+	return 0, r.statusUpdate(ctx, snapshot)
+}
+
+// Records the objects that still read from the snapshot
+func (r *SnapshotReconciler) reconcileDependents(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	dependents, err := listSnapshotDependents(ctx, r.Client, snapshot)
+	if err != nil {
+		return err
+	}
+
+	if setDependents(&snapshot.Status.Dependents, &snapshot.Status.Conditions, v1alpha1.SnapshotConditionDeletionDeferred, dependents, snapshot.DeletionTimestamp != nil) {
+		return r.statusUpdate(ctx, snapshot)
+	}
+	return nil
+}
+
+func (r *SnapshotReconciler) reconcileDeleting(ctx context.Context, snapshot *v1alpha1.Snapshot, thinPoolLv *v1alpha1.ThinPoolLv) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if len(snapshot.Status.Dependents) > 0 {
+		log.Info("Snapshot deletion deferred", "dependents", snapshot.Status.Dependents)
+		return nil // the watches trigger once the dependents are done
+	}
+
+	if snapshot.Status.CopyNodeName != "" {
+		log.Info("reconcileDeleting waiting for node to stop copying", "node", snapshot.Status.CopyNodeName)
+		return nil // wait until the node controller cancels the copy
+	}
+
+	if nodes, err := r.nodesStillFrozen(ctx, snapshot); err != nil {
+		return err
+	} else if len(nodes) > 0 {
+		log.Info("reconcileDeleting waiting for nodes to thaw the source volume", "nodes", nodes)
+		return nil // wait until the node controllers thaw it
+	}
+
+	if done, err := r.removeThinLv(ctx, snapshot, thinPoolLv); err != nil || !done {
+		return err
+	}
+
+	if snapshot.Spec.Copy != nil {
+		if err := r.newCopyBlobManager(snapshot).RemoveBlob(ctx, snapshot.Name); err != nil {
+			if _, ok := err.(*util.WatchPending); ok {
+				return nil // wait until Watch triggers
+			}
+			return err
+		}
+	}
+
+	if controllerutil.RemoveFinalizer(snapshot, config.Finalizer) {
+		if err := r.Update(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the nodes that may still have the source volume frozen for the
+// snapshot. The snapshot must not go away before they thaw it, since they only
+// know about the freeze through the snapshot. Nodes to which the volume is no
+// longer attached are ignored, e.g. if they went away.
+func (r *SnapshotReconciler) nodesStillFrozen(ctx context.Context, snapshot *v1alpha1.Snapshot) ([]string, error) {
+	if len(snapshot.Status.FreezingNodes) == 0 && len(snapshot.Status.FrozenNodes) == 0 {
+		return nil, nil
+	}
+
+	source := &v1alpha1.Volume{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, source)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for _, node := range append(slices.Clone(snapshot.Status.FreezingNodes), snapshot.Status.FrozenNodes...) {
+		if source.Status.IsAttachedToNode(node) {
+			nodes = kubesanslices.AppendUnique(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// Removes the snapshot's thin LV from the thin-pool of its source volume and
+// drops the snapshot's owner reference to the thin-pool, deleting it if the
+// snapshot was its last user. Returns true once done.
+func (r *SnapshotReconciler) removeThinLv(ctx context.Context, snapshot *v1alpha1.Snapshot, thinPoolLv *v1alpha1.ThinPoolLv) (bool, error) {
+	if thinPoolLv == nil {
+		return true, nil
+	}
+
+	thinLvName := thinpoollv.SnapshotToThinLvName(snapshot.Name)
+	thinLvSpec := thinPoolLv.Spec.FindThinLv(thinLvName)
+
+	if thinLvSpec != nil {
+		thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvName)
+		removed := thinLvStatus == nil || thinLvStatus.State.Name == v1alpha1.ThinLvStatusStateNameRemoved
+
+		// The thin-pool is not activated while it is being
+		// deleted, instead the cluster controller removes all
+		// of its thin LVs once the last snapshot is gone.
+
+		if removed || thinPoolLv.DeletionTimestamp != nil {
+			i := slices.IndexFunc(thinPoolLv.Spec.ThinLvs, func(spec v1alpha1.ThinLvSpec) bool { return spec.Name == thinLvName })
+			thinPoolLv.Spec.ThinLvs = slices.Delete(thinPoolLv.Spec.ThinLvs, i, i+1)
+			return false, thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, true)
+		}
+
+		if thinLvSpec.State.Name != v1alpha1.ThinLvSpecStateNameRemoved {
+			thinLvSpec.State = v1alpha1.ThinLvSpecState{
+				Name: v1alpha1.ThinLvSpecStateNameRemoved,
+			}
+			return false, thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, true)
+		}
+
+		return false, nil // wait until the node controller removes the thin LV
+	}
+
+	return true, thinpoollv.ReleaseThinPoolLv(ctx, r.Client, r.Scheme, thinPoolLv, snapshot)
+}
+
+// Returns the BlobManager for the LV holding the copy of a snapshot with
+// Spec.Copy, which is named after the snapshot
+func (r *SnapshotReconciler) newCopyBlobManager(snapshot *v1alpha1.Snapshot) BlobManager {
+	if snapshot.Spec.Copy.Mode == v1alpha1.SnapshotCopyModeThin {
+		return NewThinBlobManager(r.Client, r.Scheme, snapshot, snapshot.Spec.Copy.VgName)
+	}
+	return NewLinearBlobManager(r.workers, snapshot, snapshot.Spec.Copy.VgName)
+}
+
+// Has the snapshot copied into its own LV and then removes its thin LV.
+// Returns true once done.
+func (r *SnapshotReconciler) reconcileCopy(ctx context.Context, snapshot *v1alpha1.Snapshot, thinPoolLv *v1alpha1.ThinPoolLv) (bool, error) {
+	blobMgr := r.newCopyBlobManager(snapshot)
+	thinLvName := thinpoollv.SnapshotToThinLvName(snapshot.Name)
+
+	if conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, v1alpha1.SnapshotConditionCopied) {
+		if snapshot.Spec.Copy.Mode == v1alpha1.SnapshotCopyModeThin {
+			if err := r.setCopyThinLvActive(ctx, snapshot, ""); err != nil {
+				return false, err
+			}
+		}
+
+		// the thin LV must be inactive before it can be removed
+
+		if err := setSnapshotThinLvActive(ctx, r.Client, snapshot, false); err != nil {
+			return false, err
+		}
+		thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvName)
+		if thinLvStatus != nil && thinLvStatus.State.Name == v1alpha1.ThinLvStatusStateNameActive {
+			return false, nil // wait until the node controller deactivates the thin LV
+		}
+
+		return r.removeThinLv(ctx, snapshot, thinPoolLv)
+	}
+
+	// create the LV holding the copy, which reads as zeroes
+
+	thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvName)
+	if err := blobMgr.CreateBlob(ctx, snapshot.Name, thinLvStatus.SizeBytes); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			return false, nil // wait until Watch triggers
+		}
+		return false, err
+	}
+
+	// Activate the thin LV so that the node where its thin-pool becomes
+	// active copies it. Thin copies must be active on the same node.
+
+	if err := setSnapshotThinLvActive(ctx, r.Client, snapshot, true); err != nil {
+		return false, err
+	}
+	if snapshot.Spec.Copy.Mode == v1alpha1.SnapshotCopyModeThin && thinPoolLv.Spec.ActiveOnNode != "" {
+		if err := r.setCopyThinLvActive(ctx, snapshot, thinPoolLv.Spec.ActiveOnNode); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil // wait until the node controller has copied the snapshot
+}
+
+// Activates the thin LV holding a Thin copy on the given node, or deactivates
+// it if node is empty
+func (r *SnapshotReconciler) setCopyThinLvActive(ctx context.Context, snapshot *v1alpha1.Snapshot, node string) error {
+	copyThinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Name, Namespace: config.Namespace}, copyThinPoolLv)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	thinLvSpec := copyThinPoolLv.Spec.FindThinLv(thinpoollv.VolumeToThinLvName(snapshot.Name))
+	if thinLvSpec == nil {
+		return nil
+	}
+
+	needUpdate := false
+	state := v1alpha1.ThinLvSpecStateNameInactive
+	if node != "" {
+		state = v1alpha1.ThinLvSpecStateNameActive
+
+		// UpdateThinPoolLv() keeps the node if one is set
+		if copyThinPoolLv.Spec.ActiveOnNode == "" {
+			copyThinPoolLv.Spec.ActiveOnNode = node
+			needUpdate = true
+		}
+	}
+
+	if thinLvSpec.State.Name != state {
+		needUpdate = true
+	}
+	thinLvSpec.State = v1alpha1.ThinLvSpecState{Name: state}
+	return thinpoollv.UpdateThinPoolLv(ctx, r.Client, copyThinPoolLv, needUpdate)
+}
+
+// Activates or deactivates the snapshot's thin LV so that a node controller
+// can read it. It is never activated while its thin-pool is being deleted, nor
+// while the snapshot is being deleted unless its deletion waits for
+// dependents.
+func setSnapshotThinLvActive(ctx context.Context, c client.Client, snapshot *v1alpha1.Snapshot, active bool) error {
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := c.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, thinPoolLv)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	thinLvSpec := thinPoolLv.Spec.FindThinLv(thinpoollv.SnapshotToThinLvName(snapshot.Name))
+	if thinLvSpec == nil || thinLvSpec.State.Name == v1alpha1.ThinLvSpecStateNameRemoved {
+		return nil
+	}
+
+	state := v1alpha1.ThinLvSpecStateNameInactive
+	if active && (snapshot.DeletionTimestamp == nil || len(snapshot.Status.Dependents) > 0) && thinPoolLv.DeletionTimestamp == nil {
+		state = v1alpha1.ThinLvSpecStateNameActive
+	}
+
+	needUpdate := thinLvSpec.State.Name != state
+	thinLvSpec.State = v1alpha1.ThinLvSpecState{Name: state}
+	return thinpoollv.UpdateThinPoolLv(ctx, c, thinPoolLv, needUpdate)
+}
+
+func (r *SnapshotReconciler) statusUpdate(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	snapshot.Status.ObservedGeneration = snapshot.Generation
+	return r.Status().Update(ctx, snapshot)
 }
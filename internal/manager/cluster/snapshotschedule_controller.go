@@ -0,0 +1,305 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/cron"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// Snapshots taken by a schedule are labeled with its name but not owned by
+// it, so deleting a SnapshotSchedule keeps its snapshots.
+
+// Label on the snapshots taken by a SnapshotSchedule, holding its name
+const scheduleSnapshotLabel = config.Domain + "/snapshot-schedule"
+
+// Longest name given to a scheduled snapshot. With volume names of 40
+// characters ("pvc-" and a UUID), schedule names of up to 18 characters are
+// kept whole.
+const maxScheduledSnapshotNameLen = 72
+
+type SnapshotScheduleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpSnapshotScheduleReconciler(mgr ctrl.Manager) error {
+	r := &SnapshotScheduleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SnapshotSchedule{}).
+		Watches(&v1alpha1.Snapshot{}, handler.EnqueueRequestsFromMapFunc(r.mapSnapshotToSchedule)).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshotschedules,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshotschedules/status,verbs=get;update;patch,namespace=kubesan-system
+
+func (r *SnapshotScheduleReconciler) mapSnapshotToSchedule(ctx context.Context, snapshot client.Object) []reconcile.Request {
+	name, ok := snapshot.GetLabels()[scheduleSnapshotLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: snapshot.GetNamespace()}}}
+}
+
+func (r *SnapshotScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("SnapshotScheduleReconciler entered")
+	defer log.Info("SnapshotScheduleReconciler exited")
+
+	schedule := &v1alpha1.SnapshotSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if schedule.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	oldStatus := schedule.Status.DeepCopy()
+
+	failed := conditionsv1.Condition{
+		Type:   v1alpha1.SnapshotScheduleConditionFailed,
+		Status: corev1.ConditionFalse,
+	}
+
+	cronSchedule, err := cron.Parse(schedule.Spec.Schedule)
+	if err == nil {
+		_, err = metav1.LabelSelectorAsSelector(&schedule.Spec.VolumeSelector)
+	}
+	if err == nil {
+		// the name labels the schedule's snapshots
+		if errs := validation.IsValidLabelValue(schedule.Name); len(errs) > 0 {
+			err = fmt.Errorf("name \"%s\" is not a valid label value: %s", schedule.Name, strings.Join(errs, "; "))
+		}
+	}
+	if err != nil {
+		failed.Status = corev1.ConditionTrue
+		failed.Reason = "InvalidSpec"
+		failed.Message = err.Error()
+		if util.SetStatusConditionIfChanged(&schedule.Status.Conditions, failed) {
+			return ctrl.Result{}, r.statusUpdate(ctx, schedule)
+		}
+		return ctrl.Result{}, nil // wait for the spec to be fixed
+	}
+	util.SetStatusConditionIfChanged(&schedule.Status.Conditions, failed)
+
+	now := time.Now()
+
+	last := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		last = schedule.Status.LastScheduleTime.Time
+	}
+	next := cronSchedule.Next(last)
+
+	if !schedule.Spec.Suspend && !next.IsZero() && !next.After(now) {
+		// only the most recent missed time is snapshotted
+
+		due := next
+		for n := cronSchedule.Next(due); !n.IsZero() && !n.After(now); n = cronSchedule.Next(due) {
+			due = n
+		}
+
+		if err := r.createSnapshots(ctx, schedule, due); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: due}
+		next = cronSchedule.Next(due)
+	}
+
+	if next.IsZero() {
+		schedule.Status.NextScheduleTime = nil
+	} else {
+		schedule.Status.NextScheduleTime = &metav1.Time{Time: next}
+	}
+
+	kept, expiry, err := r.applyRetention(ctx, schedule, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	schedule.Status.Snapshots = kept
+
+	if oldStatus.ObservedGeneration != schedule.Generation || !equalScheduleStatus(oldStatus, &schedule.Status) {
+		if err := r.statusUpdate(ctx, schedule); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// wake up for the next snapshots or the next expiring snapshot
+
+	var requeueAfter time.Duration
+	for _, t := range []time.Time{next, expiry} {
+		if t.IsZero() || (schedule.Spec.Suspend && t == next) {
+			continue
+		}
+		if d := max(t.Sub(now), time.Second); requeueAfter == 0 || d < requeueAfter {
+			requeueAfter = d
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// Snapshots each selected Thin volume. Names are derived from the scheduled
+// time so retries do not create duplicates.
+func (r *SnapshotScheduleReconciler) createSnapshots(ctx context.Context, schedule *v1alpha1.SnapshotSchedule, due time.Time) error {
+	log := log.FromContext(ctx)
+
+	selector, err := metav1.LabelSelectorAsSelector(&schedule.Spec.VolumeSelector)
+	if err != nil {
+		return err
+	}
+
+	volumes := &v1alpha1.VolumeList{}
+	if err := r.List(ctx, volumes, client.InNamespace(config.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	for i := range volumes.Items {
+		volume := &volumes.Items[i]
+		if volume.DeletionTimestamp != nil || volume.Spec.Mode != v1alpha1.VolumeModeThin {
+			continue
+		}
+
+		snapshot := &v1alpha1.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      scheduledSnapshotName(schedule.Name, volume.Name, due),
+				Namespace: config.Namespace,
+				Labels: map[string]string{
+					scheduleSnapshotLabel: schedule.Name,
+				},
+			},
+			Spec: v1alpha1.SnapshotSpec{
+				VgName:       volume.Spec.VgName,
+				SourceVolume: volume.Name,
+			},
+		}
+		if err := r.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+
+		log.Info("Created scheduled snapshot", "snapshot", snapshot.Name, "volume", volume.Name)
+	}
+	return nil
+}
+
+// Returns the name of the snapshot of the volume taken at the scheduled time.
+// The name of its thin LV is derived from it and must stay well within LVM's
+// 127-character limit, which also applies to the device-mapper name made of
+// the VG and LV names with every "-" doubled. Long schedule and volume names
+// are therefore truncated and made unique again with a hash of them.
+func scheduledSnapshotName(scheduleName string, volumeName string, due time.Time) string {
+	prefix := scheduleName + "-" + volumeName
+	suffix := "-" + due.UTC().Format("200601021504")
+
+	if len(prefix)+len(suffix) > maxScheduledSnapshotNameLen {
+		hash := sha256.Sum256([]byte(prefix))
+		hashSuffix := "-" + hex.EncodeToString(hash[:])[:8]
+		prefix = strings.TrimRight(prefix[:maxScheduledSnapshotNameLen-len(hashSuffix)-len(suffix)], "-.") + hashSuffix
+	}
+	return prefix + suffix
+}
+
+// Deletes the schedule's snapshots that exceed the retention limits. Returns
+// the names of the remaining snapshots and when the next one of them expires,
+// or the zero time.
+func (r *SnapshotScheduleReconciler) applyRetention(ctx context.Context, schedule *v1alpha1.SnapshotSchedule, now time.Time) ([]string, time.Time, error) {
+	snapshots := &v1alpha1.SnapshotList{}
+	err := r.List(ctx, snapshots, client.InNamespace(config.Namespace), client.MatchingLabels{scheduleSnapshotLabel: schedule.Name})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// newest first within each volume
+
+	byVolume := map[string][]*v1alpha1.Snapshot{}
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if snapshot.DeletionTimestamp == nil {
+			byVolume[snapshot.Spec.SourceVolume] = append(byVolume[snapshot.Spec.SourceVolume], snapshot)
+		}
+	}
+
+	retention := &schedule.Spec.Retention
+	kept := []string{}
+	var expiry time.Time
+
+	for _, list := range byVolume {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].CreationTimestamp.Equal(&list[j].CreationTimestamp) {
+				return list[i].Name > list[j].Name
+			}
+			return list[j].CreationTimestamp.Before(&list[i].CreationTimestamp)
+		})
+
+		for i, snapshot := range list {
+			expired := retention.MaxCount != nil && i >= int(*retention.MaxCount)
+
+			if retention.MaxAge != nil {
+				expires := snapshot.CreationTimestamp.Add(retention.MaxAge.Duration)
+				if !expires.After(now) {
+					expired = true
+				} else if !expired && (expiry.IsZero() || expires.Before(expiry)) {
+					expiry = expires
+				}
+			}
+
+			if !expired {
+				kept = append(kept, snapshot.Name)
+				continue
+			}
+
+			log.FromContext(ctx).Info("Deleting expired scheduled snapshot", "snapshot", snapshot.Name)
+
+			if err := r.Delete(ctx, snapshot); err != nil && !errors.IsNotFound(err) {
+				return nil, time.Time{}, err
+			}
+		}
+	}
+
+	slices.Sort(kept)
+	return kept, expiry, nil
+}
+
+func equalScheduleStatus(a *v1alpha1.SnapshotScheduleStatus, b *v1alpha1.SnapshotScheduleStatus) bool {
+	return slices.Equal(a.Snapshots, b.Snapshots) &&
+		a.LastScheduleTime.Equal(b.LastScheduleTime) &&
+		a.NextScheduleTime.Equal(b.NextScheduleTime) &&
+		slices.EqualFunc(a.Conditions, b.Conditions, func(x, y conditionsv1.Condition) bool {
+			return x.Type == y.Type && x.Status == y.Status && x.Reason == y.Reason && x.Message == y.Message
+		})
+}
+
+func (r *SnapshotScheduleReconciler) statusUpdate(ctx context.Context, schedule *v1alpha1.SnapshotSchedule) error {
+	schedule.Status.ObservedGeneration = schedule.Generation
+	return r.Status().Update(ctx, schedule)
+}
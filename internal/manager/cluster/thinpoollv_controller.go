@@ -7,7 +7,6 @@ package cluster
 
 import (
 	"context"
-	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,8 +18,8 @@ import (
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
-	"gitlab.com/kubesan/kubesan/internal/common/commands"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
 )
 
 type ThinPoolLvReconciler struct {
@@ -95,6 +94,12 @@ func (r *ThinPoolLvReconciler) reconcileDeleting(ctx context.Context, thinPoolLv
 		return nil
 	}
 
+	// wait for snapshots, which outlive their source volume
+
+	if len(thinPoolLv.Spec.ThinLvs) > 0 {
+		return nil
+	}
+
 	// remove LVM thin LVs
 
 	for i := range thinPoolLv.Status.ThinLvs {
@@ -128,15 +133,12 @@ func (r *ThinPoolLvReconciler) reconcileDeleting(ctx context.Context, thinPoolLv
 }
 
 func (r *ThinPoolLvReconciler) createThinPoolLv(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
-	_, err := commands.LvmLvCreateIdempotent(
-		"--devicesfile", thinPoolLv.Spec.VgName,
-		"--activate", "n",
-		"--type", "thin-pool",
-		"--metadataprofile", "kubesan",
-		"--name", thinPoolLv.Name,
-		"--size", fmt.Sprintf("%db", thinPoolLv.Spec.SizeBytes),
-		thinPoolLv.Spec.VgName,
-	)
+	err := lvm.CreateLvIdempotent(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.CreateLvOptions{
+		Type:            lvm.LvTypeThinPool,
+		SizeBytes:       thinPoolLv.Spec.SizeBytes,
+		Activation:      lvm.Deactivate,
+		MetadataProfile: "kubesan",
+	})
 	if err != nil {
 		return err
 	}
@@ -155,10 +157,7 @@ func (r *ThinPoolLvReconciler) createThinPoolLv(ctx context.Context, thinPoolLv
 }
 
 func (r *ThinPoolLvReconciler) removeThinPoolLv(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
-	_, err := commands.LvmLvRemoveIdempotent(
-		"--devicesfile", thinPoolLv.Spec.VgName,
-		fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinPoolLv.Name),
-	)
+	err := lvm.RemoveLvIdempotent(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.RemoveLvOptions{})
 	if err != nil {
 		return err
 	}
@@ -173,11 +172,7 @@ func (r *ThinPoolLvReconciler) removeThinPoolLv(ctx context.Context, thinPoolLv
 }
 
 func (r *ThinPoolLvReconciler) removeThinLv(thinPoolLv *v1alpha1.ThinPoolLv, thinLvName string) error {
-	_, err := commands.LvmLvRemoveIdempotent(
-		"--devicesfile", thinPoolLv.Spec.VgName,
-		fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvName),
-	)
-	return err
+	return lvm.RemoveLvIdempotent(thinPoolLv.Spec.VgName, thinLvName, lvm.RemoveLvOptions{})
 }
 
 func (r *ThinPoolLvReconciler) statusUpdate(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
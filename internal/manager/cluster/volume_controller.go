@@ -4,20 +4,29 @@ package cluster
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/integrity"
 	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
 	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
 	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
 )
@@ -26,6 +35,9 @@ type VolumeReconciler struct {
 	client.Client
 	Scheme  *runtime.Scheme
 	workers *workers.Workers
+
+	// Import work in progress, for reporting progress
+	imports map[string]*importWork
 }
 
 func SetUpVolumeReconciler(mgr ctrl.Manager) error {
@@ -33,25 +45,93 @@ func SetUpVolumeReconciler(mgr ctrl.Manager) error {
 		Client:  mgr.GetClient(),
 		Scheme:  mgr.GetScheme(),
 		workers: workers.NewWorkers(),
+		imports: make(map[string]*importWork),
 	}
 
 	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Volume{}).
-		Owns(&v1alpha1.ThinPoolLv{}) // for ThinBlobManager
+		Owns(&v1alpha1.ThinPoolLv{}). // for ThinBlobManager
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(mapVolumeToSources)).
+		Watches(&v1alpha1.Snapshot{}, handler.EnqueueRequestsFromMapFunc(mapSnapshotToSourceVolume)).
+		Watches(&v1alpha1.GoldenImage{}, handler.EnqueueRequestsFromMapFunc(r.mapGoldenImageToVolumes))
 	r.workers.SetUpReconciler(builder)
 	return builder.Complete(r)
 }
 
+// Volumes populated from other volumes are dependents of those volumes
+func mapVolumeToSources(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range volumeSources(obj.(*v1alpha1.Volume)) {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: config.Namespace}})
+	}
+	return requests
+}
+
+// Snapshots being taken are dependents of their source volume
+func mapSnapshotToSourceVolume(ctx context.Context, obj client.Object) []reconcile.Request {
+	snapshot := obj.(*v1alpha1.Snapshot)
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}}}
+}
+
+// Golden images being imported are dependents of their source volume, and
+// volumes using a golden image wait for it to become available
+func (r *VolumeReconciler) mapGoldenImageToVolumes(ctx context.Context, obj client.Object) []reconcile.Request {
+	goldenImage := obj.(*v1alpha1.GoldenImage)
+
+	var requests []reconcile.Request
+	if goldenImage.Spec.Source.SourceVolume != "" {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: goldenImage.Spec.Source.SourceVolume, Namespace: config.Namespace}})
+	}
+
+	volumes := &v1alpha1.VolumeList{}
+	if err := r.List(ctx, volumes, client.InNamespace(config.Namespace)); err != nil {
+		return requests
+	}
+	for i := range volumes.Items {
+		v := &volumes.Items[i]
+		if v.Spec.Contents.GoldenImage != nil && v.Spec.Contents.GoldenImage.GoldenImage == goldenImage.Name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(v)})
+		}
+	}
+	return requests
+}
+
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes/status,verbs=get;update;patch,namespace=kubesan-system
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=nbdexports,verbs=get;list;watch;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=goldenimages,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch,namespace=kubesan-system
+
+// Returns the size of the blob backing the volume. Thin volumes with integrity
+// checking store the checksums on the thin LV next to the data.
+func blobSizeBytes(volume *v1alpha1.Volume) int64 {
+	if volume.Spec.Mode == v1alpha1.VolumeModeThin && volume.Spec.Integrity != nil {
+		return volume.Spec.SizeBytes + integrity.OverheadBytes(volume.Spec.SizeBytes)
+	}
+	return volume.Spec.SizeBytes
+}
 
 func (r *VolumeReconciler) newBlobManager(volume *v1alpha1.Volume) (BlobManager, error) {
 	switch volume.Spec.Mode {
 	case v1alpha1.VolumeModeThin:
+		if volume.Spec.Contents.GoldenImage != nil {
+			originLvName := util.GoldenImageLvName(volume.Spec.Contents.GoldenImage.GoldenImage)
+			return NewThinBlobManagerWithExternalOrigin(r.Client, r.Scheme, volume, volume.Spec.VgName, originLvName), nil
+		}
 		return NewThinBlobManager(r.Client, r.Scheme, volume, volume.Spec.VgName), nil
 	case v1alpha1.VolumeModeLinear:
 		return NewLinearBlobManager(r.workers, volume, volume.Spec.VgName), nil
+	case v1alpha1.VolumeModeRaid1:
+		var pvTags []string
+		if volume.Spec.Raid != nil {
+			pvTags = volume.Spec.Raid.PvTags
+		}
+		return NewRaidBlobManager(r.workers, volume, volume.Spec.VgName, pvTags, volume.Spec.Integrity != nil), nil
+	case v1alpha1.VolumeModeVdo:
+		return NewVdoBlobManager(r.workers, volume, volume.Spec.VgName, volume.Spec.Vdo), nil
 	default:
 		return nil, errors.NewBadRequest("invalid volume mode")
 	}
@@ -65,6 +145,19 @@ func (r *VolumeReconciler) reconcileDeleting(ctx context.Context, blobMgr BlobMa
 		return nil // wait until no longer attached
 	}
 
+	if len(volume.Status.Dependents) > 0 {
+		log.Info("reconcileDeleting waiting for Dependents[] to become empty", "dependents", volume.Status.Dependents)
+		return nil // the watches trigger once the dependents are done
+	}
+
+	if err := r.cancelImport(volume); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			log.Info("cancelImport waiting for Watch")
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+
 	if err := blobMgr.RemoveBlob(ctx, volume.Name); err != nil {
 		if _, ok := err.(*util.WatchPending); ok {
 			log.Info("RemoveBlob waiting for Watch")
@@ -99,7 +192,7 @@ func (r *VolumeReconciler) reconcileNotDeleting(ctx context.Context, blobMgr Blo
 	if !conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, conditionsv1.ConditionAvailable) {
 		log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
 
-		err := blobMgr.CreateBlob(ctx, volume.Name, volume.Spec.SizeBytes)
+		err := blobMgr.CreateBlob(ctx, volume.Name, blobSizeBytes(volume))
 		if err != nil {
 			if _, ok := err.(*util.WatchPending); ok {
 				log.Info("CreateBlob waiting for Watch")
@@ -116,7 +209,9 @@ func (r *VolumeReconciler) reconcileNotDeleting(ctx context.Context, blobMgr Blo
 		}
 		conditionsv1.SetStatusCondition(&volume.Status.Conditions, condition)
 
-		volume.Status.SizeBytes = volume.Spec.SizeBytes // TODO report actual size?
+		// the provisioned size, see reconcileThinUsage() for the space
+		// actually used
+		volume.Status.SizeBytes = volume.Spec.SizeBytes
 
 		volume.Status.Path = blobMgr.GetPath(volume.Name)
 
@@ -125,6 +220,217 @@ func (r *VolumeReconciler) reconcileNotDeleting(ctx context.Context, blobMgr Blo
 		}
 	}
 
+	// apply mutable parameters
+
+	if err := blobMgr.UpdateBlobParameters(ctx, volume.Name, &volume.Spec.MutableParameters); err != nil {
+		return err
+	}
+
+	if err := r.reconcileThinUsage(ctx, volume); err != nil {
+		return err
+	}
+
+	if err := r.reconcileHealth(ctx, volume); err != nil {
+		return err
+	}
+
+	if err := r.reconcileQoSStatus(ctx, volume); err != nil {
+		return err
+	}
+
+	return r.reconcileNBDExportQoS(ctx, volume)
+}
+
+// Copy the space allocated to a Thin volume from its ThinPoolLv, where the
+// node controller measures it
+func (r *VolumeReconciler) reconcileThinUsage(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Spec.Mode != v1alpha1.VolumeModeThin {
+		return nil
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	if err := r.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	thinLvStatus := thinPoolLv.Status.FindThinLv(thinpoollv.VolumeToThinLvName(volume.Name))
+	if thinLvStatus == nil || thinLvStatus.AllocatedBytes == nil {
+		return nil
+	}
+
+	if volume.Status.AllocatedBytes != nil && *volume.Status.AllocatedBytes == *thinLvStatus.AllocatedBytes {
+		return nil
+	}
+
+	allocatedBytes := *thinLvStatus.AllocatedBytes
+	volume.Status.AllocatedBytes = &allocatedBytes
+	return r.statusUpdate(ctx, volume)
+}
+
+// Derive the Degraded condition from the health reported by each node to
+// which the volume is attached. Entries of nodes that are no longer attached
+// are dropped, e.g. if the node went away before it could remove its own.
+func (r *VolumeReconciler) reconcileHealth(ctx context.Context, volume *v1alpha1.Volume) error {
+	changed := false
+
+	nodeHealth := slices.DeleteFunc(slices.Clone(volume.Status.NodeHealth), func(health v1alpha1.VolumeNodeHealth) bool {
+		return !volume.Status.IsAttachedToNode(health.NodeName)
+	})
+	if len(nodeHealth) != len(volume.Status.NodeHealth) {
+		volume.Status.NodeHealth = nodeHealth
+		changed = true
+	}
+
+	if len(nodeHealth) == 0 {
+		if conditionsv1.FindStatusCondition(volume.Status.Conditions, conditionsv1.ConditionDegraded) != nil {
+			conditionsv1.RemoveStatusCondition(&volume.Status.Conditions, conditionsv1.ConditionDegraded)
+			changed = true
+		}
+	} else if util.SetStatusConditionIfChanged(&volume.Status.Conditions, aggregateHealthCondition(nodeHealth)) {
+		changed = true
+	}
+
+	if changed {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Returns a Degraded condition that is true if the volume is degraded on any
+// node, with the reason of the first such node and the messages of all of them
+func aggregateHealthCondition(nodeHealth []v1alpha1.VolumeNodeHealth) conditionsv1.Condition {
+	condition := conditionsv1.Condition{
+		Type:   conditionsv1.ConditionDegraded,
+		Status: corev1.ConditionFalse,
+		Reason: "Healthy",
+	}
+
+	nodeHealth = slices.Clone(nodeHealth)
+	slices.SortFunc(nodeHealth, func(a, b v1alpha1.VolumeNodeHealth) int {
+		return strings.Compare(a.NodeName, b.NodeName)
+	})
+
+	var messages []string
+	for _, health := range nodeHealth {
+		if !health.Degraded {
+			continue
+		}
+		if condition.Status != corev1.ConditionTrue {
+			condition.Status = corev1.ConditionTrue
+			condition.Reason = health.Reason
+		}
+		messages = append(messages, fmt.Sprintf("node \"%s\": %s", health.NodeName, health.Message))
+	}
+	condition.Message = strings.Join(messages, "; ")
+
+	return condition
+}
+
+// Derive Status.QoS and the QoSEnforced condition from the limits reported by
+// each node to which the volume is attached. Entries of nodes that are no
+// longer attached are dropped, as for Status.NodeHealth.
+func (r *VolumeReconciler) reconcileQoSStatus(ctx context.Context, volume *v1alpha1.Volume) error {
+	changed := false
+
+	nodeQoS := slices.DeleteFunc(slices.Clone(volume.Status.NodeQoS), func(nodeQoS v1alpha1.VolumeNodeQoS) bool {
+		return !volume.Status.IsAttachedToNode(nodeQoS.NodeName)
+	})
+	if len(nodeQoS) != len(volume.Status.NodeQoS) {
+		volume.Status.NodeQoS = nodeQoS
+		changed = true
+	}
+
+	qos := volume.Spec.MutableParameters.QoS
+	if qos == nil || len(nodeQoS) == 0 {
+		if conditionsv1.FindStatusCondition(volume.Status.Conditions, v1alpha1.VolumeConditionQoSEnforced) != nil {
+			conditionsv1.RemoveStatusCondition(&volume.Status.Conditions, v1alpha1.VolumeConditionQoSEnforced)
+			changed = true
+		}
+	} else if util.SetStatusConditionIfChanged(&volume.Status.Conditions, aggregateQoSCondition(qos, nodeQoS)) {
+		changed = true
+	}
+
+	commonQoS := commonNodeQoS(nodeQoS)
+	if !reflect.DeepEqual(commonQoS, volume.Status.QoS) {
+		volume.Status.QoS = commonQoS.DeepCopy()
+		changed = true
+	}
+
+	if changed {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Returns a QoSEnforced condition that is true if every node enforces the
+// limits qos, with the messages of the nodes that cannot enforce them or have
+// not applied them yet
+func aggregateQoSCondition(qos *v1alpha1.VolumeQoS, nodeQoS []v1alpha1.VolumeNodeQoS) conditionsv1.Condition {
+	condition := conditionsv1.Condition{
+		Type:   v1alpha1.VolumeConditionQoSEnforced,
+		Status: corev1.ConditionTrue,
+		Reason: "Enforced",
+	}
+
+	nodeQoS = slices.Clone(nodeQoS)
+	slices.SortFunc(nodeQoS, func(a, b v1alpha1.VolumeNodeQoS) int {
+		return strings.Compare(a.NodeName, b.NodeName)
+	})
+
+	var messages []string
+	for _, n := range nodeQoS {
+		switch {
+		case n.Error != "":
+			condition.Status = corev1.ConditionFalse
+			condition.Reason = "IoMaxUnavailable"
+			messages = append(messages, fmt.Sprintf("I/O limits cannot be enforced on node \"%s\": %s", n.NodeName, n.Error))
+		case !reflect.DeepEqual(n.QoS, qos):
+			if condition.Status == corev1.ConditionTrue {
+				condition.Status = corev1.ConditionFalse
+				condition.Reason = "Pending"
+			}
+			messages = append(messages, fmt.Sprintf("I/O limits not yet applied on node \"%s\"", n.NodeName))
+		}
+	}
+	condition.Message = strings.Join(messages, "; ")
+
+	return condition
+}
+
+// Returns the limits in effect on all nodes, or nil if there are none or they
+// differ between nodes
+func commonNodeQoS(nodeQoS []v1alpha1.VolumeNodeQoS) *v1alpha1.VolumeQoS {
+	if len(nodeQoS) == 0 {
+		return nil
+	}
+	for _, n := range nodeQoS[1:] {
+		if !reflect.DeepEqual(n.QoS, nodeQoS[0].QoS) {
+			return nil
+		}
+	}
+	return nodeQoS[0].QoS
+}
+
+// Propagate the volume's I/O limits to the NBD exports serving it, whose node
+// controllers enforce them in qemu-storage-daemon
+func (r *VolumeReconciler) reconcileNBDExportQoS(ctx context.Context, volume *v1alpha1.Volume) error {
+	exports := &v1alpha1.NBDExportList{}
+	if err := r.List(ctx, exports, client.InNamespace(config.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range exports.Items {
+		export := &exports.Items[i]
+		if export.Spec.Export != volume.Name || reflect.DeepEqual(export.Spec.QoS, volume.Spec.MutableParameters.QoS) {
+			continue
+		}
+
+		export.Spec.QoS = volume.Spec.MutableParameters.QoS.DeepCopy()
+		if err := r.Update(ctx, export); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -144,6 +450,10 @@ func (r *VolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
+	if err := r.reconcileDependents(ctx, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	if volume.DeletionTimestamp != nil {
 		err := r.reconcileDeleting(ctx, blobMgr, volume)
 		return ctrl.Result{}, err
@@ -160,6 +470,9 @@ func (r *VolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		volume.Spec.Contents.Empty,
 		volume.Spec.Contents.CloneVolume,
 		volume.Spec.Contents.CloneSnapshot,
+		volume.Spec.Contents.Import,
+		volume.Spec.Contents.Restore,
+		volume.Spec.Contents.GoldenImage,
 	) != 1 {
 		return ctrl.Result{}, errors.NewBadRequest("invalid volume contents")
 	}
@@ -173,9 +486,81 @@ func (r *VolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	case volume.Spec.Contents.CloneSnapshot != nil:
 		return ctrl.Result{}, errors.NewBadRequest("cloning snapshots is not yet supported")
+
+	case volume.Spec.Contents.GoldenImage != nil:
+		// the golden image must not change once the thin LV exists
+		if !conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, conditionsv1.ConditionAvailable) {
+			available, err := r.checkGoldenImage(ctx, volume)
+			if err != nil || !available {
+				return ctrl.Result{}, err
+			}
+		}
+
+	case volume.Spec.Contents.NeedsPopulating():
+		// the dm-integrity and LUKS layers only exist on nodes where
+		// the volume is attached
+		if volume.Spec.Mode == v1alpha1.VolumeModeThin && volume.Spec.Integrity != nil {
+			return ctrl.Result{}, errors.NewBadRequest("importing images and backups into Thin volumes with integrity checking is not yet supported")
+		}
+		if volume.Spec.Encryption != nil {
+			return ctrl.Result{}, errors.NewBadRequest("importing images and backups into encrypted volumes is not yet supported")
+		}
 	}
 
-	return ctrl.Result{}, r.reconcileNotDeleting(ctx, blobMgr, volume)
+	if err := r.reconcileNotDeleting(ctx, blobMgr, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if volume.Spec.Contents.NeedsPopulating() && conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return r.reconcileImport(ctx, volume)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// Returns whether the volume's golden image is available, or an error if the
+// volume cannot use it
+func (r *VolumeReconciler) checkGoldenImage(ctx context.Context, volume *v1alpha1.Volume) (bool, error) {
+	if volume.Spec.Mode != v1alpha1.VolumeModeThin {
+		return false, errors.NewBadRequest("golden images can only be used by Thin volumes")
+	}
+	if volume.Spec.Encryption != nil || volume.Spec.Integrity != nil {
+		return false, errors.NewBadRequest("golden images cannot be used by encrypted volumes or volumes with integrity checking")
+	}
+
+	goldenImage := &v1alpha1.GoldenImage{}
+	err := r.Get(ctx, types.NamespacedName{Name: volume.Spec.Contents.GoldenImage.GoldenImage, Namespace: config.Namespace}, goldenImage)
+	if errors.IsNotFound(err) {
+		return false, errors.NewBadRequest("golden image does not exist")
+	} else if err != nil {
+		return false, err
+	}
+
+	if goldenImage.DeletionTimestamp != nil {
+		return false, errors.NewBadRequest("golden image is being deleted")
+	}
+	if goldenImage.Spec.VgName != volume.Spec.VgName {
+		return false, errors.NewBadRequest("golden image must be in the volume's VG")
+	}
+	if goldenImage.Spec.SizeBytes > volume.Spec.SizeBytes {
+		return false, errors.NewBadRequest("volume must be at least as large as its golden image")
+	}
+
+	// the watch triggers once the image has been imported
+	return conditionsv1.IsStatusConditionTrue(goldenImage.Status.Conditions, conditionsv1.ConditionAvailable), nil
+}
+
+// Records the objects that still read from the volume
+func (r *VolumeReconciler) reconcileDependents(ctx context.Context, volume *v1alpha1.Volume) error {
+	dependents, err := listVolumeDependents(ctx, r.Client, volume)
+	if err != nil {
+		return err
+	}
+
+	if setDependents(&volume.Status.Dependents, &volume.Status.Conditions, v1alpha1.VolumeConditionDeletionDeferred, dependents, volume.DeletionTimestamp != nil) {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
 }
 
 func (r *VolumeReconciler) statusUpdate(ctx context.Context, volume *v1alpha1.Volume) error {
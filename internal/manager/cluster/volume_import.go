@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/common/qemuimg"
+	"gitlab.com/kubesan/kubesan/internal/common/s3"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/backup"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// How often the DataSourceCompleted condition is updated with the progress of
+// an import
+const importProgressInterval = 10 * time.Second
+
+type importWork struct {
+	// URL or path of the image
+	source string
+
+	// Set if source is the path of an LV that must be activated first
+	sourceVgName string
+	sourceLvName string
+
+	// Set if the image must be downloaded from S3 to source first
+	download *s3Download
+
+	format    v1alpha1.ImageFormat
+	vgName    string
+	lvName    string
+	sizeBytes int64
+
+	// Set if the LV is activated by the caller, like the thin LV of a
+	// Thin volume, which is activated through its ThinPoolLv
+	lvActive bool
+
+	// Set once the work has finished, until its result has been recorded
+	done bool
+	err  error
+
+	mu      sync.Mutex
+	percent float64
+}
+
+func (w *importWork) Run(ctx context.Context) error {
+	if w.lvActive {
+		return w.convertFromSource(ctx)
+	}
+	return lvm.WithLvActivated(w.vgName, w.lvName, func() error {
+		return w.convertFromSource(ctx)
+	})
+}
+
+func (w *importWork) convertFromSource(ctx context.Context) error {
+	if w.sourceLvName == "" {
+		return w.convert(ctx)
+	}
+	return lvm.WithLvActivatedShared(w.sourceVgName, w.sourceLvName, func() error {
+		return w.convert(ctx)
+	})
+}
+
+type s3Download struct {
+	client *s3.Client
+	bucket string
+	key    string
+}
+
+func (w *importWork) convert(ctx context.Context) error {
+	if w.download == nil {
+		return w.convertFrom(ctx, w.source, 0)
+	}
+
+	// qemu-img cannot sign requests, so download the image to the scratch
+	// volume and report it as the first half of the progress
+
+	sizeBytes, err := w.download.client.Size(ctx, w.download.bucket, w.download.key)
+	if err != nil {
+		return err
+	}
+
+	file, err := backup.CreateScratchFile("restore-*.qcow2", sizeBytes)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	log.FromContext(ctx).Info("import worker downloading image", "bucket", w.download.bucket, "key", w.download.key)
+	err = w.download.client.Download(ctx, w.download.bucket, w.download.key, file.File, func(doneBytes int64, sizeBytes int64) {
+		if sizeBytes > 0 {
+			w.setProgress(50 * float64(doneBytes) / float64(sizeBytes))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.convertFrom(ctx, file.Name(), 50)
+}
+
+// Converts the image at source into the LV, reporting its progress scaled to
+// the range from basePercent to 100%
+func (w *importWork) convertFrom(ctx context.Context, source string, basePercent float64) error {
+	log := log.FromContext(ctx)
+
+	virtualSize, err := qemuimg.VirtualSize(ctx, source, w.format)
+	if err != nil {
+		return err
+	}
+	if virtualSize > w.sizeBytes {
+		return fmt.Errorf("image size %d exceeds volume size %d", virtualSize, w.sizeBytes)
+	}
+
+	target := fmt.Sprintf("/dev/%s/%s", w.vgName, w.lvName)
+	log.Info("import worker converting image", "source", source, "target", target)
+	err = qemuimg.Convert(ctx, source, w.format, target, func(percent float64) {
+		w.setProgress(basePercent + percent*(100-basePercent)/100)
+	})
+	log.Info("import worker finished", "target", target)
+	return err
+}
+
+func (w *importWork) setProgress(percent float64) {
+	w.mu.Lock()
+	w.percent = percent
+	w.mu.Unlock()
+}
+
+func (w *importWork) progress() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.percent
+}
+
+// Returns a unique name for an import work item
+func importWorkName(volume *v1alpha1.Volume) string {
+	return fmt.Sprintf("import/%s/%s", volume.Spec.VgName, volume.Name)
+}
+
+// Returns the import work for a volume that has Import or Restore contents,
+// resolving the source Volume or Backup
+func (r *VolumeReconciler) newImportWork(ctx context.Context, volume *v1alpha1.Volume) (*importWork, error) {
+	var work *importWork
+	var err error
+	if volume.Spec.Contents.Restore != nil {
+		work, err = r.newRestoreWork(ctx, volume)
+	} else {
+		work, err = newImageImportWork(ctx, r.Client, volume.Spec.Contents.Import, volume.Spec.VgName, volume.Name, volume.Spec.SizeBytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if volume.Spec.Mode == v1alpha1.VolumeModeThin {
+		// see setImportThinLvActive()
+		work.lvName = thinpoollv.VolumeToThinLvName(volume.Name)
+		work.lvActive = true
+	}
+	return work, nil
+}
+
+// Returns the work converting the image described by contents into an LV,
+// resolving the source Volume if any
+func newImageImportWork(ctx context.Context, c client.Client, contents *v1alpha1.VolumeContentsImport, vgName string, lvName string, sizeBytes int64) (*importWork, error) {
+	work := &importWork{
+		source:    contents.URL,
+		format:    contents.Format,
+		vgName:    vgName,
+		lvName:    lvName,
+		sizeBytes: sizeBytes,
+	}
+
+	if contents.SourceVolume != "" {
+		source := &v1alpha1.Volume{}
+		err := c.Get(ctx, types.NamespacedName{Name: contents.SourceVolume, Namespace: config.Namespace}, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// Other modes cannot be activated on this node while attached
+		// elsewhere. Images in a file system would require mounting it.
+		if source.Spec.Mode != v1alpha1.VolumeModeLinear || source.Spec.Type.Block == nil || source.Spec.Encryption != nil {
+			return nil, errors.NewBadRequest("images can only be imported from unencrypted Linear block volumes")
+		}
+
+		work.source = fmt.Sprintf("/dev/%s/%s", source.Spec.VgName, source.Name)
+		work.sourceVgName = source.Spec.VgName
+		work.sourceLvName = source.Name
+	}
+
+	return work, nil
+}
+
+func (r *VolumeReconciler) newRestoreWork(ctx context.Context, volume *v1alpha1.Volume) (*importWork, error) {
+	b := &v1alpha1.Backup{}
+	err := r.Get(ctx, types.NamespacedName{Name: volume.Spec.Contents.Restore.Backup, Namespace: config.Namespace}, b)
+	if err != nil {
+		return nil, err
+	}
+	if b.DeletionTimestamp != nil || !conditionsv1.IsStatusConditionTrue(b.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return nil, errors.NewBadRequest("backup is not available")
+	}
+
+	work := &importWork{
+		format:    v1alpha1.ImageFormatQcow2,
+		vgName:    volume.Spec.VgName,
+		lvName:    volume.Name,
+		sizeBytes: volume.Spec.SizeBytes,
+	}
+
+	switch {
+	case b.Spec.Target.File != nil:
+		work.source = backup.HostPath(b.Spec.Target.File.Path)
+
+	case b.Spec.Target.S3 != nil:
+		client, err := backup.NewS3Client(ctx, r.Client, b.Spec.Target.S3)
+		if err != nil {
+			return nil, err
+		}
+		work.download = &s3Download{
+			client: client,
+			bucket: b.Spec.Target.S3.Bucket,
+			key:    b.Spec.Target.S3.Key,
+		}
+	}
+
+	return work, nil
+}
+
+// Converts the image into the volume's LV in the background, reflecting
+// progress in the DataSourceCompleted condition. Must only be called once the
+// LV exists.
+func (r *VolumeReconciler) reconcileImport(ctx context.Context, volume *v1alpha1.Volume) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, v1alpha1.VolumeConditionDataSourceCompleted) {
+		return ctrl.Result{}, nil
+	}
+
+	name := importWorkName(volume)
+	work, ok := r.imports[name]
+	if !ok {
+		if volume.Spec.Mode == v1alpha1.VolumeModeThin {
+			active, err := r.setImportThinLvActive(ctx, volume, true)
+			if err != nil || !active {
+				return ctrl.Result{}, err // the ThinPoolLv watch triggers
+			}
+		}
+
+		var err error
+		work, err = r.newImportWork(ctx, volume)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.imports[name] = work
+	}
+
+	if !work.done {
+		err := r.workers.Run(name, volume, work)
+		if _, ok := err.(*util.WatchPending); ok {
+			condition := conditionsv1.Condition{
+				Type:    v1alpha1.VolumeConditionDataSourceCompleted,
+				Status:  corev1.ConditionFalse,
+				Reason:  "Importing",
+				Message: fmt.Sprintf("%.0f%% imported", work.progress()),
+			}
+			if util.SetStatusConditionIfChanged(&volume.Status.Conditions, condition) {
+				if err := r.statusUpdate(ctx, volume); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{RequeueAfter: importProgressInterval}, nil
+		}
+		work.done = true
+		work.err = err
+	}
+
+	// the node controller activates the thin LV again when the volume is
+	// attached
+	if volume.Spec.Mode == v1alpha1.VolumeModeThin {
+		if _, err := r.setImportThinLvActive(ctx, volume, false); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	delete(r.imports, name)
+
+	err := work.err
+	if err != nil {
+		log.Error(err, "import failed")
+	} else {
+		log.Info("import succeeded")
+	}
+
+	if util.SetStatusConditionIfChanged(&volume.Status.Conditions, util.ImportCondition(err)) {
+		if err := r.statusUpdate(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// returning the error retries the import with backoff
+	return ctrl.Result{}, err
+}
+
+// Activates the thin LV of a Thin volume on this node so that the image can be
+// written to it, or deactivates it. The thin LV can only be active where its
+// thin-pool is, so activation waits while another node holds the thin-pool.
+// Returns whether the thin LV is active on this node.
+func (r *VolumeReconciler) setImportThinLvActive(ctx context.Context, volume *v1alpha1.Volume, active bool) (bool, error) {
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := r.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv)
+	if err != nil {
+		return false, err
+	}
+
+	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
+	thinLvSpec := thinPoolLv.Spec.FindThinLv(thinLvName)
+	if thinLvSpec == nil {
+		return false, errors.NewBadRequest("volume has no thin LV")
+	}
+
+	if active && thinPoolLv.Spec.ActiveOnNode != "" && thinPoolLv.Spec.ActiveOnNode != config.LocalNodeName {
+		return false, nil // e.g. while a snapshot is backed up
+	}
+
+	state := v1alpha1.ThinLvSpecStateNameInactive
+	if active {
+		state = v1alpha1.ThinLvSpecStateNameActive
+	}
+
+	needUpdate := thinLvSpec.State.Name != state
+	thinLvSpec.State = v1alpha1.ThinLvSpecState{Name: state}
+	if err := thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, needUpdate); err != nil {
+		return false, err
+	}
+
+	thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvName)
+	return thinLvStatus != nil && thinLvStatus.State.Name == v1alpha1.ThinLvStatusStateNameActive && thinPoolLv.Status.ActiveOnNode == config.LocalNodeName, nil
+}
+
+// Stops the import if it's running
+func (r *VolumeReconciler) cancelImport(volume *v1alpha1.Volume) error {
+	name := importWorkName(volume)
+	if err := r.workers.Cancel(name); err != nil {
+		return err
+	}
+	delete(r.imports, name)
+	return nil
+}
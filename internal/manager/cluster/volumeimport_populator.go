@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+)
+
+// The VolumeImport populator fills PVCs whose dataSourceRef is a VolumeImport
+// with a disk image, following the Kubernetes volume populator pattern:
+//
+//  1. A "prime" PVC is created in the KubeSAN namespace with the same
+//     StorageClass and size as the user's PVC, annotated with the image to
+//     import.
+//  2. The CSI controller plugin provisions the prime PVC's Volume with Import
+//     or Restore contents, and the Volume controller converts the image or
+//     backup into it.
+//  3. Once the import has completed, the prime PVC's PV is rebound to the
+//     user's PVC and the prime PVC is deleted.
+//
+// The user's PVC stays Pending until then, so the volume is never attached
+// before it holds the image.
+
+const (
+	// Label identifying prime PVCs
+	primePvcLabel = config.Domain + "/volume-import-prime"
+
+	// Annotations on prime PVCs identifying the user's PVC
+	primePvcNamespaceAnnotation = config.Domain + "/populated-pvc-namespace"
+	primePvcNameAnnotation      = config.Domain + "/populated-pvc-name"
+
+	// Set by the scheduler on PVCs of WaitForFirstConsumer StorageClasses
+	selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+)
+
+type VolumeImportPopulator struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpVolumeImportPopulator(mgr ctrl.Manager) error {
+	r := &VolumeImportPopulator{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("volumeimport-populator").
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(predicate.NewPredicateFuncs(isVolumeImportTarget))).
+		Watches(
+			&corev1.PersistentVolumeClaim{},
+			handler.EnqueueRequestsFromMapFunc(mapPrimePvcToTarget),
+			builder.WithPredicates(predicate.NewPredicateFuncs(isPrimePvc)),
+		).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumeimports,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+
+func isVolumeImportTarget(object client.Object) bool {
+	pvc, ok := object.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		return false
+	}
+	ref := pvc.Spec.DataSourceRef
+	return ref != nil && ref.APIGroup != nil && *ref.APIGroup == v1alpha1.GroupVersion.Group && ref.Kind == "VolumeImport"
+}
+
+func isPrimePvc(object client.Object) bool {
+	return object.GetNamespace() == config.Namespace && object.GetLabels()[primePvcLabel] != ""
+}
+
+func mapPrimePvcToTarget(ctx context.Context, object client.Object) []reconcile.Request {
+	annotations := object.GetAnnotations()
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Namespace: annotations[primePvcNamespaceAnnotation],
+				Name:      annotations[primePvcNameAnnotation],
+			},
+		},
+	}
+}
+
+func primePvcName(pvc *corev1.PersistentVolumeClaim) string {
+	return "prime-" + string(pvc.UID)
+}
+
+// Deletes the prime PVCs populating the given PVC, if any
+func (r *VolumeImportPopulator) deletePrimePvcs(ctx context.Context, target types.NamespacedName) error {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcs, client.InNamespace(config.Namespace), client.HasLabels{primePvcLabel}); err != nil {
+		return err
+	}
+
+	for i := range pvcs.Items {
+		prime := &pvcs.Items[i]
+		if prime.Annotations[primePvcNamespaceAnnotation] != target.Namespace || prime.Annotations[primePvcNameAnnotation] != target.Name {
+			continue
+		}
+		if err := r.Delete(ctx, prime); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the contents for the prime PVC's Volume
+func (r *VolumeImportPopulator) getContents(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*v1alpha1.VolumeContents, error) {
+	volumeImport := &v1alpha1.VolumeImport{}
+	err := r.Get(ctx, types.NamespacedName{Name: pvc.Spec.DataSourceRef.Name, Namespace: pvc.Namespace}, volumeImport)
+	if err != nil {
+		return nil, err
+	}
+
+	if volumeImport.Spec.Backup != "" {
+		restore, err := r.getRestoreContents(ctx, pvc, volumeImport.Spec.Backup)
+		if err != nil {
+			return nil, err
+		}
+		return &v1alpha1.VolumeContents{Restore: restore}, nil
+	}
+
+	contents := &v1alpha1.VolumeContentsImport{
+		URL:    volumeImport.Spec.URL,
+		Format: volumeImport.Spec.Format,
+	}
+
+	if volumeImport.Spec.PersistentVolumeClaim != "" {
+		source := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, types.NamespacedName{Name: volumeImport.Spec.PersistentVolumeClaim, Namespace: pvc.Namespace}, source)
+		if err != nil {
+			return nil, err
+		}
+		if source.Spec.VolumeName == "" {
+			return nil, errors.NewBadRequest("source PVC is not bound")
+		}
+		if source.Spec.VolumeMode == nil || *source.Spec.VolumeMode != corev1.PersistentVolumeBlock {
+			return nil, errors.NewBadRequest("source PVC must have volumeMode Block, images in file systems are not supported")
+		}
+
+		pv := &corev1.PersistentVolume{}
+		if err := r.Get(ctx, types.NamespacedName{Name: source.Spec.VolumeName}, pv); err != nil {
+			return nil, err
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != config.Domain {
+			return nil, errors.NewBadRequest("source PVC is not a KubeSAN volume")
+		}
+
+		contents.SourceVolume = pv.Spec.CSI.VolumeHandle
+	}
+
+	return &v1alpha1.VolumeContents{Import: contents}, nil
+}
+
+// Backups live in the KubeSAN namespace, so check that the PVC may read it
+func (r *VolumeImportPopulator) getRestoreContents(ctx context.Context, pvc *corev1.PersistentVolumeClaim, name string) (*v1alpha1.VolumeContentsRestore, error) {
+	backup := &v1alpha1.Backup{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: config.Namespace}, backup); err != nil {
+		return nil, err
+	}
+	if backup.Status.SourceNamespace != pvc.Namespace {
+		return nil, errors.NewBadRequest("backup is not of a PVC in the same namespace")
+	}
+	if !conditionsv1.IsStatusConditionTrue(backup.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return nil, errors.NewBadRequest("backup is not available")
+	}
+
+	return &v1alpha1.VolumeContentsRestore{Backup: name}, nil
+}
+
+func (r *VolumeImportPopulator) createPrimePvc(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	contents, err := r.getContents(ctx, pvc)
+	if err != nil {
+		return err
+	}
+
+	contentsJson, err := json.Marshal(contents)
+	if err != nil {
+		return err
+	}
+
+	prime := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      primePvcName(pvc),
+			Namespace: config.Namespace,
+			Labels: map[string]string{
+				primePvcLabel: "true",
+			},
+			Annotations: map[string]string{
+				config.ImportAnnotation:     string(contentsJson),
+				primePvcNamespaceAnnotation: pvc.Namespace,
+				primePvcNameAnnotation:      pvc.Name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Resources:        pvc.Spec.Resources,
+			StorageClassName: pvc.Spec.StorageClassName,
+			VolumeMode:       pvc.Spec.VolumeMode,
+		},
+	}
+	if node, ok := pvc.Annotations[selectedNodeAnnotation]; ok {
+		prime.Annotations[selectedNodeAnnotation] = node
+	}
+
+	if err := r.Create(ctx, prime); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *VolumeImportPopulator) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeImportPopulator entered")
+	defer log.Info("VolumeImportPopulator exited")
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, req.NamespacedName, pvc); errors.IsNotFound(err) {
+		return ctrl.Result{}, r.deletePrimePvcs(ctx, req.NamespacedName)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !isVolumeImportTarget(pvc) || pvc.DeletionTimestamp != nil || pvc.Spec.VolumeName != "" {
+		return ctrl.Result{}, r.deletePrimePvcs(ctx, req.NamespacedName)
+	}
+
+	// leave PVCs of other provisioners alone
+
+	if pvc.Spec.StorageClassName == nil {
+		return ctrl.Result{}, nil
+	}
+	storageClass := &storagev1.StorageClass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if storageClass.Provisioner != config.Domain {
+		return ctrl.Result{}, nil
+	}
+
+	// wait for the scheduler to pick a node, since the prime PVC is never
+	// consumed by a pod
+
+	if storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		if _, ok := pvc.Annotations[selectedNodeAnnotation]; !ok {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	// create the prime PVC and wait for it to be provisioned
+
+	prime := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: primePvcName(pvc), Namespace: config.Namespace}, prime)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, r.createPrimePvc(ctx, pvc)
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if prime.Spec.VolumeName == "" {
+		return ctrl.Result{}, nil // wait until the prime PVC is bound
+	}
+
+	pv := &corev1.PersistentVolume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: prime.Spec.VolumeName}, pv); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// wait for the import
+
+	if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.UID != pvc.UID {
+		if pv.Spec.CSI == nil {
+			return ctrl.Result{}, errors.NewBadRequest("prime PV is not a CSI volume")
+		}
+
+		volume := &v1alpha1.Volume{}
+		err := r.Get(ctx, types.NamespacedName{Name: pv.Spec.CSI.VolumeHandle, Namespace: config.Namespace}, volume)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, v1alpha1.VolumeConditionDataSourceCompleted) {
+			return ctrl.Result{RequeueAfter: importProgressInterval}, nil
+		}
+
+		// hand the PV over to the user's PVC, which Kubernetes then
+		// binds to it
+
+		log.Info("Import completed, rebinding PV", "pv", pv.Name)
+
+		pv.Spec.ClaimRef = &corev1.ObjectReference{
+			Namespace:       pvc.Namespace,
+			Name:            pvc.Name,
+			UID:             pvc.UID,
+			ResourceVersion: pvc.ResourceVersion,
+		}
+		if err := r.Update(ctx, pv); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the PV no longer references the prime PVC, so this does not delete it
+
+	if err := r.Delete(ctx, prime); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+)
+
+// A migration hands the volume to the node it is attached to, which creates
+// the volume's thin-pool and thin LV in the target VG, mirrors the volume into
+// them and switches the volume's dm wrapper over. Once Switched, the Volume and
+// its ThinPoolLv are pointed at the target VG and the node removes the LVs
+// left in the source VG.
+
+type VolumeMigrationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpVolumeMigrationReconciler(mgr ctrl.Manager) error {
+	r := &VolumeMigrationReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VolumeMigration{}).
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeMigrations)).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeMigrations)).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations/finalizers,verbs=update,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=thinpoollvs,verbs=get;list;watch;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=thinpoollvs/status,verbs=get;update;patch,namespace=kubesan-system
+
+// Volumes and their ThinPoolLvs have the same name
+func (r *VolumeMigrationReconciler) mapToVolumeMigrations(ctx context.Context, object client.Object) []reconcile.Request {
+	migrations := &v1alpha1.VolumeMigrationList{}
+	if err := r.List(ctx, migrations, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range migrations.Items {
+		if migrations.Items[i].Spec.Volume == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&migrations.Items[i])})
+		}
+	}
+	return requests
+}
+
+func (r *VolumeMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeMigrationReconciler entered")
+	defer log.Info("VolumeMigrationReconciler exited")
+
+	migration := &v1alpha1.VolumeMigration{}
+	if err := r.Get(ctx, req.NamespacedName, migration); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if migration.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, migration)
+	}
+
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, conditionsv1.ConditionAvailable) ||
+		conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionFailed) {
+		return ctrl.Result{}, nil
+	}
+
+	// add finalizer, so that the node can clean up after itself
+
+	if !controllerutil.ContainsFinalizer(migration, config.Finalizer) {
+		controllerutil.AddFinalizer(migration, config.Finalizer)
+
+		if err := r.Update(ctx, migration); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if migration.Status.NodeName == "" {
+		return ctrl.Result{}, r.start(ctx, migration)
+	}
+
+	// wait until the node has switched the volume to the target VG
+
+	if !conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSwitched) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.pointAtTarget(ctx, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// wait until the node has removed the source LVs
+
+	if !conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSourceRemoved) {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Volume migrated", "volume", migration.Spec.Volume, "vgName", migration.Spec.VgName)
+
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "Migrated",
+	})
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionAvailable,
+		Status: corev1.ConditionTrue,
+	})
+	return ctrl.Result{}, r.statusUpdate(ctx, migration)
+}
+
+// Checks that the volume can be migrated and hands it to the node it is
+// attached to
+func (r *VolumeMigrationReconciler) start(ctx context.Context, migration *v1alpha1.VolumeMigration) error {
+	volume := &v1alpha1.Volume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: migration.Spec.Volume, Namespace: config.Namespace}, volume); err != nil {
+		return err
+	}
+	if volume.Spec.Mode != v1alpha1.VolumeModeThin {
+		return errors.NewBadRequest("only Thin volumes can be migrated")
+	}
+	if volume.DeletionTimestamp != nil || !conditionsv1.IsStatusConditionTrue(volume.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return errors.NewBadRequest("volume is not available")
+	}
+	if volume.Spec.VgName == migration.Spec.VgName {
+		return errors.NewBadRequest(fmt.Sprintf("volume is already in VG \"%s\"", migration.Spec.VgName))
+	}
+	if volume.Spec.Cache != nil || volume.Spec.Integrity != nil {
+		return errors.NewBadRequest("volumes with a cache or integrity cannot be migrated")
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	if err := r.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv); err != nil {
+		return err
+	}
+	if thinPoolLv.Labels[config.ExternalOriginLabel] != "" {
+		return errors.NewBadRequest("volumes created from a golden image cannot be migrated")
+	}
+
+	// only the volume's thin LV moves, so snapshots would be lost
+
+	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
+	for i := range thinPoolLv.Spec.ThinLvs {
+		if thinPoolLv.Spec.ThinLvs[i].Name != thinLvName {
+			return errors.NewBadRequest("volumes with snapshots cannot be migrated")
+		}
+	}
+
+	// the mirror runs where the volume's I/O happens
+
+	if len(volume.Status.AttachedToNodes) != 1 || thinPoolLv.Status.ActiveOnNode != volume.Status.AttachedToNodes[0] {
+		return errors.NewBadRequest("volume must be attached to exactly one node")
+	}
+
+	migrations := &v1alpha1.VolumeMigrationList{}
+	if err := r.List(ctx, migrations, client.InNamespace(config.Namespace)); err != nil {
+		return err
+	}
+	for i := range migrations.Items {
+		other := &migrations.Items[i]
+		if other.Name != migration.Name && other.Spec.Volume == volume.Name && other.Status.IsInProgress() {
+			return errors.NewBadRequest(fmt.Sprintf("volume is already being migrated by \"%s\"", other.Name))
+		}
+	}
+
+	log.FromContext(ctx).Info("Starting migration", "volume", volume.Name, "from", volume.Spec.VgName, "to", migration.Spec.VgName, "node", thinPoolLv.Status.ActiveOnNode)
+
+	migration.Status.SourceVgName = volume.Spec.VgName
+	migration.Status.NodeName = thinPoolLv.Status.ActiveOnNode
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionProgressing,
+		Status: corev1.ConditionTrue,
+		Reason: "Preparing",
+	})
+	return r.statusUpdate(ctx, migration)
+}
+
+// Points the ThinPoolLv and then the Volume at the target VG, which is where
+// the node has switched the volume's I/O to
+func (r *VolumeMigrationReconciler) pointAtTarget(ctx context.Context, migration *v1alpha1.VolumeMigration) error {
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	if err := r.Get(ctx, types.NamespacedName{Name: migration.Spec.Volume, Namespace: config.Namespace}, thinPoolLv); err != nil {
+		return err
+	}
+	if thinPoolLv.Spec.VgName != migration.Spec.VgName {
+		// the target thin-pool was created with LVM's defaults, so have
+		// the node apply Spec.Autoextend and Spec.Discards to it again.
+		// This comes first since the VG name is how a retry tells
+		// whether the switch was made.
+		if thinPoolLv.Status.Autoextend != nil || thinPoolLv.Status.Discards != "" {
+			thinPoolLv.Status.Autoextend = nil
+			thinPoolLv.Status.Discards = ""
+			if err := r.Status().Update(ctx, thinPoolLv); err != nil {
+				return err
+			}
+		}
+
+		thinPoolLv.Spec.VgName = migration.Spec.VgName
+		if err := r.Update(ctx, thinPoolLv); err != nil {
+			return err
+		}
+	}
+
+	volume := &v1alpha1.Volume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: migration.Spec.Volume, Namespace: config.Namespace}, volume); err != nil {
+		return err
+	}
+	if volume.Spec.VgName != migration.Spec.VgName {
+		volume.Spec.VgName = migration.Spec.VgName
+		if err := r.Update(ctx, volume); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Waits for the node to roll back or finish the migration. A migration that
+// has switched cannot be rolled back, so it is completed first.
+func (r *VolumeMigrationReconciler) reconcileDeleting(ctx context.Context, migration *v1alpha1.VolumeMigration) error {
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSwitched) {
+		if err := r.pointAtTarget(ctx, migration); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	if migration.Status.IsInProgress() {
+		return nil // wait until the node clears Status.NodeName
+	}
+
+	if controllerutil.RemoveFinalizer(migration, config.Finalizer) {
+		if err := r.Update(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *VolumeMigrationReconciler) statusUpdate(ctx context.Context, migration *v1alpha1.VolumeMigration) error {
+	migration.Status.ObservedGeneration = migration.Generation
+	return r.Status().Update(ctx, migration)
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// Each sync takes a Snapshot of the source volume and activates its thin LV,
+// which also activates the thin-pool on some node. The node controller on
+// that node then sends the blocks that changed since the previous sync's
+// snapshot to the target. Only the snapshot of the last successful sync is
+// kept.
+
+const (
+	// Label on the snapshots taken by a VolumeReplication, holding its name
+	replicationSnapshotLabel = config.Domain + "/volume-replication"
+
+	// How often LagSeconds is refreshed between syncs
+	replicationLagInterval = time.Minute
+)
+
+type VolumeReplicationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpVolumeReplicationReconciler(mgr ctrl.Manager) error {
+	r := &VolumeReplicationReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VolumeReplication{}).
+		Watches(&v1alpha1.Snapshot{}, handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &v1alpha1.VolumeReplication{}, handler.OnlyControllerOwner())).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications/finalizers,verbs=update,namespace=kubesan-system
+
+func (r *VolumeReplicationReconciler) listSnapshots(ctx context.Context, replication *v1alpha1.VolumeReplication) ([]v1alpha1.Snapshot, error) {
+	snapshots := &v1alpha1.SnapshotList{}
+	err := r.List(ctx, snapshots, client.InNamespace(config.Namespace), client.MatchingLabels{replicationSnapshotLabel: replication.Name})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots.Items, nil
+}
+
+// Deletes the replication's snapshots that are no longer needed
+func (r *VolumeReplicationReconciler) deleteSnapshots(ctx context.Context, replication *v1alpha1.VolumeReplication, keep ...string) error {
+	snapshots, err := r.listSnapshots(ctx, replication)
+	if err != nil {
+		return err
+	}
+
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if snapshot.DeletionTimestamp != nil || slices.Contains(keep, snapshot.Name) {
+			continue
+		}
+		if err := r.Delete(ctx, snapshot); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *VolumeReplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeReplicationReconciler entered")
+	defer log.Info("VolumeReplicationReconciler exited")
+
+	replication := &v1alpha1.VolumeReplication{}
+	if err := r.Get(ctx, req.NamespacedName, replication); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if replication.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, replication)
+	}
+
+	return r.reconcileNotDeleting(ctx, replication)
+}
+
+func (r *VolumeReplicationReconciler) reconcileNotDeleting(ctx context.Context, replication *v1alpha1.VolumeReplication) (ctrl.Result, error) {
+	// add finalizer
+
+	if !controllerutil.ContainsFinalizer(replication, config.Finalizer) {
+		controllerutil.AddFinalizer(replication, config.Finalizer)
+
+		if err := r.Update(ctx, replication); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.deleteSnapshots(ctx, replication, replication.Status.LastSyncSnapshot, replication.Status.PendingSnapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if replication.Status.PendingSnapshot == "" {
+		return r.reconcileIdle(ctx, replication)
+	}
+
+	// activate the pending snapshot for the node controller
+
+	snapshot := &v1alpha1.Snapshot{}
+	err := r.Get(ctx, types.NamespacedName{Name: replication.Status.PendingSnapshot, Namespace: config.Namespace}, snapshot)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, nil // wait until the snapshot has been created
+	}
+
+	return ctrl.Result{}, setSnapshotThinLvActive(ctx, r.Client, snapshot, true)
+}
+
+// Starts the next sync once the interval has passed since the last one
+func (r *VolumeReplicationReconciler) reconcileIdle(ctx context.Context, replication *v1alpha1.VolumeReplication) (ctrl.Result, error) {
+	// the last sync's snapshot is only needed for its metadata
+
+	if replication.Status.LastSyncSnapshot != "" {
+		lastSnapshot := &v1alpha1.Snapshot{}
+		err := r.Get(ctx, types.NamespacedName{Name: replication.Status.LastSyncSnapshot, Namespace: config.Namespace}, lastSnapshot)
+		if err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err == nil {
+			if err := setSnapshotThinLvActive(ctx, r.Client, lastSnapshot, false); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	now := time.Now()
+
+	if replication.Status.LastSyncTime != nil {
+		lag := now.Sub(replication.Status.LastSyncTime.Time)
+		if lag < replication.Spec.Interval.Duration {
+			if lagSeconds := int64(lag.Seconds()); lagSeconds != replication.Status.LagSeconds {
+				replication.Status.LagSeconds = lagSeconds
+				if err := r.statusUpdate(ctx, replication); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			return ctrl.Result{RequeueAfter: min(replication.Spec.Interval.Duration-lag, replicationLagInterval)}, nil
+		}
+	}
+
+	source := &v1alpha1.Volume{}
+	err := r.Get(ctx, types.NamespacedName{Name: replication.Spec.SourceVolume, Namespace: config.Namespace}, source)
+	if errors.IsNotFound(err) || (err == nil && source.DeletionTimestamp != nil) {
+		return ctrl.Result{}, r.setFailed(ctx, replication, "SourceVolumeMissing", "source volume does not exist")
+	} else if err != nil {
+		return ctrl.Result{}, err
+	}
+	if source.Spec.Mode != v1alpha1.VolumeModeThin {
+		return ctrl.Result{}, r.setFailed(ctx, replication, "SourceVolumeNotThin", "only Thin volumes can be replicated")
+	}
+
+	snapshot := &v1alpha1.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      replication.Name + "-" + now.UTC().Format("20060102150405"),
+			Namespace: config.Namespace,
+			Labels: map[string]string{
+				replicationSnapshotLabel: replication.Name,
+			},
+		},
+		Spec: v1alpha1.SnapshotSpec{
+			VgName:       source.Spec.VgName,
+			SourceVolume: source.Name,
+		},
+	}
+	if err := controllerutil.SetControllerReference(replication, snapshot, r.Scheme); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	log.FromContext(ctx).Info("Starting sync", "snapshot", snapshot.Name)
+
+	replication.Status.PendingSnapshot = snapshot.Name
+	return ctrl.Result{}, r.statusUpdate(ctx, replication)
+}
+
+func (r *VolumeReplicationReconciler) setFailed(ctx context.Context, replication *v1alpha1.VolumeReplication, reason string, message string) error {
+	condition := conditionsv1.Condition{
+		Type:    v1alpha1.VolumeReplicationConditionFailed,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+	if util.SetStatusConditionIfChanged(&replication.Status.Conditions, condition) {
+		return r.statusUpdate(ctx, replication)
+	}
+	return nil
+}
+
+func (r *VolumeReplicationReconciler) reconcileDeleting(ctx context.Context, replication *v1alpha1.VolumeReplication) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if replication.Status.NodeName != "" {
+		log.Info("reconcileDeleting waiting for node to stop syncing", "node", replication.Status.NodeName)
+		return nil // wait until the node controller cancels the sync
+	}
+
+	if err := r.deleteSnapshots(ctx, replication); err != nil {
+		return err
+	}
+
+	if controllerutil.RemoveFinalizer(replication, config.Finalizer) {
+		if err := r.Update(ctx, replication); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *VolumeReplicationReconciler) statusUpdate(ctx context.Context, replication *v1alpha1.VolumeReplication) error {
+	replication.Status.ObservedGeneration = replication.Generation
+	return r.Status().Update(ctx, replication)
+}
@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cluster
+
+import (
+	"bytes"
+	"context"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/luks"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// A revert asks the node where the volume's thin-pool is active to replace the
+// volume's thin LV with a snapshot of the snapshot's thin LV. It is only
+// requested while the thin LV is inactive, and the node controller does not
+// activate the thin LV to attach the volume until the revert is done, so no
+// writes can be lost to a revert that happens later.
+//
+// The reverted volume carries the snapshot's LUKS header, which is unlocked
+// by the passphrase that was current when the snapshot was taken. If the
+// volume's key has been rotated since, that passphrase becomes the previous
+// passphrase in the volume's key Secret so that the key slot is switched over
+// to the current one the next time the volume is staged.
+
+type VolumeRevertReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func SetUpVolumeRevertReconciler(mgr ctrl.Manager) error {
+	r := &VolumeRevertReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VolumeRevert{}).
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeReverts)).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeReverts)).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereverts,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereverts/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;update;patch,namespace=kubesan-system
+
+// Volumes and their ThinPoolLvs have the same name
+func (r *VolumeRevertReconciler) mapToVolumeReverts(ctx context.Context, object client.Object) []reconcile.Request {
+	reverts := &v1alpha1.VolumeRevertList{}
+	if err := r.List(ctx, reverts, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range reverts.Items {
+		if reverts.Items[i].Spec.Volume == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&reverts.Items[i])})
+		}
+	}
+	return requests
+}
+
+func (r *VolumeRevertReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeRevertReconciler entered")
+	defer log.Info("VolumeRevertReconciler exited")
+
+	revert := &v1alpha1.VolumeRevert{}
+	if err := r.Get(ctx, req.NamespacedName, revert); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if revert.DeletionTimestamp != nil || conditionsv1.IsStatusConditionTrue(revert.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, nil
+	}
+
+	volume := &v1alpha1.Volume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: revert.Spec.Volume, Namespace: config.Namespace}, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+	if volume.Spec.Mode != v1alpha1.VolumeModeThin {
+		return ctrl.Result{}, errors.NewBadRequest("only Thin volumes can be reverted")
+	}
+
+	snapshot := &v1alpha1.Snapshot{}
+	if err := r.Get(ctx, types.NamespacedName{Name: revert.Spec.Snapshot, Namespace: config.Namespace}, snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+	if snapshot.Spec.SourceVolume != volume.Name {
+		return ctrl.Result{}, errors.NewBadRequest("snapshot was not taken of the volume")
+	}
+	if snapshot.DeletionTimestamp != nil || !conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, errors.NewBadRequest("snapshot is not available")
+	}
+	if snapshot.Spec.Copy != nil {
+		return ctrl.Result{}, errors.NewBadRequest("reverting to full-copy snapshots is not supported")
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	if err := r.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
+	thinLvSpec := thinPoolLv.Spec.FindThinLv(thinLvName)
+	if thinLvSpec == nil {
+		return ctrl.Result{}, errors.NewBadRequest("volume has no thin LV")
+	}
+
+	progressing := conditionsv1.Condition{
+		Type:   conditionsv1.ConditionProgressing,
+		Status: corev1.ConditionTrue,
+	}
+
+	// the node controller only reverts inactive thin LVs, so only ask
+	// once the volume is detached. The thin LV's state is checked in the
+	// same object that the revert is requested in, so it cannot be
+	// activated in between.
+
+	if len(volume.Status.AttachedToNodes) > 0 || thinLvSpec.State.Name != v1alpha1.ThinLvSpecStateNameInactive {
+		progressing.Reason = "VolumeAttached"
+		progressing.Message = "waiting for the volume to be detached from all nodes"
+		if util.SetStatusConditionIfChanged(&revert.Status.Conditions, progressing) {
+			return ctrl.Result{}, r.statusUpdate(ctx, revert)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	id := string(revert.UID)
+	if thinLvSpec.Revert == nil || thinLvSpec.Revert.Id != id {
+		if err := r.rewrapKey(ctx, volume, snapshot); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		log.Info("Requesting revert", "thin LV", thinLvName, "snapshot", snapshot.Name)
+
+		thinLvSpec.Revert = &v1alpha1.ThinLvRevert{
+			SourceThinLvName: thinpoollv.SnapshotToThinLvName(snapshot.Name),
+			Id:               id,
+		}
+		if err := thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, true); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		progressing.Reason = "Reverting"
+		if util.SetStatusConditionIfChanged(&revert.Status.Conditions, progressing) {
+			return ctrl.Result{}, r.statusUpdate(ctx, revert)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvName); thinLvStatus == nil || thinLvStatus.RevertId != id {
+		return ctrl.Result{}, nil // wait until the node controller reverts the thin LV
+	}
+
+	// clear thinPoolLv.Spec.ActiveOnNode, if necessary
+
+	if err := thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, false); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Volume reverted", "snapshot", snapshot.Name)
+
+	progressing.Status = corev1.ConditionFalse
+	progressing.Reason = "Reverted"
+	progressing.Message = ""
+	conditionsv1.SetStatusCondition(&revert.Status.Conditions, progressing)
+	conditionsv1.SetStatusCondition(&revert.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionAvailable,
+		Status: corev1.ConditionTrue,
+	})
+	return ctrl.Result{}, r.statusUpdate(ctx, revert)
+}
+
+// Makes sure that the volume's key Secret unlocks the snapshot's LUKS header
+// once the volume has been reverted, by making the snapshot's passphrase the
+// previous passphrase if the key has been rotated since the snapshot was
+// taken. Keys given in NodeStageSecrets are managed by the user.
+func (r *VolumeRevertReconciler) rewrapKey(ctx context.Context, volume *v1alpha1.Volume, snapshot *v1alpha1.Snapshot) error {
+	if volume.Spec.Encryption == nil || volume.Spec.Encryption.KeySource != v1alpha1.VolumeEncryptionKeySourceKMS {
+		return nil
+	}
+
+	snapshotKey := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: luks.KeySecretName(snapshot.Name), Namespace: config.Namespace}, snapshotKey); err != nil {
+		return err
+	}
+
+	volumeKey := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: luks.KeySecretName(volume.Name), Namespace: config.Namespace}, volumeKey); err != nil {
+		return err
+	}
+
+	// The snapshot was unlocked by its passphrase, or by its previous
+	// passphrase if a rotation was pending when it was taken
+	current := volumeKey.Data[luks.PassphraseKey]
+	var missing [][]byte
+	for _, key := range []string{luks.PassphraseKey, luks.PreviousPassphraseKey} {
+		if passphrase := snapshotKey.Data[key]; len(passphrase) > 0 && !bytes.Equal(passphrase, current) {
+			missing = append(missing, passphrase)
+		}
+	}
+
+	switch {
+	case len(missing) == 0:
+		return nil
+	case len(missing) > 1:
+		return errors.NewBadRequest("cannot revert to a snapshot taken during key rotation after the key has been rotated again")
+	case bytes.Equal(volumeKey.Data[luks.PreviousPassphraseKey], missing[0]):
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Setting snapshot passphrase as previous passphrase of the volume", "snapshot", snapshot.Name)
+	volumeKey.Data[luks.PreviousPassphraseKey] = missing[0]
+	return r.Update(ctx, volumeKey)
+}
+
+func (r *VolumeRevertReconciler) statusUpdate(ctx context.Context, revert *v1alpha1.VolumeRevert) error {
+	revert.Status.ObservedGeneration = revert.Generation
+	return r.Status().Update(ctx, revert)
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/s3"
+)
+
+// Returns the path through which the manager's container reaches the given
+// path on the host
+func HostPath(path string) string {
+	return "/proc/1/root" + path
+}
+
+// Returns a client for the target's bucket using the credentials in its
+// Secret
+func NewS3Client(ctx context.Context, c client.Client, target *v1alpha1.BackupTargetS3) (*s3.Client, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Name: target.CredentialsSecret, Namespace: config.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKeyId, ok := secret.Data["accessKeyId"]
+	if !ok {
+		return nil, fmt.Errorf("secret \"%s\" has no \"accessKeyId\" key", target.CredentialsSecret)
+	}
+	secretAccessKey, ok := secret.Data["secretAccessKey"]
+	if !ok {
+		return nil, fmt.Errorf("secret \"%s\" has no \"secretAccessKey\" key", target.CredentialsSecret)
+	}
+
+	return s3.NewClient(target.Endpoint, target.Region, string(accessKeyId), string(secretAccessKey))
+}
+
+// Deletes the backup's image from its target. Succeeds if it does not exist.
+func RemoveImage(ctx context.Context, c client.Client, target *v1alpha1.BackupTarget) error {
+	switch {
+	case target.File != nil:
+		err := os.Remove(HostPath(target.File.Path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+
+	case target.S3 != nil:
+		s3Client, err := NewS3Client(ctx, c, target.S3)
+		if err != nil {
+			return err
+		}
+		return s3Client.Delete(ctx, target.S3.Bucket, target.S3.Key)
+
+	default:
+		return fmt.Errorf("invalid backup target")
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+)
+
+var (
+	scratchMu       sync.Mutex
+	scratchReserved int64
+	scratchCleaned  bool
+)
+
+// A file in the scratch volume, with space reserved for an image of up to a
+// given size. Closing it removes the file and releases its space.
+type ScratchFile struct {
+	*os.File
+	sizeBytes int64
+}
+
+// Creates a file in config.ScratchDir to stage an image of up to sizeBytes.
+// Fails right away if the scratch volume does not have room for it next to
+// the images already staged there, instead of running out of space, or
+// getting the pod evicted, halfway through writing it.
+func CreateScratchFile(pattern string, sizeBytes int64) (*ScratchFile, error) {
+	scratchMu.Lock()
+	defer scratchMu.Unlock()
+
+	// files left behind by a previous run of the container are no longer
+	// in use but still take up space
+	if !scratchCleaned {
+		if err := cleanScratchDir(); err != nil {
+			return nil, err
+		}
+		scratchCleaned = true
+	}
+
+	available, err := scratchAvailable()
+	if err != nil {
+		return nil, err
+	}
+	if sizeBytes > available {
+		return nil, fmt.Errorf("staging an image of up to %d bytes needs more space than the %d bytes available in scratch volume %s", sizeBytes, available, config.ScratchDir)
+	}
+
+	file, err := os.CreateTemp(config.ScratchDir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	scratchReserved += sizeBytes
+	return &ScratchFile{File: file, sizeBytes: sizeBytes}, nil
+}
+
+// Closes and removes the file and releases its space
+func (f *ScratchFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+		err = rmErr
+	}
+
+	scratchMu.Lock()
+	scratchReserved -= f.sizeBytes
+	scratchMu.Unlock()
+
+	return err
+}
+
+// Returns how many bytes can still be reserved. Must be called with scratchMu
+// held. Whatever staged images have written so far is counted both in the
+// free space and in their reservations, which errs on the safe side.
+func scratchAvailable() (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(config.ScratchDir, &stat); err != nil {
+		return 0, err
+	}
+	available := int64(stat.Bavail)*stat.Bsize - scratchReserved
+
+	if config.ScratchSizeLimit != "" {
+		limit, err := resource.ParseQuantity(config.ScratchSizeLimit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid SCRATCH_SIZE_LIMIT \"%s\": %s", config.ScratchSizeLimit, err)
+		}
+		available = min(available, limit.Value()-scratchReserved)
+	}
+
+	return max(available, 0), nil
+}
+
+func cleanScratchDir() error {
+	entries, err := os.ReadDir(config.ScratchDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(config.ScratchDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,11 +6,16 @@ package thinpoollv
 
 import (
 	"context"
+	"slices"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -20,6 +25,11 @@ func VolumeToThinLvName(volumeName string) string {
 	return volumeName + "-thin"
 }
 
+// Returns the thin LV name given the snapshot's name.
+func SnapshotToThinLvName(snapshotName string) string {
+	return snapshotName + "-thin"
+}
+
 // Maps from ThinLvSpecState.Name to ThinLvStatusState.Name
 func SpecStateToStatusState(specStateName string) string {
 	switch specStateName {
@@ -34,6 +44,12 @@ func SpecStateToStatusState(specStateName string) string {
 	}
 }
 
+// Returns true if the thin LV has been asked to revert to another thin LV but
+// has not done so yet
+func IsRevertPending(thinLvSpec *v1alpha1.ThinLvSpec, thinLvStatus *v1alpha1.ThinLvStatus) bool {
+	return thinLvSpec.Revert != nil && thinLvStatus != nil && thinLvStatus.RevertId != thinLvSpec.Revert.Id
+}
+
 // Returns true if the ThinPoolLv should be active on a node
 func thinPoolLvNeedsActivation(thinPoolLv *v1alpha1.ThinPoolLv) bool {
 	// Cases that have been considered:
@@ -41,6 +57,7 @@ func thinPoolLvNeedsActivation(thinPoolLv *v1alpha1.ThinPoolLv) bool {
 	// 2. Thin LV deletion
 	// 3. Thin LV activation
 	// 4. Thin LV extension
+	// 5. Thin LV revert
 	//
 	// Update this list when you change which cases are handled by this
 	// function. That way it will be easier to identify what still needs to
@@ -78,6 +95,12 @@ func thinPoolLvNeedsActivation(thinPoolLv *v1alpha1.ThinPoolLv) bool {
 		if thinLvSpec.SizeBytes > thinLvStatus.SizeBytes {
 			return true
 		}
+
+		// reverting the thin LV requires that the ThinPoolLv be active on a node
+
+		if IsRevertPending(thinLvSpec, thinLvStatus) {
+			return true
+		}
 	}
 
 	return false
@@ -127,3 +150,39 @@ func UpdateThinPoolLv(ctx context.Context, client client.Client, thinPoolLv *v1a
 	log.Info("Updating ThinPoolLv", "Spec.ActiveOnNode", thinPoolLv.Spec.ActiveOnNode)
 	return client.Update(ctx, thinPoolLv)
 }
+
+// Returns true if owner holds an owner reference to the ThinPoolLv. The volume
+// that a thin-pool is named after holds the controller reference and each
+// snapshot with a thin LV in the thin-pool holds a plain owner reference.
+func IsOwnedBy(thinPoolLv *v1alpha1.ThinPoolLv, owner metav1.Object) bool {
+	return slices.ContainsFunc(thinPoolLv.OwnerReferences, func(ref metav1.OwnerReference) bool {
+		return ref.UID == owner.GetUID()
+	})
+}
+
+// Drops owner's reference to the ThinPoolLv and deletes the ThinPoolLv once no
+// owner references and no thin LVs are left. The caller must have forgotten
+// its thin LVs already. ThinPoolLvs of snapshots taken before owner
+// references were introduced are kept until their last thin LV is gone.
+func ReleaseThinPoolLv(ctx context.Context, c client.Client, scheme *runtime.Scheme, thinPoolLv *v1alpha1.ThinPoolLv, owner metav1.Object) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	needUpdate := false
+	if IsOwnedBy(thinPoolLv, owner) {
+		if err := controllerutil.RemoveOwnerReference(owner, thinPoolLv, scheme); err != nil {
+			return err
+		}
+		needUpdate = true
+	}
+
+	if err := UpdateThinPoolLv(ctx, c, thinPoolLv, needUpdate); err != nil {
+		return err
+	}
+
+	if thinPoolLv.DeletionTimestamp != nil || len(thinPoolLv.OwnerReferences) > 0 || len(thinPoolLv.Spec.ThinLvs) > 0 {
+		return nil
+	}
+
+	log.Info("Deleting unused ThinPoolLv", "name", thinPoolLv.Name)
+	return client.IgnoreNotFound(c.Delete(ctx, thinPoolLv))
+}
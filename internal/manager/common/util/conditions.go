@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+)
+
+// Sets a condition only if its status, reason, or message differ from the
+// existing condition of the same type. Returns true if conditions was
+// modified.
+//
+// conditionsv1.SetStatusCondition() always bumps the heartbeat time, which is
+// unsuitable for conditions that are refreshed periodically since every
+// refresh would then require a status update.
+func SetStatusConditionIfChanged(conditions *[]conditionsv1.Condition, condition conditionsv1.Condition) bool {
+	old := conditionsv1.FindStatusCondition(*conditions, condition.Type)
+	if old != nil && old.Status == condition.Status && old.Reason == condition.Reason && old.Message == condition.Message {
+		return false
+	}
+
+	conditionsv1.SetStatusCondition(conditions, condition)
+	return true
+}
+
+// Returns a Degraded condition reflecting an LVM LV's lv_health_status report
+// field, see lvm.Lv.HealthStatus.
+func LvHealthCondition(healthStatus string) conditionsv1.Condition {
+	if healthStatus == "" {
+		return conditionsv1.Condition{
+			Type:   conditionsv1.ConditionDegraded,
+			Status: corev1.ConditionFalse,
+			Reason: "Healthy",
+		}
+	}
+
+	var reason string
+	switch healthStatus {
+	case "partial":
+		reason = "MissingPVs"
+	case "out_of_data":
+		reason = "OutOfDataSpace"
+	case "metadata_read_only":
+		reason = "MetadataReadOnly"
+	case "failed":
+		reason = "Failed"
+	case "refresh needed":
+		reason = "RefreshNeeded"
+	case "mismatches exist":
+		reason = "MismatchesExist"
+	default:
+		reason = "Unhealthy"
+	}
+
+	return conditionsv1.Condition{
+		Type:    conditionsv1.ConditionDegraded,
+		Status:  corev1.ConditionTrue,
+		Reason:  reason,
+		Message: fmt.Sprintf("LVM reports health status \"%s\"", healthStatus),
+	}
+}
+
+// Returns the DataSourceCompleted condition of a volume whose import has
+// finished with err.
+func ImportCondition(err error) conditionsv1.Condition {
+	if err != nil {
+		return conditionsv1.Condition{
+			Type:    v1alpha1.VolumeConditionDataSourceCompleted,
+			Status:  corev1.ConditionFalse,
+			Reason:  "ImportFailed",
+			Message: err.Error(),
+		}
+	}
+
+	return conditionsv1.Condition{
+		Type:   v1alpha1.VolumeConditionDataSourceCompleted,
+		Status: corev1.ConditionTrue,
+		Reason: "Imported",
+	}
+}
@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import "strconv"
+
+// VdoPoolLvName returns the name of the VDO pool LV backing a Vdo volume.
+func VdoPoolLvName(volumeName string) string {
+	return volumeName + "-vdopool"
+}
+
+// GoldenImageLvName returns the name of the read-only LV holding a
+// GoldenImage, which is distinct from the LV names of Volumes.
+func GoldenImageLvName(goldenImageName string) string {
+	return goldenImageName + "-golden"
+}
+
+// GroupSnapshotMemberName returns the name of the member Snapshot that a
+// GroupSnapshot takes of its index-th source volume.
+func GroupSnapshotMemberName(groupSnapshotName string, index int) string {
+	return groupSnapshotName + "-" + strconv.Itoa(index)
+}
@@ -5,13 +5,16 @@ package manager
 import (
 	"flag"
 
+	corev1 "k8s.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
 	clustercontrollers "gitlab.com/kubesan/kubesan/internal/manager/cluster"
 	nodecontrollers "gitlab.com/kubesan/kubesan/internal/manager/node"
 )
@@ -41,6 +44,15 @@ func RunClusterControllers() error {
 			DefaultNamespaces: map[string]cache.Config{
 				config.Namespace: {},
 			},
+			// the VolumeImport populator handles PVCs in all namespaces
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.PersistentVolumeClaim{}: {
+					Namespaces: map[string]cache.Config{cache.AllNamespaces: {}},
+				},
+				&v1alpha1.VolumeImport{}: {
+					Namespaces: map[string]cache.Config{cache.AllNamespaces: {}},
+				},
+			},
 		},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
@@ -59,10 +71,18 @@ func RunClusterControllers() error {
 	}
 
 	return runManager(ctrlOpts, []func(ctrl.Manager) error{
+		clustercontrollers.SetUpBackupReconciler,
+		clustercontrollers.SetUpGoldenImageReconciler,
+		clustercontrollers.SetUpGroupSnapshotReconciler,
 		clustercontrollers.SetUpSnapshotReconciler,
+		clustercontrollers.SetUpSnapshotScheduleReconciler,
 		clustercontrollers.SetUpThinBlobReconciler,
 		clustercontrollers.SetUpThinPoolLvReconciler,
+		clustercontrollers.SetUpVolumeImportPopulator,
 		clustercontrollers.SetUpVolumeReconciler,
+		clustercontrollers.SetUpVolumeMigrationReconciler,
+		clustercontrollers.SetUpVolumeReplicationReconciler,
+		clustercontrollers.SetUpVolumeRevertReconciler,
 	})
 }
 
@@ -88,16 +108,20 @@ func RunNodeControllers() error {
 	}
 
 	return runManager(ctrlOpts, []func(ctrl.Manager) error{
+		nodecontrollers.SetUpBackupNodeReconciler,
 		nodecontrollers.SetUpNBDExportNodeReconciler,
+		nodecontrollers.SetUpSnapshotNodeReconciler,
 		nodecontrollers.SetUpThinPoolLvNodeReconciler,
 		nodecontrollers.SetUpVolumeNodeReconciler,
+		nodecontrollers.SetUpVolumeMigrationNodeReconciler,
+		nodecontrollers.SetUpVolumeReplicationNodeReconciler,
 	})
 }
 
 func runManager(ctrlOpts ctrl.Options, controllerSetUpFuncs []func(ctrl.Manager) error) error {
 	// KubeSAN VGs use their own LVM profile to avoid interfering with the system-wide lvm.conf config. This profile
 	// is hardcoded here and is put in place before creating LVs that get their config from the profile.
-	err := commands.LvmCreateProfile(config.LvmProfileName, config.LvmProfile)
+	err := lvm.CreateProfile(config.LvmProfileName, config.LvmProfile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,346 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/qemuimg"
+	"gitlab.com/kubesan/kubesan/internal/common/s3"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/backup"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// How often the Progressing condition is updated while copying
+const backupProgressInterval = 10 * time.Second
+
+type BackupNodeReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	workers *workers.Workers
+
+	// Backup work in progress, for reporting progress
+	backups map[string]*backupWork
+}
+
+func SetUpBackupNodeReconciler(mgr ctrl.Manager) error {
+	r := &BackupNodeReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		workers: workers.NewWorkers(),
+		backups: make(map[string]*backupWork),
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Backup{}).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapThinPoolLvToBackups))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=backups/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch,namespace=kubesan-system
+
+// Backups are copied on the node where the thin-pool holding their snapshot
+// is active
+func (r *BackupNodeReconciler) mapThinPoolLvToBackups(ctx context.Context, thinPoolLv client.Object) []reconcile.Request {
+	backups := &v1alpha1.BackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range backups.Items {
+		snapshot := &v1alpha1.Snapshot{}
+		err := r.Get(ctx, types.NamespacedName{Name: backups.Items[i].Spec.SourceSnapshot, Namespace: config.Namespace}, snapshot)
+		if err == nil && snapshot.Spec.SourceVolume == thinPoolLv.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&backups.Items[i])})
+		}
+	}
+	return requests
+}
+
+type backupWork struct {
+	source string
+	target *v1alpha1.BackupTarget
+
+	// Set if the target is in S3
+	s3Client *s3.Client
+
+	mu      sync.Mutex
+	percent float64
+}
+
+func (w *backupWork) Run(ctx context.Context) error {
+	if w.target.File != nil {
+		return w.runFile(ctx)
+	}
+	return w.runS3(ctx)
+}
+
+// Writes the image next to its final path and renames it on success so that
+// a partial image is never mistaken for a complete one
+func (w *backupWork) runFile(ctx context.Context) error {
+	path := backup.HostPath(w.target.File.Path)
+	partialPath := path + ".partial"
+
+	if err := qemuimg.ConvertToQcow2(ctx, w.source, partialPath, w.setProgress); err != nil {
+		_ = os.Remove(partialPath)
+		return err
+	}
+	return os.Rename(partialPath, path)
+}
+
+// Writes the image to the scratch volume and uploads it, reporting each as
+// half of the progress. qemu-img cannot write a qcow2 image to a pipe.
+func (w *backupWork) runS3(ctx context.Context) error {
+	maxImageBytes, err := qemuimg.MeasureQcow2(ctx, w.source)
+	if err != nil {
+		return err
+	}
+
+	file, err := backup.CreateScratchFile("backup-*.qcow2", maxImageBytes)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	err = qemuimg.ConvertToQcow2(ctx, w.source, file.Name(), func(percent float64) {
+		w.setProgress(percent / 2)
+	})
+	if err != nil {
+		return err
+	}
+
+	// qemu-img replaced the file, so reopen it
+	image, err := os.Open(file.Name())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = image.Close() }()
+
+	info, err := image.Stat()
+	if err != nil {
+		return err
+	}
+
+	return w.s3Client.Upload(ctx, w.target.S3.Bucket, w.target.S3.Key, image, func(doneBytes int64) {
+		if info.Size() > 0 {
+			w.setProgress(50 + 50*float64(doneBytes)/float64(info.Size()))
+		}
+	})
+}
+
+func (w *backupWork) setProgress(percent float64) {
+	w.mu.Lock()
+	w.percent = percent
+	w.mu.Unlock()
+}
+
+func (w *backupWork) progress() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.percent
+}
+
+// Returns a unique name for a backup work item
+func backupWorkName(b *v1alpha1.Backup) string {
+	return fmt.Sprintf("backup/%s", b.Name)
+}
+
+func (r *BackupNodeReconciler) newBackupWork(ctx context.Context, b *v1alpha1.Backup, snapshot *v1alpha1.Snapshot) (*backupWork, error) {
+	work := &backupWork{
+		source: fmt.Sprintf("/dev/%s/%s", snapshot.Spec.VgName, thinpoollv.SnapshotToThinLvName(snapshot.Name)),
+		target: &b.Spec.Target,
+	}
+
+	if b.Spec.Target.S3 != nil {
+		s3Client, err := backup.NewS3Client(ctx, r.Client, b.Spec.Target.S3)
+		if err != nil {
+			return nil, err
+		}
+		work.s3Client = s3Client
+	}
+
+	return work, nil
+}
+
+func (r *BackupNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("BackupNodeReconciler entered")
+	defer log.Info("BackupNodeReconciler exited")
+
+	b := &v1alpha1.Backup{}
+	if err := r.Get(ctx, req.NamespacedName, b); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if b.Status.NodeName != "" && b.Status.NodeName != config.LocalNodeName {
+		return ctrl.Result{}, nil // another node is copying the snapshot
+	}
+
+	if b.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, b)
+	}
+
+	if conditionsv1.IsStatusConditionTrue(b.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, nil
+	}
+
+	// only continue on the node where the snapshot's thin LV is active
+
+	snapshot := &v1alpha1.Snapshot{}
+	err := r.Get(ctx, types.NamespacedName{Name: b.Spec.SourceSnapshot, Namespace: config.Namespace}, snapshot)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err = r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, thinPoolLv)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !isThinLvActiveOnLocalNode(thinPoolLv, thinpoollv.SnapshotToThinLvName(snapshot.Name)) {
+		return ctrl.Result{}, nil
+	}
+
+	if b.Status.NodeName == "" {
+		b.Status.NodeName = config.LocalNodeName
+		if err := r.statusUpdate(ctx, b); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return r.reconcileCopy(ctx, b, snapshot)
+}
+
+func (r *BackupNodeReconciler) reconcileCopy(ctx context.Context, b *v1alpha1.Backup, snapshot *v1alpha1.Snapshot) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	name := backupWorkName(b)
+	work, ok := r.backups[name]
+	if !ok {
+		var err error
+		work, err = r.newBackupWork(ctx, b, snapshot)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.backups[name] = work
+	}
+
+	progressing := conditionsv1.Condition{
+		Type: v1alpha1.BackupConditionProgressing,
+	}
+
+	err := r.workers.Run(name, b, work)
+	if _, ok := err.(*util.WatchPending); ok {
+		percent := work.progress()
+		progressing.Status = corev1.ConditionTrue
+		progressing.Reason = "Copying"
+		progressing.Message = fmt.Sprintf("%.0f%% copied", percent)
+		changed := util.SetStatusConditionIfChanged(&b.Status.Conditions, progressing)
+		if b.Status.ProgressPercent != int32(percent) {
+			b.Status.ProgressPercent = int32(percent)
+			changed = true
+		}
+		if changed {
+			if err := r.statusUpdate(ctx, b); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: backupProgressInterval}, nil
+	}
+	delete(r.backups, name)
+
+	progressing.Status = corev1.ConditionFalse
+	failed := conditionsv1.Condition{
+		Type: v1alpha1.BackupConditionFailed,
+	}
+
+	if err != nil {
+		log.Error(err, "backup failed")
+		progressing.Reason = "Failed"
+		failed.Status = corev1.ConditionTrue
+		failed.Reason = "CopyFailed"
+		failed.Message = err.Error()
+		b.Status.ProgressPercent = 0
+	} else {
+		log.Info("backup succeeded")
+		progressing.Reason = "Completed"
+		failed.Status = corev1.ConditionFalse
+		b.Status.ProgressPercent = 100
+		conditionsv1.SetStatusCondition(&b.Status.Conditions, conditionsv1.Condition{
+			Type:   conditionsv1.ConditionAvailable,
+			Status: corev1.ConditionTrue,
+		})
+	}
+	conditionsv1.SetStatusCondition(&b.Status.Conditions, progressing)
+	conditionsv1.SetStatusCondition(&b.Status.Conditions, failed)
+
+	// let any node retry, in case the thin-pool moves elsewhere
+	b.Status.NodeName = ""
+
+	if err := r.statusUpdate(ctx, b); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// returning the error retries the backup with backoff
+	return ctrl.Result{}, err
+}
+
+// Stops copying and releases the backup so the cluster controller can delete
+// it
+func (r *BackupNodeReconciler) reconcileDeleting(ctx context.Context, b *v1alpha1.Backup) error {
+	if b.Status.NodeName != config.LocalNodeName {
+		return nil
+	}
+
+	name := backupWorkName(b)
+	if err := r.workers.Cancel(name); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+	delete(r.backups, name)
+
+	// an upload is aborted by the worker, but a canceled file backup may
+	// leave its partial image behind
+	if b.Spec.Target.File != nil {
+		err := os.Remove(backup.HostPath(b.Spec.Target.File.Path) + ".partial")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	b.Status.NodeName = ""
+	return r.statusUpdate(ctx, b)
+}
+
+func (r *BackupNodeReconciler) statusUpdate(ctx context.Context, b *v1alpha1.Backup) error {
+	b.Status.ObservedGeneration = b.Generation
+	return r.Status().Update(ctx, b)
+}
@@ -4,6 +4,7 @@ package node
 
 import (
 	"context"
+	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -89,11 +90,12 @@ func (r *NBDExportNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if export.Status.URI == "" {
 		log.Info("Starting NBD export")
 
-		uri, err := nbd.StartServer(ctx, serverId, export.Spec.Path)
+		uri, err := nbd.StartServer(ctx, serverId, export.Spec.Path, export.Spec.QoS)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 		export.Status.URI = uri
+		export.Status.QoS = export.Spec.QoS.DeepCopy()
 		condition := conditionsv1.Condition{
 			Type:    conditionsv1.ConditionAvailable,
 			Status:  corev1.ConditionTrue,
@@ -121,6 +123,18 @@ func (r *NBDExportNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
+	if !reflect.DeepEqual(export.Spec.QoS, export.Status.QoS) {
+		log.Info("Updating NBD export I/O limits")
+
+		if err := nbd.SetServerQoS(ctx, serverId, export.Spec.QoS); err != nil {
+			return ctrl.Result{}, err
+		}
+		export.Status.QoS = export.Spec.QoS.DeepCopy()
+		if err := r.statusUpdate(ctx, export); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
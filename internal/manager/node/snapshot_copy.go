@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/common/qemuimg"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+)
+
+// Snapshots with Spec.Copy are copied on the node where the cluster controller
+// activated their thin LV, and for Thin copies the thin LV holding the copy.
+
+// How often the Copied condition is updated while copying
+const snapshotCopyProgressInterval = 10 * time.Second
+
+// Snapshots live in the thin-pool of their source volume, which is named after
+// the volume. Thin copies live in a thin-pool named after the snapshot.
+func (r *SnapshotNodeReconciler) mapThinPoolLvToSnapshotCopies(ctx context.Context, thinPoolLv client.Object) []reconcile.Request {
+	snapshots := &v1alpha1.SnapshotList{}
+	if err := r.List(ctx, snapshots, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if snapshot.Spec.Copy != nil && (snapshot.Spec.SourceVolume == thinPoolLv.GetName() || snapshot.Name == thinPoolLv.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(snapshot)})
+		}
+	}
+	return requests
+}
+
+type snapshotCopyWork struct {
+	source string
+	target string
+
+	// Set if the target is a linear LV that must be activated first
+	targetVgName string
+	targetLvName string
+
+	mu      sync.Mutex
+	percent float64
+}
+
+func (w *snapshotCopyWork) Run(ctx context.Context) error {
+	if w.targetLvName == "" {
+		return w.copy(ctx)
+	}
+	return lvm.WithLvActivated(w.targetVgName, w.targetLvName, func() error {
+		return w.copy(ctx)
+	})
+}
+
+// The target was zeroed or is a new thin LV, so zero regions are skipped
+func (w *snapshotCopyWork) copy(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	log.Info("snapshot copy worker copying", "source", w.source, "target", w.target)
+	err := qemuimg.Convert(ctx, w.source, v1alpha1.ImageFormatRaw, w.target, w.setProgress)
+	log.Info("snapshot copy worker finished", "target", w.target)
+	return err
+}
+
+func (w *snapshotCopyWork) setProgress(percent float64) {
+	w.mu.Lock()
+	w.percent = percent
+	w.mu.Unlock()
+}
+
+func (w *snapshotCopyWork) progress() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.percent
+}
+
+// Returns a unique name for a snapshot copy work item
+func snapshotCopyWorkName(snapshot *v1alpha1.Snapshot) string {
+	return fmt.Sprintf("snapshot-copy/%s", snapshot.Name)
+}
+
+func newSnapshotCopyWork(snapshot *v1alpha1.Snapshot) *snapshotCopyWork {
+	work := &snapshotCopyWork{
+		source: fmt.Sprintf("/dev/%s/%s", snapshot.Spec.VgName, thinpoollv.SnapshotToThinLvName(snapshot.Name)),
+	}
+
+	if snapshot.Spec.Copy.Mode == v1alpha1.SnapshotCopyModeThin {
+		work.target = fmt.Sprintf("/dev/%s/%s", snapshot.Spec.Copy.VgName, thinpoollv.VolumeToThinLvName(snapshot.Name))
+	} else {
+		work.target = fmt.Sprintf("/dev/%s/%s", snapshot.Spec.Copy.VgName, snapshot.Name)
+		work.targetVgName = snapshot.Spec.Copy.VgName
+		work.targetLvName = snapshot.Name
+	}
+
+	return work
+}
+
+// Returns true if the snapshot's thin LV and, for Thin copies, the thin LV
+// holding the copy are active on this node
+func (r *SnapshotNodeReconciler) isCopyActiveOnLocalNode(ctx context.Context, snapshot *v1alpha1.Snapshot) (bool, error) {
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, thinPoolLv)
+	if err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !isThinLvActiveOnLocalNode(thinPoolLv, thinpoollv.SnapshotToThinLvName(snapshot.Name)) {
+		return false, nil
+	}
+
+	if snapshot.Spec.Copy.Mode != v1alpha1.SnapshotCopyModeThin {
+		return true, nil
+	}
+
+	copyThinPoolLv := &v1alpha1.ThinPoolLv{}
+	err = r.Get(ctx, types.NamespacedName{Name: snapshot.Name, Namespace: config.Namespace}, copyThinPoolLv)
+	if err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return isThinLvActiveOnLocalNode(copyThinPoolLv, thinpoollv.VolumeToThinLvName(snapshot.Name)), nil
+}
+
+// Copies the snapshot into the LV created by the cluster controller in the
+// background, reflecting progress in the Copied condition
+func (r *SnapshotNodeReconciler) reconcileCopy(ctx context.Context, snapshot *v1alpha1.Snapshot) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	if snapshot.Status.CopyNodeName != "" && snapshot.Status.CopyNodeName != config.LocalNodeName {
+		return ctrl.Result{}, nil // another node is copying the snapshot
+	}
+
+	if snapshot.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.cancelCopy(ctx, snapshot)
+	}
+
+	if conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, v1alpha1.SnapshotConditionCopied) {
+		return ctrl.Result{}, nil
+	}
+
+	// only continue on the node where the thin LVs are active
+
+	active, err := r.isCopyActiveOnLocalNode(ctx, snapshot)
+	if err != nil || !active {
+		return ctrl.Result{}, err
+	}
+
+	if snapshot.Status.CopyNodeName == "" {
+		snapshot.Status.CopyNodeName = config.LocalNodeName
+		if err := r.statusUpdate(ctx, snapshot); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	name := snapshotCopyWorkName(snapshot)
+	work, ok := r.copies[name]
+	if !ok {
+		work = newSnapshotCopyWork(snapshot)
+		r.copies[name] = work
+	}
+
+	condition := conditionsv1.Condition{
+		Type: v1alpha1.SnapshotConditionCopied,
+	}
+
+	err = r.workers.Run(name, snapshot, work)
+	if _, ok := err.(*util.WatchPending); ok {
+		percent := work.progress()
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "Copying"
+		condition.Message = fmt.Sprintf("%.0f%% copied", percent)
+		changed := util.SetStatusConditionIfChanged(&snapshot.Status.Conditions, condition)
+		if snapshot.Status.CopyProgressPercent != int32(percent) {
+			snapshot.Status.CopyProgressPercent = int32(percent)
+			changed = true
+		}
+		if changed {
+			if err := r.statusUpdate(ctx, snapshot); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: snapshotCopyProgressInterval}, nil
+	}
+	delete(r.copies, name)
+
+	if err != nil {
+		log.Error(err, "snapshot copy failed")
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "CopyFailed"
+		condition.Message = err.Error()
+		snapshot.Status.CopyProgressPercent = 0
+	} else {
+		log.Info("snapshot copy succeeded")
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "Copied"
+		snapshot.Status.CopyProgressPercent = 100
+	}
+	conditionsv1.SetStatusCondition(&snapshot.Status.Conditions, condition)
+
+	// let any node retry, in case the thin-pool moves elsewhere
+	snapshot.Status.CopyNodeName = ""
+
+	if err := r.statusUpdate(ctx, snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// returning the error retries the copy with backoff
+	return ctrl.Result{}, err
+}
+
+// Stops copying and releases the snapshot so the cluster controller can
+// delete it
+func (r *SnapshotNodeReconciler) cancelCopy(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	if snapshot.Status.CopyNodeName != config.LocalNodeName {
+		return nil
+	}
+
+	name := snapshotCopyWorkName(snapshot)
+	if err := r.workers.Cancel(name); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+	delete(r.copies, name)
+
+	snapshot.Status.CopyNodeName = ""
+	return r.statusUpdate(ctx, snapshot)
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/dm"
+	"gitlab.com/kubesan/kubesan/internal/common/fsfreeze"
+	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// While the cluster controller takes a snapshot of a mounted file system, it
+// sets a freeze deadline. Nodes that have the source volume attached freeze
+// its file system and list themselves in the snapshot's FrozenNodes. They thaw
+// it as soon as the snapshot is available, and a timer thaws it at the
+// deadline no matter what. Nodes list themselves in FreezingNodes before they
+// freeze, so the state of the freeze is always recorded in the snapshot and
+// never only in memory. Should the manager restart in between, the first
+// reconcile thaws file systems whose freeze was interrupted or whose deadline
+// has passed. Members of a GroupSnapshot freeze block volumes too, by
+// suspending I/O on their dm wrapper, and stay frozen until the whole group
+// has been cut.
+
+type SnapshotNodeReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	workers *workers.Workers
+
+	// Timers thawing frozen file systems at their deadline, by snapshot
+	mu         sync.Mutex
+	thawTimers map[string]*time.Timer
+
+	// Copy work in progress, for reporting progress
+	copies map[string]*snapshotCopyWork
+}
+
+func SetUpSnapshotNodeReconciler(mgr ctrl.Manager) error {
+	r := &SnapshotNodeReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		workers:    workers.NewWorkers(),
+		thawTimers: make(map[string]*time.Timer),
+		copies:     make(map[string]*snapshotCopyWork),
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Snapshot{}).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapThinPoolLvToSnapshotCopies))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=snapshots/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=thinpoollvs,verbs=get;list;watch,namespace=kubesan-system
+
+func (r *SnapshotNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("SnapshotNodeReconciler entered")
+	defer log.Info("SnapshotNodeReconciler exited")
+
+	snapshot := &v1alpha1.Snapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		// the thaw timer, if any, still fires
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// the copy is taken once the file system has been thawed
+
+	result, err := r.reconcileFreeze(ctx, snapshot)
+	if err != nil || !result.IsZero() || snapshot.Spec.Copy == nil {
+		return result, err
+	}
+	return r.reconcileCopy(ctx, snapshot)
+}
+
+func (r *SnapshotNodeReconciler) reconcileFreeze(ctx context.Context, snapshot *v1alpha1.Snapshot) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	freezing := slices.Contains(snapshot.Status.FreezingNodes, config.LocalNodeName)
+	frozen := slices.Contains(snapshot.Status.FrozenNodes, config.LocalNodeName)
+	deadline := snapshot.Status.FreezeDeadline
+	done := snapshot.DeletionTimestamp != nil ||
+		conditionsv1.IsStatusConditionTrue(snapshot.Status.Conditions, conditionsv1.ConditionAvailable) ||
+		deadline == nil || !time.Now().Before(deadline.Time)
+
+	if !freezing && !frozen && (done || snapshot.Status.Consistency != "") {
+		return ctrl.Result{}, nil // nothing to freeze or the snapshot is being taken already
+	}
+
+	volume := &v1alpha1.Volume{}
+	err := r.Get(ctx, types.NamespacedName{Name: snapshot.Spec.SourceVolume, Namespace: config.Namespace}, volume)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if freezing {
+		// Reconciles of a snapshot never overlap, so the freeze was
+		// interrupted, e.g. by a restart, and may or may not have
+		// happened. The update triggers another attempt if there is
+		// still time.
+		log.Info("Thawing file system after interrupted freeze", "volume", volume.Name)
+
+		r.stopThawTimer(snapshot.Name)
+		if err := thawVolume(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		snapshot.Status.FreezingNodes = kubesanslices.RemoveAll(snapshot.Status.FreezingNodes, config.LocalNodeName)
+		return ctrl.Result{}, r.statusUpdate(ctx, snapshot)
+	}
+
+	if frozen && done {
+		log.Info("Thawing file system", "volume", volume.Name)
+
+		r.stopThawTimer(snapshot.Name)
+		if err := thawVolume(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		snapshot.Status.FrozenNodes = kubesanslices.RemoveAll(snapshot.Status.FrozenNodes, config.LocalNodeName)
+		return ctrl.Result{}, r.statusUpdate(ctx, snapshot)
+	}
+
+	if frozen {
+		// after a restart, the timer is gone
+		r.startThawTimer(snapshot.Name, volume, deadline.Time)
+		return ctrl.Result{RequeueAfter: time.Until(deadline.Time)}, nil
+	}
+
+	if !slices.Contains(volume.Status.AttachedToNodes, config.LocalNodeName) || volume.Status.Path == "" {
+		return ctrl.Result{}, nil
+	}
+
+	// record the freeze before starting it, so that it is undone even if
+	// the manager restarts before it can be listed in FrozenNodes
+
+	snapshot.Status.FreezingNodes = kubesanslices.AppendUnique(snapshot.Status.FreezingNodes, config.LocalNodeName)
+	if err := r.statusUpdate(ctx, snapshot); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.startThawTimer(snapshot.Name, volume, deadline.Time)
+
+	freezeCtx, cancel := context.WithDeadline(ctx, deadline.Time)
+	defer cancel()
+
+	if err := freezeVolume(freezeCtx, volume); err != nil {
+		// The snapshot is taken at the deadline without us. Keep the
+		// timer in case a freeze completed after all.
+		log.Error(err, "Failed to freeze file system", "volume", volume.Name)
+		if err := thawVolume(context.Background(), volume); err != nil {
+			return ctrl.Result{}, err // FreezingNodes makes the next reconcile try again
+		}
+
+		snapshot.Status.FreezingNodes = kubesanslices.RemoveAll(snapshot.Status.FreezingNodes, config.LocalNodeName)
+		return ctrl.Result{}, r.statusUpdate(ctx, snapshot)
+	}
+
+	snapshot.Status.FreezingNodes = kubesanslices.RemoveAll(snapshot.Status.FreezingNodes, config.LocalNodeName)
+	snapshot.Status.FrozenNodes = kubesanslices.AppendUnique(snapshot.Status.FrozenNodes, config.LocalNodeName)
+	if err := r.statusUpdate(ctx, snapshot); err != nil {
+		r.stopThawTimer(snapshot.Name)
+		_ = thawVolume(context.Background(), volume)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: time.Until(deadline.Time)}, nil
+}
+
+// Freezes every file system on the volume, or suspends I/O on a block volume
+func freezeVolume(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Spec.Type.Filesystem == nil {
+		log.FromContext(ctx).Info("Suspending I/O", "volume", volume.Name)
+		return dm.Quiesce(ctx, volume.Name)
+	}
+
+	mountPoints, err := fsfreeze.MountPoints(ctx, volume.Status.Path)
+	if err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Freezing file system", "volume", volume.Name, "mount points", mountPoints)
+	return fsfreeze.Freeze(ctx, mountPoints)
+}
+
+// Thaws every file system on the volume, or resumes I/O on a block volume
+func thawVolume(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Spec.Type.Filesystem == nil {
+		return dm.Unquiesce(ctx, volume.Name)
+	}
+
+	mountPoints, err := fsfreeze.MountPoints(ctx, volume.Status.Path)
+	if err != nil {
+		return err
+	}
+	return fsfreeze.Thaw(ctx, mountPoints)
+}
+
+func (r *SnapshotNodeReconciler) startThawTimer(name string, volume *v1alpha1.Volume, deadline time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.thawTimers[name]; ok {
+		return
+	}
+
+	volume = volume.DeepCopy()
+	r.thawTimers[name] = time.AfterFunc(time.Until(deadline), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), config.SnapshotFreezeTimeout)
+		defer cancel()
+
+		log := log.Log.WithValues("nodeName", config.LocalNodeName)
+		log.Info("Freeze deadline passed, thawing file system", "volume", volume.Name)
+		if err := thawVolume(ctx, volume); err != nil {
+			log.Error(err, "Failed to thaw file system", "volume", volume.Name)
+		}
+
+		r.mu.Lock()
+		delete(r.thawTimers, name)
+		r.mu.Unlock()
+	})
+}
+
+func (r *SnapshotNodeReconciler) stopThawTimer(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timer, ok := r.thawTimers[name]; ok {
+		timer.Stop()
+		delete(r.thawTimers, name)
+	}
+}
+
+func (r *SnapshotNodeReconciler) statusUpdate(ctx context.Context, snapshot *v1alpha1.Snapshot) error {
+	snapshot.Status.ObservedGeneration = snapshot.Generation
+	return r.Status().Update(ctx, snapshot)
+}
@@ -4,9 +4,14 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -17,7 +22,11 @@ import (
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
 	"gitlab.com/kubesan/kubesan/internal/common/commands"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
 	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
+	"gitlab.com/kubesan/kubesan/internal/common/thindelta"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
 )
 
 type ThinPoolLvNodeReconciler struct {
@@ -76,12 +85,7 @@ func (r *ThinPoolLvNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 	if !stayActive {
 		defer func() {
-			_, _ = commands.Lvm(
-				"lvchange",
-				"--devicesfile", thinPoolLv.Spec.VgName,
-				"--activate", "n",
-				fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinPoolLv.Name),
-			)
+			_ = lvm.DeactivateLv(thinPoolLv.Spec.VgName, thinPoolLv.Name)
 		}()
 	}
 
@@ -99,6 +103,14 @@ func (r *ThinPoolLvNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{}, err
 	}
 
+	// revert before activating so that a volume being attached never
+	// sees its contents change
+
+	err = r.reconcileThinLvReverts(ctx, thinPoolLv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	err = r.reconcileThinLvActivations(ctx, thinPoolLv)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -111,9 +123,161 @@ func (r *ThinPoolLvNodeReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 	// TODO thin LV expansion
 
+	err = r.reconcileThinPoolLvUsage(ctx, thinPoolLv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if stayActive {
+		err = r.reconcileThinPoolLvParameters(ctx, thinPoolLv, true)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// the thin-pool can fill up or lose PVs at any time, so check periodically
+
+		err = r.reconcileThinPoolLvHealth(ctx, thinPoolLv)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{RequeueAfter: config.HealthCheckInterval}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// Reflect the thin-pool's LVM health status in the Degraded condition
+func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvHealth(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
+	lv, err := lvm.GetLv(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.LvFieldHealthStatus)
+	if err != nil {
+		return err
+	}
+
+	if util.SetStatusConditionIfChanged(&thinPoolLv.Status.Conditions, util.LvHealthCondition(lv.HealthStatus)) {
+		if err := r.statusUpdate(ctx, thinPoolLv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Record the space usage of the thin-pool and its thin LVs. Measuring reads
+// the thin-pool metadata, so it is only repeated every HealthCheckInterval
+// unless a thin LV has not been measured yet.
+func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvUsage(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
+	unmeasured := kubesanslices.Any(thinPoolLv.Status.ThinLvs, func(thinLvStatus v1alpha1.ThinLvStatus) bool {
+		return thinLvStatus.State.Name != v1alpha1.ThinLvStatusStateNameRemoved && thinLvStatus.AllocatedBytes == nil
+	})
+	usage := thinPoolLv.Status.Usage
+	if !unmeasured && usage != nil && time.Since(usage.UpdateTime.Time) < config.HealthCheckInterval {
+		return nil
+	}
+
+	poolUsage, err := thindelta.GetPoolUsage(thinPoolLv.Spec.VgName, thinPoolLv.Name)
+	if err != nil {
+		return err
+	}
+
+	lvUsages, err := thindelta.LvUsages(ctx, thinPoolLv.Spec.VgName, thinPoolLv.Name)
+	if err != nil {
+		return err
+	}
+
+	thinPoolLv.Status.Usage = &v1alpha1.ThinPoolUsage{
+		DataBytes:         poolUsage.DataBytes,
+		DataUsedBytes:     poolUsage.DataUsedBytes,
+		MetadataBytes:     poolUsage.MetadataBytes,
+		MetadataUsedBytes: poolUsage.MetadataUsedBytes,
+		UpdateTime:        metav1.Now(),
+	}
+
+	for i := range thinPoolLv.Status.ThinLvs {
+		thinLvStatus := &thinPoolLv.Status.ThinLvs[i]
+		if lvUsage, ok := lvUsages[thinLvStatus.Name]; ok {
+			thinLvStatus.AllocatedBytes = &lvUsage.AllocatedBytes
+			thinLvStatus.ExclusiveBytes = &lvUsage.ExclusiveBytes
+		}
+	}
+
+	return r.statusUpdate(ctx, thinPoolLv)
+}
+
+// Apply Spec.Autoextend and Spec.Discards to the LVM thin pool LV. Changing
+// discards to or from "ignore" is deferred until the LVM thin pool LV is
+// inactive since LVM does not support it otherwise.
+func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvParameters(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv, isActive bool) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+	needUpdate := false
+
+	// the profile must exist on whichever node monitors the thin-pool, so
+	// always put it in place
+
+	profile, err := createAutoextendProfile(thinPoolLv.Spec.Autoextend)
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(thinPoolLv.Spec.Autoextend, thinPoolLv.Status.Autoextend) {
+		err := lvm.ChangeLv(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.ChangeLvOptions{MetadataProfile: profile})
+		if err != nil {
+			return err
+		}
+
+		thinPoolLv.Status.Autoextend = thinPoolLv.Spec.Autoextend.DeepCopy()
+		needUpdate = true
+	}
+
+	want := discardsOrDefault(thinPoolLv.Spec.Discards)
+	have := discardsOrDefault(thinPoolLv.Status.Discards)
+	if want != have {
+		if isActive && (want == v1alpha1.ThinPoolDiscardsIgnore || have == v1alpha1.ThinPoolDiscardsIgnore) {
+			log.Info("Deferring discards change until thin-pool is inactive", "discards", want)
+		} else {
+			err := lvm.ChangeLv(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.ChangeLvOptions{Discards: strings.ToLower(string(want))})
+			if err != nil {
+				return err
+			}
+
+			thinPoolLv.Status.Discards = want
+			needUpdate = true
+		}
+	}
+
+	if needUpdate {
+		return r.statusUpdate(ctx, thinPoolLv)
+	}
+	return nil
+}
+
+func discardsOrDefault(discards v1alpha1.ThinPoolDiscards) v1alpha1.ThinPoolDiscards {
+	if discards == "" {
+		return v1alpha1.ThinPoolDiscardsPassdown
+	}
+	return discards
+}
+
+// Returns the name of an LVM profile with the given autoextend policy after
+// putting it in place, or the KubeSAN profile if autoextend is nil.
+func createAutoextendProfile(autoextend *v1alpha1.ThinPoolAutoextend) (string, error) {
+	if autoextend == nil {
+		return config.LvmProfileName, nil
+	}
+
+	name := fmt.Sprintf("%s-autoextend-%d-%d", config.LvmProfileName, autoextend.ThresholdPercent, autoextend.Percent)
+	contents := fmt.Sprintf(""+
+		"# This file is part of the KubeSAN CSI plugin and may be automatically\n"+
+		"# updated. Do not edit!\n"+
+		"\n"+
+		"activation {\n"+
+		"        thin_pool_autoextend_threshold=%d\n"+
+		"        thin_pool_autoextend_percent=%d\n"+
+		"}\n", autoextend.ThresholdPercent, autoextend.Percent)
+
+	return name, lvm.CreateProfile(name, contents)
+}
+
 // Returns true if the thin-pool should be active
 func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvActivation(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) (bool, error) {
 	thinPoolLvShouldBeActive := thinPoolLv.DeletionTimestamp == nil &&
@@ -121,14 +285,17 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvActivation(ctx context.Con
 
 	if thinPoolLvShouldBeActive {
 		if thinPoolLv.Spec.ActiveOnNode == config.LocalNodeName {
+			// some changes can only be made while inactive
+
+			if thinPoolLv.Status.ActiveOnNode != config.LocalNodeName {
+				if err := r.reconcileThinPoolLvParameters(ctx, thinPoolLv, false); err != nil {
+					return thinPoolLvShouldBeActive, err
+				}
+			}
+
 			// activate LVM thin pool LV
 
-			_, err := commands.Lvm(
-				"lvchange",
-				"--devicesfile", thinPoolLv.Spec.VgName,
-				"--activate", "ey",
-				fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinPoolLv.Name),
-			)
+			err := lvm.ActivateLv(thinPoolLv.Spec.VgName, thinPoolLv.Name, lvm.ActivateExclusive)
 			if err != nil {
 				return thinPoolLvShouldBeActive, err
 			}
@@ -157,15 +324,16 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvActivation(ctx context.Con
 			for i := range thinPoolLv.Status.ThinLvs {
 				thinLvStatus := &thinPoolLv.Status.ThinLvs[i]
 
-				_, err := commands.Lvm(
-					"lvchange",
-					"--devicesfile", thinPoolLv.Spec.VgName,
-					"--activate", "n",
-					fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvStatus.Name),
-				)
+				err := lvm.DeactivateLv(thinPoolLv.Spec.VgName, thinLvStatus.Name)
 				if err != nil {
 					return thinPoolLvShouldBeActive, err
 				}
+
+				if originLvName := externalOriginLvName(thinPoolLv); originLvName != "" {
+					if err := deactivateExternalOrigin(thinPoolLv.Spec.VgName, originLvName); err != nil {
+						return thinPoolLvShouldBeActive, err
+					}
+				}
 			}
 
 			for i := range thinPoolLv.Status.ThinLvs {
@@ -178,12 +346,7 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinPoolLvActivation(ctx context.Con
 
 			// deactivate LVM thin pool LV
 
-			_, err := commands.Lvm(
-				"lvchange",
-				"--devicesfile", thinPoolLv.Spec.VgName,
-				"--activate", "n",
-				fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinPoolLv.Name),
-			)
+			err := lvm.DeactivateLv(thinPoolLv.Spec.VgName, thinPoolLv.Name)
 			if err != nil {
 				return thinPoolLvShouldBeActive, err
 			}
@@ -302,14 +465,15 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinLvActivations(ctx context.Contex
 		}
 
 		if shouldBeActive && !isActuallyActive {
+			if originLvName := externalOriginLvName(thinPoolLv); originLvName != "" {
+				if err := activateExternalOrigin(thinPoolLv.Spec.VgName, originLvName); err != nil {
+					return err
+				}
+			}
+
 			// activate LVM thin LV
 
-			_, err = commands.Lvm(
-				"lvchange",
-				"--devicesfile", thinPoolLv.Spec.VgName,
-				"--activate", "ey",
-				fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvStatus.Name),
-			)
+			err = lvm.ActivateLv(thinPoolLv.Spec.VgName, thinLvStatus.Name, lvm.ActivateExclusive)
 			if err != nil {
 				return err
 			}
@@ -331,16 +495,17 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinLvActivations(ctx context.Contex
 		} else if !shouldBeActive && isActuallyActive {
 			// deactivate LVM thin LV
 
-			_, err = commands.Lvm(
-				"lvchange",
-				"--devicesfile", thinPoolLv.Spec.VgName,
-				"--activate", "n",
-				fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvStatus.Name),
-			)
+			err = lvm.DeactivateLv(thinPoolLv.Spec.VgName, thinLvStatus.Name)
 			if err != nil {
 				return err
 			}
 
+			if originLvName := externalOriginLvName(thinPoolLv); originLvName != "" {
+				if err := deactivateExternalOrigin(thinPoolLv.Spec.VgName, originLvName); err != nil {
+					return err
+				}
+			}
+
 			// update status to reflect reality if necessary
 
 			if isActiveInStatus {
@@ -358,6 +523,77 @@ func (r *ThinPoolLvNodeReconciler) reconcileThinLvActivations(ctx context.Contex
 	return nil
 }
 
+// Reverts inactive thin LVs by replacing them with a new snapshot of the
+// source thin LV. Other snapshots of the thin LV are independent of it and
+// stay intact.
+func (r *ThinPoolLvNodeReconciler) reconcileThinLvReverts(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
+	for i := range thinPoolLv.Spec.ThinLvs {
+		thinLvSpec := &thinPoolLv.Spec.ThinLvs[i]
+		thinLvStatus := thinPoolLv.Status.FindThinLv(thinLvSpec.Name)
+
+		if !thinpoollv.IsRevertPending(thinLvSpec, thinLvStatus) {
+			continue
+		}
+		if thinLvSpec.State.Name != v1alpha1.ThinLvSpecStateNameInactive || thinLvStatus.State.Name != v1alpha1.ThinLvStatusStateNameInactive {
+			continue // wait until the thin LV is no longer in use
+		}
+
+		sourceStatus := thinPoolLv.Status.FindThinLv(thinLvSpec.Revert.SourceThinLvName)
+		if sourceStatus == nil || sourceStatus.State.Name == v1alpha1.ThinLvStatusStateNameRemoved {
+			continue // source thin LV does not (currently) exist
+		}
+
+		log.FromContext(ctx).Info("Reverting", "thin LV", thinLvSpec.Name, "source", thinLvSpec.Revert.SourceThinLvName)
+
+		if err := r.revertThinLv(thinPoolLv.Spec.VgName, thinLvSpec.Name, thinLvSpec.Revert.SourceThinLvName); err != nil {
+			return err
+		}
+
+		thinLvStatus.SizeBytes = sourceStatus.SizeBytes
+		thinLvStatus.RevertId = thinLvSpec.Revert.Id
+
+		if err := r.statusUpdate(ctx, thinPoolLv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Swaps in a writable snapshot of the source under the thin LV's name. This
+// takes several steps, so it picks up where it left off if interrupted.
+func (r *ThinPoolLvNodeReconciler) revertThinLv(vgName string, thinLvName string, sourceThinLvName string) error {
+	tmpLvName := thinLvName + "-revert"
+
+	exists, err := lvm.LvExists(vgName, thinLvName)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		err = lvm.RemoveLvIdempotent(vgName, tmpLvName, lvm.RemoveLvOptions{})
+		if err != nil {
+			return err
+		}
+
+		err = lvm.CreateLvIdempotent(vgName, tmpLvName, lvm.CreateLvOptions{
+			SnapshotOrigin:   sourceThinLvName,
+			NoActivationSkip: true,
+			ReadWrite:        true,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = lvm.RemoveLvIdempotent(vgName, thinLvName, lvm.RemoveLvOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return lvm.RenameLv(vgName, tmpLvName, thinLvName)
+}
+
 func (r *ThinPoolLvNodeReconciler) createThinLv(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv, thinLvSpec *v1alpha1.ThinLvSpec) error {
 	log := log.FromContext(ctx)
 
@@ -366,30 +602,34 @@ func (r *ThinPoolLvNodeReconciler) createThinLv(ctx context.Context, thinPoolLv
 		// create empty LVM thin LV
 		log.Info("Creating an empty thin LV")
 
-		_, err := commands.LvmLvCreateIdempotent(
-			"--devicesfile", thinPoolLv.Spec.VgName,
-			"--type", "thin",
-			"--name", thinLvSpec.Name,
-			"--thinpool", thinPoolLv.Name,
-			"--virtualsize", fmt.Sprintf("%db", thinLvSpec.SizeBytes),
-			thinPoolLv.Spec.VgName,
-		)
+		err := lvm.CreateLvIdempotent(thinPoolLv.Spec.VgName, thinLvSpec.Name, lvm.CreateLvOptions{
+			Type:             lvm.LvTypeThin,
+			VirtualSizeBytes: thinLvSpec.SizeBytes,
+			ThinPool:         thinPoolLv.Name,
+		})
 		if err != nil {
 			return err
 		}
 
 		// deactivate LVM thin LV (`--activate n` has no effect on `lvcreate --type thin`)
 
-		_, err = commands.Lvm(
-			"lvchange",
-			"--devicesfile", thinPoolLv.Spec.VgName,
-			"--activate", "n",
-			fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvSpec.Name),
-		)
+		err = lvm.DeactivateLv(thinPoolLv.Spec.VgName, thinLvSpec.Name)
 		if err != nil {
 			return err
 		}
 
+	case v1alpha1.ThinLvContentsTypeExternalOrigin:
+		if thinLvSpec.Contents.ExternalOrigin == nil {
+			log.Info("Missing external origin contents", "thin LV", thinLvSpec.Name)
+			return nil
+		}
+
+		log.Info("Creating a thin LV with an external origin")
+
+		if err := r.createExternalOriginThinLv(thinPoolLv, thinLvSpec); err != nil {
+			return err
+		}
+
 	case v1alpha1.ThinLvContentsTypeSnapshot:
 		if thinLvSpec.Contents.Snapshot == nil {
 			log.Info("Missing snapshot contents", "thin LV", thinLvSpec.Name)
@@ -407,13 +647,10 @@ func (r *ThinPoolLvNodeReconciler) createThinLv(ctx context.Context, thinPoolLv
 
 		// create snapshot LVM thin LV
 
-		_, err := commands.LvmLvCreateIdempotent(
-			"--devicesfile", thinPoolLv.Spec.VgName,
-			"--name", thinLvSpec.Name,
-			"--snapshot",
-			"--setactivationskip", "n",
-			fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, sourceLv),
-		)
+		err := lvm.CreateLvIdempotent(thinPoolLv.Spec.VgName, thinLvSpec.Name, lvm.CreateLvOptions{
+			SnapshotOrigin:   sourceLv,
+			NoActivationSkip: true,
+		})
 		if err != nil {
 			return err
 		}
@@ -443,14 +680,70 @@ func (r *ThinPoolLvNodeReconciler) createThinLv(ctx context.Context, thinPoolLv
 	return nil
 }
 
-func (r *ThinPoolLvNodeReconciler) removeThinLv(_ context.Context, thinPoolLv *v1alpha1.ThinPoolLv, thinLvName string) error {
-	_, err := commands.LvmLvRemoveIdempotent(
-		"--devicesfile", thinPoolLv.Spec.VgName,
-		fmt.Sprintf("%s/%s", thinPoolLv.Spec.VgName, thinLvName),
-	)
+// Creates a thin LV whose unprovisioned blocks are read from a read-only LV
+// outside the thin-pool. The thin LV starts out the size of its origin and is
+// extended to the requested size, with the extra space reading as zeroes.
+func (r *ThinPoolLvNodeReconciler) createExternalOriginThinLv(thinPoolLv *v1alpha1.ThinPoolLv, thinLvSpec *v1alpha1.ThinLvSpec) error {
+	vgName := thinPoolLv.Spec.VgName
+	originLvName := thinLvSpec.Contents.ExternalOrigin.OriginLvName
+
+	if err := activateExternalOrigin(vgName, originLvName); err != nil {
+		return err
+	}
+
+	err := lvm.CreateLvIdempotent(vgName, thinLvSpec.Name, lvm.CreateLvOptions{
+		ThinPool:         thinPoolLv.Name,
+		SnapshotOrigin:   originLvName,
+		NoActivationSkip: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	lv, err := lvm.GetLv(vgName, thinLvSpec.Name, lvm.LvFieldSize)
+	if err != nil {
+		return err
+	}
+	if lv.SizeBytes < thinLvSpec.SizeBytes {
+		if err := lvm.ExtendLv(vgName, thinLvSpec.Name, thinLvSpec.SizeBytes); err != nil {
+			return err
+		}
+	}
+
+	if err := lvm.DeactivateLv(vgName, thinLvSpec.Name); err != nil {
+		return err
+	}
+
+	return deactivateExternalOrigin(vgName, originLvName)
+}
+
+// Returns the read-only LV that the thin LVs in the thin-pool, including
+// snapshots, use as their external origin, or "".
+func externalOriginLvName(thinPoolLv *v1alpha1.ThinPoolLv) string {
+	return thinPoolLv.Labels[config.ExternalOriginLabel]
+}
+
+// External origins are shared by thin LVs in many thin-pools, which may be
+// active on different nodes, so they are activated in shared mode before any
+// thin LV using them.
+func activateExternalOrigin(vgName string, originLvName string) error {
+	return lvm.ActivateLv(vgName, originLvName, lvm.ActivateShared)
+}
+
+// Deactivates the external origin unless other thin LVs on this node still
+// use it.
+func deactivateExternalOrigin(vgName string, originLvName string) error {
+	err := lvm.DeactivateLv(vgName, originLvName)
+	if errors.Is(err, lvm.ErrInUse) {
+		err = nil // still open
+	}
 	return err
 }
 
+func (r *ThinPoolLvNodeReconciler) removeThinLv(_ context.Context, thinPoolLv *v1alpha1.ThinPoolLv, thinLvName string) error {
+	return lvm.RemoveLvIdempotent(thinPoolLv.Spec.VgName, thinLvName, lvm.RemoveLvOptions{})
+}
+
 func (r *ThinPoolLvNodeReconciler) statusUpdate(ctx context.Context, thinPoolLv *v1alpha1.ThinPoolLv) error {
 	thinPoolLv.Status.ObservedGeneration = thinPoolLv.Generation
 	return r.Status().Update(ctx, thinPoolLv)
@@ -4,22 +4,35 @@ package node
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"reflect"
 	"slices"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
 
 	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/cgroup"
 	"gitlab.com/kubesan/kubesan/internal/common/commands"
 	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/cron"
 	"gitlab.com/kubesan/kubesan/internal/common/dm"
+	"gitlab.com/kubesan/kubesan/internal/common/fsfreeze"
+	"gitlab.com/kubesan/kubesan/internal/common/fstrim"
+	"gitlab.com/kubesan/kubesan/internal/common/integrity"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
 	kubesanslices "gitlab.com/kubesan/kubesan/internal/common/slices"
 	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
 	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
@@ -39,11 +52,40 @@ func SetUpVolumeNodeReconciler(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Volume{}).
 		Owns(&v1alpha1.ThinPoolLv{}).
+		Watches(&v1alpha1.VolumeMigration{}, handler.EnqueueRequestsFromMapFunc(mapVolumeMigrationToVolume)).
 		Complete(r)
 }
 
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
 // +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations,verbs=get;list;watch,namespace=kubesan-system
+
+func mapVolumeMigrationToVolume(ctx context.Context, migration client.Object) []reconcile.Request {
+	return []reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      migration.(*v1alpha1.VolumeMigration).Spec.Volume,
+				Namespace: migration.GetNamespace(),
+			},
+		},
+	}
+}
+
+// Returns true if a VolumeMigration on this node owns the volume's dm devices
+func (r *VolumeNodeReconciler) isMigratingOnLocalNode(ctx context.Context, volume *v1alpha1.Volume) (bool, error) {
+	migrations := &v1alpha1.VolumeMigrationList{}
+	if err := r.List(ctx, migrations, client.InNamespace(config.Namespace)); err != nil {
+		return false, err
+	}
+
+	for i := range migrations.Items {
+		migration := &migrations.Items[i]
+		if migration.Spec.Volume == volume.Name && migration.Status.NodeName == config.LocalNodeName && migration.Status.IsInProgress() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
 // Ensure that the volume is attached to this node
 // May fail with WatchPending if another reconcile will trigger progress
@@ -61,9 +103,14 @@ func (r *VolumeNodeReconciler) reconcileThinAttaching(ctx context.Context, volum
 		return err
 	}
 
+	// Writes made before a pending revert would be thrown away by it, so
+	// the thin LV is only activated once the revert is done. The thin-pool
+	// is still activated here so that the revert can happen.
+
 	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
 	thinLvSpec := thinPoolLv.Spec.FindThinLv(thinLvName)
-	if thinLvSpec != nil && thinLvSpec.State.Name == v1alpha1.ThinLvSpecStateNameInactive {
+	revertPending := thinLvSpec != nil && thinpoollv.IsRevertPending(thinLvSpec, thinPoolLv.Status.FindThinLv(thinLvName))
+	if thinLvSpec != nil && thinLvSpec.State.Name == v1alpha1.ThinLvSpecStateNameInactive && !revertPending {
 		thinLvSpec.State = v1alpha1.ThinLvSpecState{
 			Name: v1alpha1.ThinLvSpecStateNameActive,
 		}
@@ -73,11 +120,22 @@ func (r *VolumeNodeReconciler) reconcileThinAttaching(ctx context.Context, volum
 		return err
 	}
 
+	if revertPending {
+		log.Info("Waiting for revert before attaching", "thin LV", thinLvName)
+		return &util.WatchPending{}
+	}
+
 	if !isThinLvActiveOnLocalNode(thinPoolLv, thinLvName) {
 		return &util.WatchPending{}
 	}
 
-	return dm.Resume(ctx, volume.Name, volume.Spec.SizeBytes, devName(volume))
+	if volume.Spec.Integrity != nil {
+		if err := formatIntegrity(ctx, volume); err != nil {
+			return err
+		}
+	}
+
+	return dm.Resume(ctx, volume.Name, volume.Spec.SizeBytes, devName(volume), dmLayers(volume))
 }
 
 // Ensure that the volume is detached from this node
@@ -92,7 +150,8 @@ func (r *VolumeNodeReconciler) reconcileThinDetaching(ctx context.Context, volum
 		return nil // it's not attached to this node
 	}
 
-	if err := dm.Remove(ctx, volume.Name); err != nil {
+	// flushes the cache, if any, before the LV is deactivated
+	if err := dm.Remove(ctx, volume.Name, dmLayers(volume)); err != nil {
 		return err
 	}
 
@@ -107,6 +166,28 @@ func (r *VolumeNodeReconciler) reconcileThinDetaching(ctx context.Context, volum
 	return thinpoollv.UpdateThinPoolLv(ctx, r.Client, thinPoolLv, oldThinPoolLv != thinPoolLv)
 }
 
+// Write the integrity metadata of a new volume the first time it is attached.
+// Clones already have it since they are copies of their source.
+func formatIntegrity(ctx context.Context, volume *v1alpha1.Volume) error {
+	formatted, err := integrity.IsFormatted(ctx, devName(volume))
+	if err != nil || formatted {
+		return err
+	}
+
+	if volume.Spec.Contents.Empty == nil {
+		return errors.NewBadRequest("volume has contents but no integrity metadata")
+	}
+
+	return integrity.Format(ctx, devName(volume))
+}
+
+func dmLayers(volume *v1alpha1.Volume) dm.Layers {
+	return dm.Layers{
+		Cache:     volume.Spec.Cache,
+		Integrity: volume.Spec.Integrity != nil,
+	}
+}
+
 func isThinLvActiveOnLocalNode(thinPoolLv *v1alpha1.ThinPoolLv, name string) bool {
 	thinLvStatus := thinPoolLv.Status.FindThinLv(name)
 	return thinLvStatus != nil && thinPoolLv.Status.ActiveOnNode == config.LocalNodeName && thinLvStatus.State.Name == v1alpha1.ThinLvStatusStateNameActive
@@ -150,6 +231,28 @@ func (r *VolumeNodeReconciler) reconcileThin(ctx context.Context, volume *v1alph
 		return client.IgnoreNotFound(err)
 	}
 
+	// the migration switches the lower dm device between LVs itself and
+	// the volume's VG may change underneath it
+
+	migrating, err := r.isMigratingOnLocalNode(ctx, volume)
+	if err != nil {
+		return err
+	}
+	if migrating {
+		log.Info("reconcileThin waiting for VolumeMigration")
+		return nil
+	}
+
+	// a fenced node neither attaches nor detaches, either of which could
+	// write to the LV, and I/O to the volume fails
+
+	if slices.Contains(volume.Spec.FenceNodes, config.LocalNodeName) {
+		if err := dm.Fence(ctx, volume.Name); err != nil {
+			return err
+		}
+		return r.updateStatusAttachedToNodes(ctx, volume, thinPoolLv)
+	}
+
 	if slices.Contains(volume.Spec.AttachToNodes, config.LocalNodeName) {
 		err = r.reconcileThinAttaching(ctx, volume, thinPoolLv)
 	} else {
@@ -177,15 +280,16 @@ func (r *VolumeNodeReconciler) reconcileLinear(ctx context.Context, volume *v1al
 		return err
 	}
 
+	// RAID and VDO LVs can only be activated exclusively
+	activation := lvm.ActivateShared
+	if volume.Spec.Mode == v1alpha1.VolumeModeRaid1 || volume.Spec.Mode == v1alpha1.VolumeModeVdo {
+		activation = lvm.ActivateExclusive
+	}
+
 	if shouldBeActive && !isActuallyActive {
 		// activate LVM LV on local node
 
-		_, err := commands.Lvm(
-			"lvchange",
-			"--devicesfile", volume.Spec.VgName,
-			"--activate", "sy",
-			fmt.Sprintf("%s/%s", volume.Spec.VgName, volume.Name),
-		)
+		err := lvm.ActivateLv(volume.Spec.VgName, volume.Name, activation)
 		if err != nil {
 			return err
 		}
@@ -193,12 +297,7 @@ func (r *VolumeNodeReconciler) reconcileLinear(ctx context.Context, volume *v1al
 	} else if !shouldBeActive && isActuallyActive {
 		// deactivate LVM LV from local node
 
-		_, err := commands.Lvm(
-			"lvchange",
-			"--devicesfile", volume.Spec.VgName,
-			"--activate", "n",
-			fmt.Sprintf("%s/%s", volume.Spec.VgName, volume.Name),
-		)
+		err := lvm.DeactivateLv(volume.Spec.VgName, volume.Name)
 		if err != nil {
 			return err
 		}
@@ -244,13 +343,463 @@ func (r *VolumeNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	switch volume.Spec.Mode {
 	case v1alpha1.VolumeModeThin:
 		err = r.reconcileThin(ctx, volume)
-	case v1alpha1.VolumeModeLinear:
+	case v1alpha1.VolumeModeLinear, v1alpha1.VolumeModeRaid1, v1alpha1.VolumeModeVdo:
 		err = r.reconcileLinear(ctx, volume)
 	default:
 		err = errors.NewBadRequest("invalid volume mode")
 	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if volume.Status.IsAttachedToNode(config.LocalNodeName) {
+		// host state can change at any time, so check periodically
+
+		if err := r.reconcileHealth(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if err := r.reconcileQoS(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if volume.Spec.Integrity != nil {
+			if err := r.reconcileIntegrity(ctx, volume); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		if err := r.reconcileReclaim(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// come back in time to enforce the no-path timeout
+		requeueAfter := config.HealthCheckInterval
+		if left, ok := noPathTimeoutLeft(volume, volume.Status.FindPath(config.LocalNodeName), time.Now()); ok {
+			requeueAfter = min(requeueAfter, left+time.Second)
+		}
+
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if volume.Spec.Mode == v1alpha1.VolumeModeThin {
+		if err := r.removePathState(ctx, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.removeNodeHealth(ctx, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.removeNodeQoS(ctx, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
 
-	return ctrl.Result{}, err
+// Reflect the health of the volume on this node in its Status.NodeHealth
+// entry, from which the cluster controller derives the Degraded condition
+func (r *VolumeNodeReconciler) reconcileHealth(ctx context.Context, volume *v1alpha1.Volume) error {
+	var condition conditionsv1.Condition
+
+	switch volume.Spec.Mode {
+	case v1alpha1.VolumeModeThin:
+		// thin-pool health is reported by the ThinPoolLv, only the
+		// dm wrappers are specific to the volume
+
+		state, err := r.reconcilePathState(ctx, volume)
+		if err != nil {
+			return err
+		}
+
+		switch state {
+		case v1alpha1.VolumePathStateQueueing:
+			condition = conditionsv1.Condition{
+				Type:    conditionsv1.ConditionDegraded,
+				Status:  corev1.ConditionTrue,
+				Reason:  "PathFailed",
+				Message: "dm-multipath path failed, I/O is queued",
+			}
+		case v1alpha1.VolumePathStateFailing:
+			condition = conditionsv1.Condition{
+				Type:    conditionsv1.ConditionDegraded,
+				Status:  corev1.ConditionTrue,
+				Reason:  "PathFailed",
+				Message: "dm-multipath path failed for longer than the no-path timeout, I/O fails",
+			}
+		case v1alpha1.VolumePathStateFenced:
+			condition = conditionsv1.Condition{
+				Type:    conditionsv1.ConditionDegraded,
+				Status:  corev1.ConditionTrue,
+				Reason:  "Fenced",
+				Message: "node is fenced, I/O fails",
+			}
+		default:
+			condition = util.LvHealthCondition("")
+		}
+
+	case v1alpha1.VolumeModeLinear:
+		lv, err := lvm.GetLv(volume.Spec.VgName, volume.Name, lvm.LvFieldHealthStatus)
+		if err != nil {
+			return err
+		}
+
+		condition = util.LvHealthCondition(lv.HealthStatus)
+
+	case v1alpha1.VolumeModeRaid1:
+		return r.reconcileRaidHealth(ctx, volume)
+
+	case v1alpha1.VolumeModeVdo:
+		return r.reconcileVdoHealth(ctx, volume)
+	}
+
+	if setNodeHealth(volume, condition) {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Updates this node's Status.NodeHealth entry from a Degraded condition.
+// Returns true if the entry changed.
+func setNodeHealth(volume *v1alpha1.Volume, condition conditionsv1.Condition) bool {
+	health := v1alpha1.VolumeNodeHealth{
+		NodeName:           config.LocalNodeName,
+		Degraded:           condition.Status == corev1.ConditionTrue,
+		Reason:             condition.Reason,
+		Message:            condition.Message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	old := volume.Status.FindNodeHealth(config.LocalNodeName)
+	if old == nil {
+		volume.Status.NodeHealth = append(volume.Status.NodeHealth, health)
+		return true
+	}
+
+	if old.Degraded == health.Degraded && old.Reason == health.Reason && old.Message == health.Message {
+		return false
+	}
+	if old.Degraded == health.Degraded {
+		health.LastTransitionTime = old.LastTransitionTime
+	}
+	*old = health
+	return true
+}
+
+// Removes this node's Status.NodeHealth entry once the volume is detached, so
+// that it does not keep the volume Degraded
+func (r *VolumeNodeReconciler) removeNodeHealth(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Status.FindNodeHealth(config.LocalNodeName) == nil {
+		return nil
+	}
+
+	volume.Status.NodeHealth = slices.DeleteFunc(volume.Status.NodeHealth, func(health v1alpha1.VolumeNodeHealth) bool {
+		return health.NodeName == config.LocalNodeName
+	})
+	return r.statusUpdate(ctx, volume)
+}
+
+// Reflect the state of the dm-multipath path on this node in Status.Paths,
+// failing queued I/O once the path has been failed for longer than the
+// volume's no-path timeout.
+func (r *VolumeNodeReconciler) reconcilePathState(ctx context.Context, volume *v1alpha1.Volume) (v1alpha1.VolumePathState, error) {
+	state, err := dm.GetPathState(ctx, volume.Name)
+	if err != nil || state == "" {
+		return state, err
+	}
+
+	now := metav1.Now()
+	path := volume.Status.FindPath(config.LocalNodeName)
+
+	if left, ok := noPathTimeoutLeft(volume, path, now.Time); ok && state == v1alpha1.VolumePathStateQueueing && left == 0 {
+		log.FromContext(ctx).Info("No-path timeout expired, failing queued I/O", "volume", volume.Name)
+
+		if err := dm.FailQueuedIO(ctx, volume.Name); err != nil {
+			return "", err
+		}
+		state = v1alpha1.VolumePathStateFailing
+	}
+
+	if path == nil {
+		volume.Status.Paths = append(volume.Status.Paths, v1alpha1.VolumePathStatus{
+			NodeName:           config.LocalNodeName,
+			State:              state,
+			LastTransitionTime: now,
+		})
+	} else if path.State != state {
+		path.State = state
+		path.LastTransitionTime = now
+	} else {
+		return state, nil
+	}
+
+	return state, r.statusUpdate(ctx, volume)
+}
+
+// Returns how much longer I/O is queued on this node's path, if it is known
+// to be queueing and the volume has a no-path timeout
+func noPathTimeoutLeft(volume *v1alpha1.Volume, path *v1alpha1.VolumePathStatus, now time.Time) (time.Duration, bool) {
+	timeout := time.Duration(volume.Spec.MutableParameters.NoPathTimeoutSeconds) * time.Second
+	if timeout == 0 || path == nil || path.State != v1alpha1.VolumePathStateQueueing {
+		return 0, false
+	}
+	return max(path.LastTransitionTime.Add(timeout).Sub(now), 0), true
+}
+
+// Forget the path state of a volume that is no longer attached to this node
+func (r *VolumeNodeReconciler) removePathState(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Status.FindPath(config.LocalNodeName) == nil {
+		return nil
+	}
+
+	volume.Status.Paths = slices.DeleteFunc(volume.Status.Paths, func(path v1alpha1.VolumePathStatus) bool {
+		return path.NodeName == config.LocalNodeName
+	})
+	return r.statusUpdate(ctx, volume)
+}
+
+// Reflect degraded legs and sync progress of a Raid1 volume in this node's
+// Status.NodeHealth entry and the Synced condition. A leg whose PV has come
+// back is repaired by refreshing the LV, after which the leg resyncs.
+func (r *VolumeNodeReconciler) reconcileRaidHealth(ctx context.Context, volume *v1alpha1.Volume) error {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	lv, err := lvm.GetLv(volume.Spec.VgName, volume.Name, lvm.LvFieldHealthStatus, lvm.LvFieldSyncPercent)
+	if err != nil {
+		return err
+	}
+
+	if lv.HealthStatus == "refresh needed" {
+		log.Info("Refreshing RAID LV to repair leg", "volume", volume.Name)
+
+		if err := lvm.RefreshLv(volume.Spec.VgName, volume.Name); err != nil {
+			return err
+		}
+
+		lv, err = lvm.GetLv(volume.Spec.VgName, volume.Name, lvm.LvFieldHealthStatus, lvm.LvFieldSyncPercent)
+		if err != nil {
+			return err
+		}
+	}
+
+	synced := conditionsv1.Condition{
+		Type:   v1alpha1.VolumeConditionSynced,
+		Status: corev1.ConditionTrue,
+		Reason: "InSync",
+	}
+	if lv.SyncPercent < 100 {
+		synced = conditionsv1.Condition{
+			Type:    v1alpha1.VolumeConditionSynced,
+			Status:  corev1.ConditionFalse,
+			Reason:  "Syncing",
+			Message: fmt.Sprintf("mirror legs are %.2f%% in sync", lv.SyncPercent),
+		}
+	}
+
+	degradedChanged := setNodeHealth(volume, util.LvHealthCondition(lv.HealthStatus))
+	syncedChanged := util.SetStatusConditionIfChanged(&volume.Status.Conditions, synced)
+	if degradedChanged || syncedChanged {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Reflect the health of a Vdo volume in this node's Status.NodeHealth entry
+// and the space usage of its VDO pool in Status.Vdo. The pool runs out of
+// space when the data does not compress or deduplicate as well as expected.
+func (r *VolumeNodeReconciler) reconcileVdoHealth(ctx context.Context, volume *v1alpha1.Volume) error {
+	lv, err := lvm.GetLv(volume.Spec.VgName, volume.Name, lvm.LvFieldHealthStatus)
+	if err != nil {
+		return err
+	}
+
+	pool, err := lvm.GetLv(volume.Spec.VgName, util.VdoPoolLvName(volume.Name), lvm.LvFieldVdoUsedSize, lvm.LvFieldVdoSavingPercent)
+	if err != nil {
+		return err
+	}
+
+	vdoStatus := &v1alpha1.VolumeVdoStatus{
+		UsedBytes:     pool.VdoUsedSizeBytes,
+		SavingPercent: int32(pool.VdoSavingPercent),
+	}
+
+	degradedChanged := setNodeHealth(volume, util.LvHealthCondition(lv.HealthStatus))
+	if degradedChanged || !reflect.DeepEqual(volume.Status.Vdo, vdoStatus) {
+		volume.Status.Vdo = vdoStatus
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Reflect checksum mismatches detected on this node in the IntegrityVerified
+// condition. Each mismatch was returned to the reader as an I/O error, or for
+// Raid1 volumes, repaired from the other leg.
+func (r *VolumeNodeReconciler) reconcileIntegrity(ctx context.Context, volume *v1alpha1.Volume) error {
+	var mismatches int64
+	var err error
+
+	switch volume.Spec.Mode {
+	case v1alpha1.VolumeModeThin:
+		mismatches, err = dm.IntegrityMismatches(ctx, volume.Name)
+		if err != nil || mismatches < 0 {
+			return err // not set up on this node yet
+		}
+
+	case v1alpha1.VolumeModeRaid1:
+		lv, err := lvm.GetLv(volume.Spec.VgName, volume.Name, lvm.LvFieldIntegrityMismatches)
+		if err != nil {
+			return err
+		}
+		mismatches = lv.IntegrityMismatches
+
+	default:
+		return nil
+	}
+
+	condition := conditionsv1.Condition{
+		Type:   v1alpha1.VolumeConditionIntegrityVerified,
+		Status: corev1.ConditionTrue,
+		Reason: "NoMismatches",
+	}
+	if mismatches > 0 {
+		condition = conditionsv1.Condition{
+			Type:    v1alpha1.VolumeConditionIntegrityVerified,
+			Status:  corev1.ConditionFalse,
+			Reason:  "MismatchesDetected",
+			Message: fmt.Sprintf("%d checksum mismatches detected on node \"%s\"", mismatches, config.LocalNodeName),
+		}
+	}
+
+	if util.SetStatusConditionIfChanged(&volume.Status.Conditions, condition) {
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
+}
+
+// Enforce the volume's I/O limits on this node and report them in this node's
+// Status.NodeQoS entry, from which the cluster controller derives the
+// QoSEnforced condition. io.max is rewritten every time since it is cheap and
+// the device may have been recreated since last time. Nodes that cannot
+// enforce limits report it in their entry rather than failing, so that the
+// remaining checks still run.
+func (r *VolumeNodeReconciler) reconcileQoS(ctx context.Context, volume *v1alpha1.Volume) error {
+	qos := volume.Spec.MutableParameters.QoS
+	if qos == nil && volume.Status.FindNodeQoS(config.LocalNodeName) == nil {
+		return nil
+	}
+
+	err := cgroup.SetIoMax(volume.Status.Path, qos)
+	if err != nil && !stderrors.Is(err, cgroup.ErrIoMaxUnavailable) {
+		return err
+	}
+
+	if qos == nil {
+		// no limits left to enforce, or to fail to enforce
+		return r.removeNodeQoS(ctx, volume)
+	}
+
+	nodeQoS := v1alpha1.VolumeNodeQoS{
+		NodeName: config.LocalNodeName,
+		QoS:      qos.DeepCopy(),
+	}
+	if err != nil {
+		nodeQoS.QoS = nil
+		nodeQoS.Error = err.Error()
+	}
+
+	old := volume.Status.FindNodeQoS(config.LocalNodeName)
+	if old == nil {
+		volume.Status.NodeQoS = append(volume.Status.NodeQoS, nodeQoS)
+	} else if reflect.DeepEqual(*old, nodeQoS) {
+		return nil
+	} else {
+		*old = nodeQoS
+	}
+	return r.statusUpdate(ctx, volume)
+}
+
+// Removes this node's Status.NodeQoS entry once the volume is detached or has
+// no limits anymore
+func (r *VolumeNodeReconciler) removeNodeQoS(ctx context.Context, volume *v1alpha1.Volume) error {
+	if volume.Status.FindNodeQoS(config.LocalNodeName) == nil {
+		return nil
+	}
+
+	volume.Status.NodeQoS = slices.DeleteFunc(volume.Status.NodeQoS, func(nodeQoS v1alpha1.VolumeNodeQoS) bool {
+		return nodeQoS.NodeName == config.LocalNodeName
+	})
+	return r.statusUpdate(ctx, volume)
+}
+
+// Run fstrim when the volume's schedule says so and report how much space has
+// been discarded on this node. Only nodes where the file system is mounted do
+// anything, which is where the discards are issued.
+func (r *VolumeNodeReconciler) reconcileReclaim(ctx context.Context, volume *v1alpha1.Volume) error {
+	reclaim := volume.Spec.MutableParameters.Reclaim
+	if reclaim == nil {
+		if volume.Status.Reclaim != nil {
+			volume.Status.Reclaim = nil
+			return r.statusUpdate(ctx, volume)
+		}
+		return nil
+	}
+
+	// lsblk also finds the file system on top of a LUKS device
+	mountPoints, err := fsfreeze.MountPoints(ctx, volume.Status.Path)
+	if err != nil {
+		return err
+	}
+	if len(mountPoints) == 0 {
+		return nil
+	}
+
+	reclaimStatus := &v1alpha1.VolumeReclaimStatus{}
+	if volume.Status.Reclaim != nil {
+		*reclaimStatus = *volume.Status.Reclaim
+	}
+
+	if reclaim.Policy == v1alpha1.VolumeReclaimPolicyFstrim {
+		spec := reclaim.FstrimSchedule
+		if spec == "" {
+			spec = v1alpha1.DefaultFstrimSchedule
+		}
+		schedule, err := cron.Parse(spec)
+		if err != nil {
+			return errors.NewBadRequest(fmt.Sprintf("invalid fstrim schedule: %v", err))
+		}
+
+		last := volume.CreationTimestamp.Time
+		if reclaimStatus.LastFstrimTime != nil {
+			last = reclaimStatus.LastFstrimTime.Time
+		}
+
+		now := time.Now()
+		if next := schedule.Next(last); !next.IsZero() && !next.After(now) {
+			log.FromContext(ctx).Info("Running fstrim", "volume", volume.Name, "mountPoints", mountPoints)
+
+			trimmed, err := fstrim.Trim(ctx, mountPoints)
+			if err != nil {
+				return err
+			}
+			reclaimStatus.LastFstrimTime = &metav1.Time{Time: now}
+			reclaimStatus.LastFstrimBytes = trimmed
+		}
+	}
+
+	// every discard, whichever way it was issued, passes the upper device
+	discarded, err := fstrim.DiscardedBytes(volume.Status.Path)
+	if err != nil {
+		return err
+	}
+	reclaimStatus.DiscardedBytes = discarded
+
+	if !reflect.DeepEqual(reclaimStatus, volume.Status.Reclaim) {
+		volume.Status.Reclaim = reclaimStatus
+		return r.statusUpdate(ctx, volume)
+	}
+	return nil
 }
 
 func (r *VolumeNodeReconciler) statusUpdate(ctx context.Context, volume *v1alpha1.Volume) error {
@@ -0,0 +1,604 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/commands"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/dm"
+	"gitlab.com/kubesan/kubesan/internal/common/lvm"
+	"gitlab.com/kubesan/kubesan/internal/common/nbd"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// The node a migrating volume is attached to serves the volume's source thin
+// LV with qemu-storage-daemon, points the volume's lower dm device at an NBD
+// client of it and mirrors it into a new thin LV in the target VG. Once the
+// mirror has caught up, the lower device is suspended, the mirror is stopped
+// and the lower device is resumed on the new thin LV.
+//
+// Until Switched is recorded the source thin LV holds every write, so any
+// failure, including a restart of this node's manager, switches the volume
+// back to it. Switched is only recorded while the lower device is suspended
+// and both thin LVs are identical, and the volume is never switched back
+// after that.
+//
+// The VolumeNodeReconciler leaves the dm devices alone while the migration is
+// in progress.
+
+// How often the Progressing condition is updated while mirroring
+const volumeMigrationProgressInterval = 10 * time.Second
+
+// How often the mirror is polled
+const volumeMigrationPollInterval = time.Second
+
+type VolumeMigrationNodeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	workers *workers.Workers
+
+	// Mirrors, keyed by work name, that are running or have switched but
+	// still have their NBD server and client to clean up
+	migrations map[string]*migrationWork
+}
+
+func SetUpVolumeMigrationNodeReconciler(mgr ctrl.Manager) error {
+	r := &VolumeMigrationNodeReconciler{
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		workers:    workers.NewWorkers(),
+		migrations: make(map[string]*migrationWork),
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VolumeMigration{}).
+		Watches(&v1alpha1.Volume{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeMigrations)).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapToVolumeMigrations))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumemigrations/status,verbs=get;update;patch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumes,verbs=get;list;watch,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=thinpoollvs,verbs=get;list;watch,namespace=kubesan-system
+
+// Volumes and their ThinPoolLvs have the same name
+func (r *VolumeMigrationNodeReconciler) mapToVolumeMigrations(ctx context.Context, object client.Object) []reconcile.Request {
+	migrations := &v1alpha1.VolumeMigrationList{}
+	if err := r.List(ctx, migrations, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range migrations.Items {
+		migration := &migrations.Items[i]
+		if migration.Spec.Volume == object.GetName() && migration.Status.NodeName == config.LocalNodeName {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(migration)})
+		}
+	}
+	return requests
+}
+
+type migrationWork struct {
+	volumeName string
+	sizeBytes  int64
+	source     string
+	target     string
+	serverId   *nbd.ServerId
+
+	// Set once the NBD client is connected
+	nbdPath string
+
+	mu       sync.Mutex
+	percent  float64
+	switched bool
+}
+
+func newMigrationWork(migration *v1alpha1.VolumeMigration, volume *v1alpha1.Volume) *migrationWork {
+	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
+
+	return &migrationWork{
+		volumeName: volume.Name,
+		sizeBytes:  volume.Spec.SizeBytes,
+		source:     fmt.Sprintf("/dev/%s/%s", migration.Status.SourceVgName, thinLvName),
+		target:     fmt.Sprintf("/dev/%s/%s", migration.Spec.VgName, thinLvName),
+		serverId: &nbd.ServerId{
+			Node:   config.LocalNodeName,
+			Export: "migration-" + volume.Name,
+		},
+	}
+}
+
+// Mirrors the volume into the target and returns with the lower dm device
+// suspended and both thin LVs identical, ready to be switched over
+func (w *migrationWork) Run(ctx context.Context) (err error) {
+	log := log.FromContext(ctx).WithValues("volume", w.volumeName)
+
+	defer func() {
+		if err != nil {
+			// the worker's ctx may already be canceled
+			w.rollBack(context.Background())
+		}
+	}()
+
+	if _, err = nbd.StartServer(ctx, w.serverId, w.source, nil); err != nil {
+		return err
+	}
+
+	w.nbdPath, err = commands.NBDClientConnect(config.PodIP, w.serverId.Export)
+	if err != nil {
+		return err
+	}
+
+	// from now on all writes go through qemu-storage-daemon
+
+	if err = dm.Suspend(ctx, w.volumeName, true, dm.Layers{}); err != nil {
+		return err
+	}
+	if err = dm.Resume(ctx, w.volumeName, w.sizeBytes, w.nbdPath, dm.Layers{}); err != nil {
+		return err
+	}
+
+	log.Info("mirroring volume", "source", w.source, "target", w.target)
+
+	if err = nbd.StartMirror(ctx, w.serverId, w.target); err != nil {
+		return err
+	}
+
+	for {
+		percent, ready, err := nbd.QueryMirror(ctx, w.serverId)
+		if err != nil {
+			return err
+		}
+		w.setProgress(percent)
+		if ready {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(volumeMigrationPollInterval):
+		}
+	}
+
+	// suspending waits for in-flight writes, which reach both thin LVs
+
+	if err = dm.Suspend(ctx, w.volumeName, true, dm.Layers{}); err != nil {
+		return err
+	}
+	if err = nbd.StopMirror(ctx, w.serverId); err != nil {
+		return err
+	}
+
+	log.Info("mirror stopped, ready to switch", "target", w.target)
+
+	w.mu.Lock()
+	w.switched = true
+	w.mu.Unlock()
+	return nil
+}
+
+// Points the lower dm device back at the source thin LV and tears down the
+// NBD server and client. Errors are logged so that as much as possible is
+// cleaned up.
+func (w *migrationWork) rollBack(ctx context.Context) {
+	log := log.FromContext(ctx).WithValues("volume", w.volumeName)
+
+	log.Info("rolling back migration", "source", w.source)
+
+	if err := dm.Suspend(ctx, w.volumeName, true, dm.Layers{}); err != nil {
+		log.Error(err, "failed to suspend volume")
+	}
+	if err := dm.Resume(ctx, w.volumeName, w.sizeBytes, w.source, dm.Layers{}); err != nil {
+		log.Error(err, "failed to resume volume on source")
+	}
+	if err := nbd.StopMirror(ctx, w.serverId); err != nil {
+		log.Error(err, "failed to stop mirror")
+	}
+	w.stopNBD(ctx)
+}
+
+// Disconnects the NBD client and stops the server
+func (w *migrationWork) stopNBD(ctx context.Context) {
+	log := log.FromContext(ctx).WithValues("volume", w.volumeName)
+
+	if w.nbdPath != "" {
+		if err := commands.NBDClientDisconnect(w.nbdPath); err != nil {
+			log.Error(err, "failed to disconnect NBD client", "path", w.nbdPath)
+		}
+		w.nbdPath = ""
+	}
+	if err := nbd.StopServer(ctx, w.serverId); err != nil {
+		log.Error(err, "failed to stop NBD server")
+	}
+}
+
+func (w *migrationWork) setProgress(percent float64) {
+	w.mu.Lock()
+	w.percent = percent
+	w.mu.Unlock()
+}
+
+func (w *migrationWork) progress() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.percent
+}
+
+// Returns true once Run() has left the volume ready to switch over
+func (w *migrationWork) readyToSwitch() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.switched
+}
+
+// Returns a unique name for a volume migration work item
+func migrationWorkName(migration *v1alpha1.VolumeMigration) string {
+	return fmt.Sprintf("volume-migration/%s", migration.Name)
+}
+
+func (r *VolumeMigrationNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeMigrationNodeReconciler entered")
+	defer log.Info("VolumeMigrationNodeReconciler exited")
+
+	migration := &v1alpha1.VolumeMigration{}
+	if err := r.Get(ctx, req.NamespacedName, migration); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if migration.Status.NodeName != config.LocalNodeName ||
+		conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, conditionsv1.ConditionAvailable) {
+		return ctrl.Result{}, nil
+	}
+
+	volume := &v1alpha1.Volume{}
+	if err := r.Get(ctx, types.NamespacedName{Name: migration.Spec.Volume, Namespace: config.Namespace}, volume); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if migration.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, migration, volume)
+	}
+
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionFailed) {
+		return ctrl.Result{}, nil
+	}
+
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSwitched) {
+		_, err := r.reconcileSwitched(ctx, migration, volume)
+		return ctrl.Result{}, err
+	}
+
+	return r.reconcileMirror(ctx, migration, volume)
+}
+
+// Mirrors the volume in the background, reflecting progress in the
+// Progressing condition, and records Switched once it is ready
+func (r *VolumeMigrationNodeReconciler) reconcileMirror(ctx context.Context, migration *v1alpha1.VolumeMigration, volume *v1alpha1.Volume) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	name := migrationWorkName(migration)
+	work, ok := r.migrations[name]
+	if !ok {
+		if isMirroring(migration) {
+			// the mirror was lost with the previous manager
+			newMigrationWork(migration, volume).rollBack(ctx)
+			return ctrl.Result{}, r.fail(ctx, migration, "interrupted by a restart")
+		}
+
+		if err := createTargetLvs(migration, volume); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// record that the volume may be routed through NBD before it is
+
+		conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+			Type:    conditionsv1.ConditionProgressing,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Mirroring",
+			Message: "0% mirrored",
+		})
+		if err := r.statusUpdate(ctx, migration); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		work = newMigrationWork(migration, volume)
+		r.migrations[name] = work
+	}
+
+	var err error
+	if !work.readyToSwitch() {
+		err = r.workers.Run(name, migration, work)
+	}
+	if _, ok := err.(*util.WatchPending); ok {
+		percent := work.progress()
+		condition := conditionsv1.Condition{
+			Type:    conditionsv1.ConditionProgressing,
+			Status:  corev1.ConditionTrue,
+			Reason:  "Mirroring",
+			Message: fmt.Sprintf("%.0f%% mirrored", percent),
+		}
+		changed := util.SetStatusConditionIfChanged(&migration.Status.Conditions, condition)
+		if migration.Status.ProgressPercent != int32(percent) {
+			migration.Status.ProgressPercent = int32(percent)
+			changed = true
+		}
+		if changed {
+			if err := r.statusUpdate(ctx, migration); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: volumeMigrationProgressInterval}, nil
+	}
+
+	if err != nil {
+		// the work has already rolled back
+		delete(r.migrations, name)
+		log.Error(err, "volume migration failed")
+		return ctrl.Result{}, r.fail(ctx, migration, err.Error())
+	}
+
+	// the volume is suspended until it is resumed on the target below
+
+	log.Info("Switching volume", "volume", volume.Name, "vgName", migration.Spec.VgName)
+
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   v1alpha1.VolumeMigrationConditionSwitched,
+		Status: corev1.ConditionTrue,
+	})
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionProgressing,
+		Status: corev1.ConditionTrue,
+		Reason: "Switching",
+	})
+	migration.Status.ProgressPercent = 100
+	if err := r.statusUpdate(ctx, migration); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	_, err = r.reconcileSwitched(ctx, migration, volume)
+	return ctrl.Result{}, err
+}
+
+// Returns true if the volume may be routed through NBD
+func isMirroring(migration *v1alpha1.VolumeMigration) bool {
+	progressing := conditionsv1.FindStatusCondition(migration.Status.Conditions, conditionsv1.ConditionProgressing)
+	return progressing != nil && progressing.Reason == "Mirroring"
+}
+
+// Resumes the volume on the target, then removes the source LVs once the
+// cluster controller has pointed the Volume and ThinPoolLv at the target VG.
+// Returns true once the source LVs are gone.
+func (r *VolumeMigrationNodeReconciler) reconcileSwitched(ctx context.Context, migration *v1alpha1.VolumeMigration, volume *v1alpha1.Volume) (bool, error) {
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSourceRemoved) {
+		return true, nil
+	}
+
+	progressing := conditionsv1.FindStatusCondition(migration.Status.Conditions, conditionsv1.ConditionProgressing)
+	if progressing == nil || progressing.Reason != "RemovingSource" {
+		name := migrationWorkName(migration)
+		work, ok := r.migrations[name]
+		if !ok {
+			work = newMigrationWork(migration, volume)
+		}
+
+		if err := dm.Resume(ctx, volume.Name, volume.Spec.SizeBytes, work.target, dm.Layers{}); err != nil {
+			return false, err
+		}
+
+		// without the work, the NBD client and server went away with
+		// the previous manager
+		if ok {
+			work.stopNBD(ctx)
+			delete(r.migrations, name)
+		}
+
+		conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+			Type:   conditionsv1.ConditionProgressing,
+			Status: corev1.ConditionTrue,
+			Reason: "RemovingSource",
+		})
+		if err := r.statusUpdate(ctx, migration); err != nil {
+			return false, err
+		}
+	}
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	if err := r.Get(ctx, types.NamespacedName{Name: volume.Name, Namespace: config.Namespace}, thinPoolLv); err != nil {
+		return false, err
+	}
+	if volume.Spec.VgName != migration.Spec.VgName || thinPoolLv.Spec.VgName != migration.Spec.VgName {
+		return false, nil // wait until the cluster controller points them at the target VG
+	}
+
+	// Snapshots are refused while the volume is being migrated, but one
+	// may have been taken after the migration started and before that
+	// took effect. Its thin LV would go with the source thin-pool.
+	snapshotLvs, err := sourceSnapshotLvs(migration.Status.SourceVgName, volume.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(snapshotLvs) > 0 {
+		return false, fmt.Errorf("not removing thin-pool \"%s\" from VG \"%s\" since it holds snapshot LVs %v", volume.Name, migration.Status.SourceVgName, snapshotLvs)
+	}
+
+	if err := removeThinPoolAndThinLv(migration.Status.SourceVgName, volume.Name); err != nil {
+		return false, err
+	}
+
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   v1alpha1.VolumeMigrationConditionSourceRemoved,
+		Status: corev1.ConditionTrue,
+	})
+	return true, r.statusUpdate(ctx, migration)
+}
+
+// Creates the volume's thin-pool and thin LV in the target VG with the same
+// sizes as in the source VG and activates them on this node. The thin-pool's
+// Spec.Autoextend and Spec.Discards are applied once the ThinPoolLv points at
+// the target VG.
+func createTargetLvs(migration *v1alpha1.VolumeMigration, volume *v1alpha1.Volume) error {
+	sourceVgName := migration.Status.SourceVgName
+	vgName := migration.Spec.VgName
+	thinLvName := thinpoollv.VolumeToThinLvName(volume.Name)
+
+	pool, err := lvm.GetLv(sourceVgName, volume.Name, lvm.LvFieldSize)
+	if err != nil {
+		return err
+	}
+
+	thinLv, err := lvm.GetLv(sourceVgName, thinLvName, lvm.LvFieldSize)
+	if err != nil {
+		return err
+	}
+
+	err = lvm.CreateLvIdempotent(vgName, volume.Name, lvm.CreateLvOptions{
+		Type:            lvm.LvTypeThinPool,
+		SizeBytes:       pool.SizeBytes,
+		Activation:      lvm.Deactivate,
+		MetadataProfile: "kubesan",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = lvm.CreateLvIdempotent(vgName, thinLvName, lvm.CreateLvOptions{
+		Type:             lvm.LvTypeThin,
+		VirtualSizeBytes: thinLv.SizeBytes,
+		ThinPool:         volume.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, lvName := range []string{volume.Name, thinLvName} {
+		if err := lvm.ActivateLv(vgName, lvName, lvm.ActivateExclusive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the names of the thin LVs in the volume's thin-pool in the source VG
+// other than the volume's own thin LV
+func sourceSnapshotLvs(vgName string, volumeName string) ([]string, error) {
+	lvs, err := lvm.ListLvs(vgName, "pool_lv="+volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, lv := range lvs {
+		if lv.Name != thinpoollv.VolumeToThinLvName(volumeName) {
+			names = append(names, lv.Name)
+		}
+	}
+	return names, nil
+}
+
+// Deactivates and removes a volume's thin LV and thin-pool, if they exist
+func removeThinPoolAndThinLv(vgName string, volumeName string) error {
+	for _, lvName := range []string{thinpoollv.VolumeToThinLvName(volumeName), volumeName} {
+		exists, err := lvm.LvExists(vgName, lvName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		if err := lvm.DeactivateLv(vgName, lvName); err != nil {
+			return err
+		}
+
+		if err := lvm.RemoveLvIdempotent(vgName, lvName, lvm.RemoveLvOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records a failure, after which the volume stays in the source VG
+func (r *VolumeMigrationNodeReconciler) fail(ctx context.Context, migration *v1alpha1.VolumeMigration, message string) error {
+	if err := removeThinPoolAndThinLv(migration.Spec.VgName, migration.Spec.Volume); err != nil {
+		return err
+	}
+
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:    v1alpha1.VolumeMigrationConditionFailed,
+		Status:  corev1.ConditionTrue,
+		Reason:  "MigrationFailed",
+		Message: message,
+	})
+	conditionsv1.SetStatusCondition(&migration.Status.Conditions, conditionsv1.Condition{
+		Type:   conditionsv1.ConditionProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "MigrationFailed",
+	})
+	migration.Status.ProgressPercent = 0
+	return r.statusUpdate(ctx, migration)
+}
+
+// Rolls back a migration that has not switched yet, or finishes one that
+// has, and then releases it so the cluster controller can delete it
+func (r *VolumeMigrationNodeReconciler) reconcileDeleting(ctx context.Context, migration *v1alpha1.VolumeMigration, volume *v1alpha1.Volume) error {
+	name := migrationWorkName(migration)
+
+	if conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionSwitched) {
+		if done, err := r.reconcileSwitched(ctx, migration, volume); err != nil || !done {
+			return err
+		}
+	} else if !conditionsv1.IsStatusConditionTrue(migration.Status.Conditions, v1alpha1.VolumeMigrationConditionFailed) {
+		// canceling the work rolls it back
+		if err := r.workers.Cancel(name); err != nil {
+			if _, ok := err.(*util.WatchPending); ok {
+				return nil // wait until Watch triggers
+			}
+			return err
+		}
+
+		if work, ok := r.migrations[name]; ok {
+			if work.readyToSwitch() {
+				work.rollBack(ctx)
+			}
+			delete(r.migrations, name)
+		} else if isMirroring(migration) {
+			newMigrationWork(migration, volume).rollBack(ctx)
+		}
+
+		if err := removeThinPoolAndThinLv(migration.Spec.VgName, migration.Spec.Volume); err != nil {
+			return err
+		}
+	}
+
+	migration.Status.NodeName = ""
+	return r.statusUpdate(ctx, migration)
+}
+
+func (r *VolumeMigrationNodeReconciler) statusUpdate(ctx context.Context, migration *v1alpha1.VolumeMigration) error {
+	migration.Status.ObservedGeneration = migration.Generation
+	return r.Status().Update(ctx, migration)
+}
@@ -0,0 +1,380 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	conditionsv1 "github.com/openshift/custom-resource-status/conditions/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"gitlab.com/kubesan/kubesan/api/v1alpha1"
+	"gitlab.com/kubesan/kubesan/internal/common/config"
+	"gitlab.com/kubesan/kubesan/internal/common/nbdclient"
+	"gitlab.com/kubesan/kubesan/internal/common/thindelta"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/thinpoollv"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/util"
+	"gitlab.com/kubesan/kubesan/internal/manager/common/workers"
+)
+
+// How often the Progressing condition is updated while syncing
+const replicationProgressInterval = 10 * time.Second
+
+// How much data is read and sent at a time
+const replicationChunkBytes = 4 * 1024 * 1024
+
+type VolumeReplicationNodeReconciler struct {
+	client.Client
+	Scheme  *runtime.Scheme
+	workers *workers.Workers
+
+	// Sync work in progress, for reporting progress
+	syncs map[string]*syncWork
+}
+
+func SetUpVolumeReplicationNodeReconciler(mgr ctrl.Manager) error {
+	r := &VolumeReplicationNodeReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		workers: workers.NewWorkers(),
+		syncs:   make(map[string]*syncWork),
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VolumeReplication{}).
+		Watches(&v1alpha1.ThinPoolLv{}, handler.EnqueueRequestsFromMapFunc(r.mapThinPoolLvToReplications))
+	r.workers.SetUpReconciler(builder)
+	return builder.Complete(r)
+}
+
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications,verbs=get;list;watch;create;update;patch;delete,namespace=kubesan-system
+// +kubebuilder:rbac:groups=kubesan.gitlab.io,resources=volumereplications/status,verbs=get;update;patch,namespace=kubesan-system
+
+// Syncs run on the node where the source volume's thin-pool is active
+func (r *VolumeReplicationNodeReconciler) mapThinPoolLvToReplications(ctx context.Context, thinPoolLv client.Object) []reconcile.Request {
+	replications := &v1alpha1.VolumeReplicationList{}
+	if err := r.List(ctx, replications, client.InNamespace(config.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range replications.Items {
+		if replications.Items[i].Spec.SourceVolume == thinPoolLv.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&replications.Items[i])})
+		}
+	}
+	return requests
+}
+
+type syncWork struct {
+	vgName     string
+	poolLvName string
+
+	// Thin LV of the snapshot the target holds, or "" to send everything
+	baseLvName string
+
+	lvName string
+	target string
+
+	mu        sync.Mutex
+	doneBytes int64
+	sizeBytes int64
+
+	// Bytes of data sent, excluding zeroes
+	sentBytes int64
+}
+
+func (w *syncWork) Run(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	source, err := os.Open(fmt.Sprintf("/dev/%s/%s", w.vgName, w.lvName))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+
+	sourceSize, err := source.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	var ranges []thindelta.Range
+	if w.baseLvName == "" {
+		ranges = []thindelta.Range{{OffsetBytes: 0, LengthBytes: sourceSize}}
+	} else {
+		ranges, err = thindelta.Changes(ctx, w.vgName, w.poolLvName, w.baseLvName, w.lvName)
+		if err != nil {
+			return err
+		}
+	}
+
+	var total int64
+	for _, r := range ranges {
+		total += r.LengthBytes
+	}
+	w.setProgress(0, total)
+
+	target, err := nbdclient.Dial(ctx, w.target)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = target.Close() }()
+
+	if target.Size() < sourceSize {
+		return fmt.Errorf("target size %d is smaller than source volume size %d", target.Size(), sourceSize)
+	}
+
+	log.Info("sync worker sending changes", "source", w.lvName, "base", w.baseLvName, "bytes", total)
+
+	var done int64
+	buf := make([]byte, replicationChunkBytes)
+	zeroes := make([]byte, replicationChunkBytes)
+
+	for _, r := range ranges {
+		if r.Discarded {
+			if err := target.WriteZeroesAt(ctx, r.OffsetBytes, r.LengthBytes); err != nil {
+				return err
+			}
+			done += r.LengthBytes
+			w.setProgress(done, total)
+			continue
+		}
+
+		for offset := r.OffsetBytes; offset < r.OffsetBytes+r.LengthBytes; offset += replicationChunkBytes {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			n := min(int64(replicationChunkBytes), r.OffsetBytes+r.LengthBytes-offset)
+			if _, err := source.ReadAt(buf[:n], offset); err != nil {
+				return err
+			}
+
+			// unprovisioned blocks read as zeroes, avoid sending them
+			if bytes.Equal(buf[:n], zeroes[:n]) {
+				err = target.WriteZeroesAt(ctx, offset, n)
+			} else {
+				err = target.WriteAt(ctx, buf[:n], offset)
+				w.addSentBytes(n)
+			}
+			if err != nil {
+				return err
+			}
+
+			done += n
+			w.setProgress(done, total)
+		}
+	}
+
+	return target.Flush(ctx)
+}
+
+func (w *syncWork) setProgress(doneBytes int64, sizeBytes int64) {
+	w.mu.Lock()
+	w.doneBytes = doneBytes
+	w.sizeBytes = sizeBytes
+	w.mu.Unlock()
+}
+
+func (w *syncWork) progress() (doneBytes int64, sizeBytes int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.doneBytes, w.sizeBytes
+}
+
+func (w *syncWork) addSentBytes(n int64) {
+	w.mu.Lock()
+	w.sentBytes += n
+	w.mu.Unlock()
+}
+
+func (w *syncWork) getSentBytes() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sentBytes
+}
+
+// Returns a unique name for a sync work item
+func syncWorkName(replication *v1alpha1.VolumeReplication) string {
+	return fmt.Sprintf("sync/%s/%s", replication.Name, replication.Status.PendingSnapshot)
+}
+
+func (r *VolumeReplicationNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	log.Info("VolumeReplicationNodeReconciler entered")
+	defer log.Info("VolumeReplicationNodeReconciler exited")
+
+	replication := &v1alpha1.VolumeReplication{}
+	if err := r.Get(ctx, req.NamespacedName, replication); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if replication.Status.NodeName != "" && replication.Status.NodeName != config.LocalNodeName {
+		return ctrl.Result{}, nil // another node is syncing
+	}
+
+	if replication.DeletionTimestamp != nil {
+		return ctrl.Result{}, r.reconcileDeleting(ctx, replication)
+	}
+
+	if replication.Status.PendingSnapshot == "" {
+		return ctrl.Result{}, nil
+	}
+
+	// only continue on the node where the pending snapshot's thin LV is
+	// active
+
+	thinPoolLv := &v1alpha1.ThinPoolLv{}
+	err := r.Get(ctx, types.NamespacedName{Name: replication.Spec.SourceVolume, Namespace: config.Namespace}, thinPoolLv)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !isThinLvActiveOnLocalNode(thinPoolLv, thinpoollv.SnapshotToThinLvName(replication.Status.PendingSnapshot)) {
+		return ctrl.Result{}, nil
+	}
+
+	if replication.Status.NodeName == "" {
+		replication.Status.NodeName = config.LocalNodeName
+		if err := r.statusUpdate(ctx, replication); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return r.reconcileSync(ctx, replication, thinPoolLv)
+}
+
+func newSyncWork(replication *v1alpha1.VolumeReplication, thinPoolLv *v1alpha1.ThinPoolLv) *syncWork {
+	work := &syncWork{
+		vgName:     thinPoolLv.Spec.VgName,
+		poolLvName: thinPoolLv.Name,
+		lvName:     thinpoollv.SnapshotToThinLvName(replication.Status.PendingSnapshot),
+		target:     replication.Spec.Target.URI,
+	}
+
+	// send everything if the last snapshot is gone
+	if replication.Status.LastSyncSnapshot != "" {
+		baseLvName := thinpoollv.SnapshotToThinLvName(replication.Status.LastSyncSnapshot)
+		if thinPoolLv.Status.FindThinLv(baseLvName) != nil {
+			work.baseLvName = baseLvName
+		}
+	}
+
+	return work
+}
+
+func (r *VolumeReplicationNodeReconciler) reconcileSync(ctx context.Context, replication *v1alpha1.VolumeReplication, thinPoolLv *v1alpha1.ThinPoolLv) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("nodeName", config.LocalNodeName)
+
+	name := syncWorkName(replication)
+	work, ok := r.syncs[name]
+	if !ok {
+		work = newSyncWork(replication, thinPoolLv)
+		r.syncs[name] = work
+	}
+
+	progressing := conditionsv1.Condition{
+		Type: v1alpha1.VolumeReplicationConditionProgressing,
+	}
+
+	err := r.workers.Run(name, replication, work)
+	if _, ok := err.(*util.WatchPending); ok {
+		doneBytes, sizeBytes := work.progress()
+		percent := 0.0
+		if sizeBytes > 0 {
+			percent = 100 * float64(doneBytes) / float64(sizeBytes)
+		}
+		progressing.Status = corev1.ConditionTrue
+		progressing.Reason = "Syncing"
+		progressing.Message = fmt.Sprintf("%.0f%% of %d bytes sent", percent, sizeBytes)
+		if util.SetStatusConditionIfChanged(&replication.Status.Conditions, progressing) {
+			if err := r.statusUpdate(ctx, replication); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: replicationProgressInterval}, nil
+	}
+	delete(r.syncs, name)
+
+	progressing.Status = corev1.ConditionFalse
+	failed := conditionsv1.Condition{
+		Type: v1alpha1.VolumeReplicationConditionFailed,
+	}
+
+	if err != nil {
+		log.Error(err, "sync failed")
+		progressing.Reason = "Failed"
+		failed.Status = corev1.ConditionTrue
+		failed.Reason = "SyncFailed"
+		failed.Message = err.Error()
+	} else {
+		// the target holds the data as of when the snapshot was taken
+		snapshot := &v1alpha1.Snapshot{}
+		getErr := r.Get(ctx, types.NamespacedName{Name: replication.Status.PendingSnapshot, Namespace: config.Namespace}, snapshot)
+		if getErr != nil {
+			return ctrl.Result{}, getErr
+		}
+
+		log.Info("sync succeeded", "snapshot", replication.Status.PendingSnapshot)
+		progressing.Reason = "Synced"
+		failed.Status = corev1.ConditionFalse
+
+		lastSyncTime := snapshot.CreationTimestamp
+		replication.Status.LastSyncSnapshot = replication.Status.PendingSnapshot
+		replication.Status.LastSyncTime = &lastSyncTime
+		replication.Status.LastSyncBytes = work.getSentBytes()
+		replication.Status.LagSeconds = int64(time.Since(lastSyncTime.Time).Seconds())
+		replication.Status.PendingSnapshot = ""
+	}
+	conditionsv1.SetStatusCondition(&replication.Status.Conditions, progressing)
+	conditionsv1.SetStatusCondition(&replication.Status.Conditions, failed)
+
+	// let any node retry, in case the thin-pool moves elsewhere
+	replication.Status.NodeName = ""
+
+	if err := r.statusUpdate(ctx, replication); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// returning the error retries the sync with backoff
+	return ctrl.Result{}, err
+}
+
+// Stops syncing and releases the replication so the cluster controller can
+// delete it
+func (r *VolumeReplicationNodeReconciler) reconcileDeleting(ctx context.Context, replication *v1alpha1.VolumeReplication) error {
+	if replication.Status.NodeName != config.LocalNodeName {
+		return nil
+	}
+
+	name := syncWorkName(replication)
+	if err := r.workers.Cancel(name); err != nil {
+		if _, ok := err.(*util.WatchPending); ok {
+			return nil // wait until Watch triggers
+		}
+		return err
+	}
+	delete(r.syncs, name)
+
+	replication.Status.NodeName = ""
+	return r.statusUpdate(ctx, replication)
+}
+
+func (r *VolumeReplicationNodeReconciler) statusUpdate(ctx context.Context, replication *v1alpha1.VolumeReplication) error {
+	replication.Status.ObservedGeneration = replication.Generation
+	return r.Status().Update(ctx, replication)
+}
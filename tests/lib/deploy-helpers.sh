@@ -191,7 +191,13 @@ __setup_snapshotter() {
     kubectl create -k "${base_url}/deploy/kubernetes/snapshot-controller?ref=v7.0.1"
     unset base_url
 
-    __log_cyan "Creating volume snapshot class..."
+    __log_cyan "Enabling volume group snapshot support in the snapshot controller..."
+    kubectl patch --namespace kube-system deployment snapshot-controller --type json --patch '[
+        {"op": "add", "path": "/spec/template/spec/containers/0/args/-", "value": "--enable-volume-group-snapshots=true"}
+    ]'
+
+    __log_cyan "Creating volume snapshot classes..."
     kubectl create -f "${script_dir}/t-data/volume-snapshot-class.yaml"
+    kubectl create -f "${script_dir}/t-data/volume-group-snapshot-class.yaml"
 }
 export -f __setup_snapshotter
@@ -0,0 +1,127 @@
+# SPDX-License-Identifier: Apache-2.0
+#
+# This test verifies that a VolumeGroupSnapshot of two volumes yields a KubeSAN
+# GroupSnapshot with a member for each volume, and that volumes restored from
+# the members hold the data of their source volumes.
+
+ksan-supported-modes Thin
+
+ksan-create-rwo-volume test-pvc-1 64Mi
+ksan-create-rwo-volume test-pvc-2 64Mi
+ksan-fill-volume test-pvc-1 64
+ksan-fill-volume test-pvc-2 64
+
+ksan-stage 'Snapshotting both volumes as a group...'
+
+kubectl label pvc test-pvc-1 test-pvc-2 test-group=true
+
+kubectl create -f - <<EOF
+apiVersion: groupsnapshot.storage.k8s.io/v1alpha1
+kind: VolumeGroupSnapshot
+metadata:
+  name: test-vgs
+spec:
+  volumeGroupSnapshotClassName: kubesan
+  source:
+    selector:
+      matchLabels:
+        test-group: "true"
+EOF
+
+ksan-poll 1 60 "[[ \"\$( kubectl get vgs test-vgs --output jsonpath='{.status.readyToUse}' )\" == true ]]"
+
+ksan-stage 'Checking the members of the group snapshot...'
+
+content="$( kubectl get vgs test-vgs --output jsonpath='{.status.boundVolumeGroupSnapshotContentName}' )"
+group="$( kubectl get vgsc "${content}" --output jsonpath='{.status.volumeGroupSnapshotHandle}' )"
+
+members="$( kubectl get --namespace kubesan-system groupsnapshot "${group}" --output jsonpath='{.status.snapshots[*]}' )"
+read -r -a members <<< "${members}"
+(( ${#members[@]} == 2 ))
+
+for member in "${members[@]}"; do
+    [[ "$( ksan-get-condition snapshot "${member}" Available )" == True ]]
+done
+
+# Usage: member_vs <pvc_name>
+member_vs() {
+    kubectl get vs --output jsonpath="{.items[?(@.spec.source.persistentVolumeClaimName==\"$1\")].metadata.name}"
+}
+
+vs_1="$( member_vs test-pvc-1 )"
+vs_2="$( member_vs test-pvc-2 )"
+[[ -n "${vs_1}" && -n "${vs_2}" ]]
+
+ksan-stage 'Creating volumes 3 and 4 from the members...'
+
+# make_pvc_from_snapshot pvc_name vs_name
+make_pvc_from_snapshot()
+{
+    kubectl create -f - <<EOF
+    apiVersion: v1
+    kind: PersistentVolumeClaim
+    metadata:
+      name: $1
+    spec:
+      storageClassName: kubesan
+      volumeMode: Block
+      dataSource:
+        apiGroup: snapshot.storage.k8s.io
+        kind: VolumeSnapshot
+        name: $2
+      accessModes:
+        - ReadWriteOnce
+      resources:
+        requests:
+          storage: 64Mi
+EOF
+}
+
+make_pvc_from_snapshot test-pvc-3 "${vs_1}"
+make_pvc_from_snapshot test-pvc-4 "${vs_2}"
+
+ksan-wait-for-pvc-to-be-bound 300 test-pvc-3
+ksan-wait-for-pvc-to-be-bound 300 test-pvc-4
+
+ksan-stage 'Validating volume data of both members...'
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  restartPolicy: Never
+  containers:
+    - name: container
+      image: $TEST_IMAGE
+      command:
+        - bash
+        - -c
+        - |
+          set -o errexit -o pipefail -o nounset -o xtrace
+          cmp /var/pvc-1 /var/pvc-3
+          cmp /var/pvc-2 /var/pvc-4
+          ! cmp /var/pvc-3 /var/pvc-4
+      volumeDevices:
+        - { name: test-pvc-1, devicePath: /var/pvc-1 }
+        - { name: test-pvc-2, devicePath: /var/pvc-2 }
+        - { name: test-pvc-3, devicePath: /var/pvc-3 }
+        - { name: test-pvc-4, devicePath: /var/pvc-4 }
+  volumes:
+    - { name: test-pvc-1, persistentVolumeClaim: { claimName: test-pvc-1 } }
+    - { name: test-pvc-2, persistentVolumeClaim: { claimName: test-pvc-2 } }
+    - { name: test-pvc-3, persistentVolumeClaim: { claimName: test-pvc-3 } }
+    - { name: test-pvc-4, persistentVolumeClaim: { claimName: test-pvc-4 } }
+EOF
+
+ksan-wait-for-pod-to-succeed 60 test-pod
+kubectl delete pod test-pod --timeout=60s
+
+ksan-delete-volume test-pvc-3 test-pvc-4
+
+ksan-stage 'Deleting group snapshot...'
+
+kubectl delete vgs test-vgs --timeout=60s
+
+ksan-delete-volume test-pvc-1 test-pvc-2
@@ -0,0 +1,82 @@
+# SPDX-License-Identifier: Apache-2.0
+#
+# This test verifies that the VolumeImport populator fills a new volume with
+# an image held by another volume.
+
+ksan-supported-modes Linear # TODO add Thin when importing is implemented
+
+ksan-create-rwo-volume test-pvc-1 64Mi
+ksan-fill-volume test-pvc-1 64
+
+ksan-stage 'Creating volume 2 by importing volume 1 as a raw image...'
+
+kubectl create -f - <<EOF
+apiVersion: kubesan.gitlab.io/v1alpha1
+kind: VolumeImport
+metadata:
+  name: test-import
+spec:
+  persistentVolumeClaim: test-pvc-1
+  format: Raw
+EOF
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: test-pvc-2
+spec:
+  storageClassName: kubesan
+  volumeMode: Block
+  accessModes:
+    - ReadWriteOnce
+  resources:
+    requests:
+      storage: 64Mi
+  dataSourceRef:
+    apiGroup: kubesan.gitlab.io
+    kind: VolumeImport
+    name: test-import
+EOF
+
+ksan-wait-for-pvc-to-be-bound 300 test-pvc-2
+
+volume="$( kubectl get pvc test-pvc-2 --output jsonpath='{.spec.volumeName}' )"
+[[ "$( ksan-get-condition volume "${volume}" DataSourceCompleted )" == True ]]
+
+ksan-stage 'Validating volume data...'
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  restartPolicy: Never
+  containers:
+    - name: container
+      image: $TEST_IMAGE
+      command:
+        - bash
+        - -c
+        - |
+          set -o errexit -o pipefail -o nounset -o xtrace
+          cmp /var/pvc-1 /var/pvc-2
+      volumeDevices:
+        - { name: test-pvc-1, devicePath: /var/pvc-1 }
+        - { name: test-pvc-2, devicePath: /var/pvc-2 }
+  volumes:
+    - { name: test-pvc-1, persistentVolumeClaim: { claimName: test-pvc-1 } }
+    - { name: test-pvc-2, persistentVolumeClaim: { claimName: test-pvc-2 } }
+EOF
+
+ksan-wait-for-pod-to-succeed 60 test-pod
+kubectl delete pod test-pod --timeout=30s
+
+ksan-stage 'Checking that the prime PVC was cleaned up...'
+
+# shellcheck disable=SC2016
+ksan-poll 1 30 '[[ -z "$( kubectl get pvc --namespace kubesan-system --selector kubesan.gitlab.io/volume-import-prime --output name )" ]]'
+
+kubectl delete volumeimport test-import
+ksan-delete-volume test-pvc-1 test-pvc-2
@@ -0,0 +1,96 @@
+# SPDX-License-Identifier: Apache-2.0
+#
+# This test verifies that Raid1 volumes are mirrored across PVs selected by
+# tag and report that their legs are in sync.
+
+ksan-supported-modes Linear # the StorageClass below sets its own mode
+
+ksan-stage 'Adding a second PV to the shared VG...'
+
+__${deploy_tool}_ssh "${NODES[0]}" "
+    sudo vgextend --devicesfile kubesan-vg kubesan-vg /dev/kubesan-drive-1
+    sudo pvchange --devicesfile kubesan-vg --addtag array-a /dev/kubesan-drive-0
+    sudo pvchange --devicesfile kubesan-vg --addtag array-b /dev/kubesan-drive-1
+"
+
+for node in "${NODES[@]}"; do
+    __${deploy_tool}_ssh "${node}" "
+        sudo lvmdevices --devicesfile kubesan-vg --adddev /dev/kubesan-drive-1
+    "
+done
+
+ksan-stage 'Creating Raid1 StorageClass'
+
+kubectl create -f - <<EOF
+apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: raid1
+provisioner: kubesan.gitlab.io
+parameters:
+  lvmVolumeGroup: kubesan-vg
+  mode: Raid1
+  raidPvTags: array-a,array-b
+EOF
+
+ksan-stage 'Provisioning volume...'
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: test-pvc
+spec:
+  storageClassName: raid1
+  accessModes:
+    - ReadWriteOnce
+  resources:
+    requests:
+      storage: 64Mi
+  volumeMode: Block
+EOF
+
+ksan-wait-for-pvc-to-be-bound 300 test-pvc
+
+volume="$( kubectl get pvc test-pvc --output jsonpath='{.spec.volumeName}' )"
+
+ksan-stage 'Writing to volume...'
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  terminationGracePeriodSeconds: 0
+  restartPolicy: Never
+  containers:
+    - name: container
+      image: $TEST_IMAGE
+      command:
+        - bash
+        - -c
+        - |
+          dd if=/dev/urandom of=/var/pvc conv=fsync bs=1M count=64 &&
+          sleep infinity
+      volumeDevices:
+        - { name: test-pvc, devicePath: /var/pvc }
+  volumes:
+    - { name: test-pvc, persistentVolumeClaim: { claimName: test-pvc } }
+EOF
+
+ksan-wait-for-pod-to-start-running 60 test-pod
+
+ksan-stage 'Checking that both legs exist and are in sync...'
+
+# shellcheck disable=SC2016
+ksan-poll 1 60 '[[ "$( ksan-get-condition volume '"${volume}"' Synced )" == True ]]'
+
+legs="$( __${deploy_tool}_ssh "${NODES[0]}" "
+    sudo lvs --devicesfile kubesan-vg --noheadings --options devices kubesan-vg/${volume}
+" )"
+[[ "${legs}" == *"${volume}_rimage_0"* && "${legs}" == *"${volume}_rimage_1"* ]]
+
+kubectl delete pod test-pod --timeout=30s
+
+ksan-delete-volume test-pvc
@@ -0,0 +1,112 @@
+# SPDX-License-Identifier: Apache-2.0
+#
+# This test verifies that a detached volume can be reverted in place to one of
+# its snapshots, and that the snapshots of the volume survive the revert.
+
+ksan-supported-modes Thin
+
+ksan-create-rwo-volume test-pvc-1 64Mi
+ksan-fill-volume test-pvc-1 64
+ksan-create-snapshot test-pvc-1 test-vs-1
+ksan-fill-volume test-pvc-1 64
+ksan-create-snapshot test-pvc-1 test-vs-2
+ksan-fill-volume test-pvc-1 64
+
+volume="$( kubectl get pvc test-pvc-1 --output jsonpath='{.spec.volumeName}' )"
+content="$( kubectl get vs test-vs-1 --output jsonpath='{.status.boundVolumeSnapshotContentName}' )"
+snapshot="$( kubectl get vsc "${content}" --output jsonpath='{.status.snapshotHandle}' )"
+
+ksan-stage 'Reverting volume 1 to its first snapshot...'
+
+kubectl create -f - <<EOF
+apiVersion: kubesan.gitlab.io/v1alpha1
+kind: VolumeRevert
+metadata:
+  name: test-revert
+  namespace: kubesan-system
+spec:
+  volume: ${volume}
+  snapshot: ${snapshot}
+EOF
+
+# shellcheck disable=SC2016
+ksan-poll 1 300 '[[ "$( ksan-get-condition volumerevert test-revert Available )" == True ]]'
+
+kubectl delete --namespace kubesan-system volumerevert test-revert --timeout=30s
+
+ksan-stage 'Checking that both snapshots are still ready...'
+
+for vs in test-vs-1 test-vs-2; do
+    [[ "$( kubectl get vs "${vs}" --output jsonpath='{.status.readyToUse}' )" == true ]]
+done
+
+ksan-stage 'Creating volumes 2 and 3 from the snapshots of volume 1...'
+
+# make_pvc_from_snapshot pvc_name vs_name
+make_pvc_from_snapshot()
+{
+    kubectl create -f - <<EOF
+    apiVersion: v1
+    kind: PersistentVolumeClaim
+    metadata:
+      name: $1
+    spec:
+      storageClassName: kubesan
+      volumeMode: Block
+      dataSource:
+        apiGroup: snapshot.storage.k8s.io
+        kind: VolumeSnapshot
+        name: $2
+      accessModes:
+        - ReadWriteOnce
+      resources:
+        requests:
+          storage: 64Mi
+EOF
+}
+
+make_pvc_from_snapshot test-pvc-2 test-vs-1
+make_pvc_from_snapshot test-pvc-3 test-vs-2
+
+ksan-wait-for-pvc-to-be-bound 300 test-pvc-2
+ksan-wait-for-pvc-to-be-bound 300 test-pvc-3
+
+ksan-stage 'Validating volume data against both snapshots...'
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  restartPolicy: Never
+  containers:
+    - name: container
+      image: $TEST_IMAGE
+      command:
+        - bash
+        - -c
+        - |
+          set -o errexit -o pipefail -o nounset -o xtrace
+          cmp /var/pvc-1 /var/pvc-2
+          ! cmp /var/pvc-1 /var/pvc-3
+      volumeDevices:
+        - { name: test-pvc-1, devicePath: /var/pvc-1 }
+        - { name: test-pvc-2, devicePath: /var/pvc-2 }
+        - { name: test-pvc-3, devicePath: /var/pvc-3 }
+  volumes:
+    - { name: test-pvc-1, persistentVolumeClaim: { claimName: test-pvc-1 } }
+    - { name: test-pvc-2, persistentVolumeClaim: { claimName: test-pvc-2 } }
+    - { name: test-pvc-3, persistentVolumeClaim: { claimName: test-pvc-3 } }
+EOF
+
+ksan-wait-for-pod-to-succeed 60 test-pod
+kubectl delete pod test-pod --timeout=60s
+
+ksan-delete-volume test-pvc-2 test-pvc-3
+
+ksan-stage 'Deleting snapshots of volume 1...'
+
+kubectl delete vs test-vs-1 test-vs-2 --timeout=60s
+
+ksan-delete-volume test-pvc-1
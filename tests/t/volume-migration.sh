@@ -0,0 +1,95 @@
+# SPDX-License-Identifier: Apache-2.0
+#
+# This test verifies that an attached volume can be migrated to another shared
+# VG while it is being written to, that it holds the last data written once
+# the migration is done, and that its LVs are gone from the source VG.
+
+ksan-supported-modes Thin
+
+ksan-stage 'Creating second shared VG'
+
+__create_ksan_shared_vg second-vg /dev/kubesan-drive-1
+
+ksan-create-rwo-volume test-pvc 64Mi
+
+volume="$( kubectl get pvc test-pvc --output jsonpath='{.spec.volumeName}' )"
+
+ksan-stage 'Writing to volume...'
+
+# Each pass writes new random data and only then records it as the last data
+# written, so the volume must match /tmp/last once the writer has stopped.
+
+kubectl create -f - <<EOF
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  terminationGracePeriodSeconds: 0
+  restartPolicy: Never
+  containers:
+    - name: container
+      image: $TEST_IMAGE
+      command:
+        - bash
+        - -c
+        - |
+          set -o errexit -o pipefail -o nounset
+          while [[ ! -e /tmp/stop ]]; do
+              head -c 64M /dev/urandom > /tmp/next
+              dd if=/tmp/next of=/var/pvc bs=1M oflag=direct conv=fsync status=none
+              mv /tmp/next /tmp/last
+          done
+          touch /tmp/stopped
+          sleep infinity
+      volumeDevices:
+        - { name: test-pvc, devicePath: /var/pvc }
+  volumes:
+    - { name: test-pvc, persistentVolumeClaim: { claimName: test-pvc } }
+EOF
+
+ksan-wait-for-pod-to-start-running 60 test-pod
+ksan-poll 1 60 "kubectl exec test-pod -- test -e /tmp/last"
+
+ksan-stage 'Migrating volume to second VG...'
+
+kubectl create -f - <<EOF
+apiVersion: kubesan.gitlab.io/v1alpha1
+kind: VolumeMigration
+metadata:
+  name: test-migration
+  namespace: kubesan-system
+spec:
+  volume: ${volume}
+  vgName: second-vg
+EOF
+
+# shellcheck disable=SC2016
+ksan-poll 1 300 '[[ "$( ksan-get-condition volumemigration test-migration Available )" == True ]]'
+
+[[ "$( kubectl get --namespace kubesan-system volume "${volume}" --output jsonpath='{.spec.vgName}' )" == second-vg ]]
+
+ksan-pod-is-running test-pod
+
+ksan-stage 'Checking the LVs moved to the second VG...'
+
+target_lvs="$( __${deploy_tool}_ssh "${NODES[0]}" "
+    sudo lvs --devicesfile second-vg --noheadings --options lv_name second-vg
+" )"
+[[ "${target_lvs}" == *"${volume}-thin"* ]]
+
+source_lvs="$( __${deploy_tool}_ssh "${NODES[0]}" "
+    sudo lvs --devicesfile kubesan-vg --noheadings --options lv_name kubesan-vg
+" )"
+[[ "${source_lvs}" != *"${volume}"* ]]
+
+ksan-stage 'Validating volume data...'
+
+kubectl exec test-pod -- touch /tmp/stop
+ksan-poll 1 60 "kubectl exec test-pod -- test -e /tmp/stopped"
+kubectl exec test-pod -- cmp /tmp/last /var/pvc
+
+kubectl delete pod test-pod --timeout=30s
+kubectl delete --namespace kubesan-system volumemigration test-migration --timeout=30s
+
+ksan-delete-volume test-pvc